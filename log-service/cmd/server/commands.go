@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/services"
+	"zxm_ai_admin/log-service/internal/utils"
+)
+
+// commands 子命令，参数为子命令之后的命令行参数，返回进程退出码
+var commands = map[string]func(args []string) int{
+	"rebuild-rollups": runRebuildRollups,
+	"purge":           runPurge,
+}
+
+// runRebuildRollups 根据原始日志重建预聚合统计
+//
+// 用法：log-service rebuild-rollups [-config configs/config.yaml] [-tz Asia/Shanghai] [-start "2006-01-02 15:04:05" -end "2006-01-02 15:04:05"]
+// 未指定时间范围时重建全部数据；不带时区的时间按 -tz（默认为配置的时区）解析，范围向外对齐到自然日（北京时间）
+func runRebuildRollups(args []string) int {
+	fs := flag.NewFlagSet("rebuild-rollups", flag.ContinueOnError)
+	configPath := fs.String("config", "configs/config.yaml", "配置文件路径")
+	startStr := fs.String("start", "", "开始时间，RFC3339 或 2006-01-02 15:04:05")
+	endStr := fs.String("end", "", "结束时间，RFC3339 或 2006-01-02 15:04:05")
+	tz := fs.String("tz", "", "不带时区的时间使用的时区，默认为配置的时区")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := bootstrap(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer database.Close()
+
+	loc, err := utils.LoadTimeZone(*tz)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+
+	var start, end time.Time
+	if *startStr != "" {
+		if start, err = utils.ParseTimeParam(*startStr, loc); err != nil {
+			fmt.Fprintln(os.Stderr, "开始时间"+err.Error())
+			return 2
+		}
+	}
+	if *endStr != "" {
+		if end, err = utils.ParseTimeParam(*endStr, loc); err != nil {
+			fmt.Fprintln(os.Stderr, "结束时间"+err.Error())
+			return 2
+		}
+	}
+
+	result, err := services.NewRollupService().Rebuild(start, end)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "重建预聚合数据失败: %v\n", err)
+		return 1
+	}
+
+	fmt.Printf("重建范围: %s ~ %s\n", result.StartTime.In(loc).Format("2006-01-02 15:04:05"), result.EndTime.In(loc).Format("2006-01-02 15:04:05"))
+	for _, span := range result.Skipped {
+		fmt.Printf("跳过（请求日志曾通过接口删除）: %s ~ %s\n", span.Start.In(loc).Format("2006-01-02 15:04:05"), span.End.In(loc).Format("2006-01-02 15:04:05"))
+	}
+	fmt.Printf("扫描原始日志: %d 条\n", result.ScannedLogs)
+	fmt.Printf("删除旧预聚合: %d 行\n", result.DeletedRows)
+	fmt.Printf("写入预聚合: %d 行\n", result.RollupRows)
+	fmt.Printf("耗时: %s\n", result.ElapsedTime.Round(time.Millisecond))
+	return 0
+}
+
+// runPurge 按配置的保留策略立即执行一次清理（不要求 retention.enabled）
+//
+// 用法：log-service purge [-config configs/config.yaml]
+func runPurge(args []string) int {
+	fs := flag.NewFlagSet("purge", flag.ContinueOnError)
+	configPath := fs.String("config", "configs/config.yaml", "配置文件路径")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	if err := bootstrap(*configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer database.Close()
+
+	run, err := services.NewRetentionService().Run(context.Background(), models.PurgeTriggerCommand)
+	if run != nil {
+		for _, detail := range run.Details {
+			fmt.Printf("%s %s（保留 %d 天）: 删除 %d 条\n", detail.Table, detail.Rule, detail.Days, detail.Deleted)
+		}
+		fmt.Printf("回收空间: %t\n", run.Vacuumed)
+		fmt.Printf("耗时: %s\n", (time.Duration(run.DurationMs) * time.Millisecond).String())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "清理失败: %v\n", err)
+		return 1
+	}
+	return 0
+}
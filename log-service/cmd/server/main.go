@@ -16,41 +16,34 @@ import (
 	"zxm_ai_admin/log-service/internal/handlers"
 	"zxm_ai_admin/log-service/internal/logger"
 	"zxm_ai_admin/log-service/internal/middleware"
+	"zxm_ai_admin/log-service/internal/services"
+	"zxm_ai_admin/log-service/internal/utils"
 
 	"github.com/gin-gonic/gin"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	// 加载配置
 	configPath := "configs/config.yaml"
 	if len(os.Args) > 1 {
 		configPath = os.Args[1]
 	}
 
-	if err := config.Load(configPath); err != nil {
-		// 配置加载失败时使用标准库 log，因为 logger 还未初始化
-		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+	if err := bootstrap(configPath); err != nil {
+		fmt.Fprintln(os.Stderr, err)
 		os.Exit(1)
 	}
+	defer database.Close()
 
 	cfg := config.GetConfig()
 
-	// 初始化日志
-	logLevel := config.ParseLogLevel(cfg.Log.Level)
-	logDir := cfg.Log.Dir
-
-	if err := logger.System.Init(logDir, logLevel); err != nil {
-		fmt.Fprintf(os.Stderr, "系统日志初始化失败: %v\n", err)
-		os.Exit(1)
-	}
-
-	// 初始化数据库
-	if err := database.Init(); err != nil {
-		logger.Error("初始化数据库失败", "error", err)
-		os.Exit(1)
-	}
-	defer database.Close()
-
 	// 设置Gin模式
 	gin.SetMode(cfg.Server.Mode)
 
@@ -63,6 +56,34 @@ func main() {
 	// 注册路由
 	setupRoutes(r)
 
+	// 启动数据保留定时清理
+	ctx, cancel := context.WithCancel(context.Background())
+	retentionDone := make(chan struct{})
+	go func() {
+		defer close(retentionDone)
+		if cfg.Retention.Enabled {
+			services.NewRetentionService().Schedule(ctx)
+		}
+	}()
+
+	// 启动告警规则评估
+	alertDone := make(chan struct{})
+	go func() {
+		defer close(alertDone)
+		if cfg.Alerting.Enabled {
+			services.NewAlertService().Schedule(ctx)
+		}
+	}()
+
+	// 启动 Token 异常检测
+	anomalyDone := make(chan struct{})
+	go func() {
+		defer close(anomalyDone)
+		if cfg.Anomaly.Enabled {
+			services.NewAnomalyService().Schedule(ctx)
+		}
+	}()
+
 	// 创建HTTP服务器
 	srv := &http.Server{
 		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
@@ -85,10 +106,16 @@ func main() {
 
 	logger.Info("正在关闭服务器...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	// 停止定时清理、告警评估与异常检测，等待当前批次完成
+	cancel()
+	<-retentionDone
+	<-alertDone
+	<-anomalyDone
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer shutdownCancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logger.Error("服务器强制关闭", "error", err)
 		os.Exit(1)
 	}
@@ -96,6 +123,60 @@ func main() {
 	logger.Info("服务器已关闭")
 }
 
+// bootstrap 加载配置并初始化日志与数据库（服务与子命令共用）
+func bootstrap(configPath string) error {
+	if err := config.Load(configPath); err != nil {
+		// 配置加载失败时使用标准库输出，因为 logger 还未初始化
+		return fmt.Errorf("加载配置失败: %w", err)
+	}
+
+	cfg := config.GetConfig()
+
+	// 校验默认时区
+	if _, err := utils.LoadTimeZone(cfg.Server.Timezone); err != nil {
+		return fmt.Errorf("默认时区配置错误: %w", err)
+	}
+
+	// 校验数据保留策略
+	if err := services.ValidateRetentionConfig(cfg.Retention); err != nil {
+		return fmt.Errorf("数据保留策略配置错误: %w", err)
+	}
+
+	// 校验冷归档配置
+	if err := services.ValidateArchiveConfig(cfg.Archive); err != nil {
+		return fmt.Errorf("冷归档配置错误: %w", err)
+	}
+
+	// 校验告警配置
+	if err := services.ValidateAlertingConfig(cfg.Alerting); err != nil {
+		return fmt.Errorf("告警配置错误: %w", err)
+	}
+
+	// 校验异常检测配置
+	if err := services.ValidateAnomalyConfig(cfg.Anomaly); err != nil {
+		return fmt.Errorf("异常检测配置错误: %w", err)
+	}
+
+	// 校验客户自助查询配置
+	if err := services.ValidatePortalConfig(cfg.Portal); err != nil {
+		return fmt.Errorf("自助查询配置错误: %w", err)
+	}
+
+	// 初始化日志
+	logLevel := config.ParseLogLevel(cfg.Log.Level)
+	if err := logger.System.Init(cfg.Log.Dir, logLevel); err != nil {
+		return fmt.Errorf("系统日志初始化失败: %w", err)
+	}
+
+	// 初始化数据库
+	if err := database.Init(); err != nil {
+		logger.Error("初始化数据库失败", "error", err)
+		return fmt.Errorf("初始化数据库失败: %w", err)
+	}
+
+	return nil
+}
+
 // setupRoutes 设置路由
 func setupRoutes(r *gin.Engine) {
 	// 健康检查
@@ -108,6 +189,12 @@ func setupRoutes(r *gin.Engine) {
 
 	logHandler := handlers.NewLogHandler()
 	statisticsHandler := handlers.NewStatisticsHandler()
+	dashboardHandler := handlers.NewDashboardHandler()
+	exportHandler := handlers.NewExportHandler()
+	retentionHandler := handlers.NewRetentionHandler()
+	archiveHandler := handlers.NewArchiveHandler()
+	alertHandler := handlers.NewAlertHandler()
+	anomalyHandler := handlers.NewAnomalyHandler()
 
 	// API路由组
 	api := r.Group("/api")
@@ -119,17 +206,66 @@ func setupRoutes(r *gin.Engine) {
 
 		// 查询日志（使用 JWT 认证）
 		api.GET("/request-logs", middleware.AuthMiddleware(), logHandler.ListLogs)
+		api.GET("/request-logs/tail", middleware.StreamAuthMiddleware(), logHandler.TailLogs)
+		api.POST("/stream-tickets", middleware.AuthMiddleware(), logHandler.CreateStreamTicket)
+		api.GET("/request-logs/export", middleware.AuthMiddleware(), exportHandler.ExportLogs)
+		api.GET("/request-logs/usage-export", middleware.AuthMiddleware(), exportHandler.ExportUsage)
+		api.GET("/request-logs/timeline", middleware.AuthMiddleware(), logHandler.GetRequestTimeline)
 		api.GET("/request-logs/:id", middleware.AuthMiddleware(), logHandler.GetLog)
 		// 统计数据（使用 JWT 认证）
 		api.GET("/request-logs/statistics", middleware.AuthMiddleware(), statisticsHandler.GetUserStatistics)
 		api.GET("/request-logs/ranking", middleware.AuthMiddleware(), statisticsHandler.GetAuthorizationRanking)
+		api.GET("/request-logs/stream-statistics", middleware.AuthMiddleware(), statisticsHandler.GetStreamStatistics)
+		api.GET("/request-logs/latency-statistics", middleware.AuthMiddleware(), statisticsHandler.GetLatencyStatistics)
+		api.GET("/request-logs/error-statistics", middleware.AuthMiddleware(), statisticsHandler.GetErrorStatistics)
+		api.GET("/request-logs/dashboard", middleware.AuthMiddleware(), dashboardHandler.GetDashboard)
 
 		// 系统日志查询（使用 JWT 认证）
 		api.GET("/system-logs", middleware.AuthMiddleware(), logHandler.ListSystemLogs)
+		api.GET("/system-logs/tail", middleware.StreamAuthMiddleware(), logHandler.TailSystemLogs)
 		api.GET("/system-logs/:id", middleware.AuthMiddleware(), logHandler.GetSystemLog)
 
 		// 删除日志（token 在 body 中验证）
 		api.POST("/request-logs/delete", logHandler.DeleteRequestLogs)
 		api.POST("/system-logs/delete", logHandler.DeleteSystemLogs)
+
+		// 数据保留清理记录与手动触发（使用 JWT 认证）
+		api.GET("/purge-runs", middleware.AuthMiddleware(), retentionHandler.ListPurgeRuns)
+		api.POST("/purge-runs", middleware.AuthMiddleware(), retentionHandler.RunPurge)
+
+		// 冷归档查询与重新导入（使用 JWT 认证）
+		api.GET("/archives", middleware.AuthMiddleware(), archiveHandler.ListArchives)
+		api.GET("/archives/imports", middleware.AuthMiddleware(), archiveHandler.ListImports)
+		api.POST("/archives/imports", middleware.AuthMiddleware(), archiveHandler.CreateImport)
+		api.DELETE("/archives/imports/:id", middleware.AuthMiddleware(), archiveHandler.DeleteImport)
+
+		// 告警规则、告警事件与告警渠道（使用 JWT 认证）
+		api.GET("/alert-rules", middleware.AuthMiddleware(), alertHandler.ListRules)
+		api.POST("/alert-rules", middleware.AuthMiddleware(), alertHandler.CreateRule)
+		api.PUT("/alert-rules/:id", middleware.AuthMiddleware(), alertHandler.UpdateRule)
+		api.DELETE("/alert-rules/:id", middleware.AuthMiddleware(), alertHandler.DeleteRule)
+		api.GET("/alert-events", middleware.AuthMiddleware(), alertHandler.ListEvents)
+		api.GET("/alert-channels", middleware.AuthMiddleware(), alertHandler.ListChannels)
+		api.POST("/alert-channels/:name/test", middleware.AuthMiddleware(), alertHandler.TestChannel)
+
+		// Token 异常检测结果与手动检测（使用 JWT 认证）
+		api.GET("/token-anomalies", middleware.AuthMiddleware(), anomalyHandler.ListAnomalies)
+		api.POST("/token-anomalies/scan", middleware.AuthMiddleware(), anomalyHandler.Scan)
+	}
+
+	// 客户自助查询（使用客户自己的 sk- Token 认证，按来源 IP 与 Token 限流）
+	if portalCfg := config.GetConfig().Portal; portalCfg.Enabled {
+		portalHandler := handlers.NewPortalHandler()
+		portal := r.Group("/api/portal")
+		portal.Use(
+			middleware.RateLimitMiddleware(portalCfg.IPRateLimit, func(c *gin.Context) string { return c.ClientIP() }),
+			portalHandler.Authenticate,
+			middleware.RateLimitMiddleware(portalCfg.RateLimit, handlers.PortalTokenKey),
+		)
+		{
+			portal.GET("/overview", portalHandler.Overview)
+			portal.GET("/usage", portalHandler.Usage)
+			portal.GET("/requests", portalHandler.Requests)
+		}
 	}
 }
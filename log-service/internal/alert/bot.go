@@ -0,0 +1,111 @@
+// Package alert 告警通知渠道
+// 钉钉 / 飞书群机器人，支持加签
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// botChannel 钉钉 / 飞书群机器人
+type botChannel struct {
+	name   string
+	kind   string // dingtalk / feishu
+	url    string
+	secret string
+}
+
+func (c *botChannel) Name() string { return c.name }
+
+func (c *botChannel) Type() string { return c.kind }
+
+func (c *botChannel) Send(ctx context.Context, msg Message) error {
+	target := c.url
+	var payload map[string]interface{}
+
+	now := time.Now()
+	if c.kind == ChannelDingTalk {
+		// 钉钉加签：timestamp（毫秒）与 sign 作为 URL 参数
+		if c.secret != "" {
+			timestamp := strconv.FormatInt(now.UnixMilli(), 10)
+			sign := hmacSign(c.secret, timestamp+"\n"+c.secret)
+			separator := "?"
+			if strings.Contains(target, "?") {
+				separator = "&"
+			}
+			target += fmt.Sprintf("%stimestamp=%s&sign=%s", separator, timestamp, url.QueryEscape(sign))
+		}
+		payload = map[string]interface{}{
+			"msgtype": "text",
+			"text":    map[string]string{"content": msg.Text()},
+		}
+	} else {
+		// 飞书加签：以 timestamp（秒）+ "\n" + secret 为密钥签名空串，放在请求体中
+		payload = map[string]interface{}{
+			"msg_type": "text",
+			"content":  map[string]string{"text": msg.Text()},
+		}
+		if c.secret != "" {
+			timestamp := strconv.FormatInt(now.Unix(), 10)
+			payload["timestamp"] = timestamp
+			payload["sign"] = hmacSign(timestamp+"\n"+c.secret, "")
+		}
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s 机器人返回状态码 %d", c.kind, resp.StatusCode)
+	}
+
+	// 钉钉返回 errcode，飞书返回 code，非 0 表示发送失败（如签名错误、关键词不匹配）
+	var result struct {
+		ErrCode int    `json:"errcode"`
+		ErrMsg  string `json:"errmsg"`
+		Code    int    `json:"code"`
+		Msg     string `json:"msg"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("%s 机器人发送失败: %d %s", c.kind, result.ErrCode, result.ErrMsg)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("%s 机器人发送失败: %d %s", c.kind, result.Code, result.Msg)
+	}
+	return nil
+}
+
+// hmacSign 计算 HMAC-SHA256 签名并以 base64 编码
+func hmacSign(key, data string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
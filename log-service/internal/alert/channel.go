@@ -0,0 +1,134 @@
+// Package alert 告警通知渠道
+// 支持 webhook、邮件（SMTP）以及钉钉 / 飞书群机器人
+package alert
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+)
+
+// 支持的渠道类型
+const (
+	ChannelWebhook  = "webhook"
+	ChannelEmail    = "email"
+	ChannelDingTalk = "dingtalk"
+	ChannelFeishu   = "feishu"
+)
+
+// 告警消息状态
+const (
+	StatusFiring   = "firing"
+	StatusResolved = "resolved"
+	StatusTest     = "test"
+)
+
+// httpClient 渠道共用的 HTTP 客户端
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// Message 告警消息
+type Message struct {
+	Status    string    `json:"status"` // firing / resolved / test
+	RuleID    uint      `json:"rule_id"`
+	RuleName  string    `json:"rule_name"`
+	Metric    string    `json:"metric"`
+	GroupKey  string    `json:"group_key,omitempty"` // 按维度分组时的分组值（如 Token、模型名）
+	Value     float64   `json:"value"`
+	Threshold float64   `json:"threshold"`
+	Summary   string    `json:"summary"` // 可读的告警描述
+	Time      time.Time `json:"time"`
+}
+
+// Title 消息标题
+func (m Message) Title() string {
+	switch m.Status {
+	case StatusResolved:
+		return "[恢复] " + m.RuleName
+	case StatusTest:
+		return "[测试] " + m.RuleName
+	default:
+		return "[告警] " + m.RuleName
+	}
+}
+
+// Text 纯文本消息正文
+func (m Message) Text() string {
+	var b strings.Builder
+	b.WriteString(m.Title())
+	b.WriteString("\n")
+	b.WriteString(m.Summary)
+	if m.GroupKey != "" {
+		fmt.Fprintf(&b, "\n分组: %s", m.GroupKey)
+	}
+	if m.Status != StatusTest {
+		fmt.Fprintf(&b, "\n当前值: %s，阈值: %s", formatValue(m.Value), formatValue(m.Threshold))
+	}
+	fmt.Fprintf(&b, "\n时间: %s", m.Time.UTC().Format(time.RFC3339))
+	return b.String()
+}
+
+// formatValue 去掉多余的小数位
+func formatValue(v float64) string {
+	return strings.TrimSuffix(strings.TrimRight(fmt.Sprintf("%.2f", v), "0"), ".")
+}
+
+// Channel 告警通知渠道
+type Channel interface {
+	// Name 渠道名称
+	Name() string
+	// Type 渠道类型
+	Type() string
+	// Send 发送告警消息
+	Send(ctx context.Context, msg Message) error
+}
+
+// NewChannel 根据配置创建通知渠道
+func NewChannel(cfg config.AlertChannelConfig) (Channel, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("告警渠道需要配置 name")
+	}
+
+	switch strings.ToLower(cfg.Type) {
+	case ChannelWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("告警渠道 %s 需要配置 url", cfg.Name)
+		}
+		return &webhookChannel{name: cfg.Name, url: cfg.URL, headers: cfg.Headers}, nil
+	case ChannelDingTalk, ChannelFeishu:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("告警渠道 %s 需要配置 url", cfg.Name)
+		}
+		return &botChannel{name: cfg.Name, kind: strings.ToLower(cfg.Type), url: cfg.URL, secret: cfg.Secret}, nil
+	case ChannelEmail:
+		if cfg.SMTP.Host == "" || cfg.SMTP.From == "" || len(cfg.SMTP.To) == 0 {
+			return nil, fmt.Errorf("告警渠道 %s 需要配置 smtp.host、smtp.from 和 smtp.to", cfg.Name)
+		}
+		smtpCfg := cfg.SMTP
+		if smtpCfg.Port == 0 {
+			smtpCfg.Port = 25
+		}
+		return &emailChannel{name: cfg.Name, cfg: smtpCfg}, nil
+	default:
+		return nil, fmt.Errorf("告警渠道 %s 的类型不支持: %s", cfg.Name, cfg.Type)
+	}
+}
+
+// NewChannels 根据配置创建全部通知渠道，按名称索引
+func NewChannels(cfgs []config.AlertChannelConfig) (map[string]Channel, error) {
+	channels := make(map[string]Channel, len(cfgs))
+	for _, cfg := range cfgs {
+		channel, err := NewChannel(cfg)
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := channels[channel.Name()]; ok {
+			return nil, fmt.Errorf("告警渠道名称重复: %s", channel.Name())
+		}
+		channels[channel.Name()] = channel
+	}
+	return channels, nil
+}
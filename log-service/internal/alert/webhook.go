@@ -0,0 +1,51 @@
+// Package alert 告警通知渠道
+// 通用 webhook：以 JSON 格式 POST 告警消息
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// webhookChannel 将告警消息原样以 JSON POST 到指定地址
+type webhookChannel struct {
+	name    string
+	url     string
+	headers map[string]string
+}
+
+func (c *webhookChannel) Name() string { return c.name }
+
+func (c *webhookChannel) Type() string { return ChannelWebhook }
+
+func (c *webhookChannel) Send(ctx context.Context, msg Message) error {
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range c.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook 返回状态码 %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Package archive 冷归档存储
+// S3 兼容对象存储（AWS S3、MinIO 等）
+package archive
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"zxm_ai_admin/log-service/internal/config"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// s3Store 将归档文件保存在 S3 兼容的对象存储中
+type s3Store struct {
+	client *minio.Client
+	bucket string
+}
+
+func newS3Store(cfg config.ArchiveS3Config) (*s3Store, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" {
+		return nil, errors.New("s3 归档存储需要配置 endpoint 和 bucket")
+	}
+
+	client, err := minio.New(cfg.Endpoint, &minio.Options{
+		Creds:  credentials.NewStaticV4(cfg.AccessKey, cfg.SecretKey, ""),
+		Secure: cfg.UseSSL,
+		Region: cfg.Region,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &s3Store{client: client, bucket: cfg.Bucket}, nil
+}
+
+func (s *s3Store) Put(ctx context.Context, key, localPath string) error {
+	_, err := s.client.FPutObject(ctx, s.bucket, key, localPath, minio.PutObjectOptions{
+		ContentType:     "application/x-ndjson",
+		ContentEncoding: "gzip",
+	})
+	return err
+}
+
+func (s *s3Store) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.client.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+}
+
+func (s *s3Store) List(ctx context.Context, prefix string) ([]Object, error) {
+	var objects []Object
+	for info := range s.client.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true}) {
+		if info.Err != nil {
+			return nil, info.Err
+		}
+		objects = append(objects, Object{Key: info.Key, Size: info.Size, LastModified: info.LastModified.UTC()})
+	}
+	return objects, nil
+}
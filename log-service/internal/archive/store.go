@@ -0,0 +1,101 @@
+// Package archive 冷归档存储
+// 数据清理前将待删除的日志按日期分区写入 gzip 压缩的 NDJSON 文件，
+// 存放在本地目录或 S3 兼容的对象存储（AWS S3、MinIO 等），需要排查时可重新导入
+package archive
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+)
+
+// 支持的归档存储
+const (
+	StorageLocal = "local"
+	StorageS3    = "s3"
+)
+
+// dateLayout 分区日期格式（UTC）
+const dateLayout = "2006-01-02"
+
+// fileSuffix 归档文件扩展名
+const fileSuffix = ".ndjson.gz"
+
+// Object 归档存储中的一个文件
+type Object struct {
+	Key          string    `json:"key"`
+	Table        string    `json:"table"`
+	Date         string    `json:"date"` // 分区日期（UTC）
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"last_modified"`
+}
+
+// Store 归档存储
+type Store interface {
+	// Put 将本地文件上传到 key
+	Put(ctx context.Context, key, localPath string) error
+	// Open 读取 key 对应的文件
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// List 列出 prefix 下的全部文件
+	List(ctx context.Context, prefix string) ([]Object, error)
+}
+
+// NewStore 根据配置创建归档存储
+func NewStore(cfg config.ArchiveConfig) (Store, error) {
+	switch strings.ToLower(cfg.Storage) {
+	case "", StorageLocal:
+		return newLocalStore(cfg.Dir), nil
+	case StorageS3:
+		return newS3Store(cfg.S3)
+	default:
+		return nil, fmt.Errorf("不支持的归档存储: %s", cfg.Storage)
+	}
+}
+
+// ObjectKey 生成归档文件的键：{prefix}{table}/dt={date}/{table}-{date}-{batch}.ndjson.gz
+// batch 区分同一分区的多次归档（如清理任务的开始时间）
+func ObjectKey(prefix, table string, date time.Time, batch string) string {
+	day := date.UTC().Format(dateLayout)
+	return prefix + path.Join(table, "dt="+day, fmt.Sprintf("%s-%s-%s%s", table, day, batch, fileSuffix))
+}
+
+// ListRange 列出 table 在 [start, end] 日期范围内（UTC，按天）的归档文件，按日期和键排序
+func ListRange(ctx context.Context, store Store, prefix, table string, start, end time.Time) ([]Object, error) {
+	objects, err := store.List(ctx, prefix+table+"/")
+	if err != nil {
+		return nil, err
+	}
+
+	startDay := start.UTC().Format(dateLayout)
+	endDay := end.UTC().Format(dateLayout)
+
+	var result []Object
+	for _, obj := range objects {
+		rel := strings.TrimPrefix(obj.Key, prefix)
+		parts := strings.Split(rel, "/")
+		if len(parts) != 3 || parts[0] != table || !strings.HasPrefix(parts[1], "dt=") || !strings.HasSuffix(parts[2], fileSuffix) {
+			continue
+		}
+		day := strings.TrimPrefix(parts[1], "dt=")
+		if day < startDay || day > endDay {
+			continue
+		}
+		obj.Table = table
+		obj.Date = day
+		result = append(result, obj)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Date != result[j].Date {
+			return result[i].Date < result[j].Date
+		}
+		return result[i].Key < result[j].Key
+	})
+	return result, nil
+}
@@ -0,0 +1,149 @@
+// Package archive 冷归档存储
+// 归档文件的写入与读取：每行一条 JSON 记录，gzip 压缩
+package archive
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Writer 按日期（UTC）分区写入归档文件
+// 记录先写入本地暂存目录，Commit 时上传到存储；上传成功前不应删除源数据
+type Writer struct {
+	store  Store
+	prefix string
+	table  string
+	batch  string
+	dir    string
+	parts  map[string]*partFile
+}
+
+// partFile 一个日期分区的暂存文件
+type partFile struct {
+	date time.Time
+	path string
+	file *os.File
+	gz   *gzip.Writer
+	enc  *json.Encoder
+	rows int64
+}
+
+// NewWriter 创建归档写入器，batch 用于区分同一分区的多次归档
+func NewWriter(store Store, prefix, table, batch string) (*Writer, error) {
+	dir, err := os.MkdirTemp("", "log-archive-*")
+	if err != nil {
+		return nil, err
+	}
+	return &Writer{
+		store:  store,
+		prefix: prefix,
+		table:  table,
+		batch:  batch,
+		dir:    dir,
+		parts:  make(map[string]*partFile),
+	}, nil
+}
+
+// Write 将记录写入 t 所在日期的分区
+func (w *Writer) Write(t time.Time, record interface{}) error {
+	day := t.UTC().Format(dateLayout)
+	part, ok := w.parts[day]
+	if !ok {
+		path := filepath.Join(w.dir, w.table+"-"+day+fileSuffix)
+		file, err := os.Create(path)
+		if err != nil {
+			return err
+		}
+		gz := gzip.NewWriter(file)
+		part = &partFile{date: t.UTC(), path: path, file: file, gz: gz, enc: json.NewEncoder(gz)}
+		w.parts[day] = part
+	}
+
+	if err := part.enc.Encode(record); err != nil {
+		return err
+	}
+	part.rows++
+	return nil
+}
+
+// Commit 关闭全部分区文件并上传，返回上传的文件键（按日期排序）
+func (w *Writer) Commit(ctx context.Context) ([]string, error) {
+	days := make([]string, 0, len(w.parts))
+	for day := range w.parts {
+		days = append(days, day)
+	}
+	sort.Strings(days)
+
+	keys := make([]string, 0, len(days))
+	for _, day := range days {
+		part := w.parts[day]
+		if err := part.close(); err != nil {
+			return keys, err
+		}
+		key := ObjectKey(w.prefix, w.table, part.date, w.batch)
+		if err := w.store.Put(ctx, key, part.path); err != nil {
+			return keys, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// Close 删除暂存文件，Commit 之后或放弃归档时调用
+func (w *Writer) Close() error {
+	for _, part := range w.parts {
+		part.close()
+	}
+	return os.RemoveAll(w.dir)
+}
+
+func (p *partFile) close() error {
+	if p.file == nil {
+		return nil
+	}
+	err := p.gz.Close()
+	if closeErr := p.file.Close(); err == nil {
+		err = closeErr
+	}
+	p.file = nil
+	return err
+}
+
+// ReadFile 逐行读取归档文件，每行调用一次 fn（行内容为一条 JSON 记录）
+func ReadFile(ctx context.Context, store Store, key string, fn func(line []byte) error) error {
+	rc, err := store.Open(ctx, key)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	gz, err := gzip.NewReader(rc)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	reader := bufio.NewReader(gz)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 && !(len(line) == 1 && line[0] == '\n') {
+			if fnErr := fn(line); fnErr != nil {
+				return fnErr
+			}
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
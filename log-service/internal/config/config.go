@@ -14,23 +14,31 @@ import (
 
 // Config 应用配置
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Log      LogConfig      `yaml:"log"`
-	API      APIConfig      `yaml:"api"`
+	Server    ServerConfig    `yaml:"server"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Log       LogConfig       `yaml:"log"`
+	API       APIConfig       `yaml:"api"`
+	Retention RetentionConfig `yaml:"retention"`
+	Archive   ArchiveConfig   `yaml:"archive"`
+	Alerting  AlertingConfig  `yaml:"alerting"`
+	Anomaly   AnomalyConfig   `yaml:"anomaly"`
+	Portal    PortalConfig    `yaml:"portal"`
 }
 
 // ServerConfig 服务器配置
 type ServerConfig struct {
-	Port int    `yaml:"port"`
-	Mode string `yaml:"mode"`
+	Port     int    `yaml:"port"`
+	Mode     string `yaml:"mode"`
+	Timezone string `yaml:"timezone"` // 默认时区：不带时区的时间参数按该时区解析，统计按该时区分组
 }
 
 // DatabaseConfig 数据库配置
 type DatabaseConfig struct {
-	Path          string `yaml:"path"`
-	MaxOpenConns  int    `yaml:"max_open_conns"`
-	MaxIdleConns  int    `yaml:"max_idle_conns"`
+	Driver       string `yaml:"driver"` // 存储驱动：sqlite（默认）、postgres、clickhouse
+	DSN          string `yaml:"dsn"`    // postgres / clickhouse 连接串
+	Path         string `yaml:"path"`   // sqlite 数据库文件路径
+	MaxOpenConns int    `yaml:"max_open_conns"`
+	MaxIdleConns int    `yaml:"max_idle_conns"`
 }
 
 // LogConfig 日志配置
@@ -42,7 +50,115 @@ type LogConfig struct {
 // APIConfig API 配置
 type APIConfig struct {
 	SystemAuthToken string `yaml:"system_auth_token"` // proxy 写入日志用的系统认证令牌
-	JWTSecret      string `yaml:"jwt_secret"`        // 用于验证 JWT
+	JWTSecret       string `yaml:"jwt_secret"`        // 用于验证 JWT
+}
+
+// RetentionConfig 数据保留策略配置
+type RetentionConfig struct {
+	Enabled        bool                 `yaml:"enabled"`          // 是否启用后台定时清理
+	Interval       string               `yaml:"interval"`         // 清理任务执行间隔，如 1h
+	BatchSize      int                  `yaml:"batch_size"`       // 每批删除的行数，分批删除避免长时间锁表
+	BatchPause     string               `yaml:"batch_pause"`      // 批次之间的暂停时间，让出写锁给日志写入
+	IngestBatchTTL string               `yaml:"ingest_batch_ttl"` // 已接收的上传批次记录保留时长，过期后由清理任务删除
+	RequestLogs    RetentionTableConfig `yaml:"request_logs"`     // 请求日志保留策略（规则按状态码匹配）
+	SystemLogs     RetentionTableConfig `yaml:"system_logs"`      // 系统日志保留策略（规则按日志级别匹配）
+}
+
+// RetentionTableConfig 单张表的保留策略
+type RetentionTableConfig struct {
+	Days  int             `yaml:"days"`  // 未匹配任何规则的记录保留天数，0 表示永久保留
+	Rules []RetentionRule `yaml:"rules"` // 按顺序匹配，记录只使用第一条匹配的规则
+}
+
+// RetentionRule 保留规则
+type RetentionRule struct {
+	Status string `yaml:"status"` // 请求日志：状态码或状态码分类，逗号分隔，如 2xx、4xx,5xx、429
+	Level  string `yaml:"level"`  // 系统日志：日志级别，逗号分隔，如 ERROR,WARN
+	Days   int    `yaml:"days"`   // 保留天数，0 表示永久保留
+}
+
+// ArchiveConfig 冷归档配置
+type ArchiveConfig struct {
+	Enabled   bool            `yaml:"enabled"`    // 清理前是否将待删除的日志归档
+	Storage   string          `yaml:"storage"`    // 归档存储：local（默认）、s3
+	Dir       string          `yaml:"dir"`        // local 存储目录
+	Prefix    string          `yaml:"prefix"`     // 归档文件键前缀
+	ImportTTL string          `yaml:"import_ttl"` // 重新导入的临时表保留时长，过期后由清理任务删除
+	S3        ArchiveS3Config `yaml:"s3"`
+}
+
+// ArchiveS3Config S3 兼容对象存储配置（AWS S3、MinIO 等）
+type ArchiveS3Config struct {
+	Endpoint  string `yaml:"endpoint"` // 如 s3.amazonaws.com、127.0.0.1:9000
+	Region    string `yaml:"region"`
+	Bucket    string `yaml:"bucket"`
+	AccessKey string `yaml:"access_key"`
+	SecretKey string `yaml:"secret_key"`
+	UseSSL    bool   `yaml:"use_ssl"`
+}
+
+// AlertingConfig 告警配置，规则通过接口管理并保存在数据库中
+type AlertingConfig struct {
+	Enabled           bool                 `yaml:"enabled"`             // 是否启用告警规则评估
+	Interval          string               `yaml:"interval"`            // 定时评估间隔，如 1m
+	EvaluateOnIngest  bool                 `yaml:"evaluate_on_ingest"`  // 写入请求日志后是否立即评估
+	IngestMinInterval string               `yaml:"ingest_min_interval"` // 写入触发评估的最小间隔，避免高频写入时反复评估
+	Channels          []AlertChannelConfig `yaml:"channels"`            // 通知渠道，规则按名称引用
+}
+
+// AlertChannelConfig 告警通知渠道配置
+type AlertChannelConfig struct {
+	Name    string            `yaml:"name"`    // 渠道名称，规则中引用
+	Type    string            `yaml:"type"`    // webhook / email / dingtalk / feishu
+	URL     string            `yaml:"url"`     // webhook / 机器人地址
+	Secret  string            `yaml:"secret"`  // 钉钉 / 飞书机器人加签密钥
+	Headers map[string]string `yaml:"headers"` // webhook 附加请求头
+	SMTP    AlertSMTPConfig   `yaml:"smtp"`    // email 使用
+}
+
+// AlertSMTPConfig 邮件告警的 SMTP 配置
+type AlertSMTPConfig struct {
+	Host     string   `yaml:"host"`
+	Port     int      `yaml:"port"` // 465 使用 TLS，其他端口支持时使用 STARTTLS
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+}
+
+// AnomalyConfig Token 异常使用检测配置，阈值为 0 的检测项不启用
+type AnomalyConfig struct {
+	Enabled               bool                     `yaml:"enabled"`                  // 是否启用后台定时检测
+	Interval              string                   `yaml:"interval"`                 // 检测间隔，如 10m
+	Window                string                   `yaml:"window"`                   // 检测窗口，如 1h
+	MaxDistinctIPs        int                      `yaml:"max_distinct_ips"`         // 窗口内不同来源 IP（x_forwarded_for）数上限
+	MaxDistinctUserAgents int                      `yaml:"max_distinct_user_agents"` // 窗口内不同 User-Agent 数上限
+	BaselineDays          int                      `yaml:"baseline_days"`            // 基线统计天数（来自按小时预聚合）
+	SpikeRatio            float64                  `yaml:"spike_ratio"`              // 窗口请求数超过基线的倍数视为突增
+	SpikeMinRequests      int64                    `yaml:"spike_min_requests"`       // 窗口请求数至少达到该值才判定突增
+	NightStartHour        int                      `yaml:"night_start_hour"`         // 夜间起始小时（含，按 server.timezone）
+	NightEndHour          int                      `yaml:"night_end_hour"`           // 夜间结束小时（不含）
+	NightMinRequests      int64                    `yaml:"night_min_requests"`       // 窗口内夜间请求数至少达到该值才判定夜间突发
+	AutoDisable           AnomalyAutoDisableConfig `yaml:"auto_disable"`             // 自动禁用 Token
+}
+
+// AnomalyAutoDisableConfig 异常 Token 自动禁用配置，通过管理服务的系统认证接口禁用
+type AnomalyAutoDisableConfig struct {
+	Enabled     bool   `yaml:"enabled"`
+	MinScore    int    `yaml:"min_score"`    // 评分达到该值时禁用
+	ServerURL   string `yaml:"server_url"`   // 管理服务地址，如 http://localhost:6808
+	SystemToken string `yaml:"system_token"` // 管理服务的系统认证令牌
+}
+
+// PortalConfig 客户自助查询接口配置，客户使用自己的 sk- Token 认证，Token 通过管理服务校验
+type PortalConfig struct {
+	Enabled     bool   `yaml:"enabled"`       // 是否开放自助查询接口
+	ServerURL   string `yaml:"server_url"`    // 管理服务地址，如 http://localhost:6808
+	SystemToken string `yaml:"system_token"`  // 管理服务的系统认证令牌
+	CacheTTL    string `yaml:"cache_ttl"`     // Token 校验结果缓存时长，如 1m
+	RateLimit   int    `yaml:"rate_limit"`    // 每个 Token 每分钟请求数上限
+	IPRateLimit int    `yaml:"ip_rate_limit"` // 每个来源 IP 每分钟请求数上限（含无效 Token 的请求）
+	MaxDays     int    `yaml:"max_days"`      // 用量查询的最大时间跨度（天）
 }
 
 var (
@@ -54,15 +170,15 @@ var (
 func Load(configPath string) error {
 	var err error
 	once.Do(func() {
-		data, err := os.ReadFile(configPath)
-		if err != nil {
-			err = fmt.Errorf("读取配置文件失败: %w", err)
+		data, readErr := os.ReadFile(configPath)
+		if readErr != nil {
+			err = fmt.Errorf("读取配置文件失败: %w", readErr)
 			return
 		}
 
 		cfg = &Config{}
-		if err := yaml.Unmarshal(data, cfg); err != nil {
-			err = fmt.Errorf("解析配置文件失败: %w", err)
+		if parseErr := yaml.Unmarshal(data, cfg); parseErr != nil {
+			err = fmt.Errorf("解析配置文件失败: %w", parseErr)
 			return
 		}
 
@@ -73,6 +189,12 @@ func Load(configPath string) error {
 		if cfg.Server.Mode == "" {
 			cfg.Server.Mode = "release"
 		}
+		if cfg.Server.Timezone == "" {
+			cfg.Server.Timezone = "Asia/Shanghai"
+		}
+		if cfg.Database.Driver == "" {
+			cfg.Database.Driver = "sqlite"
+		}
 		if cfg.Database.Path == "" {
 			cfg.Database.Path = "./data/logs.db"
 		}
@@ -82,6 +204,60 @@ func Load(configPath string) error {
 		if cfg.Database.MaxIdleConns == 0 {
 			cfg.Database.MaxIdleConns = 5
 		}
+		if cfg.Retention.Interval == "" {
+			cfg.Retention.Interval = "1h"
+		}
+		if cfg.Retention.BatchSize == 0 {
+			cfg.Retention.BatchSize = 5000
+		}
+		if cfg.Retention.BatchPause == "" {
+			cfg.Retention.BatchPause = "100ms"
+		}
+		if cfg.Retention.IngestBatchTTL == "" {
+			cfg.Retention.IngestBatchTTL = "168h"
+		}
+		if cfg.Archive.Storage == "" {
+			cfg.Archive.Storage = "local"
+		}
+		if cfg.Archive.Dir == "" {
+			cfg.Archive.Dir = "./data/archive"
+		}
+		if cfg.Archive.ImportTTL == "" {
+			cfg.Archive.ImportTTL = "24h"
+		}
+		if cfg.Alerting.Interval == "" {
+			cfg.Alerting.Interval = "1m"
+		}
+		if cfg.Alerting.IngestMinInterval == "" {
+			cfg.Alerting.IngestMinInterval = "10s"
+		}
+		if cfg.Anomaly.Interval == "" {
+			cfg.Anomaly.Interval = "10m"
+		}
+		if cfg.Anomaly.Window == "" {
+			cfg.Anomaly.Window = "1h"
+		}
+		if cfg.Anomaly.BaselineDays == 0 {
+			cfg.Anomaly.BaselineDays = 7
+		}
+		if cfg.Anomaly.NightStartHour == 0 && cfg.Anomaly.NightEndHour == 0 {
+			cfg.Anomaly.NightEndHour = 6
+		}
+		if cfg.Anomaly.AutoDisable.MinScore == 0 {
+			cfg.Anomaly.AutoDisable.MinScore = 90
+		}
+		if cfg.Portal.CacheTTL == "" {
+			cfg.Portal.CacheTTL = "1m"
+		}
+		if cfg.Portal.RateLimit == 0 {
+			cfg.Portal.RateLimit = 60
+		}
+		if cfg.Portal.IPRateLimit == 0 {
+			cfg.Portal.IPRateLimit = 120
+		}
+		if cfg.Portal.MaxDays == 0 {
+			cfg.Portal.MaxDays = 92
+		}
 		if cfg.Log.Level == "" {
 			cfg.Log.Level = "info"
 		}
@@ -1,19 +1,18 @@
 // Package database 数据库连接管理模块
-// 负责初始化 SQLite 数据库连接，配置连接池，自动迁移表结构
+// 负责按配置的驱动初始化数据库连接（默认 SQLite），配置连接池，自动迁移表结构
 package database
 
 import (
 	"fmt"
-	"os"
-	"path/filepath"
+	"reflect"
 	"time"
 
 	"zxm_ai_admin/log-service/internal/config"
 	"zxm_ai_admin/log-service/internal/logger"
 	"zxm_ai_admin/log-service/internal/models"
 
-	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	gormLogger "gorm.io/gorm/logger"
 )
 
@@ -26,21 +25,31 @@ func Init() error {
 		return fmt.Errorf("配置未初始化")
 	}
 
-	// 确保数据库目录存在
-	dbDir := filepath.Dir(cfg.Database.Path)
-	if err := os.MkdirAll(dbDir, 0755); err != nil {
-		return fmt.Errorf("创建数据库目录失败: %w", err)
+	d, err := newDialect(cfg.Database.Driver)
+	if err != nil {
+		return err
+	}
+	dialect = d
+
+	dialector, err := dialect.Open(cfg.Database)
+	if err != nil {
+		return err
 	}
 
-	var err error
-	DB, err = gorm.Open(sqlite.Open(cfg.Database.Path), &gorm.Config{
+	DB, err = gorm.Open(dialector, &gorm.Config{
 		Logger: gormLogger.Default.LogMode(gormLogger.Info),
+		// created_at / updated_at 统一使用 UTC，与日志时间一致
+		NowFunc: func() time.Time { return time.Now().UTC() },
 	})
 
 	if err != nil {
 		return fmt.Errorf("连接数据库失败: %w", err)
 	}
 
+	if err := dialect.AfterOpen(DB); err != nil {
+		return fmt.Errorf("初始化数据库失败: %w", err)
+	}
+
 	// 配置连接池
 	sqlDB, err := DB.DB()
 	if err != nil {
@@ -56,56 +65,93 @@ func Init() error {
 		return fmt.Errorf("数据库迁移失败: %w", err)
 	}
 
-	logger.Info("数据库连接成功", "path", cfg.Database.Path)
+	logger.Info("数据库连接成功", "driver", dialect.Name(), "path", cfg.Database.Path)
 	return nil
 }
 
 // autoMigrate 自动迁移数据库表
 func autoMigrate() error {
-	if err := DB.AutoMigrate(
+	tables := []interface{}{
 		&models.TokenUsageLog{},
 		&models.SystemLog{},
-	); err != nil {
-		return fmt.Errorf("数据库迁移失败: %w", err)
+		&models.IngestBatch{},
+		&models.UsageRollup{},
+		&models.PurgeRun{},
+		&models.ArchiveImport{},
+		&models.AlertRule{},
+		&models.AlertEvent{},
+		&models.TokenAnomaly{},
 	}
 
-	// 修复 token_usage_logs 表的 request_id UNIQUE 约束
-	if err := fixTokenUsageLogsUniqueConstraint(); err != nil {
-		return fmt.Errorf("修复 token_usage_logs UNIQUE 约束失败: %w", err)
+	for _, table := range tables {
+		stmt := &gorm.Statement{DB: DB}
+		if err := stmt.Parse(table); err != nil {
+			return fmt.Errorf("解析表结构失败: %w", err)
+		}
+
+		tx := DB
+		if options := dialect.TableOptions(stmt.Schema.Table); options != "" {
+			tx = DB.Set("gorm:table_options", options)
+		}
+		if err := tx.AutoMigrate(table); err != nil {
+			return fmt.Errorf("数据库迁移失败: %w", err)
+		}
+	}
+
+	// 各存储后端的额外迁移
+	if err := dialect.AfterMigrate(DB); err != nil {
+		return err
 	}
 
 	return nil
 }
 
-// fixTokenUsageLogsUniqueConstraint 修复 token_usage_logs 表的 request_id UNIQUE 约束
-func fixTokenUsageLogsUniqueConstraint() error {
-	// 检查是否已存在 UNIQUE 索引
-	var count int64
-	if err := DB.Raw(`
-		SELECT COUNT(*) FROM sqlite_master 
-		WHERE type='index' 
-		AND name='idx_token_usage_logs_request_id' 
-		AND sql LIKE '%UNIQUE%'
-	`).Scan(&count).Error; err != nil {
-		return err
+// CreateIgnoreConflicts 在 tx 中批量插入，忽略唯一键冲突的记录，返回实际插入的记录数
+// 不支持 ON CONFLICT 的后端（ClickHouse）直接插入，由表引擎在合并时去重，返回提交的记录数
+func CreateIgnoreConflicts(tx *gorm.DB, value interface{}, columns ...string) (int64, error) {
+	if !dialect.SupportsOnConflict() {
+		result := tx.Create(value)
+		if result.Error != nil {
+			return 0, result.Error
+		}
+		return int64(reflect.Indirect(reflect.ValueOf(value)).Len()), nil
 	}
 
-	// 如果不存在 UNIQUE 索引，则创建
-	if count == 0 {
-		// 删除旧的普通索引
-		if err := DB.Exec(`DROP INDEX IF EXISTS idx_token_usage_logs_request_id`).Error; err != nil {
-			return err
-		}
+	conflictColumns := make([]clause.Column, 0, len(columns))
+	for _, column := range columns {
+		conflictColumns = append(conflictColumns, clause.Column{Name: column})
+	}
 
-		// 创建 UNIQUE 索引（等同于 UNIQUE 约束）
-		if err := DB.Exec(`CREATE UNIQUE INDEX idx_token_usage_logs_request_id ON token_usage_logs(request_id)`).Error; err != nil {
-			return err
-		}
+	result := tx.Clauses(clause.OnConflict{
+		Columns:   conflictColumns,
+		DoNothing: true,
+	}).Create(value)
+
+	return result.RowsAffected, result.Error
+}
 
-		logger.Info("已为 token_usage_logs 表添加 request_id UNIQUE 约束")
+// CreateTableFor 按 model 的表结构创建名为 table 的新表，建表选项沿用 model 的原表
+func CreateTableFor(table string, model interface{}) error {
+	stmt := &gorm.Statement{DB: DB}
+	if err := stmt.Parse(model); err != nil {
+		return fmt.Errorf("解析表结构失败: %w", err)
 	}
 
-	return nil
+	tx := DB
+	if options := dialect.TableOptions(stmt.Schema.Table); options != "" {
+		tx = DB.Set("gorm:table_options", options)
+	}
+	return tx.Table(table).Migrator().CreateTable(model)
+}
+
+// DropTable 删除表（不存在时忽略）
+func DropTable(table string) error {
+	return DB.Migrator().DropTable(table)
+}
+
+// ReclaimSpace 删除数据后回收存储空间
+func ReclaimSpace() error {
+	return dialect.ReclaimSpace(DB)
 }
 
 // Close 关闭数据库连接
@@ -0,0 +1,101 @@
+// Package database 数据库连接管理模块
+// 定义存储后端方言接口，屏蔽 SQLite / PostgreSQL / ClickHouse 的差异
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"zxm_ai_admin/log-service/internal/config"
+
+	"gorm.io/gorm"
+)
+
+// 支持的存储驱动
+const (
+	DriverSQLite     = "sqlite"
+	DriverPostgres   = "postgres"
+	DriverClickHouse = "clickhouse"
+)
+
+// TimeUnit 时间分桶粒度
+type TimeUnit string
+
+const (
+	TimeUnitMinute TimeUnit = "minute"
+	TimeUnitHour   TimeUnit = "hour"
+	TimeUnitDay    TimeUnit = "day"
+)
+
+// Dialect 存储后端方言
+// 业务层通过 gorm 访问数据库，仅在各后端 SQL 不兼容的地方经由方言生成
+type Dialect interface {
+	// Name 驱动名称
+	Name() string
+	// Open 根据配置创建 gorm 方言
+	Open(cfg config.DatabaseConfig) (gorm.Dialector, error)
+	// AfterOpen 连接建立后的初始化（注册回调等）
+	AfterOpen(db *gorm.DB) error
+	// TableOptions 建表附加选项（如 ClickHouse 的表引擎）
+	TableOptions(table string) string
+	// AfterMigrate 自动迁移后的额外迁移（索引、全文检索等）
+	AfterMigrate(db *gorm.DB) error
+	// TimeBucket 将时间列按指定粒度格式化为字符串的 SQL 表达式
+	// offsetSeconds 为相对 UTC 的偏移秒数，day 返回 2006-01-02，hour 返回 2006-01-02 15:00:00，
+	// minute 返回 2006-01-02 15:04:00
+	TimeBucket(column string, unit TimeUnit, offsetSeconds int) string
+	// SupportsOnConflict 是否支持 ON CONFLICT DO NOTHING 去重插入
+	SupportsOnConflict() bool
+	// SupportsFullText 是否有原生全文检索索引（不支持时退化为 LIKE 匹配）
+	SupportsFullText() bool
+	// Contains 大小写不敏感的子串匹配条件，条件中的 ? 由返回的参数填充；
+	// substr 按字面匹配，% _ \ 不是通配符
+	Contains(expr, substr string) (string, interface{})
+	// Least 两个表达式中较小值的 SQL 表达式
+	Least(a, b string) string
+	// Greatest 两个表达式中较大值的 SQL 表达式
+	Greatest(a, b string) string
+	// JSONField 文本列中 JSON 对象某个键的字符串值的 SQL 表达式，表达式中的 ? 由返回的参数填充；
+	// 键不存在时为 NULL（ClickHouse 为空字符串）。key 只包含字母、数字、_ . -
+	JSONField(column, key string) (string, interface{})
+	// ReclaimSpace 批量删除后回收存储空间（SQLite 增量 VACUUM，其他后端由后台任务自动完成）
+	ReclaimSpace(db *gorm.DB) error
+}
+
+var dialect Dialect = sqliteDialect{}
+
+// CurrentDialect 获取当前存储后端方言
+func CurrentDialect() Dialect {
+	return dialect
+}
+
+// newDialect 根据驱动名称创建方言
+func newDialect(driver string) (Dialect, error) {
+	switch strings.ToLower(driver) {
+	case "", DriverSQLite:
+		return sqliteDialect{}, nil
+	case DriverPostgres, "postgresql":
+		return postgresDialect{}, nil
+	case DriverClickHouse:
+		return &clickhouseDialect{}, nil
+	default:
+		return nil, fmt.Errorf("不支持的数据库驱动: %s", driver)
+	}
+}
+
+// likePattern 将子串转义为 LIKE 模式（以 \ 为转义字符），配合 ESCAPE '\' 使用
+func likePattern(substr string) string {
+	return "%" + strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(substr) + "%"
+}
+
+// timeBucketFormat 时间分桶粒度对应的 strftime 风格格式
+func timeBucketFormat(unit TimeUnit) string {
+	switch unit {
+	case TimeUnitMinute:
+		return "%Y-%m-%d %H:%M:00"
+	case TimeUnitHour:
+		return "%Y-%m-%d %H:00:00"
+	default:
+		return "%Y-%m-%d"
+	}
+}
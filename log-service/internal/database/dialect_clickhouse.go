@@ -0,0 +1,133 @@
+// Package database 数据库连接管理模块
+// ClickHouse 列式存储后端，适合大数据量下的统计分析
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+
+	"gorm.io/driver/clickhouse"
+	"gorm.io/gorm"
+)
+
+// clickhouseDialect ClickHouse 方言
+// ClickHouse 没有自增主键和唯一约束：
+//   - 主键 ID 由应用按时间单调递增生成
+//   - 请求日志按 request_id、系统日志按 来源/文件/行首位置 去重，依赖 ReplacingMergeTree 在后台合并时完成
+type clickhouseDialect struct {
+	mu     sync.Mutex
+	lastID uint64
+}
+
+func (*clickhouseDialect) Name() string {
+	return DriverClickHouse
+}
+
+func (*clickhouseDialect) Open(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	if cfg.DSN == "" {
+		return nil, errors.New("clickhouse 驱动需要配置 database.dsn")
+	}
+	return clickhouse.Open(cfg.DSN), nil
+}
+
+func (d *clickhouseDialect) AfterOpen(db *gorm.DB) error {
+	return db.Callback().Create().Before("gorm:create").Register("clickhouse:assign_id", d.assignIDs)
+}
+
+func (*clickhouseDialect) TableOptions(table string) string {
+	switch table {
+	case "token_usage_logs":
+		return "ENGINE=ReplacingMergeTree() PARTITION BY toYYYYMM(time) ORDER BY (toDate(time), request_id)"
+	case "system_logs":
+		// 按 来源/文件/行首位置 去重，没有行首位置的日志以 id 区分，不去重
+		return "ENGINE=ReplacingMergeTree() PARTITION BY toYYYYMM(time) ORDER BY (toDate(time), source, source_file, ifNull(source_offset, -toInt64(id)))"
+	case "usage_rollups":
+		// 没有 upsert，增量以多行写入，查询时按维度汇总
+		return "ENGINE=MergeTree() PARTITION BY toYYYYMM(bucket_start) ORDER BY (granularity, bucket_start, authorization)"
+	default:
+		return "ENGINE=MergeTree() ORDER BY id"
+	}
+}
+
+func (*clickhouseDialect) AfterMigrate(db *gorm.DB) error {
+	return nil
+}
+
+func (*clickhouseDialect) TimeBucket(column string, unit TimeUnit, offsetSeconds int) string {
+	return fmt.Sprintf("formatDateTime(%s + INTERVAL %d SECOND, '%s', 'UTC')", column, offsetSeconds, timeBucketFormat(unit))
+}
+
+func (*clickhouseDialect) SupportsOnConflict() bool {
+	return false
+}
+
+func (*clickhouseDialect) SupportsFullText() bool {
+	return false
+}
+
+func (*clickhouseDialect) Contains(expr, substr string) (string, interface{}) {
+	// ClickHouse 的 LIKE 不支持 ESCAPE 子句，直接按位置查找，不需要转义通配符
+	return "positionCaseInsensitiveUTF8(" + expr + ", ?) > 0", substr
+}
+
+func (*clickhouseDialect) Least(a, b string) string {
+	return "least(" + a + ", " + b + ")"
+}
+
+func (*clickhouseDialect) Greatest(a, b string) string {
+	return "greatest(" + a + ", " + b + ")"
+}
+
+func (*clickhouseDialect) JSONField(column, key string) (string, interface{}) {
+	return "JSONExtractString(" + column + ", ?)", key
+}
+
+func (*clickhouseDialect) ReclaimSpace(db *gorm.DB) error {
+	// 删除以 mutation 方式执行，空间在后台合并分区时回收
+	return nil
+}
+
+// nextID 生成单调递增的 ID（微秒时间戳，同一微秒内递增）
+func (d *clickhouseDialect) nextID() uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	id := uint64(time.Now().UnixMicro())
+	if id <= d.lastID {
+		id = d.lastID + 1
+	}
+	d.lastID = id
+	return id
+}
+
+// assignIDs 插入前为主键为零值的记录分配 ID
+func (d *clickhouseDialect) assignIDs(db *gorm.DB) {
+	if db.Statement.Schema == nil || db.Statement.Schema.PrioritizedPrimaryField == nil {
+		return
+	}
+	field := db.Statement.Schema.PrioritizedPrimaryField
+	if field.DataType != "uint" && field.DataType != "int" {
+		return
+	}
+
+	assign := func(rv reflect.Value) {
+		if _, isZero := field.ValueOf(db.Statement.Context, rv); isZero {
+			db.AddError(field.Set(db.Statement.Context, rv, d.nextID()))
+		}
+	}
+
+	rv := db.Statement.ReflectValue
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < rv.Len(); i++ {
+			assign(reflect.Indirect(rv.Index(i)))
+		}
+	case reflect.Struct:
+		assign(rv)
+	}
+}
@@ -0,0 +1,76 @@
+// Package database 数据库连接管理模块
+// PostgreSQL 存储后端
+package database
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"zxm_ai_admin/log-service/internal/config"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// postgresDialect PostgreSQL 方言
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string {
+	return DriverPostgres
+}
+
+func (postgresDialect) Open(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	if cfg.DSN == "" {
+		return nil, errors.New("postgres 驱动需要配置 database.dsn")
+	}
+	return postgres.Open(cfg.DSN), nil
+}
+
+func (postgresDialect) AfterOpen(db *gorm.DB) error {
+	return nil
+}
+
+func (postgresDialect) TableOptions(table string) string {
+	return ""
+}
+
+func (postgresDialect) AfterMigrate(db *gorm.DB) error {
+	// 旧版本建表时 system_logs.request_id 为列级 UNIQUE 约束，一个请求的多条系统日志会被丢弃
+	return db.Exec(`ALTER TABLE system_logs DROP CONSTRAINT IF EXISTS system_logs_request_id_key`).Error
+}
+
+func (postgresDialect) TimeBucket(column string, unit TimeUnit, offsetSeconds int) string {
+	// strftime 格式转换为 to_char 格式
+	format := strings.NewReplacer("%Y", "YYYY", "%m", "MM", "%d", "DD", "%H", "HH24", "%M", "MI").Replace(timeBucketFormat(unit))
+	return fmt.Sprintf("to_char((%s AT TIME ZONE 'UTC') + interval '%d seconds', '%s')", column, offsetSeconds, format)
+}
+
+func (postgresDialect) SupportsOnConflict() bool {
+	return true
+}
+
+func (postgresDialect) SupportsFullText() bool {
+	return false
+}
+
+func (postgresDialect) Contains(expr, substr string) (string, interface{}) {
+	return expr + ` ILIKE ? ESCAPE '\'`, likePattern(substr)
+}
+
+func (postgresDialect) Least(a, b string) string {
+	return "LEAST(" + a + ", " + b + ")"
+}
+
+func (postgresDialect) Greatest(a, b string) string {
+	return "GREATEST(" + a + ", " + b + ")"
+}
+
+func (postgresDialect) JSONField(column, key string) (string, interface{}) {
+	return "(" + column + "::jsonb ->> ?)", key
+}
+
+func (postgresDialect) ReclaimSpace(db *gorm.DB) error {
+	// 由 autovacuum 回收删除行占用的空间
+	return nil
+}
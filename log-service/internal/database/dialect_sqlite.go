@@ -0,0 +1,281 @@
+// Package database 数据库连接管理模块
+// SQLite 存储后端（默认）
+package database
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/logger"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+)
+
+// sqliteAutoVacuumIncremental PRAGMA auto_vacuum 返回的 INCREMENTAL 模式值
+const sqliteAutoVacuumIncremental = 2
+
+// SQLite 数据迁移版本，记录在 PRAGMA user_version 中，已执行的一次性迁移不再重复执行
+const (
+	sqliteVersionUTCTimes = 1 // 旧版本按本地偏移写入的时间已转换为 UTC
+)
+
+// sqliteMigrateBatchSize 数据迁移每批更新的 id 范围大小，避免长时间持有写锁
+const sqliteMigrateBatchSize = 5000
+
+// sqliteDialect SQLite 方言
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string {
+	return DriverSQLite
+}
+
+func (sqliteDialect) Open(cfg config.DatabaseConfig) (gorm.Dialector, error) {
+	// 确保数据库目录存在
+	dbDir := filepath.Dir(cfg.Path)
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		return nil, fmt.Errorf("创建数据库目录失败: %w", err)
+	}
+	return sqlite.Open(cfg.Path), nil
+}
+
+func (sqliteDialect) AfterOpen(db *gorm.DB) error {
+	return nil
+}
+
+func (sqliteDialect) TableOptions(table string) string {
+	return ""
+}
+
+func (sqliteDialect) AfterMigrate(db *gorm.DB) error {
+	// 修复 token_usage_logs 表的 request_id UNIQUE 约束
+	if err := fixTokenUsageLogsUniqueConstraint(db); err != nil {
+		return fmt.Errorf("修复 token_usage_logs UNIQUE 约束失败: %w", err)
+	}
+
+	// 创建全文检索索引
+	if err := ensureTokenUsageLogsFTS(db); err != nil {
+		return fmt.Errorf("创建 token_usage_logs 全文检索索引失败: %w", err)
+	}
+
+	// 将旧版本按本地时区写入的时间统一转换为 UTC（只执行一次）
+	if err := migrateTimesToUTC(db); err != nil {
+		return err
+	}
+
+	// 开启增量 VACUUM，数据清理后回收空间
+	if err := ensureIncrementalVacuum(db); err != nil {
+		return fmt.Errorf("开启增量 VACUUM 失败: %w", err)
+	}
+
+	return nil
+}
+
+func (sqliteDialect) TimeBucket(column string, unit TimeUnit, offsetSeconds int) string {
+	return fmt.Sprintf("strftime('%s', datetime(%s, '%+d seconds'))", timeBucketFormat(unit), column, offsetSeconds)
+}
+
+func (sqliteDialect) SupportsOnConflict() bool {
+	return true
+}
+
+func (sqliteDialect) SupportsFullText() bool {
+	return true
+}
+
+func (sqliteDialect) Contains(expr, substr string) (string, interface{}) {
+	// SQLite 的 LIKE 对 ASCII 字符默认大小写不敏感，没有默认的转义字符
+	return expr + ` LIKE ? ESCAPE '\'`, likePattern(substr)
+}
+
+func (sqliteDialect) Least(a, b string) string {
+	return "MIN(" + a + ", " + b + ")"
+}
+
+func (sqliteDialect) Greatest(a, b string) string {
+	return "MAX(" + a + ", " + b + ")"
+}
+
+func (sqliteDialect) JSONField(column, key string) (string, interface{}) {
+	// 路径中的键加引号，含 . 的键不会被当作嵌套路径
+	return "json_extract(" + column + ", ?)", `$."` + key + `"`
+}
+
+func (sqliteDialect) ReclaimSpace(db *gorm.DB) error {
+	// 将空闲页归还给文件系统，需要 auto_vacuum = INCREMENTAL（见 ensureIncrementalVacuum）
+	// 该 PRAGMA 每执行一步释放一页，需要读完全部结果行才会释放所有空闲页
+	rows, err := db.Raw("PRAGMA incremental_vacuum").Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+	}
+	return rows.Err()
+}
+
+// fixTokenUsageLogsUniqueConstraint 修复 token_usage_logs 表的 request_id UNIQUE 约束
+func fixTokenUsageLogsUniqueConstraint(db *gorm.DB) error {
+	// 检查是否已存在 UNIQUE 索引
+	var count int64
+	if err := db.Raw(`
+		SELECT COUNT(*) FROM sqlite_master 
+		WHERE type='index' 
+		AND name='idx_token_usage_logs_request_id' 
+		AND sql LIKE '%UNIQUE%'
+	`).Scan(&count).Error; err != nil {
+		return err
+	}
+
+	// 如果不存在 UNIQUE 索引，则创建
+	if count == 0 {
+		// 删除旧的普通索引
+		if err := db.Exec(`DROP INDEX IF EXISTS idx_token_usage_logs_request_id`).Error; err != nil {
+			return err
+		}
+
+		// 创建 UNIQUE 索引（等同于 UNIQUE 约束）
+		if err := db.Exec(`CREATE UNIQUE INDEX idx_token_usage_logs_request_id ON token_usage_logs(request_id)`).Error; err != nil {
+			return err
+		}
+
+		logger.Info("已为 token_usage_logs 表添加 request_id UNIQUE 约束")
+	}
+
+	return nil
+}
+
+// migrateTimesToUTC 将旧版本按本地时区写入的时间统一转换为 UTC，完成后记录到 PRAGMA user_version
+func migrateTimesToUTC(db *gorm.DB) error {
+	var version int
+	if err := db.Raw("PRAGMA user_version").Scan(&version).Error; err != nil {
+		return fmt.Errorf("读取数据迁移版本失败: %w", err)
+	}
+	if version >= sqliteVersionUTCTimes {
+		return nil
+	}
+
+	for _, table := range []string{"token_usage_logs", "system_logs"} {
+		if err := normalizeTimesToUTC(db, table); err != nil {
+			return fmt.Errorf("转换 %s 时间为 UTC 失败: %w", table, err)
+		}
+	}
+
+	if err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", sqliteVersionUTCTimes)).Error; err != nil {
+		return fmt.Errorf("记录数据迁移版本失败: %w", err)
+	}
+	return nil
+}
+
+// normalizeTimesToUTC 将 time 列中带非 UTC 偏移的时间转换为 UTC，按 id 范围分批更新
+// SQLite 以字符串存储时间并按字符串比较，偏移不一致时时间范围查询与分桶都会出错。
+// 驱动写入的格式（2006-01-02 15:04:05.999999999-07:00）保留原有的小数秒，其他格式保留到毫秒
+func normalizeTimesToUTC(db *gorm.DB, table string) error {
+	var bounds struct {
+		MinID int64
+		MaxID int64
+	}
+	if err := db.Raw(`SELECT COALESCE(MIN(id), 0) AS min_id, COALESCE(MAX(id), 0) AS max_id FROM ` + table).
+		Scan(&bounds).Error; err != nil {
+		return err
+	}
+
+	var total int64
+	for from := bounds.MinID; from <= bounds.MaxID && bounds.MaxID > 0; from += sqliteMigrateBatchSize {
+		result := db.Exec(`UPDATE `+table+` SET time = CASE
+			WHEN time GLOB '[0-9][0-9][0-9][0-9]-[0-9][0-9]-[0-9][0-9] [0-9][0-9]:[0-9][0-9]:[0-9][0-9]*[+-][0-9][0-9]:[0-9][0-9]'
+				THEN strftime('%Y-%m-%d %H:%M:%S', time) || substr(time, 20, length(time) - 25) || '+00:00'
+			ELSE strftime('%Y-%m-%d %H:%M:', time) || rtrim(rtrim(strftime('%f', time), '0'), '.') || '+00:00'
+			END
+			WHERE id >= ? AND id < ? AND time NOT LIKE '%+00:00' AND strftime('%f', time) IS NOT NULL`,
+			from, from+sqliteMigrateBatchSize)
+		if result.Error != nil {
+			return result.Error
+		}
+		total += result.RowsAffected
+	}
+	if total > 0 {
+		logger.Info("已将时间统一转换为 UTC", "table", table, "rows", total)
+	}
+	return nil
+}
+
+// ensureTokenUsageLogsFTS 创建 token_usage_logs 的 FTS5 全文检索索引
+// 使用外部内容表（content=token_usage_logs），由触发器在插入、删除、更新索引列时同步；
+// trigram 分词器支持中文和路径的子串匹配
+func ensureTokenUsageLogsFTS(db *gorm.DB) error {
+	var count int64
+	if err := db.Raw(`
+		SELECT COUNT(*) FROM sqlite_master
+		WHERE type='table' AND name='token_usage_logs_fts'
+	`).Scan(&count).Error; err != nil {
+		return err
+	}
+	created := count == 0
+
+	statements := []string{
+		`CREATE VIRTUAL TABLE IF NOT EXISTS token_usage_logs_fts USING fts5(
+			request_body, path, user_agent, msg,
+			content='token_usage_logs', content_rowid='id', tokenize='trigram'
+		)`,
+		`CREATE TRIGGER IF NOT EXISTS token_usage_logs_fts_ai AFTER INSERT ON token_usage_logs BEGIN
+			INSERT INTO token_usage_logs_fts(rowid, request_body, path, user_agent, msg)
+			VALUES (new.id, new.request_body, new.path, new.user_agent, new.msg);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS token_usage_logs_fts_ad AFTER DELETE ON token_usage_logs BEGIN
+			INSERT INTO token_usage_logs_fts(token_usage_logs_fts, rowid, request_body, path, user_agent, msg)
+			VALUES ('delete', old.id, old.request_body, old.path, old.user_agent, old.msg);
+		END`,
+		`CREATE TRIGGER IF NOT EXISTS token_usage_logs_fts_au AFTER UPDATE OF request_body, path, user_agent, msg ON token_usage_logs BEGIN
+			INSERT INTO token_usage_logs_fts(token_usage_logs_fts, rowid, request_body, path, user_agent, msg)
+			VALUES ('delete', old.id, old.request_body, old.path, old.user_agent, old.msg);
+			INSERT INTO token_usage_logs_fts(rowid, request_body, path, user_agent, msg)
+			VALUES (new.id, new.request_body, new.path, new.user_agent, new.msg);
+		END`,
+	}
+	for _, stmt := range statements {
+		if err := db.Exec(stmt).Error; err != nil {
+			return err
+		}
+	}
+
+	// 首次创建时为已有数据建立索引
+	if created {
+		if err := db.Exec(`INSERT INTO token_usage_logs_fts(token_usage_logs_fts) VALUES ('rebuild')`).Error; err != nil {
+			return err
+		}
+		logger.Info("已为 token_usage_logs 表建立全文检索索引")
+	}
+
+	return nil
+}
+
+// ensureIncrementalVacuum 将数据库的 auto_vacuum 模式设置为 INCREMENTAL
+// 已有数据的数据库修改 auto_vacuum 后需要执行一次完整 VACUUM 才会生效，
+// 设置与 VACUUM 必须在同一连接上执行；数据库较大时首次启动会耗时较长
+func ensureIncrementalVacuum(db *gorm.DB) error {
+	var mode int
+	if err := db.Raw("PRAGMA auto_vacuum").Scan(&mode).Error; err != nil {
+		return err
+	}
+	if mode == sqliteAutoVacuumIncremental {
+		return nil
+	}
+
+	logger.Info("正在开启 SQLite 增量 VACUUM（仅首次执行完整 VACUUM）")
+	start := time.Now()
+	err := db.Connection(func(tx *gorm.DB) error {
+		if err := tx.Exec("PRAGMA auto_vacuum = INCREMENTAL").Error; err != nil {
+			return err
+		}
+		return tx.Exec("VACUUM").Error
+	})
+	if err != nil {
+		return err
+	}
+	logger.Info("已开启 SQLite 增量 VACUUM", "elapsed_ms", time.Since(start).Milliseconds())
+	return nil
+}
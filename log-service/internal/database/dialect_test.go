@@ -0,0 +1,273 @@
+package database
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/models"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/clickhouse"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestTimeBucket(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		unit    TimeUnit
+		offset  int
+		want    string
+	}{
+		{sqliteDialect{}, TimeUnitDay, 28800, `strftime('%Y-%m-%d', datetime(time, '+28800 seconds'))`},
+		{sqliteDialect{}, TimeUnitHour, -3600, `strftime('%Y-%m-%d %H:00:00', datetime(time, '-3600 seconds'))`},
+		{postgresDialect{}, TimeUnitDay, 28800, `to_char((time AT TIME ZONE 'UTC') + interval '28800 seconds', 'YYYY-MM-DD')`},
+		{postgresDialect{}, TimeUnitMinute, 0, `to_char((time AT TIME ZONE 'UTC') + interval '0 seconds', 'YYYY-MM-DD HH24:MI:00')`},
+		{&clickhouseDialect{}, TimeUnitHour, 28800, `formatDateTime(time + INTERVAL 28800 SECOND, '%Y-%m-%d %H:00:00', 'UTC')`},
+	}
+	for _, tt := range tests {
+		if got := tt.dialect.TimeBucket("time", tt.unit, tt.offset); got != tt.want {
+			t.Errorf("%s TimeBucket(%s, %d) = %q, want %q", tt.dialect.Name(), tt.unit, tt.offset, got, tt.want)
+		}
+	}
+}
+
+func TestContains(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		wantExpr string
+		wantArg  interface{}
+	}{
+		{sqliteDialect{}, `msg LIKE ? ESCAPE '\'`, `%50\% off\_now%`},
+		{postgresDialect{}, `msg ILIKE ? ESCAPE '\'`, `%50\% off\_now%`},
+		// ClickHouse 的 LIKE 不支持 ESCAPE 子句
+		{&clickhouseDialect{}, `positionCaseInsensitiveUTF8(msg, ?) > 0`, `50% off_now`},
+	}
+	for _, tt := range tests {
+		expr, arg := tt.dialect.Contains("msg", "50% off_now")
+		if expr != tt.wantExpr || arg != tt.wantArg {
+			t.Errorf("%s Contains = %q, %v; want %q, %v", tt.dialect.Name(), expr, arg, tt.wantExpr, tt.wantArg)
+		}
+	}
+}
+
+func TestJSONField(t *testing.T) {
+	tests := []struct {
+		dialect  Dialect
+		wantExpr string
+		wantArg  interface{}
+	}{
+		{sqliteDialect{}, `json_extract(attrs, ?)`, `$."upstream.name"`},
+		{postgresDialect{}, `(attrs::jsonb ->> ?)`, `upstream.name`},
+		{&clickhouseDialect{}, `JSONExtractString(attrs, ?)`, `upstream.name`},
+	}
+	for _, tt := range tests {
+		expr, arg := tt.dialect.JSONField("attrs", "upstream.name")
+		if expr != tt.wantExpr || arg != tt.wantArg {
+			t.Errorf("%s JSONField = %q, %v; want %q, %v", tt.dialect.Name(), expr, arg, tt.wantExpr, tt.wantArg)
+		}
+	}
+}
+
+func TestClickHouseTableOptions(t *testing.T) {
+	d := &clickhouseDialect{}
+	tests := map[string]string{
+		"token_usage_logs": "ENGINE=ReplacingMergeTree() PARTITION BY toYYYYMM(time) ORDER BY (toDate(time), request_id)",
+		"system_logs":      "ENGINE=ReplacingMergeTree() PARTITION BY toYYYYMM(time) ORDER BY (toDate(time), source, source_file, ifNull(source_offset, -toInt64(id)))",
+		"purge_runs":       "ENGINE=MergeTree() ORDER BY id",
+	}
+	for table, want := range tests {
+		if got := d.TableOptions(table); got != want {
+			t.Errorf("TableOptions(%s) = %q, want %q", table, got, want)
+		}
+	}
+	if got := (postgresDialect{}).TableOptions("token_usage_logs"); got != "" {
+		t.Errorf("postgres TableOptions = %q, want empty", got)
+	}
+}
+
+// dryRunDB 以 DryRun 模式打开指定方言，只生成 SQL 不连接数据库
+func dryRunDB(t *testing.T, d Dialect) *gorm.DB {
+	t.Helper()
+	var dialector gorm.Dialector
+	switch d.Name() {
+	case DriverPostgres:
+		dialector = postgres.New(postgres.Config{DSN: "host=127.0.0.1 user=test dbname=test sslmode=disable"})
+	case DriverClickHouse:
+		// ClickHouse 驱动即使在 DryRun 下也会预编译 INSERT，用空驱动代替真实连接
+		dialector = clickhouse.New(clickhouse.Config{DriverName: nopDriverName, DSN: "clickhouse://127.0.0.1:9000/test", SkipInitializeWithVersion: true})
+	default:
+		dialector = sqlite.Open(":memory:")
+	}
+	db, err := gorm.Open(dialector, &gorm.Config{DryRun: true, DisableAutomaticPing: true, SkipDefaultTransaction: true, Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开 %s 失败: %v", d.Name(), err)
+	}
+	if err := d.AfterOpen(db); err != nil {
+		t.Fatalf("%s AfterOpen 失败: %v", d.Name(), err)
+	}
+	return db
+}
+
+// nopDriverName 测试用的空 database/sql 驱动，接受任何语句且不执行
+const nopDriverName = "nop"
+
+func init() {
+	sql.Register(nopDriverName, nopDriver{})
+}
+
+type nopDriver struct{}
+
+func (nopDriver) Open(string) (driver.Conn, error) { return nopConn{}, nil }
+
+type nopConn struct{}
+
+func (nopConn) Prepare(string) (driver.Stmt, error) { return nopStmt{}, nil }
+func (nopConn) Close() error                        { return nil }
+func (nopConn) Begin() (driver.Tx, error)           { return nopTx{}, nil }
+
+type nopStmt struct{}
+
+func (nopStmt) Close() error                               { return nil }
+func (nopStmt) NumInput() int                              { return -1 }
+func (nopStmt) Exec([]driver.Value) (driver.Result, error) { return driver.RowsAffected(1), nil }
+func (nopStmt) Query([]driver.Value) (driver.Rows, error)  { return nil, driver.ErrSkip }
+
+type nopTx struct{}
+
+func (nopTx) Commit() error   { return nil }
+func (nopTx) Rollback() error { return nil }
+
+// useDialect 在测试期间替换当前方言
+func useDialect(t *testing.T, d Dialect) {
+	t.Helper()
+	previous := dialect
+	dialect = d
+	t.Cleanup(func() { dialect = previous })
+}
+
+func TestCreateIgnoreConflictsSQL(t *testing.T) {
+	tests := []struct {
+		dialect Dialect
+		want    string // 生成的 INSERT 语句中应包含的子句
+		notWant string
+	}{
+		{sqliteDialect{}, "ON CONFLICT (`request_id`) DO NOTHING", ""},
+		{postgresDialect{}, `ON CONFLICT ("request_id") DO NOTHING`, ""},
+		// ClickHouse 没有唯一约束，依赖 ReplacingMergeTree 合并去重
+		{&clickhouseDialect{}, "INSERT INTO", "ON CONFLICT"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.dialect.Name(), func(t *testing.T) {
+			useDialect(t, tt.dialect)
+			db := dryRunDB(t, tt.dialect)
+
+			var sql string
+			db.Callback().Create().After("gorm:create").Register("test:capture_sql", func(tx *gorm.DB) {
+				sql = tx.Statement.SQL.String()
+			})
+
+			logs := []models.TokenUsageLog{
+				{RequestID: "a", Time: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)},
+				{RequestID: "b", Time: time.Date(2025, 1, 1, 0, 0, 1, 0, time.UTC)},
+			}
+			if _, err := CreateIgnoreConflicts(db, &logs, "request_id"); err != nil {
+				t.Fatalf("CreateIgnoreConflicts: %v", err)
+			}
+			if !strings.Contains(sql, tt.want) {
+				t.Errorf("SQL 缺少 %q: %s", tt.want, sql)
+			}
+			if tt.notWant != "" && strings.Contains(sql, tt.notWant) {
+				t.Errorf("SQL 不应包含 %q: %s", tt.notWant, sql)
+			}
+		})
+	}
+}
+
+func TestClickHouseAssignsIncreasingIDs(t *testing.T) {
+	d := &clickhouseDialect{}
+	db := dryRunDB(t, d)
+
+	logs := []models.SystemLog{{Msg: "a"}, {Msg: "b"}, {ID: 7, Msg: "c"}}
+	if err := db.Create(&logs).Error; err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if logs[0].ID == 0 || logs[1].ID <= logs[0].ID {
+		t.Errorf("ID 未单调递增: %d, %d", logs[0].ID, logs[1].ID)
+	}
+	if logs[2].ID != 7 {
+		t.Errorf("已有的 ID 被覆盖: %d", logs[2].ID)
+	}
+}
+
+// TestSQLiteExpressions 在内存 SQLite 中执行生成的表达式，验证结果而不只是 SQL 文本
+func TestSQLiteExpressions(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开 SQLite 失败: %v", err)
+	}
+	d := sqliteDialect{}
+	if err := db.Exec(`CREATE TABLE t (time DATETIME, msg TEXT, attrs TEXT)`).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Exec(`INSERT INTO t VALUES (?, ?, ?), (?, ?, ?)`,
+		time.Date(2025, 1, 1, 17, 30, 0, 0, time.UTC), "Disk 50% off_now", `{"upstream.name":"redis-a","retry":"3"}`,
+		time.Date(2025, 1, 1, 1, 0, 0, 0, time.UTC), "disk 50X offYnow", `{"retry":"1"}`,
+	).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	var buckets []string
+	if err := db.Raw(`SELECT ` + d.TimeBucket("time", TimeUnitDay, 8*3600) + ` FROM t ORDER BY time`).Scan(&buckets).Error; err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(buckets, ",") != "2025-01-01,2025-01-02" {
+		t.Errorf("TimeBucket = %v", buckets)
+	}
+
+	var count int64
+	expr, arg := d.Contains("msg", "DISK 50% off_")
+	if err := db.Raw(`SELECT COUNT(*) FROM t WHERE `+expr, arg).Scan(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("Contains 匹配 %d 行，通配符应按字面匹配", count)
+	}
+
+	field, key := d.JSONField("attrs", "upstream.name")
+	if err := db.Raw(`SELECT COUNT(*) FROM t WHERE `+field+` = ?`, key, "redis-a").Scan(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("JSONField 匹配 %d 行", count)
+	}
+}
+
+// TestCreateTableForSystemLogs 按模型创建归档导入临时表时，索引名不能与原表重名
+func TestCreateTableForSystemLogs(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("打开 SQLite 失败: %v", err)
+	}
+	previous := DB
+	DB = db
+	t.Cleanup(func() { DB = previous })
+	useDialect(t, sqliteDialect{})
+
+	if err := db.AutoMigrate(&models.SystemLog{}); err != nil {
+		t.Fatalf("AutoMigrate: %v", err)
+	}
+	if !db.Migrator().HasIndex(&models.SystemLog{}, "idx_system_logs_source_line") {
+		t.Error("原表缺少 idx_system_logs_source_line")
+	}
+	if err := CreateTableFor("archive_system_logs_1", &models.SystemLog{}); err != nil {
+		t.Fatalf("CreateTableFor: %v", err)
+	}
+	if !db.Table("archive_system_logs_1").Migrator().HasIndex("archive_system_logs_1", "idx_archive_system_logs_1_source_line") {
+		t.Error("临时表缺少 idx_archive_system_logs_1_source_line")
+	}
+}
@@ -0,0 +1,194 @@
+// Package handlers 告警接口处理器
+// 处理告警规则管理、告警事件查询与告警渠道测试的 HTTP 请求
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AlertHandler 告警处理器
+type AlertHandler struct {
+	alertService *services.AlertService
+}
+
+// NewAlertHandler 创建告警处理器实例
+func NewAlertHandler() *AlertHandler {
+	return &AlertHandler{
+		alertService: services.NewAlertService(),
+	}
+}
+
+// ListRules 获取告警规则列表
+// @Summary 获取告警规则列表
+// @Description 获取全部告警规则
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.AlertRule
+// @Router /api/alert-rules [get]
+func (h *AlertHandler) ListRules(c *gin.Context) {
+	rules, err := h.alertService.ListRules()
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, rules)
+}
+
+// CreateRule 创建告警规则
+// @Summary 创建告警规则
+// @Description 创建告警规则，规则在下一次评估时生效
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Param request body services.AlertRuleRequest true "告警规则"
+// @Success 200 {object} models.AlertRule
+// @Router /api/alert-rules [post]
+func (h *AlertHandler) CreateRule(c *gin.Context) {
+	var req services.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	rule, err := h.alertService.CreateRule(&req)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+
+	Success(c, rule)
+}
+
+// UpdateRule 更新告警规则
+// @Summary 更新告警规则
+// @Description 整体替换告警规则的配置
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Param id path int true "规则ID"
+// @Param request body services.AlertRuleRequest true "告警规则"
+// @Success 200 {object} models.AlertRule
+// @Router /api/alert-rules/{id} [put]
+func (h *AlertHandler) UpdateRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequest(c, "无效的ID")
+		return
+	}
+
+	var req services.AlertRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	rule, err := h.alertService.UpdateRule(uint(id), &req)
+	if err != nil {
+		alertError(c, err)
+		return
+	}
+
+	Success(c, rule)
+}
+
+// DeleteRule 删除告警规则
+// @Summary 删除告警规则
+// @Description 删除告警规则，未恢复的告警事件标记为已恢复，历史事件保留
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Param id path int true "规则ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/alert-rules/{id} [delete]
+func (h *AlertHandler) DeleteRule(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequest(c, "无效的ID")
+		return
+	}
+
+	if err := h.alertService.DeleteRule(uint(id)); err != nil {
+		alertError(c, err)
+		return
+	}
+
+	Success(c, gin.H{"id": id})
+}
+
+// ListEvents 获取告警事件列表
+// @Summary 获取告警事件列表
+// @Description 查询告警历史，按触发时间倒序
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Param page query int false "页码，默认 1"
+// @Param page_size query int false "每页数量，默认 10，最大 100"
+// @Param rule_id query int false "规则ID"
+// @Param status query string false "状态：firing / resolved"
+// @Success 200 {object} services.ListAlertEventsResponse
+// @Router /api/alert-events [get]
+func (h *AlertHandler) ListEvents(c *gin.Context) {
+	var req services.ListAlertEventsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.alertService.ListEvents(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
+
+// ListChannels 获取告警渠道列表
+// @Summary 获取告警渠道列表
+// @Description 获取配置文件中的告警渠道名称与类型
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Success 200 {array} services.AlertChannelInfo
+// @Router /api/alert-channels [get]
+func (h *AlertHandler) ListChannels(c *gin.Context) {
+	Success(c, h.alertService.ListChannels())
+}
+
+// TestChannel 发送测试通知
+// @Summary 发送测试通知
+// @Description 向指定告警渠道发送一条测试消息，用于验证渠道配置
+// @Tags 告警
+// @Accept json
+// @Produce json
+// @Param name path string true "渠道名称"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/alert-channels/{name}/test [post]
+func (h *AlertHandler) TestChannel(c *gin.Context) {
+	name := c.Param("name")
+	if err := h.alertService.TestChannel(c.Request.Context(), name); err != nil {
+		alertError(c, err)
+		return
+	}
+
+	Success(c, gin.H{"name": name})
+}
+
+// alertError 按错误类型返回告警接口的错误响应
+func alertError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrAlertParam):
+		BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrAlertNotFound):
+		NotFound(c, err.Error())
+	default:
+		InternalServerError(c, err.Error())
+	}
+}
@@ -0,0 +1,77 @@
+// Package handlers Token 异常检测接口处理器
+// 处理异常检测结果查询与手动触发检测的 HTTP 请求
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// AnomalyHandler Token 异常检测处理器
+type AnomalyHandler struct {
+	anomalyService *services.AnomalyService
+}
+
+// NewAnomalyHandler 创建异常检测处理器实例
+func NewAnomalyHandler() *AnomalyHandler {
+	return &AnomalyHandler{
+		anomalyService: services.NewAnomalyService(),
+	}
+}
+
+// ListAnomalies 获取异常检测结果列表
+// @Summary 获取 Token 异常检测结果列表
+// @Description 查询按 Token 检测到的异常使用（来源 IP / User-Agent 过多、请求量突增、夜间突发），按最近命中时间倒序
+// @Tags Token 异常检测
+// @Accept json
+// @Produce json
+// @Param page query int false "页码，默认 1"
+// @Param page_size query int false "每页数量，默认 10，最大 100"
+// @Param authorization query string false "Token（模糊匹配）"
+// @Param kind query string false "异常类型：distinct_ips / distinct_user_agents / volume_spike / night_burst"
+// @Param min_score query int false "最低评分"
+// @Param action query string false "自动处置结果：disabled / failed"
+// @Success 200 {object} services.ListTokenAnomaliesResponse
+// @Router /api/token-anomalies [get]
+func (h *AnomalyHandler) ListAnomalies(c *gin.Context) {
+	var req services.ListTokenAnomaliesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.anomalyService.ListAnomalies(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
+
+// Scan 手动执行一次异常检测
+// @Summary 手动执行 Token 异常检测
+// @Description 按配置的阈值立即检测最近一个窗口，返回命中统计；已有检测在执行时返回 400
+// @Tags Token 异常检测
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.AnomalyScanResult
+// @Router /api/token-anomalies/scan [post]
+func (h *AnomalyHandler) Scan(c *gin.Context) {
+	// 不随请求取消，客户端断开后检测继续执行完成
+	result, err := h.anomalyService.Scan(context.WithoutCancel(c.Request.Context()))
+	if err != nil {
+		if errors.Is(err, services.ErrAnomalyScanRunning) {
+			BadRequest(c, err.Error())
+			return
+		}
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, result)
+}
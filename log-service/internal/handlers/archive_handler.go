@@ -0,0 +1,130 @@
+// Package handlers 冷归档接口处理器
+// 处理归档文件查询与归档重新导入的 HTTP 请求
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ArchiveHandler 冷归档处理器
+type ArchiveHandler struct {
+	archiveService *services.ArchiveService
+}
+
+// NewArchiveHandler 创建冷归档处理器实例
+func NewArchiveHandler() *ArchiveHandler {
+	return &ArchiveHandler{
+		archiveService: services.NewArchiveService(),
+	}
+}
+
+// ListArchives 获取归档文件列表
+// @Summary 获取归档文件列表
+// @Description 列出指定表在日期范围内（UTC 分区日期）的归档文件
+// @Tags 冷归档
+// @Accept json
+// @Produce json
+// @Param table query string true "表名：token_usage_logs / system_logs"
+// @Param start_date query string false "开始日期 YYYY-MM-DD（UTC）"
+// @Param end_date query string false "结束日期 YYYY-MM-DD（UTC）"
+// @Success 200 {array} archive.Object
+// @Router /api/archives [get]
+func (h *ArchiveHandler) ListArchives(c *gin.Context) {
+	var req services.ListArchivesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	objects, err := h.archiveService.ListArchives(c.Request.Context(), &req)
+	if err != nil {
+		archiveError(c, err)
+		return
+	}
+
+	Success(c, objects)
+}
+
+// CreateImport 将归档重新导入临时表
+// @Summary 重新导入归档
+// @Description 将日期范围内的归档文件导入一张临时表，导入完成后可在日志列表接口中通过 archive 参数查询
+// @Tags 冷归档
+// @Accept json
+// @Produce json
+// @Param request body services.CreateImportRequest true "导入范围"
+// @Success 200 {object} models.ArchiveImport
+// @Router /api/archives/imports [post]
+func (h *ArchiveHandler) CreateImport(c *gin.Context) {
+	var req services.CreateImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	record, err := h.archiveService.CreateImport(c.Request.Context(), &req)
+	if err != nil {
+		archiveError(c, err)
+		return
+	}
+
+	Success(c, record)
+}
+
+// ListImports 获取归档导入记录列表
+// @Summary 获取归档导入记录
+// @Description 列出未过期的归档导入及其临时表，按创建时间倒序
+// @Tags 冷归档
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.ArchiveImport
+// @Router /api/archives/imports [get]
+func (h *ArchiveHandler) ListImports(c *gin.Context) {
+	list, err := h.archiveService.ListImports()
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, list)
+}
+
+// DeleteImport 删除归档导入
+// @Summary 删除归档导入
+// @Description 删除导入记录及其临时表（不影响归档文件）
+// @Tags 冷归档
+// @Accept json
+// @Produce json
+// @Param id path int true "导入记录ID"
+// @Success 200 {object} map[string]interface{}
+// @Router /api/archives/imports/{id} [delete]
+func (h *ArchiveHandler) DeleteImport(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		BadRequest(c, "无效的ID")
+		return
+	}
+
+	if err := h.archiveService.DeleteImport(uint(id)); err != nil {
+		archiveError(c, err)
+		return
+	}
+
+	Success(c, gin.H{"id": id})
+}
+
+// archiveError 按错误类型返回归档接口的错误响应
+func archiveError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, services.ErrArchiveParam):
+		BadRequest(c, err.Error())
+	case errors.Is(err, services.ErrArchiveNotFound):
+		NotFound(c, err.Error())
+	default:
+		InternalServerError(c, err.Error())
+	}
+}
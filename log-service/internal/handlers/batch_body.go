@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// ndjsonContentType NDJSON 请求体，每行一个 JSON 对象
+	ndjsonContentType = "application/x-ndjson"
+	// batchChunkSize NDJSON 每读取多少行写入一次数据库
+	batchChunkSize = 500
+	// maxBatchArrayBytes JSON 数组请求体（解压后）的最大字节数，数组需要整体读入内存
+	maxBatchArrayBytes = 64 << 20
+	// maxBatchLineBytes NDJSON 单行最大字节数，超过的行被拒绝
+	maxBatchLineBytes = 8 << 20
+)
+
+// 上传批次标识的请求头
+const (
+	headerBatchID     = "X-Batch-ID"     // 批次 ID，相同 ID 的批次只写入一次
+	headerBatchSource = "X-Batch-Source" // 上传方的日志来源名称
+	headerBatchFile   = "X-Batch-File"   // 来源文件名
+	headerBatchOffset = "X-Batch-Offset" // 批次结束时在来源文件中的字节位置
+)
+
+// batchBodyError 请求体无法读取（格式或压缩错误），整个请求失败
+type batchBodyError struct {
+	status  int
+	message string
+}
+
+func (e *batchBodyError) Error() string {
+	return e.message
+}
+
+// ingestIdempotent 读取批量写入请求体并分块写入，返回合并后的逐行结果。
+// 支持 Content-Encoding: gzip；Content-Type 为 application/x-ndjson 时按行流式读取，否则按 JSON 数组读取。
+// 带 X-Batch-ID 的批次只写入一次：已接收过的批次不再读取请求体，直接返回首次写入的结果；
+// 新批次的数据与批次记录在同一事务中写入，出错时整批回滚。不带 X-Batch-ID 时出错返回的结果包含出错前已写入的分块
+func (h *LogHandler) ingestIdempotent(r *http.Request, kind string, ingest services.IngestChunk) (*services.BatchResult, error) {
+	meta, err := parseBatchMeta(r, kind)
+	if err != nil {
+		return &services.BatchResult{Results: []services.RowResult{}}, err
+	}
+	read := func(handle func(rows []services.BatchRow) error) error {
+		return readBatchBody(r, handle)
+	}
+	if meta == nil {
+		return h.batchService.Ingest(nil, read, ingest)
+	}
+
+	previous, err := h.batchService.Find(meta)
+	if errors.Is(err, services.ErrBatchKindMismatch) {
+		return &services.BatchResult{Results: []services.RowResult{}}, &batchBodyError{status: http.StatusConflict, message: err.Error()}
+	}
+	if err != nil {
+		return &services.BatchResult{Results: []services.RowResult{}}, fmt.Errorf("查询批次记录失败: %w", err)
+	}
+	if previous != nil {
+		logger.Info("批次已接收，返回首次写入的结果", "batch_id", meta.BatchID, "kind", kind, "source", meta.Source, "file", meta.File)
+		return previous, nil
+	}
+
+	return h.batchService.Ingest(meta, read, ingest)
+}
+
+// parseBatchMeta 读取请求头中的批次标识，没有 X-Batch-ID 时返回 nil
+func parseBatchMeta(r *http.Request, kind string) (*services.BatchMeta, error) {
+	batchID := strings.TrimSpace(r.Header.Get(headerBatchID))
+	if batchID == "" {
+		return nil, nil
+	}
+	meta := &services.BatchMeta{
+		BatchID: batchID,
+		Kind:    kind,
+		Source:  r.Header.Get(headerBatchSource),
+		File:    r.Header.Get(headerBatchFile),
+	}
+	if value := r.Header.Get(headerBatchOffset); value != "" {
+		offset, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return nil, &batchBodyError{status: http.StatusBadRequest, message: "参数错误: " + headerBatchOffset + " 格式错误"}
+		}
+		meta.Offset = offset
+	}
+	if err := meta.Validate(); err != nil {
+		return nil, &batchBodyError{status: http.StatusBadRequest, message: "参数错误: " + err.Error()}
+	}
+	return meta, nil
+}
+
+// batchError 批量写入失败的响应：请求体错误返回对应的 4xx，数据库错误返回 500
+func batchError(c *gin.Context, err error, result *services.BatchResult) {
+	status := http.StatusInternalServerError
+	var bodyErr *batchBodyError
+	if errors.As(err, &bodyErr) {
+		status = bodyErr.status
+	}
+	c.JSON(status, gin.H{
+		"code":    status,
+		"message": err.Error(),
+		"data":    batchResponse(result), // NDJSON 出错前已写入的分块
+	})
+}
+
+// batchResponse 批量写入响应，count 为插入条数，兼容旧版调用方
+func batchResponse(result *services.BatchResult) gin.H {
+	response := gin.H{
+		"count":     result.Inserted,
+		"received":  result.Received,
+		"inserted":  result.Inserted,
+		"duplicate": result.Duplicate,
+		"rejected":  result.Rejected,
+		"results":   result.Results,
+	}
+	if result.BatchID != "" {
+		response["batch_id"] = result.BatchID
+		response["replayed"] = result.Replayed
+	}
+	return response
+}
+
+// readBatchBody 解压并解析请求体，按块回调 handle
+func readBatchBody(r *http.Request, handle func(rows []services.BatchRow) error) error {
+	body, err := decodeContentEncoding(r)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == ndjsonContentType {
+		return readNDJSON(body, handle)
+	}
+	return readJSONArray(body, handle)
+}
+
+// decodeContentEncoding 按 Content-Encoding 解压请求体
+func decodeContentEncoding(r *http.Request) (io.ReadCloser, error) {
+	switch encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding"))); encoding {
+	case "", "identity":
+		return r.Body, nil
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, &batchBodyError{status: http.StatusBadRequest, message: "gzip 解压失败: " + err.Error()}
+		}
+		return gz, nil
+	default:
+		return nil, &batchBodyError{status: http.StatusUnsupportedMediaType, message: "不支持的 Content-Encoding: " + encoding}
+	}
+}
+
+// readJSONArray 读取 JSON 数组。数组整体解析成功后才开始写入，语法错误时整个请求失败；
+// 单个元素的字段类型错误等只拒绝该行
+func readJSONArray(body io.Reader, handle func(rows []services.BatchRow) error) error {
+	limited := &io.LimitedReader{R: body, N: maxBatchArrayBytes + 1}
+	var items []json.RawMessage
+	if err := json.NewDecoder(limited).Decode(&items); err != nil {
+		if limited.N <= 0 {
+			return &batchBodyError{status: http.StatusRequestEntityTooLarge, message: fmt.Sprintf("请求体超过 %d MB，请使用 NDJSON 分块上传", maxBatchArrayBytes>>20)}
+		}
+		return &batchBodyError{status: http.StatusBadRequest, message: "参数错误: " + err.Error()}
+	}
+
+	rows := make([]services.BatchRow, len(items))
+	for i, item := range items {
+		rows[i] = services.BatchRow{Index: i, Data: item}
+	}
+	for start := 0; start < len(rows); start += batchChunkSize {
+		if err := handle(rows[start:min(start+batchChunkSize, len(rows))]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readNDJSON 按行流式读取 NDJSON，每 batchChunkSize 行写入一次，内存中只保留一个分块。
+// 空行跳过但计入行号；无法解析或过长的行只拒绝该行
+func readNDJSON(body io.Reader, handle func(rows []services.BatchRow) error) error {
+	reader := bufio.NewReaderSize(body, 64*1024)
+	rows := make([]services.BatchRow, 0, batchChunkSize)
+	index := 0
+	for {
+		line, tooLong, err := readBatchLine(reader)
+		if err != nil && err != io.EOF {
+			return &batchBodyError{status: http.StatusBadRequest, message: "读取请求体失败: " + err.Error()}
+		}
+		if tooLong {
+			rows = append(rows, services.BatchRow{Index: index, Err: fmt.Errorf("行长度超过 %d MB", maxBatchLineBytes>>20)})
+			index++
+		} else if len(bytes.TrimSpace(line)) > 0 {
+			rows = append(rows, services.BatchRow{Index: index, Data: json.RawMessage(line)})
+			index++
+		} else if err == nil {
+			index++
+		}
+
+		if len(rows) >= batchChunkSize || (err == io.EOF && len(rows) > 0) {
+			if err := handle(rows); err != nil {
+				return err
+			}
+			rows = make([]services.BatchRow, 0, batchChunkSize)
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
+
+// readBatchLine 读取一行，超过 maxBatchLineBytes 的部分被丢弃并返回 tooLong=true
+func readBatchLine(reader *bufio.Reader) (line []byte, tooLong bool, err error) {
+	for {
+		chunk, err := reader.ReadSlice('\n')
+		if !tooLong {
+			if len(line)+len(chunk) > maxBatchLineBytes {
+				tooLong, line = true, nil
+			} else {
+				line = append(line, chunk...)
+			}
+		}
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		return line, tooLong, err
+	}
+}
@@ -0,0 +1,49 @@
+// Package handlers 全局运营看板接口处理器
+package handlers
+
+import (
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DashboardHandler 全局运营看板处理器
+type DashboardHandler struct {
+	dashboardService *services.DashboardService
+}
+
+// NewDashboardHandler 创建全局运营看板处理器实例
+func NewDashboardHandler() *DashboardHandler {
+	return &DashboardHandler{
+		dashboardService: services.NewDashboardService(),
+	}
+}
+
+// GetDashboard 获取全局运营看板
+// @Summary 获取全局运营看板
+// @Description 跨所有 token 的汇总指标、时间序列、token / IP 排行，并与上一个等长时间范围对比
+// @Tags 统计
+// @Accept json
+// @Produce json
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Param interval query string false "时间序列粒度：minute / hour / day"
+// @Param top query int false "排行榜条数，默认 10，最大 100"
+// @Success 200 {object} services.DashboardResponse
+// @Router /api/request-logs/dashboard [get]
+func (h *DashboardHandler) GetDashboard(c *gin.Context) {
+	var req services.GetDashboardRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.dashboardService.GetDashboard(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
@@ -0,0 +1,110 @@
+// Package handlers 导出接口处理器
+// 以流式响应下载请求日志明细与用量汇总（CSV / NDJSON / Parquet）
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ExportHandler 导出处理器
+type ExportHandler struct {
+	exportService *services.ExportService
+}
+
+// NewExportHandler 创建导出处理器实例
+func NewExportHandler() *ExportHandler {
+	return &ExportHandler{
+		exportService: services.NewExportService(),
+	}
+}
+
+// ExportLogs 导出请求日志明细
+// @Summary 导出请求日志
+// @Description 流式导出请求日志明细（不含请求体与请求/响应头），过滤条件与列表接口相同，按时间升序
+// @Tags 请求日志
+// @Produce text/csv,application/x-ndjson,application/vnd.apache.parquet
+// @Param format query string false "导出格式：csv（默认）/ ndjson / parquet"
+// @Param request_id query string false "按 request_id 过滤"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区；导出的时间按该时区输出"
+// @Param status query string false "状态码（单个如 200 或多个逗号分隔如 200,401,404）"
+// @Param method query string false "HTTP 方法"
+// @Param authorization query string false "Authorization"
+// @Param order_no query string false "订单号"
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
+// @Param q query string false "全文检索"
+// @Param archive query int false "归档导入 ID，指定时导出重新导入的归档临时表"
+// @Router /api/request-logs/export [get]
+func (h *ExportHandler) ExportLogs(c *gin.Context) {
+	var req services.ExportLogsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	export, err := h.exportService.ExportLogs(&req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	streamExport(c, export)
+}
+
+// ExportUsage 导出按天汇总的用量
+// @Summary 导出用量汇总
+// @Description 流式导出按天（tz 时区）、authorization、订单号、模型汇总的请求数、错误数、流量与 token 用量，过滤条件与列表接口相同
+// @Tags 统计
+// @Produce text/csv,application/x-ndjson,application/vnd.apache.parquet
+// @Param format query string false "导出格式：csv（默认）/ ndjson / parquet"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区；按该时区的自然日汇总"
+// @Param status query string false "状态码（单个如 200 或多个逗号分隔如 200,401,404）"
+// @Param method query string false "HTTP 方法"
+// @Param authorization query string false "Authorization"
+// @Param order_no query string false "订单号"
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
+// @Param q query string false "全文检索"
+// @Router /api/request-logs/usage-export [get]
+func (h *ExportHandler) ExportUsage(c *gin.Context) {
+	var req services.ExportLogsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	export, err := h.exportService.ExportUsage(&req)
+	if err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+
+	streamExport(c, export)
+}
+
+// streamExport 写出下载响应头并流式写出导出内容
+// 响应头写出后无法再返回错误状态码，中途失败只记录日志，客户端会收到被截断的文件
+func streamExport(c *gin.Context, export *services.Export) {
+	c.Header("Content-Type", export.ContentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, export.Filename))
+	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+	c.Status(http.StatusOK)
+
+	count, err := export.Stream(c.Request.Context(), c.Writer)
+	if err != nil {
+		logger.Error("导出失败", "error", err, "filename", export.Filename, "rows", count)
+		return
+	}
+	c.Writer.Flush()
+	logger.Info("导出完成", "filename", export.Filename, "rows", count)
+}
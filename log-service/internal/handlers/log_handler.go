@@ -3,9 +3,11 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
 	"zxm_ai_admin/log-service/internal/services"
 
 	"github.com/gin-gonic/gin"
@@ -14,6 +16,8 @@ import (
 type LogHandler struct {
 	logService       *services.LogService
 	systemLogService *services.SystemLogService
+	batchService     *services.IngestBatchService
+	timelineService  *services.TimelineService
 }
 
 // NewLogHandler 创建日志处理器实例
@@ -21,6 +25,8 @@ func NewLogHandler() *LogHandler {
 	return &LogHandler{
 		logService:       services.NewLogService(),
 		systemLogService: services.NewSystemLogService(),
+		batchService:     services.NewIngestBatchService(),
+		timelineService:  services.NewTimelineService(),
 	}
 }
 
@@ -101,9 +107,14 @@ func (h *LogHandler) CreateRequestLog(c *gin.Context) {
 // @Param request_id query string false "按 request_id 查询"
 // @Param start_time query string false "开始时间"
 // @Param end_time query string false "结束时间"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Param archive query int false "归档导入 ID，指定时查询重新导入的归档临时表"
 // @Param status query string false "状态码（单个如 200 或多个逗号分隔如 200,401,404）"
 // @Param method query string false "HTTP 方法"
 // @Param authorization query string false "Authorization"
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
+// @Param q query string false "全文检索，支持 body:/path:/ua:/msg: 字段前缀和 AND/OR/NOT"
 // @Success 200 {object} services.ListLogsResponse
 // @Router /api/request-logs [get]
 func (h *LogHandler) ListLogs(c *gin.Context) {
@@ -113,8 +124,20 @@ func (h *LogHandler) ListLogs(c *gin.Context) {
 		return
 	}
 
+	// 检索语法错误属于参数错误
+	if req.Q != "" {
+		if _, err := services.ParseSearchQuery(req.Q); err != nil {
+			BadRequest(c, "参数错误: "+err.Error())
+			return
+		}
+	}
+
 	response, err := h.logService.ListLogs(&req)
 	if err != nil {
+		if errors.Is(err, services.ErrArchiveNotFound) {
+			NotFound(c, err.Error())
+			return
+		}
 		InternalServerError(c, err.Error())
 		return
 	}
@@ -148,65 +171,109 @@ func (h *LogHandler) GetLog(c *gin.Context) {
 	Success(c, log)
 }
 
-// BatchCreateRequestLogs 批量创建请求日志记录（proxy 调用）
-// @Summary 批量创建请求日志记录
-// @Description 接收多条请求日志记录的数组，批量保存到数据库
+// GetRequestTimeline 获取请求时间线（admin 调用）
+// @Summary 获取请求时间线
+// @Description 将请求日志与同一 request_id 的系统日志（token 不在缓存中、上游调用失败等）合并为按时间排序的事件。
+// @Description 按 request_id 查询单个请求，或按 authorization 查询时间窗口（不超过 24 小时）内的请求，最多 100 个
 // @Tags 请求日志
 // @Accept json
 // @Produce json
-// @Param request body []services.CreateLogRequest true "日志记录数组"
-// @Success 200 {object} map[string]interface{}
-// @Router /api/request-logs/batch [post]
-func (h *LogHandler) BatchCreateRequestLogs(c *gin.Context) {
-	var reqs []services.CreateLogRequest
-	if err := c.ShouldBindJSON(&reqs); err != nil {
-		logger.Error("批量创建请求日志失败：参数绑定错误", "error", err)
+// @Param request_id query string false "request_id，与 authorization 二选一"
+// @Param authorization query string false "Token（模糊匹配），需同时指定 start_time 和 end_time"
+// @Param start_time query string false "开始时间"
+// @Param end_time query string false "结束时间"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Success 200 {object} services.RequestTimelineResponse
+// @Router /api/request-logs/timeline [get]
+func (h *LogHandler) GetRequestTimeline(c *gin.Context) {
+	var req services.RequestTimelineRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
 		BadRequest(c, "参数错误: "+err.Error())
 		return
 	}
 
-	logger.Info("批量创建请求日志", "received_count", len(reqs))
+	response, err := h.timelineService.GetRequestTimeline(&req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrTimelineParam):
+			BadRequest(c, err.Error())
+		case errors.Is(err, services.ErrTimelineNotFound):
+			NotFound(c, err.Error())
+		default:
+			InternalServerError(c, err.Error())
+		}
+		return
+	}
 
-	count := h.logService.BatchCreateLogs(reqs)
+	Success(c, response)
+}
 
-	logger.Info("批量创建请求日志完成", "received_count", len(reqs), "inserted_count", count)
+// BatchCreateRequestLogs 批量创建请求日志记录（proxy、log-syncer 调用）
+// @Summary 批量创建请求日志记录
+// @Description 接收多条请求日志记录，逐行校验后批量保存到数据库，返回每行的结果（inserted/duplicate/rejected）。
+// @Description 请求体为 JSON 数组，或 Content-Type: application/x-ndjson 时每行一条记录（流式分块写入）；支持 Content-Encoding: gzip
+// @Tags 请求日志
+// @Accept json,application/x-ndjson
+// @Produce json
+// @Param X-Batch-ID header string false "批次 ID，相同 ID 的批次只写入一次，重复上传返回首次写入的结果"
+// @Param X-Batch-Source header string false "上传方的日志来源名称"
+// @Param X-Batch-File header string false "来源文件名"
+// @Param X-Batch-Offset header int false "批次结束时在来源文件中的字节位置"
+// @Param request body []services.CreateLogRequest true "日志记录数组"
+// @Success 200 {object} services.BatchResult
+// @Router /api/request-logs/batch [post]
+func (h *LogHandler) BatchCreateRequestLogs(c *gin.Context) {
+	result, err := h.ingestIdempotent(c.Request, models.IngestKindRequest, h.logService.IngestRequestLogs)
+	if err != nil {
+		logger.Error("批量创建请求日志失败", "error", err, "content_type", c.ContentType(), "content_encoding", c.GetHeader("Content-Encoding"))
+		batchError(c, err, result)
+		return
+	}
 
-	Success(c, gin.H{
-		"count": count,
-	})
+	logger.Info("批量创建请求日志完成",
+		"batch_id", result.BatchID,
+		"replayed", result.Replayed,
+		"received_count", result.Received,
+		"inserted_count", result.Inserted,
+		"duplicate_count", result.Duplicate,
+		"rejected_count", result.Rejected,
+	)
+
+	Success(c, batchResponse(result))
 }
 
-// BatchCreateSystemLogs 批量创建系统日志记录（proxy 调用）
+// BatchCreateSystemLogs 批量创建系统日志记录（proxy、log-syncer 调用）
 // @Summary 批量创建系统日志记录
-// @Description 接收多条系统日志记录的数组，批量保存到数据库
+// @Description 接收多条系统日志记录，逐行校验后批量保存到数据库，返回每行的结果（inserted/duplicate/rejected）。
+// @Description 请求体为 JSON 数组，或 Content-Type: application/x-ndjson 时每行一条记录（流式分块写入）；支持 Content-Encoding: gzip
 // @Tags 系统日志
-// @Accept json
+// @Accept json,application/x-ndjson
 // @Produce json
+// @Param X-Batch-ID header string false "批次 ID，相同 ID 的批次只写入一次，重复上传返回首次写入的结果"
+// @Param X-Batch-Source header string false "上传方的日志来源名称"
+// @Param X-Batch-File header string false "来源文件名"
+// @Param X-Batch-Offset header int false "批次结束时在来源文件中的字节位置"
 // @Param request body []services.CreateSystemLogRequest true "系统日志记录数组"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} services.BatchResult
 // @Router /api/system-logs/batch [post]
 func (h *LogHandler) BatchCreateSystemLogs(c *gin.Context) {
-	var reqs []services.CreateSystemLogRequest
-	if err := c.ShouldBindJSON(&reqs); err != nil {
-		logger.Error("批量创建系统日志失败：参数绑定错误", "error", err)
-		BadRequest(c, "参数错误: "+err.Error())
-		return
-	}
-
-	logger.Info("批量创建系统日志", "received_count", len(reqs))
-
-	count, err := h.systemLogService.BatchCreateSystemLogs(reqs)
+	result, err := h.ingestIdempotent(c.Request, models.IngestKindSystem, h.systemLogService.IngestSystemLogs)
 	if err != nil {
-		logger.Error("批量创建系统日志失败", "error", err, "received_count", len(reqs))
-		InternalServerError(c, err.Error())
+		logger.Error("批量创建系统日志失败", "error", err, "content_type", c.ContentType(), "content_encoding", c.GetHeader("Content-Encoding"))
+		batchError(c, err, result)
 		return
 	}
 
-	logger.Info("批量创建系统日志完成", "received_count", len(reqs), "inserted_count", count)
+	logger.Info("批量创建系统日志完成",
+		"batch_id", result.BatchID,
+		"replayed", result.Replayed,
+		"received_count", result.Received,
+		"inserted_count", result.Inserted,
+		"duplicate_count", result.Duplicate,
+		"rejected_count", result.Rejected,
+	)
 
-	Success(c, gin.H{
-		"count": count,
-	})
+	Success(c, batchResponse(result))
 }
 
 // ListSystemLogs 获取系统日志列表（admin 调用）
@@ -218,8 +285,14 @@ func (h *LogHandler) BatchCreateSystemLogs(c *gin.Context) {
 // @Param page query int false "页码" default(1)
 // @Param page_size query int false "每页数量" default(10)
 // @Param level query string false "日志级别"
+// @Param msg query string false "消息内容子串"
+// @Param attr query []string false "属性过滤，key=value 完全匹配或 key~value 子串匹配，可重复，需同时满足" collectionFormat(multi)
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
 // @Param start_time query string false "开始时间"
 // @Param end_time query string false "结束时间"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Param archive query int false "归档导入 ID，指定时查询重新导入的归档临时表"
 // @Success 200 {object} services.ListSystemLogsResponse
 // @Router /api/system-logs [get]
 func (h *LogHandler) ListSystemLogs(c *gin.Context) {
@@ -231,6 +304,14 @@ func (h *LogHandler) ListSystemLogs(c *gin.Context) {
 
 	response, err := h.systemLogService.ListSystemLogs(&req)
 	if err != nil {
+		if errors.Is(err, services.ErrArchiveNotFound) {
+			NotFound(c, err.Error())
+			return
+		}
+		if errors.Is(err, services.ErrSystemLogParam) {
+			BadRequest(c, err.Error())
+			return
+		}
 		InternalServerError(c, err.Error())
 		return
 	}
@@ -0,0 +1,147 @@
+// Package handlers 客户自助查询接口处理器
+// 处理客户使用自己的 sk- Token 查询用量的 HTTP 请求，只返回该 Token 的数据
+package handlers
+
+import (
+	"errors"
+	"strings"
+
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// portalTokenKey 上下文中保存已校验 Token 的 key
+const portalTokenKey = "portal_token"
+
+// PortalHandler 客户自助查询处理器
+type PortalHandler struct {
+	portalService *services.PortalService
+}
+
+// NewPortalHandler 创建客户自助查询处理器实例
+func NewPortalHandler() *PortalHandler {
+	return &PortalHandler{
+		portalService: services.NewPortalService(),
+	}
+}
+
+// Authenticate 客户 Token 认证中间件，支持 "Bearer sk-xxx" 或直接 "sk-xxx"
+func (h *PortalHandler) Authenticate(c *gin.Context) {
+	token := strings.TrimSpace(c.GetHeader("Authorization"))
+	if parts := strings.SplitN(token, " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		token = strings.TrimSpace(parts[1])
+	}
+	if token == "" {
+		Unauthorized(c, "未提供认证token")
+		c.Abort()
+		return
+	}
+
+	info, err := h.portalService.Authenticate(c.Request.Context(), token)
+	if err != nil {
+		if errors.Is(err, services.ErrPortalUnauthorized) {
+			Unauthorized(c, err.Error())
+		} else {
+			logger.Error("自助查询校验 Token 失败", "error", err)
+			InternalServerError(c, "校验 Token 失败")
+		}
+		c.Abort()
+		return
+	}
+
+	c.Set(portalTokenKey, info)
+	c.Next()
+}
+
+// PortalTokenKey 限流使用的 key：已校验的 Token
+func PortalTokenKey(c *gin.Context) string {
+	if info, ok := c.Get(portalTokenKey); ok {
+		return info.(*services.PortalToken).Token
+	}
+	return ""
+}
+
+// Overview 获取 Token 概览
+// @Summary 客户自助查询：Token 概览
+// @Description 使用客户自己的 sk- Token 认证，返回状态、过期时间、请求次数限额、累计用量与剩余次数
+// @Tags 客户自助查询
+// @Accept json
+// @Produce json
+// @Success 200 {object} services.PortalOverview
+// @Router /api/portal/overview [get]
+func (h *PortalHandler) Overview(c *gin.Context) {
+	overview, err := h.portalService.Overview(c.MustGet(portalTokenKey).(*services.PortalToken))
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, overview)
+}
+
+// Usage 按天、按模型获取用量
+// @Summary 客户自助查询：按天、按模型用量
+// @Description 使用客户自己的 sk- Token 认证，按天（请求时区）、按模型统计请求数、错误数与 token 用量
+// @Tags 客户自助查询
+// @Accept json
+// @Produce json
+// @Param start_time query string false "开始时间，默认 7 天前"
+// @Param end_time query string false "结束时间，默认当前时间"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的默认时区"
+// @Success 200 {object} services.PortalUsageResponse
+// @Router /api/portal/usage [get]
+func (h *PortalHandler) Usage(c *gin.Context) {
+	var req services.PortalUsageRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.portalService.Usage(c.MustGet(portalTokenKey).(*services.PortalToken), &req)
+	if err != nil {
+		portalError(c, err)
+		return
+	}
+
+	Success(c, response)
+}
+
+// Requests 获取最近请求
+// @Summary 客户自助查询：最近请求
+// @Description 使用客户自己的 sk- Token 认证，按时间倒序返回该 Token 的请求记录，不含请求/响应体、请求头与来源 IP
+// @Tags 客户自助查询
+// @Accept json
+// @Produce json
+// @Param page query int false "页码，默认 1"
+// @Param page_size query int false "每页数量，默认 10，最大 100"
+// @Param start_time query string false "开始时间"
+// @Param end_time query string false "结束时间"
+// @Param tz query string false "时区，用于解析不带时区的时间参数"
+// @Success 200 {object} services.PortalRequestsResponse
+// @Router /api/portal/requests [get]
+func (h *PortalHandler) Requests(c *gin.Context) {
+	var req services.PortalRequestsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.portalService.Requests(c.MustGet(portalTokenKey).(*services.PortalToken), &req)
+	if err != nil {
+		portalError(c, err)
+		return
+	}
+
+	Success(c, response)
+}
+
+// portalError 按错误类型返回响应：参数错误 400，其他 500
+func portalError(c *gin.Context, err error) {
+	if errors.Is(err, services.ErrPortalParam) {
+		BadRequest(c, err.Error())
+		return
+	}
+	InternalServerError(c, err.Error())
+}
@@ -0,0 +1,75 @@
+// Package handlers 数据保留接口处理器
+// 处理清理任务执行记录查询与手动触发清理的 HTTP 请求
+package handlers
+
+import (
+	"context"
+	"errors"
+
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RetentionHandler 数据保留处理器
+type RetentionHandler struct {
+	retentionService *services.RetentionService
+}
+
+// NewRetentionHandler 创建数据保留处理器实例
+func NewRetentionHandler() *RetentionHandler {
+	return &RetentionHandler{
+		retentionService: services.NewRetentionService(),
+	}
+}
+
+// ListPurgeRuns 获取清理执行记录列表
+// @Summary 获取清理执行记录列表
+// @Description 查询数据保留清理任务的执行记录，包含每条规则删除的记录数，按开始时间倒序
+// @Tags 数据保留
+// @Accept json
+// @Produce json
+// @Param page query int false "页码，默认 1"
+// @Param page_size query int false "每页数量，默认 10，最大 100"
+// @Param status query string false "执行状态：success / failed / canceled"
+// @Success 200 {object} services.ListPurgeRunsResponse
+// @Router /api/purge-runs [get]
+func (h *RetentionHandler) ListPurgeRuns(c *gin.Context) {
+	var req services.ListPurgeRunsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.retentionService.ListPurgeRuns(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
+
+// RunPurge 手动执行一次清理
+// @Summary 手动执行清理
+// @Description 按配置的保留策略立即执行一次清理，执行完成后返回执行记录；已有清理任务在执行时返回 400
+// @Tags 数据保留
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.PurgeRun
+// @Router /api/purge-runs [post]
+func (h *RetentionHandler) RunPurge(c *gin.Context) {
+	// 不随请求取消，客户端断开后清理继续执行完成
+	run, err := h.retentionService.Run(context.WithoutCancel(c.Request.Context()), models.PurgeTriggerManual)
+	if err != nil {
+		if errors.Is(err, services.ErrPurgeRunning) {
+			BadRequest(c, err.Error())
+			return
+		}
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, run)
+}
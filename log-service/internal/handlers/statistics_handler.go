@@ -27,8 +27,9 @@ func NewStatisticsHandler() *StatisticsHandler {
 // @Accept json
 // @Produce json
 // @Param authorization query string true "用户唯一标识（authorization）"
-// @Param start_time query string false "开始时间，格式：2006-01-02 15:04:05"
-// @Param end_time query string false "结束时间，格式：2006-01-02 15:04:05"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
 // @Success 200 {object} services.UserStatisticsResponse
 // @Router /api/request-logs/statistics [get]
 func (h *StatisticsHandler) GetUserStatistics(c *gin.Context) {
@@ -53,8 +54,9 @@ func (h *StatisticsHandler) GetUserStatistics(c *gin.Context) {
 // @Tags 统计
 // @Accept json
 // @Produce json
-// @Param start_time query string false "开始时间，格式：2006-01-02 15:04:05"
-// @Param end_time query string false "结束时间，格式：2006-01-02 15:04:05"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
 // @Param page query int false "页码，默认 1"
 // @Param page_size query int false "每页数量，默认 20，最大 100"
 // @Success 200 {object} services.AuthorizationRankingResponse
@@ -74,3 +76,91 @@ func (h *StatisticsHandler) GetAuthorizationRanking(c *gin.Context) {
 
 	Success(c, response)
 }
+
+// GetStreamStatistics 获取流式响应时间指标统计
+// @Summary 获取流式响应时间指标统计
+// @Description 统计流式（SSE）请求的响应头耗时、首字节耗时、流持续时间和分块数的分位数，整体及按模型分组
+// @Tags 统计
+// @Accept json
+// @Produce json
+// @Param authorization query string false "用户唯一标识（authorization）"
+// @Param ai_model_name query string false "模型名称"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Success 200 {object} services.StreamStatisticsResponse
+// @Router /api/request-logs/stream-statistics [get]
+func (h *StatisticsHandler) GetStreamStatistics(c *gin.Context) {
+	var req services.GetStreamStatisticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.statisticsService.GetStreamStatistics(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
+
+// GetLatencyStatistics 获取延迟分位数统计
+// @Summary 获取延迟分位数统计
+// @Description 统计请求延迟的平均值、极值与 P50/P90/P95/P99，整体及按路径分组；可按 authorization、路径过滤，均不传时统计全局
+// @Tags 统计
+// @Accept json
+// @Produce json
+// @Param authorization query string false "用户唯一标识（authorization）"
+// @Param path query string false "请求路径"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Success 200 {object} services.LatencyStatisticsResponse
+// @Router /api/request-logs/latency-statistics [get]
+func (h *StatisticsHandler) GetLatencyStatistics(c *gin.Context) {
+	var req services.GetLatencyStatisticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.statisticsService.GetLatencyStatistics(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
+
+// GetErrorStatistics 获取错误率统计
+// @Summary 获取错误率统计
+// @Description 统计状态码分类（2xx/4xx/5xx）与具体状态码分布，以及按小时/按天的错误率时间序列；可按 authorization、路径过滤，均不传时统计全局
+// @Tags 统计
+// @Accept json
+// @Produce json
+// @Param authorization query string false "用户唯一标识（authorization）"
+// @Param path query string false "请求路径"
+// @Param start_time query string false "开始时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param end_time query string false "结束时间，RFC3339 或 2006-01-02 15:04:05（按 tz 解析）"
+// @Param tz query string false "时区，如 Asia/Shanghai、+08:00，默认为配置的时区"
+// @Param interval query string false "时间序列粒度：hour / day"
+// @Success 200 {object} services.ErrorStatisticsResponse
+// @Router /api/request-logs/error-statistics [get]
+func (h *StatisticsHandler) GetErrorStatistics(c *gin.Context) {
+	var req services.GetErrorStatisticsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	response, err := h.statisticsService.GetErrorStatistics(&req)
+	if err != nil {
+		InternalServerError(c, err.Error())
+		return
+	}
+
+	Success(c, response)
+}
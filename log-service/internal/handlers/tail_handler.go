@@ -0,0 +1,159 @@
+// Package handlers HTTP请求处理器
+// 处理实时日志流（SSE）相关的 HTTP 请求
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/middleware"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CreateStreamTicket 签发实时日志流票据（admin 调用）
+// @Summary 签发实时日志流票据
+// @Description 浏览器 EventSource 无法设置请求头，先用 JWT 换取一次性票据，再以 ticket 查询参数连接实时日志流。票据 30 秒内有效，只能使用一次
+// @Tags 请求日志
+// @Produce json
+// @Success 200 {object} map[string]interface{}
+// @Router /api/stream-tickets [post]
+func (h *LogHandler) CreateStreamTicket(c *gin.Context) {
+	ticket, expiresAt, err := middleware.NewStreamTicket()
+	if err != nil {
+		InternalServerError(c, "签发票据失败")
+		return
+	}
+
+	Success(c, gin.H{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+	})
+}
+
+// TailLogs 实时推送新写入的请求日志（admin 调用）
+// @Summary 请求日志实时流
+// @Description 以 SSE 推送新写入的请求日志，过滤条件与列表接口相同
+// @Tags 请求日志
+// @Produce text/event-stream
+// @Param since_id query int false "从该 ID 之后开始推送，默认从最新记录之后开始"
+// @Param request_id query string false "按 request_id 过滤"
+// @Param status query string false "状态码（单个如 200 或多个逗号分隔如 200,401,404）"
+// @Param method query string false "HTTP 方法"
+// @Param authorization query string false "Authorization"
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
+// @Router /api/request-logs/tail [get]
+func (h *LogHandler) TailLogs(c *gin.Context) {
+	var req services.TailLogsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	if req.SinceID == 0 {
+		req.SinceID = lastEventID(c)
+	}
+
+	startSSE(c)
+	err := h.logService.TailLogs(c.Request.Context(), &req, services.TailSink[models.TokenUsageLog]{
+		Send: func(list []models.TokenUsageLog) error {
+			for _, log := range list {
+				if err := writeSSEEvent(c, log.ID, "request_log", log); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		},
+		Heartbeat: func() error { return writeSSEHeartbeat(c) },
+	})
+	if err != nil {
+		logger.Warn("请求日志实时流中断", "error", err)
+	}
+}
+
+// TailSystemLogs 实时推送新写入的系统日志（admin 调用）
+// @Summary 系统日志实时流
+// @Description 以 SSE 推送新写入的系统日志
+// @Tags 系统日志
+// @Produce text/event-stream
+// @Param since_id query int false "从该 ID 之后开始推送，默认从最新记录之后开始"
+// @Param level query string false "日志级别"
+// @Param request_id query string false "按 request_id 过滤"
+// @Param service_id query string false "来源服务标识"
+// @Param host query string false "来源主机"
+// @Param msg query string false "消息内容子串"
+// @Param attr query []string false "属性过滤，key=value 或 key~value，可重复" collectionFormat(multi)
+// @Router /api/system-logs/tail [get]
+func (h *LogHandler) TailSystemLogs(c *gin.Context) {
+	var req services.TailSystemLogsRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+	if _, err := services.ParseAttrFilters(req.Attrs); err != nil {
+		BadRequest(c, err.Error())
+		return
+	}
+	if req.SinceID == 0 {
+		req.SinceID = lastEventID(c)
+	}
+
+	startSSE(c)
+	err := h.systemLogService.TailSystemLogs(c.Request.Context(), &req, services.TailSink[models.SystemLog]{
+		Send: func(list []models.SystemLog) error {
+			for _, log := range list {
+				if err := writeSSEEvent(c, log.ID, "system_log", log); err != nil {
+					return err
+				}
+			}
+			c.Writer.Flush()
+			return nil
+		},
+		Heartbeat: func() error { return writeSSEHeartbeat(c) },
+	})
+	if err != nil {
+		logger.Warn("系统日志实时流中断", "error", err)
+	}
+}
+
+// lastEventID 读取 EventSource 断线重连时携带的 Last-Event-ID
+func lastEventID(c *gin.Context) uint {
+	id, err := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return uint(id)
+}
+
+// startSSE 写出 SSE 响应头
+func startSSE(c *gin.Context) {
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // 禁用 Nginx 缓冲
+	c.Status(200)
+	c.Writer.Flush()
+}
+
+// writeSSEEvent 写出一条 SSE 事件，id 为记录 ID，断线重连时可作为 since_id
+func writeSSEEvent(c *gin.Context, id uint, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", id, event, payload)
+	return err
+}
+
+// writeSSEHeartbeat 写出 SSE 注释行作为心跳
+func writeSSEHeartbeat(c *gin.Context) error {
+	if _, err := fmt.Fprint(c.Writer, ": ping\n\n"); err != nil {
+		return err
+	}
+	c.Writer.Flush()
+	return nil
+}
@@ -35,14 +35,63 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		tokenString := parts[1]
-		cfg := config.GetConfig()
 
 		// 验证 JWT
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.API.JWTSecret), nil
-		})
+		if !validJWT(tokenString) {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "无效的token",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// StreamAuthMiddleware 认证中间件（用于 SSE 实时日志流）
+// 浏览器 EventSource 无法设置请求头，因此除 Authorization 头（JWT）外也接受 ticket 查询参数：
+// 由 POST /api/stream-tickets 签发的一次性短期票据，JWT 不会出现在 URL 中
+func StreamAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if ticket := c.Query("ticket"); ticket != "" && c.GetHeader("Authorization") == "" {
+			if !consumeStreamTicket(ticket) {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    401,
+					"message": "无效或已使用的票据",
+				})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		var tokenString string
+		if authHeader := c.GetHeader("Authorization"); authHeader != "" {
+			parts := strings.SplitN(authHeader, " ", 2)
+			if !(len(parts) == 2 && parts[0] == "Bearer") {
+				c.JSON(http.StatusUnauthorized, gin.H{
+					"code":    401,
+					"message": "认证格式错误",
+				})
+				c.Abort()
+				return
+			}
+			tokenString = parts[1]
+		}
 
-		if err != nil || !token.Valid {
+		if tokenString == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{
+				"code":    401,
+				"message": "未提供认证token",
+			})
+			c.Abort()
+			return
+		}
+
+		if !validJWT(tokenString) {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"code":    401,
 				"message": "无效的token",
@@ -55,6 +104,17 @@ func AuthMiddleware() gin.HandlerFunc {
 	}
 }
 
+// validJWT 验证 JWT 是否有效
+func validJWT(tokenString string) bool {
+	cfg := config.GetConfig()
+
+	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.API.JWTSecret), nil
+	})
+
+	return err == nil && token.Valid
+}
+
 // SystemAuthMiddleware 系统 Token 认证中间件（用于 proxy 写入日志）
 func SystemAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
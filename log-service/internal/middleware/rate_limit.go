@@ -0,0 +1,91 @@
+// Package middleware 中间件
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitMaxKeys 限流器记录的 key 数量超过该值时清理已回满的令牌桶
+const rateLimitMaxKeys = 10000
+
+// tokenBucket 单个 key 的令牌桶
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter 按 key 的令牌桶限流器，每分钟补充 perMinute 个令牌，最多积累 perMinute 个
+type rateLimiter struct {
+	mu        sync.Mutex
+	perMinute float64
+	buckets   map[string]*tokenBucket
+}
+
+// allow 消耗一个令牌，令牌不足时返回需要等待的时间
+func (l *rateLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rate := l.perMinute / float64(time.Minute)
+	bucket, ok := l.buckets[key]
+	if !ok {
+		if len(l.buckets) >= rateLimitMaxKeys {
+			l.prune(now)
+		}
+		bucket = &tokenBucket{tokens: l.perMinute, last: now}
+		l.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(l.perMinute, bucket.tokens+float64(now.Sub(bucket.last))*rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / rate)
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// prune 删除已回满的令牌桶（与新建的桶等价）
+func (l *rateLimiter) prune(now time.Time) {
+	rate := l.perMinute / float64(time.Minute)
+	for key, bucket := range l.buckets {
+		if bucket.tokens+float64(now.Sub(bucket.last))*rate >= l.perMinute {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// RateLimitMiddleware 按 key 限流，每个 key 每分钟最多 perMinute 次请求（允许同等数量的突发）
+// key 为空时不限流；超过限制返回 429 并通过 Retry-After 提示等待秒数
+func RateLimitMiddleware(perMinute int, key func(c *gin.Context) string) gin.HandlerFunc {
+	limiter := &rateLimiter{
+		perMinute: float64(perMinute),
+		buckets:   make(map[string]*tokenBucket),
+	}
+
+	return func(c *gin.Context) {
+		k := key(c)
+		if k == "" {
+			c.Next()
+			return
+		}
+
+		if ok, wait := limiter.allow(k, time.Now()); !ok {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{
+				"code":    429,
+				"message": "请求过于频繁，请稍后再试",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
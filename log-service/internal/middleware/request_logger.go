@@ -6,6 +6,7 @@ import (
 	"bytes"
 	"io"
 	"log/slog"
+	"net/url"
 	"time"
 
 	"zxm_ai_admin/log-service/internal/logger"
@@ -19,17 +20,52 @@ type bodyLogWriter struct {
 	body *bytes.Buffer
 }
 
+// maxCapturedBodySize 响应体最多捕获的字节数（只记录不超过 1000 字节的响应体）
+// 避免 SSE 等长连接响应无限占用内存
+const maxCapturedBodySize = 1001
+
 func (w bodyLogWriter) Write(b []byte) (int, error) {
-	w.body.Write(b)
+	if remaining := maxCapturedBodySize - w.body.Len(); remaining > 0 {
+		if len(b) > remaining {
+			w.body.Write(b[:remaining])
+		} else {
+			w.body.Write(b)
+		}
+	}
 	return w.ResponseWriter.Write(b)
 }
 
+// redactedQueryParams 记录日志时隐藏值的查询参数（认证凭据）
+var redactedQueryParams = []string{"ticket", "access_token", "token"}
+
+// redactQuery 隐藏查询字符串中认证凭据的值，无法解析时只记录参数被省略
+func redactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "(unparsable query omitted)"
+	}
+	redacted := false
+	for _, name := range redactedQueryParams {
+		if _, ok := values[name]; ok {
+			values.Set(name, "REDACTED")
+			redacted = true
+		}
+	}
+	if !redacted {
+		return rawQuery
+	}
+	return values.Encode()
+}
+
 // RequestLogger HTTP 请求日志中间件
 func RequestLogger() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
 		path := c.Request.URL.Path
-		query := c.Request.URL.RawQuery
+		query := redactQuery(c.Request.URL.RawQuery)
 
 		// 读取请求体
 		var requestBody string
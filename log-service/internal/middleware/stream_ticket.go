@@ -0,0 +1,57 @@
+// Package middleware 中间件
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// StreamTicketTTL 实时日志流票据的有效期，票据只能使用一次
+const StreamTicketTTL = 30 * time.Second
+
+// streamTicketMaxCount 未使用的票据超过该数量时清理过期票据
+const streamTicketMaxCount = 10000
+
+// streamTickets 已签发、未使用的票据及其过期时间（只在当前进程内有效）
+var streamTickets = struct {
+	mu      sync.Mutex
+	expires map[string]time.Time
+}{expires: make(map[string]time.Time)}
+
+// NewStreamTicket 签发一个实时日志流票据，返回票据和过期时间。
+// 浏览器 EventSource 无法设置请求头，用票据代替 JWT 放在 URL 中，避免 JWT 出现在访问日志里
+func NewStreamTicket() (string, time.Time, error) {
+	buf := make([]byte, 24)
+	if _, err := rand.Read(buf); err != nil {
+		return "", time.Time{}, err
+	}
+	ticket := hex.EncodeToString(buf)
+	now := time.Now()
+	expiresAt := now.Add(StreamTicketTTL)
+
+	streamTickets.mu.Lock()
+	defer streamTickets.mu.Unlock()
+	if len(streamTickets.expires) >= streamTicketMaxCount {
+		for t, exp := range streamTickets.expires {
+			if now.After(exp) {
+				delete(streamTickets.expires, t)
+			}
+		}
+	}
+	streamTickets.expires[ticket] = expiresAt
+	return ticket, expiresAt, nil
+}
+
+// consumeStreamTicket 使用票据，票据存在且未过期时返回 true；无论结果如何票据都会作废
+func consumeStreamTicket(ticket string) bool {
+	streamTickets.mu.Lock()
+	defer streamTickets.mu.Unlock()
+	expiresAt, ok := streamTickets.expires[ticket]
+	if !ok {
+		return false
+	}
+	delete(streamTickets.expires, ticket)
+	return time.Now().Before(expiresAt)
+}
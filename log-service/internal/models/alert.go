@@ -0,0 +1,103 @@
+// Package models 数据模型定义
+// 定义告警规则与告警事件的数据模型结构
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 告警指标
+const (
+	AlertMetricErrorRate    = "error_rate"    // 错误率（状态码 >= 400 的百分比）
+	AlertMetricRequestCount = "request_count" // 请求数
+	AlertMetricLatency      = "latency"       // 延迟分位数（毫秒）
+	AlertMetricNoTraffic    = "no_traffic"    // 窗口内没有请求
+)
+
+// 告警事件状态
+const (
+	AlertStatusFiring   = "firing"
+	AlertStatusResolved = "resolved"
+)
+
+// StringList 以 JSON 存储的字符串列表
+type StringList []string
+
+// Scan 实现 sql.Scanner 接口
+func (l *StringList) Scan(value interface{}) error {
+	if value == nil {
+		*l = nil
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, l)
+	case string:
+		return json.Unmarshal([]byte(v), l)
+	default:
+		return errors.New("failed to unmarshal StringList value")
+	}
+}
+
+// Value 实现 driver.Valuer 接口
+func (l StringList) Value() (driver.Value, error) {
+	if l == nil {
+		return nil, nil
+	}
+	return json.Marshal(l)
+}
+
+// AlertRule 告警规则
+type AlertRule struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Name           string     `json:"name" gorm:"size:100;not null"`
+	Enabled        bool       `json:"enabled"`
+	Metric         string     `json:"metric" gorm:"size:20;not null"` // error_rate / request_count / latency / no_traffic
+	Window         string     `json:"window" gorm:"size:20;not null"` // 统计窗口，如 5m、1h
+	Threshold      float64    `json:"threshold"`                      // 超过阈值时告警：错误率为百分比，延迟为毫秒；no_traffic 不使用
+	Percentile     float64    `json:"percentile"`                     // latency 使用的分位数，默认 95
+	MinRequests    int64      `json:"min_requests"`                   // error_rate / latency 在窗口内至少有该数量的请求才评估，避免样本过少误报
+	GroupBy        string     `json:"group_by" gorm:"size:20"`        // 分组维度：空（整体）、authorization、ai_model_name、path、service_id、host
+	AIModelName    string     `json:"ai_model_name" gorm:"size:100"`  // 过滤：模型名称
+	Authorization  string     `json:"authorization" gorm:"size:500"`  // 过滤：Token
+	Path           string     `json:"path" gorm:"size:500"`           // 过滤：请求路径前缀
+	ServiceID      string     `json:"service_id" gorm:"size:100"`     // 过滤：来源服务标识
+	Host           string     `json:"host" gorm:"size:100"`           // 过滤：来源主机
+	Channels       StringList `json:"channels" gorm:"type:text"`      // 通知渠道名称，为空时发送到全部渠道
+	RepeatInterval string     `json:"repeat_interval" gorm:"size:20"` // 持续告警时重复通知的间隔，为空表示只通知一次
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AlertRule) TableName() string {
+	return "alert_rules"
+}
+
+// AlertEvent 告警事件，规则（或分组）从触发到恢复为一条记录
+type AlertEvent struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	RuleID         uint       `json:"rule_id" gorm:"not null;index"`
+	RuleName       string     `json:"rule_name" gorm:"size:100"`
+	Metric         string     `json:"metric" gorm:"size:20"`
+	GroupKey       string     `json:"group_key" gorm:"size:500"` // 分组值，整体告警为空
+	Status         string     `json:"status" gorm:"size:20;index"`
+	Value          float64    `json:"value"`     // 最近一次评估的值
+	Threshold      float64    `json:"threshold"` // 触发时的阈值
+	Summary        string     `json:"summary" gorm:"size:500"`
+	FiredAt        time.Time  `json:"fired_at" gorm:"not null;index"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+	LastNotifiedAt *time.Time `json:"last_notified_at"`
+	NotifyCount    int        `json:"notify_count"`
+	NotifyError    string     `json:"notify_error" gorm:"size:500"` // 最近一次通知失败的原因
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (AlertEvent) TableName() string {
+	return "alert_events"
+}
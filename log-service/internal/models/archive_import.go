@@ -0,0 +1,23 @@
+// Package models 数据模型定义
+// 定义归档重新导入记录的数据模型结构
+package models
+
+import "time"
+
+// ArchiveImport 归档重新导入记录，每次导入对应一张临时表
+type ArchiveImport struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	SourceTable string    `json:"source_table" gorm:"size:50;not null"`         // 归档来源表：token_usage_logs / system_logs
+	ImportTable string    `json:"import_table" gorm:"size:100;not null;unique"` // 导入的临时表名
+	StartDate   string    `json:"start_date" gorm:"size:10"`                    // 归档分区起始日期（UTC，含）
+	EndDate     string    `json:"end_date" gorm:"size:10"`                      // 归档分区结束日期（UTC，含）
+	Files       int       `json:"files"`                                        // 导入的归档文件数
+	Rows        int64     `json:"rows"`                                         // 导入的记录数
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`                      // 过期后临时表由清理任务删除
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// TableName 指定表名
+func (ArchiveImport) TableName() string {
+	return "archive_imports"
+}
@@ -0,0 +1,71 @@
+// Package models 数据模型定义
+// 定义已接收的上传批次的数据模型结构
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 上传批次的日志类型
+const (
+	IngestKindRequest = "request"
+	IngestKindSystem  = "system"
+)
+
+// RejectedRow 批次中被拒绝的行
+type RejectedRow struct {
+	Index     int    `json:"index"`
+	RequestID string `json:"request_id,omitempty"`
+	Reason    string `json:"reason"`
+}
+
+// RejectedRows 以 JSON 存储的被拒绝的行列表
+type RejectedRows []RejectedRow
+
+// Scan 实现 sql.Scanner 接口
+func (r *RejectedRows) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return errors.New("failed to unmarshal RejectedRows value")
+	}
+}
+
+// Value 实现 driver.Valuer 接口
+func (r RejectedRows) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// IngestBatch 已接收的上传批次，同一 batch_id 重复上传时直接返回记录的结果
+type IngestBatch struct {
+	ID        uint         `json:"id" gorm:"primaryKey"`
+	BatchID   string       `json:"batch_id" gorm:"size:64;not null;uniqueIndex"`
+	Kind      string       `json:"kind" gorm:"size:20"`          // request / system
+	Source    string       `json:"source" gorm:"size:100;index"` // 上传方的日志来源名称
+	File      string       `json:"file" gorm:"size:255"`         // 来源文件名
+	EndOffset int64        `json:"end_offset"`                   // 批次结束时在来源文件中的字节位置
+	Received  int          `json:"received"`
+	Inserted  int          `json:"inserted"`
+	Duplicate int          `json:"duplicate"`
+	Rejected  int          `json:"rejected"`
+	Rejects   RejectedRows `json:"rejects" gorm:"type:text"` // 被拒绝的行及原因
+	CreatedAt time.Time    `json:"created_at" gorm:"index"`
+}
+
+// TableName 指定表名
+func (IngestBatch) TableName() string {
+	return "ingest_batches"
+}
@@ -0,0 +1,87 @@
+// Package models 数据模型定义
+// 定义数据保留清理任务执行记录的数据模型结构
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// 清理任务触发方式
+const (
+	PurgeTriggerSchedule = "schedule"
+	PurgeTriggerManual   = "manual"
+	PurgeTriggerCommand  = "command"
+	PurgeTriggerDelete   = "delete" // 按时间范围删除请求日志的接口
+)
+
+// 清理任务执行状态
+const (
+	PurgeStatusSuccess  = "success"
+	PurgeStatusFailed   = "failed"
+	PurgeStatusCanceled = "canceled"
+)
+
+// PurgeRuleResult 单条保留规则的删除结果
+type PurgeRuleResult struct {
+	Table   string    `json:"table"`   // 表名
+	Rule    string    `json:"rule"`    // 规则，如 status=4xx,5xx、level=ERROR、default
+	Days    int       `json:"days"`    // 保留天数
+	Cutoff  time.Time `json:"cutoff"`  // 删除早于该时间的记录（按时间范围删除时为范围的结束时间，含）
+	Deleted int64     `json:"deleted"` // 删除的记录数
+
+	From *time.Time `json:"from,omitempty"` // 按时间范围删除时范围的开始时间，保留规则清理时为空
+
+	Archived     int64    `json:"archived"`                // 归档的记录数（未启用归档时为 0）
+	ArchiveFiles []string `json:"archive_files,omitempty"` // 上传的归档文件键
+}
+
+// PurgeRuleResults 以 JSON 存储的规则删除结果列表
+type PurgeRuleResults []PurgeRuleResult
+
+// Scan 实现 sql.Scanner 接口
+func (r *PurgeRuleResults) Scan(value interface{}) error {
+	if value == nil {
+		*r = nil
+		return nil
+	}
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, r)
+	case string:
+		return json.Unmarshal([]byte(v), r)
+	default:
+		return errors.New("failed to unmarshal PurgeRuleResults value")
+	}
+}
+
+// Value 实现 driver.Valuer 接口
+func (r PurgeRuleResults) Value() (driver.Value, error) {
+	if r == nil {
+		return nil, nil
+	}
+	return json.Marshal(r)
+}
+
+// PurgeRun 数据保留清理任务执行记录
+type PurgeRun struct {
+	ID                 uint             `json:"id" gorm:"primaryKey"`
+	TriggeredBy        string           `json:"triggered_by" gorm:"size:20"` // schedule / manual / command / delete
+	Status             string           `json:"status" gorm:"size:20;index"` // success / failed / canceled
+	Error              string           `json:"error" gorm:"size:500"`
+	StartedAt          time.Time        `json:"started_at" gorm:"not null;index"`
+	FinishedAt         time.Time        `json:"finished_at"`
+	DurationMs         int64            `json:"duration_ms"`
+	DeletedRequestLogs int64            `json:"deleted_request_logs"`
+	DeletedSystemLogs  int64            `json:"deleted_system_logs"`
+	Vacuumed           bool             `json:"vacuumed"` // 是否执行了空间回收（增量 VACUUM）
+	Details            PurgeRuleResults `json:"details" gorm:"type:text"`
+	CreatedAt          time.Time        `json:"created_at"`
+}
+
+// TableName 指定表名
+func (PurgeRun) TableName() string {
+	return "purge_runs"
+}
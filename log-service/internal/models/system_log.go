@@ -9,15 +9,23 @@ import (
 )
 
 // SystemLog 系统日志模型
+// 一个请求可以产生多条系统日志，request_id 不唯一；log-syncer 上传的日志按 来源/文件/行首位置 去重
+// 索引名由表名派生（composite），按本模型创建的归档导入临时表不会与原表索引重名
 type SystemLog struct {
-	ID        uint           `json:"id" gorm:"primaryKey"`
-	RequestID string         `json:"request_id" gorm:"size:64;unique"`
-	Time      time.Time      `json:"time" gorm:"not null;index"`
-	Level     string         `json:"level" gorm:"size:20;index"`
-	Msg       string         `json:"msg" gorm:"size:500"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
+	ID           uint           `json:"id" gorm:"primaryKey"`
+	RequestID    string         `json:"request_id" gorm:"size:64;index"` // 关联的请求 ID
+	Time         time.Time      `json:"time" gorm:"not null;index"`
+	Level        string         `json:"level" gorm:"size:20;index"`
+	Msg          string         `json:"msg" gorm:"size:500"`
+	Attrs        JSONMap        `json:"attrs" gorm:"type:text"`                                                    // 除 time/level/msg/request_id 外的全部 slog 属性，嵌套分组以 . 展开
+	ServiceID    string         `json:"service_id" gorm:"size:100;index"`                                          // 来源服务标识，由 log-syncer 写入
+	Host         string         `json:"host" gorm:"size:100;index"`                                                // 来源主机
+	Source       string         `json:"source" gorm:"size:100;uniqueIndex:,composite:source_line,priority:1"`      // log-syncer 的日志来源名称
+	SourceFile   string         `json:"source_file" gorm:"size:255;uniqueIndex:,composite:source_line,priority:2"` // 来源文件名
+	SourceOffset *int64         `json:"source_offset" gorm:"uniqueIndex:,composite:source_line,priority:3"`        // 行首在来源文件中的字节位置，为空时不去重
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	DeletedAt    gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TableName 指定表名
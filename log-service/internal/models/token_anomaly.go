@@ -0,0 +1,47 @@
+// Package models 数据模型定义
+// 定义 Token 异常使用检测结果的数据模型结构
+package models
+
+import "time"
+
+// Token 异常类型
+const (
+	AnomalyKindDistinctIPs        = "distinct_ips"         // 窗口内来源 IP 过多（疑似转售 / 泄露）
+	AnomalyKindDistinctUserAgents = "distinct_user_agents" // 窗口内 User-Agent 过多
+	AnomalyKindVolumeSpike        = "volume_spike"         // 请求量相对自身基线突增
+	AnomalyKindNightBurst         = "night_burst"          // 夜间集中请求
+)
+
+// Token 异常处置结果
+const (
+	AnomalyActionDisabled = "disabled" // 已调用管理服务禁用 Token
+	AnomalyActionFailed   = "failed"   // 禁用失败，原因见 action_error
+)
+
+// TokenAnomaly Token 异常使用检测结果
+// 同一 Token、同一类型在检测窗口内重复命中时更新同一条记录
+type TokenAnomaly struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	Authorization  string     `json:"authorization" gorm:"size:500;not null;index"`
+	Kind           string     `json:"kind" gorm:"size:30;not null;index"`
+	Score          int        `json:"score" gorm:"index"`       // 可疑程度 0-100，刚超过阈值为 50，达到阈值 2 倍为 100
+	Value          float64    `json:"value"`                    // 检测值：IP / UA 数或请求数
+	Threshold      float64    `json:"threshold"`                // 判定阈值
+	Baseline       float64    `json:"baseline"`                 // 基线：历史同长度时段的平均请求数（volume_spike / night_burst）
+	Samples        StringList `json:"samples" gorm:"type:text"` // 样本：请求最多的 IP / UA
+	Summary        string     `json:"summary" gorm:"size:500"`
+	WindowStart    time.Time  `json:"window_start"`
+	WindowEnd      time.Time  `json:"window_end" gorm:"index"`
+	Occurrences    int        `json:"occurrences"` // 命中次数
+	DetectedAt     time.Time  `json:"detected_at" gorm:"not null;index"`
+	LastDetectedAt time.Time  `json:"last_detected_at"`
+	Action         string     `json:"action" gorm:"size:20"`        // 自动处置结果：空（未处置）、disabled、failed
+	ActionError    string     `json:"action_error" gorm:"size:500"` // 处置失败原因
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (TokenAnomaly) TableName() string {
+	return "token_anomalies"
+}
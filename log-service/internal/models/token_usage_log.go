@@ -20,11 +20,15 @@ func (j *JSONMap) Scan(value interface{}) error {
 		*j = nil
 		return nil
 	}
-	bytes, ok := value.([]byte)
-	if !ok {
+	// SQLite 返回 []byte，PostgreSQL / ClickHouse 可能返回 string
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, j)
+	case string:
+		return json.Unmarshal([]byte(v), j)
+	default:
 		return errors.New("failed to unmarshal JSONMap value")
 	}
-	return json.Unmarshal(bytes, j)
 }
 
 // Value 实现 driver.Valuer 接口
@@ -37,28 +41,41 @@ func (j JSONMap) Value() (driver.Value, error) {
 
 // TokenUsageLog Token 使用记录模型
 type TokenUsageLog struct {
-	ID                uint            `json:"id" gorm:"primaryKey"`
-	Time              time.Time       `json:"time" gorm:"not null;index"`
-	Level             string          `json:"level" gorm:"size:20"`
-	Msg               string          `json:"msg" gorm:"size:200"`
-	RequestID         string          `json:"request_id" gorm:"size:64;unique"`
-	Method            string          `json:"method" gorm:"size:10;index"`
-	Path              string          `json:"path" gorm:"size:500;index"`
-	Query             string          `json:"query" gorm:"size:1000"`
-	RemoteAddr        string          `json:"remote_addr" gorm:"size:100"`
-	UserAgent         string          `json:"user_agent" gorm:"size:500"`
-	XForwardedFor     string          `json:"x_forwarded_for" gorm:"size:100"`
-	RequestHeaders    JSONMap         `json:"request_headers" gorm:"type:text"`
-	Authorization     string          `json:"authorization" gorm:"size:500;index"`
-	RequestBody       string          `json:"request_body" gorm:"type:text"`
-	Status            int             `json:"status" gorm:"index"`
-	ResponseHeaders   JSONMap         `json:"response_headers" gorm:"type:text"`
-	LatencyMs         int64           `json:"latency_ms"`
-	RequestSizeBytes  int             `json:"request_size_bytes"`
-	ResponseSizeBytes int             `json:"response_size_bytes"`
-	CreatedAt         time.Time       `json:"created_at"`
-	UpdatedAt         time.Time       `json:"updated_at"`
-	DeletedAt         gorm.DeletedAt  `json:"-" gorm:"index"`
+	ID                 uint           `json:"id" gorm:"primaryKey"`
+	Time               time.Time      `json:"time" gorm:"not null;index"`
+	Level              string         `json:"level" gorm:"size:20"`
+	Msg                string         `json:"msg" gorm:"size:200"`
+	RequestID          string         `json:"request_id" gorm:"size:64;unique"`
+	Method             string         `json:"method" gorm:"size:10;index"`
+	Path               string         `json:"path" gorm:"size:500;index"`
+	Query              string         `json:"query" gorm:"size:1000"`
+	RemoteAddr         string         `json:"remote_addr" gorm:"size:100"`
+	UserAgent          string         `json:"user_agent" gorm:"size:500"`
+	XForwardedFor      string         `json:"x_forwarded_for" gorm:"size:100"`
+	RequestHeaders     JSONMap        `json:"request_headers" gorm:"type:text"`
+	Authorization      string         `json:"authorization" gorm:"size:500;index"`
+	RequestBody        string         `json:"request_body" gorm:"type:text"`
+	Status             int            `json:"status" gorm:"index"`
+	ResponseHeaders    JSONMap        `json:"response_headers" gorm:"type:text"`
+	LatencyMs          int64          `json:"latency_ms"`
+	RequestSizeBytes   int            `json:"request_size_bytes"`
+	ResponseSizeBytes  int            `json:"response_size_bytes"`
+	AIModelID          int            `json:"ai_model_id" gorm:"index"`
+	AIModelName        string         `json:"ai_model_name" gorm:"size:100;index"`
+	OrderNo            string         `json:"order_no" gorm:"size:100;index"` // Token 关联的订单号，用于账单对账
+	IsStream           bool           `json:"is_stream" gorm:"index"`
+	HeaderLatencyMs    int64          `json:"header_latency_ms"`                // 请求开始到上游响应头的耗时
+	FirstByteLatencyMs int64          `json:"first_byte_latency_ms"`            // 请求开始到首个响应体字节（首 token）的耗时
+	StreamDurationMs   int64          `json:"stream_duration_ms"`               // 首字节到最后一个字节的耗时
+	ChunkCount         int            `json:"chunk_count"`                      // 响应体分块数
+	PromptTokens       int64          `json:"prompt_tokens"`                    // 上游返回的输入 token 数
+	CompletionTokens   int64          `json:"completion_tokens"`                // 上游返回的输出 token 数
+	TotalTokens        int64          `json:"total_tokens"`                     // 上游返回的总 token 数
+	ServiceID          string         `json:"service_id" gorm:"size:100;index"` // 来源服务标识（如 proxy 实例），由 log-syncer 写入
+	Host               string         `json:"host" gorm:"size:100;index"`       // 来源主机
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `json:"-" gorm:"index"`
 }
 
 // TableName 指定表名
@@ -0,0 +1,39 @@
+// Package models 数据模型定义
+// 定义请求日志预聚合统计的数据模型结构
+package models
+
+import "time"
+
+// 预聚合粒度
+const (
+	RollupGranularityHour = "hour"
+	RollupGranularityDay  = "day"
+)
+
+// UsageRollup 请求日志按小时/按天的预聚合统计
+// 维度：粒度 + 时间桶 + authorization + 路径 + 状态码分类 + 模型
+// 原始日志清理后统计数据仍然保留
+type UsageRollup struct {
+	ID               uint      `json:"id" gorm:"primaryKey"`
+	Granularity      string    `json:"granularity" gorm:"size:10;not null;uniqueIndex:idx_usage_rollups_key,priority:1"`
+	BucketStart      time.Time `json:"bucket_start" gorm:"not null;uniqueIndex:idx_usage_rollups_key,priority:2;index"` // 时间桶起点（UTC）
+	Authorization    string    `json:"authorization" gorm:"size:500;uniqueIndex:idx_usage_rollups_key,priority:3"`
+	Path             string    `json:"path" gorm:"size:500;uniqueIndex:idx_usage_rollups_key,priority:4"`
+	StatusClass      string    `json:"status_class" gorm:"size:10;uniqueIndex:idx_usage_rollups_key,priority:5"` // 2xx / 4xx / 5xx 等
+	AIModelName      string    `json:"ai_model_name" gorm:"size:100;uniqueIndex:idx_usage_rollups_key,priority:6"`
+	RequestCount     int64     `json:"request_count"`
+	RequestBytes     int64     `json:"request_bytes"`
+	ResponseBytes    int64     `json:"response_bytes"`
+	LatencySumMs     int64     `json:"latency_sum_ms"`
+	LatencyMinMs     int64     `json:"latency_min_ms"`
+	LatencyMaxMs     int64     `json:"latency_max_ms"`
+	PromptTokens     int64     `json:"prompt_tokens"`
+	CompletionTokens int64     `json:"completion_tokens"`
+	TotalTokens      int64     `json:"total_tokens"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// TableName 指定表名
+func (UsageRollup) TableName() string {
+	return "usage_rollups"
+}
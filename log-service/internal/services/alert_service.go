@@ -0,0 +1,682 @@
+// Package services 业务逻辑服务层
+// 告警规则管理与评估：按规则统计请求日志，超过阈值时通过配置的渠道通知，并记录告警事件
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/alert"
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// alertMu 保证同一时间只有一次评估（定时、写入触发共用）
+var alertMu sync.Mutex
+
+// alertNotifyTimeout 单个渠道发送通知的超时时间
+const alertNotifyTimeout = 15 * time.Second
+
+// maxAlertWindow 告警统计窗口上限
+const maxAlertWindow = 7 * 24 * time.Hour
+
+// noTrafficLookback 分组的 no_traffic 规则以窗口开始前这段时间内出现过的分组作为检查对象
+const noTrafficLookback = 24 * time.Hour
+
+// alertGroupColumns 告警规则支持的分组维度
+var alertGroupColumns = map[string]bool{
+	"authorization": true,
+	"ai_model_name": true,
+	"path":          true,
+	"service_id":    true,
+	"host":          true,
+}
+
+var (
+	// ErrAlertParam 告警接口参数错误
+	ErrAlertParam = errors.New("参数错误")
+	// ErrAlertNotFound 告警规则不存在
+	ErrAlertNotFound = errors.New("告警规则不存在")
+)
+
+// AlertService 告警服务
+type AlertService struct{}
+
+// NewAlertService 创建告警服务实例
+func NewAlertService() *AlertService {
+	return &AlertService{}
+}
+
+// AlertRuleRequest 创建 / 更新告警规则请求，字段含义见 models.AlertRule
+type AlertRuleRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Enabled        *bool    `json:"enabled"` // 默认启用
+	Metric         string   `json:"metric" binding:"required"`
+	Window         string   `json:"window" binding:"required"`
+	Threshold      float64  `json:"threshold"`
+	Percentile     float64  `json:"percentile"`
+	MinRequests    int64    `json:"min_requests"`
+	GroupBy        string   `json:"group_by"`
+	AIModelName    string   `json:"ai_model_name"`
+	Authorization  string   `json:"authorization"`
+	Path           string   `json:"path"`
+	ServiceID      string   `json:"service_id"`
+	Host           string   `json:"host"`
+	Channels       []string `json:"channels"`
+	RepeatInterval string   `json:"repeat_interval"`
+}
+
+// ListAlertEventsRequest 告警事件列表查询请求
+type ListAlertEventsRequest struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+	RuleID   uint   `form:"rule_id"`
+	Status   string `form:"status"` // firing / resolved
+}
+
+// ListAlertEventsResponse 告警事件列表查询响应
+type ListAlertEventsResponse struct {
+	Total int64               `json:"total"`
+	List  []models.AlertEvent `json:"list"`
+}
+
+// AlertChannelInfo 告警渠道信息（不含地址与密钥）
+type AlertChannelInfo struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// ValidateAlertingConfig 校验告警配置
+func ValidateAlertingConfig(cfg config.AlertingConfig) error {
+	if d, err := time.ParseDuration(cfg.Interval); err != nil || d <= 0 {
+		return fmt.Errorf("interval 格式错误: %s", cfg.Interval)
+	}
+	if d, err := time.ParseDuration(cfg.IngestMinInterval); err != nil || d < 0 {
+		return fmt.Errorf("ingest_min_interval 格式错误: %s", cfg.IngestMinInterval)
+	}
+	_, err := alert.NewChannels(cfg.Channels)
+	return err
+}
+
+// ListRules 获取全部告警规则
+func (s *AlertService) ListRules() ([]models.AlertRule, error) {
+	var rules []models.AlertRule
+	if err := database.DB.Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, errors.New("查询告警规则失败")
+	}
+	return rules, nil
+}
+
+// CreateRule 创建告警规则
+func (s *AlertService) CreateRule(req *AlertRuleRequest) (*models.AlertRule, error) {
+	rule := &models.AlertRule{}
+	if err := applyAlertRuleRequest(rule, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Create(rule).Error; err != nil {
+		return nil, errors.New("创建告警规则失败")
+	}
+	return rule, nil
+}
+
+// UpdateRule 更新告警规则（整体替换），规则变更后未恢复的事件在下次评估时按新规则处理
+func (s *AlertService) UpdateRule(id uint, req *AlertRuleRequest) (*models.AlertRule, error) {
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return nil, ErrAlertNotFound
+	}
+	if err := applyAlertRuleRequest(&rule, req); err != nil {
+		return nil, err
+	}
+	if err := database.DB.Save(&rule).Error; err != nil {
+		return nil, errors.New("更新告警规则失败")
+	}
+	return &rule, nil
+}
+
+// DeleteRule 删除告警规则，未恢复的事件标记为已恢复（不发送通知），历史事件保留
+func (s *AlertService) DeleteRule(id uint) error {
+	var rule models.AlertRule
+	if err := database.DB.First(&rule, id).Error; err != nil {
+		return ErrAlertNotFound
+	}
+
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.AlertEvent{}).
+			Where("rule_id = ? AND status = ?", id, models.AlertStatusFiring).
+			Updates(map[string]interface{}{
+				"status":      models.AlertStatusResolved,
+				"resolved_at": time.Now().UTC(),
+			}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&rule).Error
+	})
+}
+
+// applyAlertRuleRequest 校验请求并写入规则
+func applyAlertRuleRequest(rule *models.AlertRule, req *AlertRuleRequest) error {
+	metric := strings.ToLower(req.Metric)
+	switch metric {
+	case models.AlertMetricErrorRate, models.AlertMetricRequestCount, models.AlertMetricLatency, models.AlertMetricNoTraffic:
+	default:
+		return fmt.Errorf("%w: 不支持的指标 %s", ErrAlertParam, req.Metric)
+	}
+
+	window, err := time.ParseDuration(req.Window)
+	if err != nil || window <= 0 || window > maxAlertWindow {
+		return fmt.Errorf("%w: window 格式错误或超过 7 天: %s", ErrAlertParam, req.Window)
+	}
+	if req.Threshold < 0 || (metric == models.AlertMetricErrorRate && req.Threshold > 100) {
+		return fmt.Errorf("%w: threshold 超出范围", ErrAlertParam)
+	}
+	if req.MinRequests < 0 {
+		return fmt.Errorf("%w: min_requests 不能小于 0", ErrAlertParam)
+	}
+
+	percentile := req.Percentile
+	if metric == models.AlertMetricLatency {
+		if percentile == 0 {
+			percentile = 95
+		}
+		if percentile < 0 || percentile > 100 {
+			return fmt.Errorf("%w: percentile 应在 0 ~ 100 之间", ErrAlertParam)
+		}
+	} else {
+		percentile = 0
+	}
+
+	if req.GroupBy != "" && !alertGroupColumns[req.GroupBy] {
+		return fmt.Errorf("%w: 不支持的分组维度 %s", ErrAlertParam, req.GroupBy)
+	}
+
+	if req.RepeatInterval != "" {
+		if d, err := time.ParseDuration(req.RepeatInterval); err != nil || d <= 0 {
+			return fmt.Errorf("%w: repeat_interval 格式错误: %s", ErrAlertParam, req.RepeatInterval)
+		}
+	}
+
+	channels := make(models.StringList, 0, len(req.Channels))
+	configured := configuredAlertChannels()
+	for _, name := range req.Channels {
+		if _, ok := configured[name]; !ok {
+			return fmt.Errorf("%w: 告警渠道 %s 未配置", ErrAlertParam, name)
+		}
+		channels = append(channels, name)
+	}
+
+	enabled := true
+	if req.Enabled != nil {
+		enabled = *req.Enabled
+	}
+
+	rule.Name = req.Name
+	rule.Enabled = enabled
+	rule.Metric = metric
+	rule.Window = req.Window
+	rule.Threshold = req.Threshold
+	rule.Percentile = percentile
+	rule.MinRequests = req.MinRequests
+	rule.GroupBy = req.GroupBy
+	rule.AIModelName = req.AIModelName
+	rule.Authorization = req.Authorization
+	rule.Path = req.Path
+	rule.ServiceID = req.ServiceID
+	rule.Host = req.Host
+	rule.Channels = channels
+	rule.RepeatInterval = req.RepeatInterval
+	return nil
+}
+
+// configuredAlertChannels 配置中的渠道名称与类型（配置已在启动时校验）
+func configuredAlertChannels() map[string]string {
+	channels := make(map[string]string)
+	for _, channel := range config.GetConfig().Alerting.Channels {
+		channels[channel.Name] = strings.ToLower(channel.Type)
+	}
+	return channels
+}
+
+// ListChannels 获取配置的告警渠道
+func (s *AlertService) ListChannels() []AlertChannelInfo {
+	list := make([]AlertChannelInfo, 0)
+	for _, channel := range config.GetConfig().Alerting.Channels {
+		list = append(list, AlertChannelInfo{Name: channel.Name, Type: strings.ToLower(channel.Type)})
+	}
+	return list
+}
+
+// TestChannel 向指定渠道发送一条测试消息
+func (s *AlertService) TestChannel(ctx context.Context, name string) error {
+	channels, err := alert.NewChannels(config.GetConfig().Alerting.Channels)
+	if err != nil {
+		return err
+	}
+	channel, ok := channels[name]
+	if !ok {
+		return fmt.Errorf("%w: 告警渠道 %s 未配置", ErrAlertParam, name)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, alertNotifyTimeout)
+	defer cancel()
+	return channel.Send(ctx, alert.Message{
+		Status:   alert.StatusTest,
+		RuleName: "告警渠道测试",
+		Summary:  fmt.Sprintf("渠道 %s（%s）配置正确", channel.Name(), channel.Type()),
+		Time:     time.Now().UTC(),
+	})
+}
+
+// ListEvents 获取告警事件列表（按触发时间倒序）
+func (s *AlertService) ListEvents(req *ListAlertEventsRequest) (*ListAlertEventsResponse, error) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := database.DB.Model(&models.AlertEvent{})
+	if req.RuleID != 0 {
+		query = query.Where("rule_id = ?", req.RuleID)
+	}
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.New("查询告警事件失败")
+	}
+
+	var list []models.AlertEvent
+	if err := query.
+		Order("fired_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error; err != nil {
+		return nil, errors.New("查询告警事件失败")
+	}
+
+	return &ListAlertEventsResponse{
+		Total: total,
+		List:  list,
+	}, nil
+}
+
+// Schedule 按配置的间隔定时评估告警规则，启用 evaluate_on_ingest 时写入请求日志后也会评估，直到 ctx 结束
+func (s *AlertService) Schedule(ctx context.Context) {
+	cfg := config.GetConfig().Alerting
+	interval, _ := time.ParseDuration(cfg.Interval)
+	minInterval, _ := time.ParseDuration(cfg.IngestMinInterval)
+	logger.Info("告警规则评估已启动", "interval", interval.String(), "evaluate_on_ingest", cfg.EvaluateOnIngest)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var ingest <-chan struct{}
+	if cfg.EvaluateOnIngest {
+		ch, unsubscribe := requestLogNotifier.Subscribe()
+		defer unsubscribe()
+		ingest = ch
+	}
+
+	// 写入触发的评估距离上次不足 minInterval 时延后执行，期间的写入信号合并
+	var last time.Time
+	var delayed <-chan time.Time
+	evaluate := func() {
+		last = time.Now()
+		delayed = nil
+		if err := s.Evaluate(ctx); err != nil && !errors.Is(err, context.Canceled) {
+			logger.Error("告警规则评估失败", "error", err)
+		}
+	}
+
+	evaluate()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			evaluate()
+		case <-ingest:
+			if delayed != nil {
+				continue
+			}
+			if wait := minInterval - time.Since(last); wait > 0 {
+				delayed = time.After(wait)
+				continue
+			}
+			evaluate()
+		case <-delayed:
+			evaluate()
+		}
+	}
+}
+
+// Evaluate 评估全部启用的告警规则，已有评估在执行时直接返回
+func (s *AlertService) Evaluate(ctx context.Context) error {
+	if !alertMu.TryLock() {
+		return nil
+	}
+	defer alertMu.Unlock()
+
+	channels, err := alert.NewChannels(config.GetConfig().Alerting.Channels)
+	if err != nil {
+		return err
+	}
+
+	var rules []models.AlertRule
+	if err := database.DB.Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for i := range rules {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := s.evaluateRule(ctx, &rules[i], channels, now); err != nil {
+			logger.Error("告警规则评估失败", "rule_id", rules[i].ID, "rule", rules[i].Name, "error", err)
+		}
+	}
+	return nil
+}
+
+// evaluateRule 评估单条规则：新超过阈值的分组创建事件并通知，持续超过的按 repeat_interval 重复通知，恢复的分组关闭事件并通知
+func (s *AlertService) evaluateRule(ctx context.Context, rule *models.AlertRule, channels map[string]alert.Channel, now time.Time) error {
+	window, err := time.ParseDuration(rule.Window)
+	if err != nil {
+		return err
+	}
+	var repeat time.Duration
+	if rule.RepeatInterval != "" {
+		repeat, _ = time.ParseDuration(rule.RepeatInterval)
+	}
+
+	var open []models.AlertEvent
+	if err := database.DB.Where("rule_id = ? AND status = ?", rule.ID, models.AlertStatusFiring).Find(&open).Error; err != nil {
+		return err
+	}
+	openByKey := make(map[string]*models.AlertEvent, len(open))
+	for i := range open {
+		openByKey[open[i].GroupKey] = &open[i]
+	}
+
+	breaches, err := s.measure(rule, now.Add(-window), now, openByKey)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range breaches {
+		event, ok := openByKey[key]
+		if ok {
+			delete(openByKey, key)
+			event.Value = value
+			event.Summary = alertSummary(rule, value)
+			if repeat > 0 && (event.LastNotifiedAt == nil || now.Sub(*event.LastNotifiedAt) >= repeat) {
+				s.notify(ctx, rule, channels, event, alert.StatusFiring, now)
+			}
+			if err := database.DB.Save(event).Error; err != nil {
+				return err
+			}
+			continue
+		}
+
+		event = &models.AlertEvent{
+			RuleID:    rule.ID,
+			RuleName:  rule.Name,
+			Metric:    rule.Metric,
+			GroupKey:  key,
+			Status:    models.AlertStatusFiring,
+			Value:     value,
+			Threshold: rule.Threshold,
+			Summary:   alertSummary(rule, value),
+			FiredAt:   now,
+		}
+		if err := database.DB.Create(event).Error; err != nil {
+			return err
+		}
+		logger.Warn("告警触发", "rule_id", rule.ID, "rule", rule.Name, "group", maskAlertGroupKey(rule, key), "value", value)
+		s.notify(ctx, rule, channels, event, alert.StatusFiring, now)
+		if err := database.DB.Save(event).Error; err != nil {
+			return err
+		}
+	}
+
+	// 不再超过阈值的分组恢复
+	for _, event := range openByKey {
+		event.Status = models.AlertStatusResolved
+		event.ResolvedAt = &now
+		logger.Info("告警恢复", "rule_id", rule.ID, "rule", rule.Name, "group", maskAlertGroupKey(rule, event.GroupKey))
+		s.notify(ctx, rule, channels, event, alert.StatusResolved, now)
+		if err := database.DB.Save(event).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// measure 统计窗口内的指标，返回超过阈值的分组及其值（未分组时键为空串）
+// open 为规则仍在告警中的事件，分组的 no_traffic 规则据此继续检查回看范围内已不再出现的分组
+func (s *AlertService) measure(rule *models.AlertRule, start, end time.Time, open map[string]*models.AlertEvent) (map[string]float64, error) {
+	newRangeQuery := func(from, to time.Time) *gorm.DB {
+		query := database.DB.Model(&models.TokenUsageLog{}).
+			Where("time >= ?", from).
+			Where("time <= ?", to)
+		if rule.AIModelName != "" {
+			query = query.Where("ai_model_name = ?", rule.AIModelName)
+		}
+		if rule.Authorization != "" {
+			query = query.Where(`"authorization" = ?`, rule.Authorization)
+		}
+		if rule.Path != "" {
+			query = query.Where("path LIKE ?", rule.Path+"%")
+		}
+		return applyOriginFilters(query, rule.ServiceID, rule.Host)
+	}
+	newQuery := func() *gorm.DB {
+		return newRangeQuery(start, end)
+	}
+
+	// 分组列名由 Group 自动加引号，原生 SQL 中手动加引号（authorization 是保留字）
+	groupColumn := rule.GroupBy
+	groupSelect := "'' AS group_key"
+	if groupColumn != "" {
+		groupSelect = `"` + groupColumn + `" AS group_key`
+	}
+	grouped := func(query *gorm.DB) *gorm.DB {
+		if groupColumn != "" {
+			query = query.Group(groupColumn)
+		}
+		return query
+	}
+
+	type groupResult struct {
+		GroupKey string
+		Total    int64
+		Errors   int64
+	}
+
+	minRequests := rule.MinRequests
+	if minRequests < 1 {
+		minRequests = 1
+	}
+
+	breaches := make(map[string]float64)
+	switch rule.Metric {
+	case models.AlertMetricNoTraffic:
+		if groupColumn == "" {
+			var count int64
+			if err := newQuery().Count(&count).Error; err != nil {
+				return nil, err
+			}
+			if count == 0 {
+				breaches[""] = 0
+			}
+			break
+		}
+
+		// 分组时检查回看范围内出现过的分组（以及仍在告警中的分组），窗口内没有请求的告警；字段为空的日志不参与
+		nonEmpty := `"` + groupColumn + `" <> ''`
+		var known, active []string
+		if err := newRangeQuery(start.Add(-noTrafficLookback), start).Where(nonEmpty).Group(groupColumn).Pluck(groupColumn, &known).Error; err != nil {
+			return nil, err
+		}
+		if err := newQuery().Where(nonEmpty).Group(groupColumn).Pluck(groupColumn, &active).Error; err != nil {
+			return nil, err
+		}
+		for key := range open {
+			known = append(known, key)
+		}
+		seen := make(map[string]bool, len(active))
+		for _, key := range active {
+			seen[key] = true
+		}
+		for _, key := range known {
+			if !seen[key] {
+				breaches[key] = 0
+			}
+		}
+
+	case models.AlertMetricErrorRate, models.AlertMetricRequestCount:
+		var results []groupResult
+		if err := grouped(newQuery().Select(
+			groupSelect,
+			"COUNT(*) AS total",
+			"COALESCE(SUM(CASE WHEN status >= 400 THEN 1 ELSE 0 END), 0) AS errors",
+		)).Scan(&results).Error; err != nil {
+			return nil, err
+		}
+		for _, r := range results {
+			if rule.Metric == models.AlertMetricRequestCount {
+				if float64(r.Total) > rule.Threshold {
+					breaches[r.GroupKey] = float64(r.Total)
+				}
+				continue
+			}
+			if r.Total < minRequests {
+				continue
+			}
+			rate := math.Round(float64(r.Errors)*10000/float64(r.Total)) / 100
+			if rate > rule.Threshold {
+				breaches[r.GroupKey] = rate
+			}
+		}
+
+	case models.AlertMetricLatency:
+		group := ""
+		if groupColumn != "" {
+			group = `"` + groupColumn + `"`
+		}
+		point := percentilePoint(rule.Percentile)
+		rows, err := rankedValues(newQuery(), "latency_ms", group, []int64{point})
+		if err != nil {
+			return nil, err
+		}
+		byGroup := make(map[string][]rankedValue)
+		for _, row := range rows {
+			byGroup[row.GroupKey] = append(byGroup[row.GroupKey], row)
+		}
+		for key, rows := range byGroup {
+			if rows[0].Total < minRequests {
+				continue
+			}
+			if value := valueAtPoint(rows, point); float64(value) > rule.Threshold {
+				breaches[key] = float64(value)
+			}
+		}
+	}
+	return breaches, nil
+}
+
+// alertSummary 生成告警描述
+func alertSummary(rule *models.AlertRule, value float64) string {
+	switch rule.Metric {
+	case models.AlertMetricErrorRate:
+		return fmt.Sprintf("最近 %s 错误率 %.2f%%，超过阈值 %.2f%%", rule.Window, value, rule.Threshold)
+	case models.AlertMetricRequestCount:
+		return fmt.Sprintf("最近 %s 请求数 %.0f，超过阈值 %.0f", rule.Window, value, rule.Threshold)
+	case models.AlertMetricLatency:
+		return fmt.Sprintf("最近 %s P%g 延迟 %.0fms，超过阈值 %.0fms", rule.Window, rule.Percentile, value, rule.Threshold)
+	case models.AlertMetricNoTraffic:
+		return fmt.Sprintf("最近 %s 没有请求", rule.Window)
+	default:
+		return rule.Metric
+	}
+}
+
+// notify 发送通知并记录到事件，规则未指定渠道时发送到全部渠道
+func (s *AlertService) notify(ctx context.Context, rule *models.AlertRule, channels map[string]alert.Channel, event *models.AlertEvent, status string, now time.Time) {
+	targets := make([]alert.Channel, 0, len(channels))
+	if len(rule.Channels) == 0 {
+		for _, channel := range channels {
+			targets = append(targets, channel)
+		}
+	} else {
+		for _, name := range rule.Channels {
+			if channel, ok := channels[name]; ok {
+				targets = append(targets, channel)
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return
+	}
+
+	summary := event.Summary
+	if status == alert.StatusResolved {
+		summary = fmt.Sprintf("告警已恢复，持续 %s", now.Sub(event.FiredAt).Round(time.Second))
+	}
+	msg := alert.Message{
+		Status:    status,
+		RuleID:    rule.ID,
+		RuleName:  rule.Name,
+		Metric:    rule.Metric,
+		GroupKey:  maskAlertGroupKey(rule, event.GroupKey),
+		Value:     event.Value,
+		Threshold: event.Threshold,
+		Summary:   summary,
+		Time:      now,
+	}
+
+	var errs []string
+	for _, channel := range targets {
+		sendCtx, cancel := context.WithTimeout(ctx, alertNotifyTimeout)
+		err := channel.Send(sendCtx, msg)
+		cancel()
+		if err != nil {
+			logger.Error("告警通知发送失败", "rule_id", rule.ID, "channel", channel.Name(), "error", err)
+			errs = append(errs, channel.Name()+": "+err.Error())
+		}
+	}
+
+	event.LastNotifiedAt = &now
+	event.NotifyCount++
+	event.NotifyError = strings.Join(errs, "; ")
+	if runes := []rune(event.NotifyError); len(runes) > 500 {
+		event.NotifyError = string(runes[:500])
+	}
+}
+
+// maskAlertGroupKey 按 Token 分组时隐藏 Token 中间部分，避免在通知中泄露完整 Token
+func maskAlertGroupKey(rule *models.AlertRule, key string) string {
+	if rule.GroupBy != "authorization" || len(key) <= 12 {
+		return key
+	}
+	return key[:8] + "..." + key[len(key)-4:]
+}
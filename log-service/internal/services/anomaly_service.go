@@ -0,0 +1,691 @@
+// Package services 业务逻辑服务层
+// Token 异常使用检测：按 Token 检测来源 IP / User-Agent 过多、请求量突增与夜间突发，评分后记录，可选自动禁用 Token
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// anomalyMu 保证同一时间只有一次检测（定时、手动共用）
+var anomalyMu sync.Mutex
+
+// anomalyDisableTimeout 调用管理服务禁用 Token 的超时时间
+const anomalyDisableTimeout = 10 * time.Second
+
+// anomalySampleSize 每条检测结果保留的 IP / UA 样本数
+const anomalySampleSize = 10
+
+// maxAnomalyWindow 检测窗口上限
+const maxAnomalyWindow = 24 * time.Hour
+
+// ErrAnomalyScanRunning 已有检测在执行
+var ErrAnomalyScanRunning = errors.New("异常检测正在执行，请稍后再试")
+
+// AnomalyService Token 异常使用检测服务
+type AnomalyService struct{}
+
+// NewAnomalyService 创建异常检测服务实例
+func NewAnomalyService() *AnomalyService {
+	return &AnomalyService{}
+}
+
+// ListTokenAnomaliesRequest 异常检测结果列表查询请求
+type ListTokenAnomaliesRequest struct {
+	Page          int    `form:"page"`
+	PageSize      int    `form:"page_size"`
+	Authorization string `form:"authorization"` // Token（模糊匹配）
+	Kind          string `form:"kind"`          // distinct_ips / distinct_user_agents / volume_spike / night_burst
+	MinScore      int    `form:"min_score"`     // 最低评分
+	Action        string `form:"action"`        // disabled / failed
+}
+
+// ListTokenAnomaliesResponse 异常检测结果列表查询响应
+type ListTokenAnomaliesResponse struct {
+	Total int64                 `json:"total"`
+	List  []models.TokenAnomaly `json:"list"`
+}
+
+// AnomalyScanResult 一次检测的结果
+type AnomalyScanResult struct {
+	WindowStart time.Time `json:"window_start"`
+	WindowEnd   time.Time `json:"window_end"`
+	Findings    int       `json:"findings"` // 命中数（新建 + 更新）
+	Created     int       `json:"created"`  // 新建的检测结果数
+	Disabled    int       `json:"disabled"` // 本次自动禁用的 Token 数
+}
+
+// anomalyFinding 检测命中
+type anomalyFinding struct {
+	authorization string
+	kind          string
+	value         float64
+	threshold     float64
+	baseline      float64
+	samples       []string
+	summary       string
+}
+
+// anomalyCount 按 Token 统计的数量
+type anomalyCount struct {
+	Authorization string
+	Count         int64
+}
+
+// timeRange 左闭右开的时间区间
+type timeRange struct {
+	start time.Time
+	end   time.Time
+}
+
+// ValidateAnomalyConfig 校验异常检测配置
+func ValidateAnomalyConfig(cfg config.AnomalyConfig) error {
+	if d, err := time.ParseDuration(cfg.Interval); err != nil || d <= 0 {
+		return fmt.Errorf("interval 格式错误: %s", cfg.Interval)
+	}
+	window, err := time.ParseDuration(cfg.Window)
+	if err != nil || window <= 0 {
+		return fmt.Errorf("window 格式错误: %s", cfg.Window)
+	}
+	if window > maxAnomalyWindow {
+		return fmt.Errorf("window 不能超过 %s", maxAnomalyWindow)
+	}
+	if cfg.MaxDistinctIPs < 0 || cfg.MaxDistinctUserAgents < 0 {
+		return errors.New("max_distinct_ips / max_distinct_user_agents 不能为负数")
+	}
+	if cfg.BaselineDays < 1 || cfg.BaselineDays > 90 {
+		return errors.New("baseline_days 取值范围为 1-90")
+	}
+	if cfg.SpikeRatio != 0 && cfg.SpikeRatio <= 1 {
+		return errors.New("spike_ratio 必须大于 1，0 表示不检测请求量突增")
+	}
+	if cfg.SpikeRatio > 0 && cfg.SpikeMinRequests <= 0 {
+		return errors.New("启用请求量突增检测时 spike_min_requests 必须大于 0")
+	}
+	if cfg.NightMinRequests < 0 {
+		return errors.New("night_min_requests 不能为负数")
+	}
+	if cfg.NightStartHour < 0 || cfg.NightStartHour > 23 || cfg.NightEndHour < 0 || cfg.NightEndHour > 23 {
+		return errors.New("night_start_hour / night_end_hour 取值范围为 0-23")
+	}
+	if cfg.NightMinRequests > 0 && cfg.NightStartHour == cfg.NightEndHour {
+		return errors.New("night_start_hour 与 night_end_hour 不能相同")
+	}
+	if auto := cfg.AutoDisable; auto.Enabled {
+		if auto.ServerURL == "" || auto.SystemToken == "" {
+			return errors.New("启用自动禁用时 auto_disable.server_url 与 auto_disable.system_token 必填")
+		}
+		if auto.MinScore < 1 || auto.MinScore > 100 {
+			return errors.New("auto_disable.min_score 取值范围为 1-100")
+		}
+	}
+	return nil
+}
+
+// ListAnomalies 获取异常检测结果列表（按最近命中时间倒序）
+func (s *AnomalyService) ListAnomalies(req *ListTokenAnomaliesRequest) (*ListTokenAnomaliesResponse, error) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := database.DB.Model(&models.TokenAnomaly{})
+	if req.Authorization != "" {
+		query = query.Where(`"authorization" LIKE ?`, "%"+req.Authorization+"%")
+	}
+	if req.Kind != "" {
+		query = query.Where("kind = ?", req.Kind)
+	}
+	if req.MinScore > 0 {
+		query = query.Where("score >= ?", req.MinScore)
+	}
+	if req.Action != "" {
+		query = query.Where("action = ?", req.Action)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.New("查询异常检测结果失败")
+	}
+
+	var list []models.TokenAnomaly
+	if err := query.
+		Order("last_detected_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error; err != nil {
+		return nil, errors.New("查询异常检测结果失败")
+	}
+
+	return &ListTokenAnomaliesResponse{
+		Total: total,
+		List:  list,
+	}, nil
+}
+
+// Schedule 按配置的间隔定时检测，直到 ctx 结束
+func (s *AnomalyService) Schedule(ctx context.Context) {
+	interval, _ := time.ParseDuration(config.GetConfig().Anomaly.Interval)
+	logger.Info("Token 异常检测已启动", "interval", interval.String())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Scan(ctx); err != nil && !errors.Is(err, ErrAnomalyScanRunning) && !errors.Is(err, context.Canceled) {
+			logger.Error("Token 异常检测失败", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scan 对最近一个检测窗口执行一次检测，记录命中结果并按配置自动禁用 Token
+// 已有检测在执行时返回 ErrAnomalyScanRunning
+func (s *AnomalyService) Scan(ctx context.Context) (*AnomalyScanResult, error) {
+	if !anomalyMu.TryLock() {
+		return nil, ErrAnomalyScanRunning
+	}
+	defer anomalyMu.Unlock()
+
+	cfg := config.GetConfig().Anomaly
+	window, _ := time.ParseDuration(cfg.Window)
+	loc, err := utils.LoadTimeZone("")
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	result := &AnomalyScanResult{
+		WindowStart: now.Add(-window),
+		WindowEnd:   now,
+	}
+
+	var findings []anomalyFinding
+	detectors := []func() ([]anomalyFinding, error){
+		func() ([]anomalyFinding, error) {
+			return s.detectDistinct(models.AnomalyKindDistinctIPs, "x_forwarded_for", cfg.MaxDistinctIPs, cfg.Window, result.WindowStart, now)
+		},
+		func() ([]anomalyFinding, error) {
+			return s.detectDistinct(models.AnomalyKindDistinctUserAgents, "user_agent", cfg.MaxDistinctUserAgents, cfg.Window, result.WindowStart, now)
+		},
+		func() ([]anomalyFinding, error) {
+			return s.detectVolumeSpike(cfg, window, result.WindowStart, now)
+		},
+		func() ([]anomalyFinding, error) {
+			return s.detectNightBurst(cfg, loc, result.WindowStart, now)
+		},
+	}
+	for _, detect := range detectors {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		found, err := detect()
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, found...)
+	}
+
+	// 同一 Token 在一次检测中只调用一次禁用，处置结果记录到该 Token 所有达到评分的检测结果上
+	actions := make(map[string]error)
+	for i := range findings {
+		anomaly, created, err := s.record(&findings[i], window, now)
+		if err != nil {
+			return nil, err
+		}
+		result.Findings++
+		if created {
+			result.Created++
+			logger.Warn("检测到 Token 异常使用",
+				"kind", anomaly.Kind,
+				"authorization", maskToken(anomaly.Authorization),
+				"score", anomaly.Score,
+				"summary", anomaly.Summary,
+			)
+		}
+
+		if !cfg.AutoDisable.Enabled || anomaly.Score < cfg.AutoDisable.MinScore ||
+			anomaly.Action == models.AnomalyActionDisabled {
+			continue
+		}
+		actionErr, done := actions[anomaly.Authorization]
+		if !done {
+			actionErr = s.disableToken(ctx, cfg.AutoDisable, anomaly)
+			actions[anomaly.Authorization] = actionErr
+			if actionErr != nil {
+				logger.Error("自动禁用 Token 失败", "authorization", maskToken(anomaly.Authorization), "error", actionErr)
+			} else {
+				logger.Warn("已自动禁用异常 Token", "authorization", maskToken(anomaly.Authorization), "score", anomaly.Score)
+				result.Disabled++
+			}
+		}
+		if actionErr != nil {
+			anomaly.Action = models.AnomalyActionFailed
+			anomaly.ActionError = actionErr.Error()
+			if runes := []rune(anomaly.ActionError); len(runes) > 500 {
+				anomaly.ActionError = string(runes[:500])
+			}
+		} else {
+			anomaly.Action = models.AnomalyActionDisabled
+			anomaly.ActionError = ""
+		}
+		if err := database.DB.Model(anomaly).Updates(map[string]interface{}{
+			"action":       anomaly.Action,
+			"action_error": anomaly.ActionError,
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return result, nil
+}
+
+// detectDistinct 检测窗口内某一列（来源 IP / User-Agent）不同取值数超过上限的 Token，limit 为 0 时不检测
+func (s *AnomalyService) detectDistinct(kind, column string, limit int, windowText string, start, end time.Time) ([]anomalyFinding, error) {
+	if limit <= 0 {
+		return nil, nil
+	}
+
+	var counts []anomalyCount
+	if err := windowLogs(start, end).
+		Select(`"authorization", COUNT(DISTINCT `+column+`) AS count`).
+		Where(column+" != ''").
+		Group("authorization").
+		Having("COUNT(DISTINCT "+column+") > ?", limit).
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+
+	label := "IP"
+	if kind == models.AnomalyKindDistinctUserAgents {
+		label = "User-Agent"
+	}
+
+	findings := make([]anomalyFinding, 0, len(counts))
+	for _, c := range counts {
+		samples, err := s.topValues(column, c.Authorization, start, end)
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, anomalyFinding{
+			authorization: c.Authorization,
+			kind:          kind,
+			value:         float64(c.Count),
+			threshold:     float64(limit),
+			samples:       samples,
+			summary:       fmt.Sprintf("%s 内来自 %d 个不同 %s（阈值 %d）", windowText, c.Count, label, limit),
+		})
+	}
+	return findings, nil
+}
+
+// topValues Token 在窗口内请求最多的若干个取值，格式为 "取值 (请求数)"
+func (s *AnomalyService) topValues(column, authorization string, start, end time.Time) ([]string, error) {
+	type result struct {
+		Value string
+		Count int64
+	}
+	var results []result
+	if err := windowLogs(start, end).
+		Select(column+" AS value, COUNT(*) AS count").
+		Where(`"authorization" = ?`, authorization).
+		Where(column + " != ''").
+		Group(column).
+		Order("count DESC").
+		Limit(anomalySampleSize).
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	samples := make([]string, 0, len(results))
+	for _, r := range results {
+		samples = append(samples, fmt.Sprintf("%s (%d)", r.Value, r.Count))
+	}
+	return samples, nil
+}
+
+// detectVolumeSpike 检测窗口请求数超过自身基线 spike_ratio 倍的 Token
+// 基线为窗口之前 baseline_days 天（按小时预聚合）折算到窗口长度的平均请求数，没有历史的 Token 只按 spike_min_requests 判定
+func (s *AnomalyService) detectVolumeSpike(cfg config.AnomalyConfig, window time.Duration, start, end time.Time) ([]anomalyFinding, error) {
+	if cfg.SpikeRatio <= 0 {
+		return nil, nil
+	}
+
+	var counts []anomalyCount
+	if err := windowLogs(start, end).
+		Select(`"authorization", COUNT(*) AS count`).
+		Group("authorization").
+		Having("COUNT(*) >= ?", cfg.SpikeMinRequests).
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, nil
+	}
+
+	baselineEnd := hourBucket(start)
+	baselineStart := baselineEnd.Add(-time.Duration(cfg.BaselineDays) * 24 * time.Hour)
+	totals, err := baselineRequests(candidateTokens(counts), baselineStart, baselineEnd, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []anomalyFinding
+	for _, c := range counts {
+		baseline := float64(totals[c.Authorization]) * float64(window) / float64(baselineEnd.Sub(baselineStart))
+		threshold := math.Max(float64(cfg.SpikeMinRequests), baseline*cfg.SpikeRatio)
+		if float64(c.Count) < threshold {
+			continue
+		}
+		findings = append(findings, anomalyFinding{
+			authorization: c.Authorization,
+			kind:          models.AnomalyKindVolumeSpike,
+			value:         float64(c.Count),
+			threshold:     threshold,
+			baseline:      baseline,
+			summary: fmt.Sprintf("%s 内请求 %d 次，基线 %.1f 次（前 %d 天平均），阈值 %.0f",
+				cfg.Window, c.Count, baseline, cfg.BaselineDays, threshold),
+		})
+	}
+	return findings, nil
+}
+
+// detectNightBurst 检测窗口内夜间时段请求数达到 night_min_requests 的 Token
+// 平时夜间就有同等请求量的 Token（如夜间批处理）不判定：阈值取 night_min_requests 与夜间基线 spike_ratio 倍中的较大值
+func (s *AnomalyService) detectNightBurst(cfg config.AnomalyConfig, loc *time.Location, start, end time.Time) ([]anomalyFinding, error) {
+	if cfg.NightMinRequests <= 0 {
+		return nil, nil
+	}
+
+	ranges := nightRanges(start, end, loc, cfg.NightStartHour, cfg.NightEndHour)
+	if len(ranges) == 0 {
+		return nil, nil
+	}
+	var conditions []string
+	var args []interface{}
+	var nightDuration time.Duration
+	for _, r := range ranges {
+		conditions = append(conditions, "(time >= ? AND time < ?)")
+		args = append(args, r.start, r.end)
+		nightDuration += r.end.Sub(r.start)
+	}
+
+	var counts []anomalyCount
+	if err := windowLogs(start, end).
+		Select(`"authorization", COUNT(*) AS count`).
+		Where(strings.Join(conditions, " OR "), args...).
+		Group("authorization").
+		Having("COUNT(*) >= ?", cfg.NightMinRequests).
+		Scan(&counts).Error; err != nil {
+		return nil, err
+	}
+	if len(counts) == 0 {
+		return nil, nil
+	}
+
+	baselineEnd := hourBucket(start)
+	baselineStart := baselineEnd.Add(-time.Duration(cfg.BaselineDays) * 24 * time.Hour)
+	isNight := func(bucket time.Time) bool {
+		return isNightHour(bucket.In(loc).Hour(), cfg.NightStartHour, cfg.NightEndHour)
+	}
+	totals, err := baselineRequests(candidateTokens(counts), baselineStart, baselineEnd, isNight)
+	if err != nil {
+		return nil, err
+	}
+
+	nightHours := (cfg.NightEndHour - cfg.NightStartHour + 24) % 24
+	var findings []anomalyFinding
+	for _, c := range counts {
+		// 每晚平均请求数折算到窗口内的夜间时长
+		baseline := float64(totals[c.Authorization]) / float64(cfg.BaselineDays) *
+			float64(nightDuration) / float64(time.Duration(nightHours)*time.Hour)
+		threshold := float64(cfg.NightMinRequests)
+		if cfg.SpikeRatio > 0 {
+			threshold = math.Max(threshold, baseline*cfg.SpikeRatio)
+		}
+		if float64(c.Count) < threshold {
+			continue
+		}
+		findings = append(findings, anomalyFinding{
+			authorization: c.Authorization,
+			kind:          models.AnomalyKindNightBurst,
+			value:         float64(c.Count),
+			threshold:     threshold,
+			baseline:      baseline,
+			summary: fmt.Sprintf("夜间（%02d:00-%02d:00）请求 %d 次，基线 %.1f 次（前 %d 天平均），阈值 %.0f",
+				cfg.NightStartHour, cfg.NightEndHour, c.Count, baseline, cfg.BaselineDays, threshold),
+		})
+	}
+	return findings, nil
+}
+
+// record 保存检测命中：同一 Token、同一类型上次命中距今不超过一个窗口时更新原记录，否则新建
+func (s *AnomalyService) record(f *anomalyFinding, window time.Duration, now time.Time) (*models.TokenAnomaly, bool, error) {
+	score := anomalyScore(f.value, f.threshold)
+	summary := f.summary
+	if runes := []rune(summary); len(runes) > 500 {
+		summary = string(runes[:500])
+	}
+
+	var anomaly models.TokenAnomaly
+	err := database.DB.
+		Where(`"authorization" = ? AND kind = ? AND window_end >= ?`, f.authorization, f.kind, now.Add(-window)).
+		Order("id DESC").
+		First(&anomaly).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+
+	if err == nil {
+		anomaly.Score = score
+		anomaly.Value = f.value
+		anomaly.Threshold = f.threshold
+		anomaly.Baseline = f.baseline
+		anomaly.Samples = f.samples
+		anomaly.Summary = summary
+		anomaly.WindowEnd = now
+		anomaly.Occurrences++
+		anomaly.LastDetectedAt = now
+		if err := database.DB.Save(&anomaly).Error; err != nil {
+			return nil, false, err
+		}
+		return &anomaly, false, nil
+	}
+
+	anomaly = models.TokenAnomaly{
+		Authorization:  f.authorization,
+		Kind:           f.kind,
+		Score:          score,
+		Value:          f.value,
+		Threshold:      f.threshold,
+		Baseline:       f.baseline,
+		Samples:        f.samples,
+		Summary:        summary,
+		WindowStart:    now.Add(-window),
+		WindowEnd:      now,
+		Occurrences:    1,
+		DetectedAt:     now,
+		LastDetectedAt: now,
+	}
+	if err := database.DB.Create(&anomaly).Error; err != nil {
+		return nil, false, err
+	}
+	return &anomaly, true, nil
+}
+
+// disableToken 调用管理服务的系统认证接口禁用 Token
+func (s *AnomalyService) disableToken(ctx context.Context, cfg config.AnomalyAutoDisableConfig, anomaly *models.TokenAnomaly) error {
+	token := strings.TrimSpace(anomaly.Authorization)
+	if parts := strings.SplitN(token, " ", 2); len(parts) == 2 && strings.EqualFold(parts[0], "bearer") {
+		token = strings.TrimSpace(parts[1])
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"token":  token,
+		"reason": fmt.Sprintf("异常检测: %s，评分 %d", anomaly.Summary, anomaly.Score),
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, anomalyDisableTimeout)
+	defer cancel()
+
+	url := strings.TrimRight(cfg.ServerURL, "/") + "/api/tokens/disable"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.SystemToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return fmt.Errorf("管理服务返回 HTTP %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || result.Code != 0 {
+		return fmt.Errorf("管理服务返回 HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+	return nil
+}
+
+// windowLogs 窗口内带 Token 的请求日志查询
+func windowLogs(start, end time.Time) *gorm.DB {
+	return database.DB.Model(&models.TokenUsageLog{}).
+		Where("time >= ? AND time < ?", start, end).
+		Where(`"authorization" != ''`)
+}
+
+// candidateTokens 命中初筛的 Token 列表
+func candidateTokens(counts []anomalyCount) []string {
+	tokens := make([]string, 0, len(counts))
+	for _, c := range counts {
+		tokens = append(tokens, c.Authorization)
+	}
+	return tokens
+}
+
+// baselineRequests 按小时预聚合统计 Token 在基线时段内的请求数，include 不为空时只统计其返回 true 的小时
+func baselineRequests(tokens []string, start, end time.Time, include func(bucket time.Time) bool) (map[string]int64, error) {
+	type result struct {
+		Authorization string
+		BucketStart   time.Time
+		Count         int64
+	}
+	var results []result
+	if err := database.DB.Model(&models.UsageRollup{}).
+		Select(`"authorization", bucket_start, SUM(request_count) AS count`).
+		Where("granularity = ?", models.RollupGranularityHour).
+		Where("bucket_start >= ? AND bucket_start < ?", start, end).
+		Where(`"authorization" IN ?`, tokens).
+		Group(`"authorization", bucket_start`).
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[string]int64, len(tokens))
+	for _, r := range results {
+		if include != nil && !include(r.BucketStart) {
+			continue
+		}
+		totals[r.Authorization] += r.Count
+	}
+	return totals, nil
+}
+
+// nightRanges [start, end) 与每天夜间时段（按 loc 的 startHour 到 endHour，可跨零点）的交集
+func nightRanges(start, end time.Time, loc *time.Location, startHour, endHour int) []timeRange {
+	var ranges []timeRange
+	add := func(from, to time.Time) {
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if from.Before(to) {
+			ranges = append(ranges, timeRange{start: from.UTC(), end: to.UTC()})
+		}
+	}
+
+	local := start.In(loc)
+	day := time.Date(local.Year(), local.Month(), local.Day()-1, 0, 0, 0, 0, loc)
+	for !day.After(end) {
+		at := func(hour int) time.Time {
+			return time.Date(day.Year(), day.Month(), day.Day(), hour, 0, 0, 0, loc)
+		}
+		if startHour < endHour {
+			add(at(startHour), at(endHour))
+		} else {
+			// 跨零点：当天 startHour 到次日 endHour
+			add(at(startHour), time.Date(day.Year(), day.Month(), day.Day()+1, endHour, 0, 0, 0, loc))
+		}
+		day = time.Date(day.Year(), day.Month(), day.Day()+1, 0, 0, 0, 0, loc)
+	}
+	return ranges
+}
+
+// isNightHour 小时是否在夜间时段内
+func isNightHour(hour, startHour, endHour int) bool {
+	if startHour < endHour {
+		return hour >= startHour && hour < endHour
+	}
+	return hour >= startHour || hour < endHour
+}
+
+// anomalyScore 可疑程度评分：刚超过阈值为 50，达到阈值 2 倍及以上为 100
+func anomalyScore(value, threshold float64) int {
+	if threshold <= 0 {
+		return 100
+	}
+	score := int(math.Round(50 * value / threshold))
+	if score > 100 {
+		score = 100
+	}
+	return score
+}
+
+// maskToken 隐藏 Token 中间部分，避免在日志中泄露完整 Token
+func maskToken(token string) string {
+	if len(token) <= 12 {
+		return token
+	}
+	return token[:8] + "..." + token[len(token)-4:]
+}
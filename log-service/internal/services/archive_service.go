@@ -0,0 +1,348 @@
+// Package services 业务逻辑服务层
+// 清理前将待删除的日志归档到冷存储，需要排查时将归档重新导入临时表查询
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/archive"
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// archiveImportBatchSize 重新导入时每批插入的记录数
+const archiveImportBatchSize = 1000
+
+// archiveDateLayout 归档日期参数格式（UTC 分区日期）
+const archiveDateLayout = "2006-01-02"
+
+// archiveTables 支持归档的表及其模型
+var archiveTables = map[string]interface{}{
+	"token_usage_logs": &models.TokenUsageLog{},
+	"system_logs":      &models.SystemLog{},
+}
+
+var (
+	// ErrArchiveParam 归档接口参数错误
+	ErrArchiveParam = errors.New("参数错误")
+	// ErrArchiveNotFound 归档文件或导入记录不存在
+	ErrArchiveNotFound = errors.New("归档不存在")
+)
+
+// ArchiveService 冷归档服务
+type ArchiveService struct{}
+
+// NewArchiveService 创建冷归档服务实例
+func NewArchiveService() *ArchiveService {
+	return &ArchiveService{}
+}
+
+// ListArchivesRequest 归档文件列表查询请求
+type ListArchivesRequest struct {
+	Table     string `form:"table" binding:"required"` // token_usage_logs / system_logs
+	StartDate string `form:"start_date"`               // YYYY-MM-DD（UTC），为空表示不限
+	EndDate   string `form:"end_date"`                 // YYYY-MM-DD（UTC），为空表示不限
+}
+
+// CreateImportRequest 归档重新导入请求
+type CreateImportRequest struct {
+	Table     string `json:"table" binding:"required"`      // token_usage_logs / system_logs
+	StartDate string `json:"start_date" binding:"required"` // YYYY-MM-DD（UTC，含）
+	EndDate   string `json:"end_date" binding:"required"`   // YYYY-MM-DD（UTC，含）
+}
+
+// ValidateArchiveConfig 校验冷归档配置
+func ValidateArchiveConfig(cfg config.ArchiveConfig) error {
+	ttl, err := time.ParseDuration(cfg.ImportTTL)
+	if err != nil || ttl <= 0 {
+		return fmt.Errorf("import_ttl 格式错误: %s", cfg.ImportTTL)
+	}
+	_, err = archive.NewStore(cfg)
+	return err
+}
+
+// parseArchiveDateRange 解析归档日期范围，为空时不限
+func parseArchiveDateRange(startDate, endDate string) (time.Time, time.Time, error) {
+	start := time.Time{}
+	end := time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+	var err error
+	if startDate != "" {
+		if start, err = time.Parse(archiveDateLayout, startDate); err != nil {
+			return start, end, fmt.Errorf("%w: 开始日期格式错误，应为 YYYY-MM-DD", ErrArchiveParam)
+		}
+	}
+	if endDate != "" {
+		if end, err = time.Parse(archiveDateLayout, endDate); err != nil {
+			return start, end, fmt.Errorf("%w: 结束日期格式错误，应为 YYYY-MM-DD", ErrArchiveParam)
+		}
+	}
+	if end.Before(start) {
+		return start, end, fmt.Errorf("%w: 结束日期不能早于开始日期", ErrArchiveParam)
+	}
+	return start, end, nil
+}
+
+// archiveModel 获取表对应的模型
+func archiveModel(table string) (interface{}, error) {
+	model, ok := archiveTables[table]
+	if !ok {
+		return nil, fmt.Errorf("%w: 不支持的表 %s", ErrArchiveParam, table)
+	}
+	return model, nil
+}
+
+// ListArchives 列出日期范围内的归档文件
+func (s *ArchiveService) ListArchives(ctx context.Context, req *ListArchivesRequest) ([]archive.Object, error) {
+	if _, err := archiveModel(req.Table); err != nil {
+		return nil, err
+	}
+	start, end, err := parseArchiveDateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.GetConfig().Archive
+	store, err := archive.NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := archive.ListRange(ctx, store, cfg.Prefix, req.Table, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档列表失败: %w", err)
+	}
+	if objects == nil {
+		objects = []archive.Object{}
+	}
+	return objects, nil
+}
+
+// CreateImport 将日期范围内的归档文件导入一张新的临时表，导入完成后可通过 archive 参数查询
+// 临时表在 import_ttl 后过期，由清理任务删除
+func (s *ArchiveService) CreateImport(ctx context.Context, req *CreateImportRequest) (*models.ArchiveImport, error) {
+	model, err := archiveModel(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	start, end, err := parseArchiveDateRange(req.StartDate, req.EndDate)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := config.GetConfig().Archive
+	ttl, err := time.ParseDuration(cfg.ImportTTL)
+	if err != nil {
+		return nil, fmt.Errorf("import_ttl 格式错误: %s", cfg.ImportTTL)
+	}
+	store, err := archive.NewStore(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	objects, err := archive.ListRange(ctx, store, cfg.Prefix, req.Table, start, end)
+	if err != nil {
+		return nil, fmt.Errorf("读取归档列表失败: %w", err)
+	}
+	if len(objects) == 0 {
+		return nil, fmt.Errorf("%w: %s 在 %s ~ %s 没有归档文件", ErrArchiveNotFound, req.Table, req.StartDate, req.EndDate)
+	}
+
+	// 先创建记录以获得 ID 作为临时表名，导入完成前 files 为 0，查询时视为未完成
+	record := &models.ArchiveImport{
+		SourceTable: req.Table,
+		StartDate:   start.Format(archiveDateLayout),
+		EndDate:     end.Format(archiveDateLayout),
+		ExpiresAt:   time.Now().UTC().Add(ttl),
+	}
+	if err := database.DB.Create(record).Error; err != nil {
+		return nil, fmt.Errorf("创建导入记录失败: %w", err)
+	}
+	record.ImportTable = fmt.Sprintf("archive_%s_%d", req.Table, record.ID)
+
+	rows, err := s.load(ctx, store, objects, model, record.ImportTable)
+	if err != nil {
+		s.drop(record)
+		return nil, err
+	}
+
+	record.Files = len(objects)
+	record.Rows = rows
+	if err := database.DB.Model(record).Select("import_table", "files", "rows").Updates(record).Error; err != nil {
+		s.drop(record)
+		return nil, fmt.Errorf("保存导入记录失败: %w", err)
+	}
+
+	logger.Info("归档重新导入完成", "table", req.Table, "import_table", record.ImportTable, "files", record.Files, "rows", rows)
+	return record, nil
+}
+
+// load 创建临时表并逐个读取归档文件分批插入，返回插入的记录数
+// 同一记录可能被多次归档（归档后删除失败、下次清理重新归档），按主键忽略重复
+func (s *ArchiveService) load(ctx context.Context, store archive.Store, objects []archive.Object, model interface{}, table string) (int64, error) {
+	if err := database.CreateTableFor(table, model); err != nil {
+		return 0, fmt.Errorf("创建临时表失败: %w", err)
+	}
+
+	elemType := reflect.TypeOf(model).Elem()
+	batch := reflect.MakeSlice(reflect.SliceOf(elemType), 0, archiveImportBatchSize)
+	var total int64
+
+	flush := func() error {
+		if batch.Len() == 0 {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		value := reflect.New(batch.Type())
+		value.Elem().Set(batch)
+		inserted, err := database.CreateIgnoreConflicts(database.DB.Table(table), value.Interface())
+		if err != nil {
+			return fmt.Errorf("写入临时表失败: %w", err)
+		}
+		total += inserted
+		batch = reflect.MakeSlice(reflect.SliceOf(elemType), 0, archiveImportBatchSize)
+		return nil
+	}
+
+	for _, obj := range objects {
+		err := archive.ReadFile(ctx, store, obj.Key, func(line []byte) error {
+			row := reflect.New(elemType)
+			if err := json.Unmarshal(line, row.Interface()); err != nil {
+				return fmt.Errorf("解析归档记录失败: %w", err)
+			}
+			batch = reflect.Append(batch, row.Elem())
+			if batch.Len() >= archiveImportBatchSize {
+				return flush()
+			}
+			return nil
+		})
+		if err != nil {
+			return total, fmt.Errorf("导入归档文件 %s 失败: %w", obj.Key, err)
+		}
+	}
+
+	return total, flush()
+}
+
+// ListImports 获取未过期的导入记录（按创建时间倒序），同时删除已过期的临时表
+func (s *ArchiveService) ListImports() ([]models.ArchiveImport, error) {
+	if _, err := s.DropExpiredImports(); err != nil {
+		logger.Error("删除过期归档导入失败", "error", err)
+	}
+
+	var list []models.ArchiveImport
+	if err := database.DB.Order("created_at DESC").Find(&list).Error; err != nil {
+		return nil, errors.New("查询归档导入记录失败")
+	}
+	return list, nil
+}
+
+// DeleteImport 删除导入记录及其临时表
+func (s *ArchiveService) DeleteImport(id uint) error {
+	var record models.ArchiveImport
+	if err := database.DB.First(&record, id).Error; err != nil {
+		return ErrArchiveNotFound
+	}
+	return s.drop(&record)
+}
+
+// DropExpiredImports 删除已过期的导入记录及其临时表，返回删除的数量
+func (s *ArchiveService) DropExpiredImports() (int, error) {
+	var expired []models.ArchiveImport
+	if err := database.DB.Where("expires_at < ?", time.Now().UTC()).Find(&expired).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range expired {
+		if err := s.drop(&expired[i]); err != nil {
+			return i, err
+		}
+		logger.Info("归档导入已过期，临时表已删除", "import_table", expired[i].ImportTable)
+	}
+	return len(expired), nil
+}
+
+// drop 删除临时表与导入记录
+func (s *ArchiveService) drop(record *models.ArchiveImport) error {
+	if record.ImportTable != "" {
+		if err := database.DropTable(record.ImportTable); err != nil {
+			return fmt.Errorf("删除临时表失败: %w", err)
+		}
+	}
+	return database.DB.Delete(record).Error
+}
+
+// resolveArchiveImport 查找已完成、未过期的导入记录，返回其临时表名
+// 记录不存在或已过期时返回 ErrArchiveNotFound
+func resolveArchiveImport(id uint, sourceTable string) (string, error) {
+	var record models.ArchiveImport
+	if err := database.DB.First(&record, id).Error; err != nil || record.SourceTable != sourceTable {
+		return "", fmt.Errorf("%w: 导入记录 %d 不存在", ErrArchiveNotFound, id)
+	}
+	if time.Now().UTC().After(record.ExpiresAt) {
+		return "", fmt.Errorf("%w: 导入记录 %d 已过期", ErrArchiveNotFound, id)
+	}
+	if record.Files == 0 {
+		return "", errors.New("归档导入尚未完成")
+	}
+	return record.ImportTable, nil
+}
+
+// archiveRows 按 id 顺序分批读取 query 匹配的记录写入归档，返回写入的记录数
+// query 每次调用返回新的查询条件
+func archiveRows(ctx context.Context, query func() *gorm.DB, model interface{}, batchSize int, w *archive.Writer) (int64, error) {
+	var total int64
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		batch := query().Where("id > ?", lastID).Order("id ASC").Limit(batchSize)
+		var count int
+		switch model.(type) {
+		case *models.TokenUsageLog:
+			var rows []models.TokenUsageLog
+			if err := batch.Find(&rows).Error; err != nil {
+				return total, err
+			}
+			for i := range rows {
+				if err := w.Write(rows[i].Time, &rows[i]); err != nil {
+					return total, err
+				}
+				lastID = rows[i].ID
+			}
+			count = len(rows)
+		case *models.SystemLog:
+			var rows []models.SystemLog
+			if err := batch.Find(&rows).Error; err != nil {
+				return total, err
+			}
+			for i := range rows {
+				if err := w.Write(rows[i].Time, &rows[i]); err != nil {
+					return total, err
+				}
+				lastID = rows[i].ID
+			}
+			count = len(rows)
+		default:
+			return total, fmt.Errorf("不支持归档的模型: %T", model)
+		}
+
+		total += int64(count)
+		if count < batchSize {
+			return total, nil
+		}
+	}
+}
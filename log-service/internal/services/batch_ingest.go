@@ -0,0 +1,108 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// 批量写入的单行结果
+const (
+	RowInserted  = "inserted"  // 已写入
+	RowDuplicate = "duplicate" // request_id 已存在（或在同一批次中重复），忽略
+	RowRejected  = "rejected"  // 校验失败，未写入
+)
+
+// maxRequestIDLength request_id 字段的最大长度，与表结构一致
+const maxRequestIDLength = 64
+
+// RowResult 批量写入的单行结果
+type RowResult struct {
+	Index     int    `json:"index"` // 行在请求中的序号，从 0 开始
+	RequestID string `json:"request_id,omitempty"`
+	Status    string `json:"status"`
+	Reason    string `json:"reason,omitempty"` // 拒绝原因
+}
+
+// BatchResult 批量写入结果
+type BatchResult struct {
+	BatchID   string      `json:"batch_id,omitempty"`
+	Replayed  bool        `json:"replayed,omitempty"` // 批次已接收过，返回首次写入的结果，results 只包含被拒绝的行
+	Received  int         `json:"received"`
+	Inserted  int         `json:"inserted"`
+	Duplicate int         `json:"duplicate"`
+	Rejected  int         `json:"rejected"`
+	Results   []RowResult `json:"results"`
+}
+
+// add 记录单行结果
+func (r *BatchResult) add(row RowResult) {
+	r.Received++
+	switch row.Status {
+	case RowInserted:
+		r.Inserted++
+	case RowDuplicate:
+		r.Duplicate++
+	case RowRejected:
+		r.Rejected++
+	}
+	r.Results = append(r.Results, row)
+}
+
+// Merge 合并分块写入的结果
+func (r *BatchResult) Merge(other *BatchResult) {
+	r.Received += other.Received
+	r.Inserted += other.Inserted
+	r.Duplicate += other.Duplicate
+	r.Rejected += other.Rejected
+	r.Results = append(r.Results, other.Results...)
+}
+
+// BatchRow 批量写入请求中的一行
+type BatchRow struct {
+	Index int             // 行在请求中的序号，从 0 开始
+	Data  json.RawMessage // 原始 JSON
+	Err   error           // 读取阶段的错误（如行过长），不为空时该行直接拒绝
+}
+
+// decodeBatchRow 解析单行 JSON，失败时返回拒绝原因
+func decodeBatchRow(row BatchRow, v interface{}) error {
+	if row.Err != nil {
+		return row.Err
+	}
+	if err := json.Unmarshal(row.Data, v); err != nil {
+		return fmt.Errorf("JSON 解析失败: %w", err)
+	}
+	return nil
+}
+
+// validateRequestID 校验 request_id，用于去重，必须存在且不超过字段长度
+func validateRequestID(requestID string) error {
+	if requestID == "" {
+		return fmt.Errorf("缺少 request_id 字段")
+	}
+	if len(requestID) > maxRequestIDLength {
+		return fmt.Errorf("request_id 超过 %d 个字符", maxRequestIDLength)
+	}
+	return nil
+}
+
+// existingRequestIDs 查询 model 对应表中已存在的 request_id（含软删除的记录）
+func existingRequestIDs(tx *gorm.DB, model interface{}, ids []string) (map[string]bool, error) {
+	existing := make(map[string]bool)
+	const chunkSize = 500
+	for i := 0; i < len(ids); i += chunkSize {
+		end := min(i+chunkSize, len(ids))
+		var found []string
+		if err := tx.Unscoped().Model(model).
+			Where("request_id IN ?", ids[i:end]).
+			Pluck("request_id", &found).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range found {
+			existing[id] = true
+		}
+	}
+	return existing, nil
+}
@@ -0,0 +1,501 @@
+// Package services 业务逻辑服务层
+// 实现全局运营看板：跨所有 token 的汇总、时间序列、排行与环比
+package services
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// 看板默认值与限制
+const (
+	defaultDashboardTop   = 10
+	maxDashboardTop       = 100
+	maxMinuteBucketsRange = 24 * time.Hour // 按分钟分桶时允许的最大时间范围
+)
+
+// DashboardService 全局运营看板服务
+type DashboardService struct {
+	statisticsService *StatisticsService
+}
+
+// NewDashboardService 创建全局运营看板服务实例
+func NewDashboardService() *DashboardService {
+	return &DashboardService{
+		statisticsService: NewStatisticsService(),
+	}
+}
+
+// GetDashboardRequest 获取全局看板请求
+type GetDashboardRequest struct {
+	StartTime string `form:"start_time"`
+	EndTime   string `form:"end_time"`
+	TZ        string `form:"tz"`       // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
+	Interval  string `form:"interval"` // 时间序列粒度：minute / hour / day，默认范围不超过 2 天按小时，否则按天
+	Top       int    `form:"top"`      // 排行榜条数，默认 10，最大 100
+}
+
+// DashboardResponse 全局看板响应
+type DashboardResponse struct {
+	TimeRange         TimeRange                `json:"time_range"`
+	PreviousTimeRange TimeRange                `json:"previous_time_range"`
+	Interval          string                   `json:"interval"`
+	Current           DashboardTotals          `json:"current"`
+	Previous          DashboardTotals          `json:"previous"`
+	Change            DashboardChange          `json:"change"`
+	Timeline          []DashboardTimelinePoint `json:"timeline"`
+	TopTokensByUsage  []DashboardTokenUsage    `json:"top_tokens_by_usage"`
+	TopTokensByErrors []DashboardTokenErrors   `json:"top_tokens_by_errors"`
+	TopIPs            []IPStatistics           `json:"top_ips"`
+}
+
+// DashboardTotals 时间范围内的汇总指标
+type DashboardTotals struct {
+	Requests         int64                `json:"requests"`
+	ErrorRequests    int64                `json:"error_requests"`
+	ErrorRate        float64              `json:"error_rate"`
+	RequestBytes     int64                `json:"request_bytes"`
+	ResponseBytes    int64                `json:"response_bytes"`
+	PromptTokens     int64                `json:"prompt_tokens"`
+	CompletionTokens int64                `json:"completion_tokens"`
+	TotalTokens      int64                `json:"total_tokens"`
+	ActiveTokens     int64                `json:"active_tokens"` // 有请求的不同 authorization 数
+	LatencyMs        PercentileStatistics `json:"latency_ms"`
+}
+
+// DashboardChange 当前范围相对上一个等长范围的变化
+// 比例字段为 (当前 - 上期) / 上期，上期为 0 时为 null
+type DashboardChange struct {
+	Requests      *float64 `json:"requests"`
+	ErrorRate     float64  `json:"error_rate"` // 错误率差值（当前 - 上期）
+	ResponseBytes *float64 `json:"response_bytes"`
+	TotalTokens   *float64 `json:"total_tokens"`
+	ActiveTokens  *float64 `json:"active_tokens"`
+	LatencyP95Ms  *float64 `json:"latency_p95_ms"`
+}
+
+// DashboardTimelinePoint 时间序列中的一个点
+type DashboardTimelinePoint struct {
+	Time          string  `json:"time"`
+	Requests      int64   `json:"requests"`
+	ErrorRequests int64   `json:"error_requests"`
+	ErrorRate     float64 `json:"error_rate"`
+	RequestBytes  int64   `json:"request_bytes"`
+	ResponseBytes int64   `json:"response_bytes"`
+	TotalTokens   int64   `json:"total_tokens"`
+	ActiveTokens  int64   `json:"active_tokens"`
+	AvgLatencyMs  float64 `json:"avg_latency_ms"`
+	MaxLatencyMs  int64   `json:"max_latency_ms"`
+}
+
+// DashboardTokenUsage 按 token 用量排行
+type DashboardTokenUsage struct {
+	Authorization    string `json:"authorization"`
+	Requests         int64  `json:"requests"`
+	PromptTokens     int64  `json:"prompt_tokens"`
+	CompletionTokens int64  `json:"completion_tokens"`
+	TotalTokens      int64  `json:"total_tokens"`
+}
+
+// DashboardTokenErrors 按错误数排行
+type DashboardTokenErrors struct {
+	Authorization string  `json:"authorization"`
+	Requests      int64   `json:"requests"`
+	ErrorRequests int64   `json:"error_requests"`
+	ErrorRate     float64 `json:"error_rate"`
+}
+
+// GetDashboard 获取全局运营看板
+// 按小时/按天分桶时汇总、时间序列与 token 排行读取预聚合表（时间范围按小时/天向外对齐）；
+// 按分钟分桶时全部读取原始日志。延迟分位数与 IP 排行始终读取原始日志
+func (s *DashboardService) GetDashboard(req *GetDashboardRequest) (*DashboardResponse, error) {
+	startTime, endTime, loc, err := s.statisticsService.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := req.Interval
+	switch interval {
+	case "":
+		interval = string(database.TimeUnitHour)
+		if endTime.Sub(startTime) > 48*time.Hour {
+			interval = string(database.TimeUnitDay)
+		}
+	case string(database.TimeUnitMinute):
+		if endTime.Sub(startTime) > maxMinuteBucketsRange {
+			return nil, errors.New("按分钟分桶时时间范围不能超过 24 小时")
+		}
+	case string(database.TimeUnitHour), string(database.TimeUnitDay):
+	default:
+		return nil, errors.New("interval 参数错误，可选值: minute、hour、day")
+	}
+
+	top := req.Top
+	if top < 1 {
+		top = defaultDashboardTop
+	}
+	if top > maxDashboardTop {
+		top = maxDashboardTop
+	}
+
+	// 按分钟分桶时范围较短，全部指标读取原始日志以保证精确；否则读取预聚合表，
+	// 当前范围实际从 startTime 所在小时起算
+	src := rollupSource
+	rangeStart := hourBucket(startTime)
+	if interval == string(database.TimeUnitMinute) {
+		src = rawSource
+		rangeStart = startTime
+	}
+
+	// 上一个等长范围，在当前范围实际起点之前结束，同一小时不会同时计入两期
+	prevEnd := rangeStart.Add(-time.Second)
+	prevStart := rangeStart.Add(-endTime.Sub(rangeStart))
+
+	current, err := s.getTotals(src, startTime, endTime)
+	if err != nil {
+		return nil, errors.New("获取汇总统计失败: " + err.Error())
+	}
+	previous, err := s.getTotals(src, prevStart, prevEnd)
+	if err != nil {
+		return nil, errors.New("获取上期汇总统计失败: " + err.Error())
+	}
+
+	// 时间序列按请求时区在结束时刻的偏移分桶
+	offset := utils.ZoneOffset(loc, endTime)
+	var timeline []DashboardTimelinePoint
+	if interval == string(database.TimeUnitMinute) {
+		timeline, err = s.getRawTimeline(startTime, endTime, offset)
+	} else {
+		timeline, err = s.getRollupTimeline(database.TimeUnit(interval), startTime, endTime, offset)
+	}
+	if err != nil {
+		return nil, errors.New("获取时间序列失败: " + err.Error())
+	}
+
+	topByUsage, err := s.getTopTokensByUsage(src, startTime, endTime, top)
+	if err != nil {
+		return nil, errors.New("获取 token 用量排行失败: " + err.Error())
+	}
+	topByErrors, err := s.getTopTokensByErrors(src, startTime, endTime, top)
+	if err != nil {
+		return nil, errors.New("获取错误排行失败: " + err.Error())
+	}
+
+	topIPs, err := s.statisticsService.groupByIP(rawSource.newQuery(startTime, endTime).Limit(top))
+	if err != nil {
+		return nil, errors.New("获取 IP 排行失败: " + err.Error())
+	}
+	if topIPs == nil {
+		topIPs = []IPStatistics{}
+	}
+
+	return &DashboardResponse{
+		TimeRange:         newTimeRange(startTime, endTime, loc),
+		PreviousTimeRange: newTimeRange(prevStart, prevEnd, loc),
+		Interval:          interval,
+		Current:           *current,
+		Previous:          *previous,
+		Change:            compareTotals(current, previous),
+		Timeline:          timeline,
+		TopTokensByUsage:  topByUsage,
+		TopTokensByErrors: topByErrors,
+		TopIPs:            topIPs,
+	}, nil
+}
+
+// dashboardSource 看板指标的数据来源：预聚合表或原始日志
+// 两者的列名与聚合方式不同，但输出相同名称的指标
+type dashboardSource struct {
+	newQuery      func(startTime, endTime time.Time) *gorm.DB
+	requests      string // 请求数聚合表达式
+	errorRequests string // 错误请求数（4xx + 5xx）聚合表达式
+	columns       map[string]string
+}
+
+// rollupSource 从按小时预聚合表读取，时间范围按小时向外对齐
+var rollupSource = dashboardSource{
+	newQuery: func(startTime, endTime time.Time) *gorm.DB {
+		return rollupQuery(models.RollupGranularityHour, startTime, endTime)
+	},
+	requests:      "SUM(request_count)",
+	errorRequests: "SUM(CASE WHEN status_class IN ('4xx', '5xx') THEN request_count ELSE 0 END)",
+	columns: map[string]string{
+		"request_bytes":     "request_bytes",
+		"response_bytes":    "response_bytes",
+		"prompt_tokens":     "prompt_tokens",
+		"completion_tokens": "completion_tokens",
+		"total_tokens":      "total_tokens",
+	},
+}
+
+// rawSource 从原始日志读取，时间范围精确
+var rawSource = dashboardSource{
+	newQuery: func(startTime, endTime time.Time) *gorm.DB {
+		return database.DB.Model(&models.TokenUsageLog{}).
+			Where("time >= ?", startTime).
+			Where("time <= ?", endTime)
+	},
+	requests:      "COUNT(*)",
+	errorRequests: "SUM(CASE WHEN status >= 400 AND status < 600 THEN 1 ELSE 0 END)",
+	columns: map[string]string{
+		"request_bytes":     "request_size_bytes",
+		"response_bytes":    "response_size_bytes",
+		"prompt_tokens":     "prompt_tokens",
+		"completion_tokens": "completion_tokens",
+		"total_tokens":      "total_tokens",
+	},
+}
+
+// sum 指定指标的求和表达式（无数据时为 0）
+func (src dashboardSource) sum(metric string) string {
+	return "COALESCE(SUM(" + src.columns[metric] + "), 0) as " + metric
+}
+
+// getTotals 获取时间范围内的汇总指标，延迟分位数始终读取原始日志
+func (s *DashboardService) getTotals(src dashboardSource, startTime, endTime time.Time) (*DashboardTotals, error) {
+	type Result struct {
+		Requests         int64
+		ErrorRequests    int64
+		RequestBytes     int64
+		ResponseBytes    int64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+		ActiveTokens     int64
+	}
+
+	var result Result
+	if err := src.newQuery(startTime, endTime).Select(
+		"COALESCE("+src.requests+", 0) as requests",
+		"COALESCE("+src.errorRequests+", 0) as error_requests",
+		src.sum("request_bytes"),
+		src.sum("response_bytes"),
+		src.sum("prompt_tokens"),
+		src.sum("completion_tokens"),
+		src.sum("total_tokens"),
+		`COUNT(DISTINCT CASE WHEN "authorization" != '' THEN "authorization" END) as active_tokens`,
+	).Scan(&result).Error; err != nil {
+		return nil, err
+	}
+
+	totals := &DashboardTotals{
+		Requests:         result.Requests,
+		ErrorRequests:    result.ErrorRequests,
+		ErrorRate:        ratio(result.ErrorRequests, result.Requests),
+		RequestBytes:     result.RequestBytes,
+		ResponseBytes:    result.ResponseBytes,
+		PromptTokens:     result.PromptTokens,
+		CompletionTokens: result.CompletionTokens,
+		TotalTokens:      result.TotalTokens,
+		ActiveTokens:     result.ActiveTokens,
+	}
+
+	newQuery := func() *gorm.DB {
+		return rawSource.newQuery(startTime, endTime)
+	}
+	var count int64
+	if err := newQuery().Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		latency, err := s.statisticsService.getPercentiles(newQuery(), "latency_ms")
+		if err != nil {
+			return nil, err
+		}
+		totals.LatencyMs = latency
+	}
+
+	return totals, nil
+}
+
+// getRollupTimeline 从预聚合表获取按小时/按天的时间序列
+func (s *DashboardService) getRollupTimeline(unit database.TimeUnit, startTime, endTime time.Time, offset int) ([]DashboardTimelinePoint, error) {
+	type Result struct {
+		Bucket        string
+		Requests      int64
+		ErrorRequests int64
+		RequestBytes  int64
+		ResponseBytes int64
+		TotalTokens   int64
+		ActiveTokens  int64
+		LatencySumMs  int64
+		MaxLatencyMs  int64
+	}
+
+	query, bucket := rollupBuckets(unit, startTime, endTime, offset)
+
+	var results []Result
+	if err := query.Select(
+		bucket+" as bucket",
+		rollupSource.requests+" as requests",
+		rollupSource.errorRequests+" as error_requests",
+		rollupSource.sum("request_bytes"),
+		rollupSource.sum("response_bytes"),
+		rollupSource.sum("total_tokens"),
+		`COUNT(DISTINCT CASE WHEN "authorization" != '' THEN "authorization" END) as active_tokens`,
+		"SUM(latency_sum_ms) as latency_sum_ms",
+		"MAX(latency_max_ms) as max_latency_ms",
+	).
+		Group(bucket).
+		Order("bucket ASC").
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	timeline := make([]DashboardTimelinePoint, 0, len(results))
+	for _, r := range results {
+		point := DashboardTimelinePoint{
+			Time:          r.Bucket,
+			Requests:      r.Requests,
+			ErrorRequests: r.ErrorRequests,
+			ErrorRate:     ratio(r.ErrorRequests, r.Requests),
+			RequestBytes:  r.RequestBytes,
+			ResponseBytes: r.ResponseBytes,
+			TotalTokens:   r.TotalTokens,
+			ActiveTokens:  r.ActiveTokens,
+			MaxLatencyMs:  r.MaxLatencyMs,
+		}
+		if r.Requests > 0 {
+			point.AvgLatencyMs = float64(r.LatencySumMs) / float64(r.Requests)
+		}
+		timeline = append(timeline, point)
+	}
+
+	return timeline, nil
+}
+
+// getRawTimeline 从原始日志获取按分钟的时间序列（offset 为时区偏移秒数）
+func (s *DashboardService) getRawTimeline(startTime, endTime time.Time, offset int) ([]DashboardTimelinePoint, error) {
+	type Result struct {
+		Bucket        string
+		Requests      int64
+		ErrorRequests int64
+		RequestBytes  int64
+		ResponseBytes int64
+		TotalTokens   int64
+		ActiveTokens  int64
+		AvgLatencyMs  float64
+		MaxLatencyMs  int64
+	}
+
+	bucket := database.CurrentDialect().TimeBucket("time", database.TimeUnitMinute, offset)
+
+	var results []Result
+	if err := rawSource.newQuery(startTime, endTime).Select(
+		bucket+" as bucket",
+		rawSource.requests+" as requests",
+		rawSource.errorRequests+" as error_requests",
+		rawSource.sum("request_bytes"),
+		rawSource.sum("response_bytes"),
+		rawSource.sum("total_tokens"),
+		`COUNT(DISTINCT CASE WHEN "authorization" != '' THEN "authorization" END) as active_tokens`,
+		"AVG(latency_ms) as avg_latency_ms",
+		"MAX(latency_ms) as max_latency_ms",
+	).
+		Group(bucket).
+		Order("bucket ASC").
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	timeline := make([]DashboardTimelinePoint, 0, len(results))
+	for _, r := range results {
+		timeline = append(timeline, DashboardTimelinePoint{
+			Time:          r.Bucket,
+			Requests:      r.Requests,
+			ErrorRequests: r.ErrorRequests,
+			ErrorRate:     ratio(r.ErrorRequests, r.Requests),
+			RequestBytes:  r.RequestBytes,
+			ResponseBytes: r.ResponseBytes,
+			TotalTokens:   r.TotalTokens,
+			ActiveTokens:  r.ActiveTokens,
+			AvgLatencyMs:  r.AvgLatencyMs,
+			MaxLatencyMs:  r.MaxLatencyMs,
+		})
+	}
+
+	return timeline, nil
+}
+
+// getTopTokensByUsage 按 token 用量排行
+func (s *DashboardService) getTopTokensByUsage(src dashboardSource, startTime, endTime time.Time, top int) ([]DashboardTokenUsage, error) {
+	results := []DashboardTokenUsage{}
+	err := src.newQuery(startTime, endTime).
+		Where(`"authorization" != ''`).
+		Select(
+			`"authorization"`,
+			src.requests+" as requests",
+			src.sum("prompt_tokens"),
+			src.sum("completion_tokens"),
+			src.sum("total_tokens"),
+		).
+		Group("authorization").
+		Order("total_tokens DESC, requests DESC").
+		Limit(top).
+		Scan(&results).Error
+	return results, err
+}
+
+// getTopTokensByErrors 按错误请求数排行（只包含有错误的 token）
+func (s *DashboardService) getTopTokensByErrors(src dashboardSource, startTime, endTime time.Time, top int) ([]DashboardTokenErrors, error) {
+	type Result struct {
+		Authorization string
+		Requests      int64
+		ErrorRequests int64
+	}
+
+	var results []Result
+	if err := src.newQuery(startTime, endTime).
+		Where(`"authorization" != ''`).
+		Select(
+			`"authorization"`,
+			src.requests+" as requests",
+			src.errorRequests+" as error_requests",
+		).
+		Group("authorization").
+		Having(src.errorRequests + " > 0").
+		Order("error_requests DESC, requests DESC").
+		Limit(top).
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	list := make([]DashboardTokenErrors, 0, len(results))
+	for _, r := range results {
+		list = append(list, DashboardTokenErrors{
+			Authorization: r.Authorization,
+			Requests:      r.Requests,
+			ErrorRequests: r.ErrorRequests,
+			ErrorRate:     ratio(r.ErrorRequests, r.Requests),
+		})
+	}
+	return list, nil
+}
+
+// compareTotals 计算当前范围相对上期的变化
+func compareTotals(current, previous *DashboardTotals) DashboardChange {
+	return DashboardChange{
+		Requests:      relativeChange(current.Requests, previous.Requests),
+		ErrorRate:     math.Round((current.ErrorRate-previous.ErrorRate)*10000) / 10000,
+		ResponseBytes: relativeChange(current.ResponseBytes, previous.ResponseBytes),
+		TotalTokens:   relativeChange(current.TotalTokens, previous.TotalTokens),
+		ActiveTokens:  relativeChange(current.ActiveTokens, previous.ActiveTokens),
+		LatencyP95Ms:  relativeChange(current.LatencyMs.P95, previous.LatencyMs.P95),
+	}
+}
+
+// relativeChange 计算相对变化 (current - previous) / previous，previous 为 0 时返回 nil
+func relativeChange(current, previous int64) *float64 {
+	if previous == 0 {
+		return nil
+	}
+	change := ratio(current-previous, previous)
+	return &change
+}
@@ -0,0 +1,330 @@
+// Package services 业务逻辑服务层
+// 实现请求日志与按天用量汇总的流式导出，用于账单对账
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// exportFlushRows 每写出多少行刷新一次响应，避免数据长时间停留在缓冲区
+const exportFlushRows = 1000
+
+// exportTimeLayout 导出文件中的时间格式（按请求时区输出）
+const exportTimeLayout = "2006-01-02T15:04:05.000Z07:00"
+
+// ExportService 导出服务
+type ExportService struct{}
+
+// NewExportService 创建导出服务实例
+func NewExportService() *ExportService {
+	return &ExportService{}
+}
+
+// ExportLogsRequest 导出请求，过滤条件与日志列表相同（忽略分页参数）
+type ExportLogsRequest struct {
+	ListLogsRequest
+	Format string `form:"format"` // csv（默认）/ ndjson / parquet
+}
+
+// Export 已校验参数的导出任务，调用 Stream 时才执行查询
+type Export struct {
+	ContentType string
+	Filename    string
+
+	format string
+	sample exportRow
+	query  *gorm.DB
+	scan   func(rows *sql.Rows) (exportRow, error)
+}
+
+// RequestLogExportRow 请求日志导出行（不含请求体与请求/响应头）
+type RequestLogExportRow struct {
+	ID                 int64  `json:"id" parquet:"name=id, type=INT64"`
+	Time               string `json:"time" parquet:"name=time, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RequestID          string `json:"request_id" parquet:"name=request_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Method             string `json:"method" parquet:"name=method, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Path               string `json:"path" parquet:"name=path, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Status             int64  `json:"status" parquet:"name=status, type=INT64"`
+	Authorization      string `json:"authorization" parquet:"name=authorization, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OrderNo            string `json:"order_no" parquet:"name=order_no, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AIModelID          int64  `json:"ai_model_id" parquet:"name=ai_model_id, type=INT64"`
+	AIModelName        string `json:"ai_model_name" parquet:"name=ai_model_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	IsStream           bool   `json:"is_stream" parquet:"name=is_stream, type=BOOLEAN"`
+	LatencyMs          int64  `json:"latency_ms" parquet:"name=latency_ms, type=INT64"`
+	FirstByteLatencyMs int64  `json:"first_byte_latency_ms" parquet:"name=first_byte_latency_ms, type=INT64"`
+	RequestSizeBytes   int64  `json:"request_size_bytes" parquet:"name=request_size_bytes, type=INT64"`
+	ResponseSizeBytes  int64  `json:"response_size_bytes" parquet:"name=response_size_bytes, type=INT64"`
+	PromptTokens       int64  `json:"prompt_tokens" parquet:"name=prompt_tokens, type=INT64"`
+	CompletionTokens   int64  `json:"completion_tokens" parquet:"name=completion_tokens, type=INT64"`
+	TotalTokens        int64  `json:"total_tokens" parquet:"name=total_tokens, type=INT64"`
+	RemoteAddr         string `json:"remote_addr" parquet:"name=remote_addr, type=BYTE_ARRAY, convertedtype=UTF8"`
+	XForwardedFor      string `json:"x_forwarded_for" parquet:"name=x_forwarded_for, type=BYTE_ARRAY, convertedtype=UTF8"`
+	UserAgent          string `json:"user_agent" parquet:"name=user_agent, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ServiceID          string `json:"service_id" parquet:"name=service_id, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Host               string `json:"host" parquet:"name=host, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+func (RequestLogExportRow) csvHeader() []string {
+	return []string{
+		"id", "time", "request_id", "method", "path", "status", "authorization", "order_no",
+		"ai_model_id", "ai_model_name", "is_stream", "latency_ms", "first_byte_latency_ms",
+		"request_size_bytes", "response_size_bytes", "prompt_tokens", "completion_tokens", "total_tokens",
+		"remote_addr", "x_forwarded_for", "user_agent", "service_id", "host",
+	}
+}
+
+func (r RequestLogExportRow) csvRecord() []string {
+	return []string{
+		strconv.FormatInt(r.ID, 10), r.Time, r.RequestID, r.Method, r.Path, strconv.FormatInt(r.Status, 10),
+		r.Authorization, r.OrderNo, strconv.FormatInt(r.AIModelID, 10), r.AIModelName, strconv.FormatBool(r.IsStream),
+		strconv.FormatInt(r.LatencyMs, 10), strconv.FormatInt(r.FirstByteLatencyMs, 10),
+		strconv.FormatInt(r.RequestSizeBytes, 10), strconv.FormatInt(r.ResponseSizeBytes, 10),
+		strconv.FormatInt(r.PromptTokens, 10), strconv.FormatInt(r.CompletionTokens, 10), strconv.FormatInt(r.TotalTokens, 10),
+		r.RemoteAddr, r.XForwardedFor, r.UserAgent, r.ServiceID, r.Host,
+	}
+}
+
+// UsageExportRow 按天、token、订单号、模型汇总的用量导出行
+type UsageExportRow struct {
+	Date             string  `json:"date" parquet:"name=date, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Authorization    string  `json:"authorization" parquet:"name=authorization, type=BYTE_ARRAY, convertedtype=UTF8"`
+	OrderNo          string  `json:"order_no" parquet:"name=order_no, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AIModelName      string  `json:"ai_model_name" parquet:"name=ai_model_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Requests         int64   `json:"requests" parquet:"name=requests, type=INT64"`
+	ErrorRequests    int64   `json:"error_requests" parquet:"name=error_requests, type=INT64"`
+	RequestBytes     int64   `json:"request_bytes" parquet:"name=request_bytes, type=INT64"`
+	ResponseBytes    int64   `json:"response_bytes" parquet:"name=response_bytes, type=INT64"`
+	PromptTokens     int64   `json:"prompt_tokens" parquet:"name=prompt_tokens, type=INT64"`
+	CompletionTokens int64   `json:"completion_tokens" parquet:"name=completion_tokens, type=INT64"`
+	TotalTokens      int64   `json:"total_tokens" parquet:"name=total_tokens, type=INT64"`
+	AvgLatencyMs     float64 `json:"avg_latency_ms" parquet:"name=avg_latency_ms, type=DOUBLE"`
+}
+
+func (UsageExportRow) csvHeader() []string {
+	return []string{
+		"date", "authorization", "order_no", "ai_model_name", "requests", "error_requests",
+		"request_bytes", "response_bytes", "prompt_tokens", "completion_tokens", "total_tokens", "avg_latency_ms",
+	}
+}
+
+func (r UsageExportRow) csvRecord() []string {
+	return []string{
+		r.Date, r.Authorization, r.OrderNo, r.AIModelName,
+		strconv.FormatInt(r.Requests, 10), strconv.FormatInt(r.ErrorRequests, 10),
+		strconv.FormatInt(r.RequestBytes, 10), strconv.FormatInt(r.ResponseBytes, 10),
+		strconv.FormatInt(r.PromptTokens, 10), strconv.FormatInt(r.CompletionTokens, 10), strconv.FormatInt(r.TotalTokens, 10),
+		strconv.FormatFloat(r.AvgLatencyMs, 'f', 2, 64),
+	}
+}
+
+// ExportLogs 导出请求日志明细，按时间升序
+func (s *ExportService) ExportLogs(req *ExportLogsRequest) (*Export, error) {
+	export, err := newExport(req.Format, "request-logs")
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := utils.LoadTimeZone(req.TZ)
+	if err != nil {
+		return nil, err
+	}
+	query, err := newRequestLogQuery(&req.ListLogsRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	export.sample = &RequestLogExportRow{}
+	export.query = query.
+		Select(`id, time, request_id, method, path, status, "authorization", order_no, ai_model_id, ai_model_name, is_stream,
+			latency_ms, first_byte_latency_ms, request_size_bytes, response_size_bytes,
+			prompt_tokens, completion_tokens, total_tokens, remote_addr, x_forwarded_for, user_agent, service_id, host`).
+		Order("time ASC, id ASC")
+	export.scan = func(rows *sql.Rows) (exportRow, error) {
+		var log models.TokenUsageLog
+		if err := database.DB.ScanRows(rows, &log); err != nil {
+			return nil, err
+		}
+		return RequestLogExportRow{
+			ID:                 int64(log.ID),
+			Time:               log.Time.In(loc).Format(exportTimeLayout),
+			RequestID:          log.RequestID,
+			Method:             log.Method,
+			Path:               log.Path,
+			Status:             int64(log.Status),
+			Authorization:      log.Authorization,
+			OrderNo:            log.OrderNo,
+			AIModelID:          int64(log.AIModelID),
+			AIModelName:        log.AIModelName,
+			IsStream:           log.IsStream,
+			LatencyMs:          log.LatencyMs,
+			FirstByteLatencyMs: log.FirstByteLatencyMs,
+			RequestSizeBytes:   int64(log.RequestSizeBytes),
+			ResponseSizeBytes:  int64(log.ResponseSizeBytes),
+			PromptTokens:       log.PromptTokens,
+			CompletionTokens:   log.CompletionTokens,
+			TotalTokens:        log.TotalTokens,
+			RemoteAddr:         log.RemoteAddr,
+			XForwardedFor:      log.XForwardedFor,
+			UserAgent:          log.UserAgent,
+			ServiceID:          log.ServiceID,
+			Host:               log.Host,
+		}, nil
+	}
+
+	return export, nil
+}
+
+// ExportUsage 导出按天（请求时区）、authorization、订单号、模型汇总的用量
+// 预聚合表不含订单号且不支持状态码、全文检索等过滤，因此直接在明细表上聚合
+func (s *ExportService) ExportUsage(req *ExportLogsRequest) (*Export, error) {
+	export, err := newExport(req.Format, "usage")
+	if err != nil {
+		return nil, err
+	}
+
+	loc, err := utils.LoadTimeZone(req.TZ)
+	if err != nil {
+		return nil, err
+	}
+	query, err := newRequestLogQuery(&req.ListLogsRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	// 与统计接口一致，按结束时间（未指定时为当前时间）的时区偏移分桶
+	end := time.Now()
+	if req.EndTime != "" {
+		if end, err = utils.ParseTimeParam(req.EndTime, loc); err != nil {
+			return nil, errors.New("结束时间" + err.Error())
+		}
+	}
+	bucket := database.CurrentDialect().TimeBucket("time", database.TimeUnitDay, utils.ZoneOffset(loc, end))
+
+	export.sample = &UsageExportRow{}
+	export.query = query.
+		Select(
+			bucket+" as bucket",
+			`"authorization"`,
+			"order_no",
+			"ai_model_name",
+			"COUNT(*) as requests",
+			"SUM(CASE WHEN status >= 400 THEN 1 ELSE 0 END) as error_requests",
+			"SUM(request_size_bytes) as request_bytes",
+			"SUM(response_size_bytes) as response_bytes",
+			"SUM(prompt_tokens) as prompt_tokens",
+			"SUM(completion_tokens) as completion_tokens",
+			"SUM(total_tokens) as total_tokens",
+			"SUM(latency_ms) as latency_sum_ms",
+		).
+		Group(bucket + `, "authorization", order_no, ai_model_name`).
+		Order(`bucket ASC, "authorization" ASC, order_no ASC, ai_model_name ASC`)
+	export.scan = func(rows *sql.Rows) (exportRow, error) {
+		var r struct {
+			Bucket           string
+			Authorization    string
+			OrderNo          string
+			AIModelName      string
+			Requests         int64
+			ErrorRequests    int64
+			RequestBytes     int64
+			ResponseBytes    int64
+			PromptTokens     int64
+			CompletionTokens int64
+			TotalTokens      int64
+			LatencySumMs     int64
+		}
+		if err := database.DB.ScanRows(rows, &r); err != nil {
+			return nil, err
+		}
+		row := UsageExportRow{
+			Date:             r.Bucket,
+			Authorization:    r.Authorization,
+			OrderNo:          r.OrderNo,
+			AIModelName:      r.AIModelName,
+			Requests:         r.Requests,
+			ErrorRequests:    r.ErrorRequests,
+			RequestBytes:     r.RequestBytes,
+			ResponseBytes:    r.ResponseBytes,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			TotalTokens:      r.TotalTokens,
+		}
+		if r.Requests > 0 {
+			row.AvgLatencyMs = math.Round(float64(r.LatencySumMs)/float64(r.Requests)*100) / 100
+		}
+		return row, nil
+	}
+
+	return export, nil
+}
+
+// newExport 校验导出格式并生成下载文件名
+func newExport(format, name string) (*Export, error) {
+	if format == "" {
+		format = ExportFormatCSV
+	}
+	contentType, ext, err := ExportContentType(format)
+	if err != nil {
+		return nil, err
+	}
+	return &Export{
+		ContentType: contentType,
+		Filename:    fmt.Sprintf("%s-%s.%s", name, time.Now().UTC().Format("20060102150405"), ext),
+		format:      format,
+	}, nil
+}
+
+// Stream 逐行读取查询结果并编码写入 w，不在内存中缓存结果集，返回写出的行数
+// ctx 结束（如客户端断开）时查询随之取消
+func (e *Export) Stream(ctx context.Context, w io.Writer) (int, error) {
+	rows, err := e.query.WithContext(ctx).Rows()
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	ew, err := newExportWriter(e.format, w, e.sample)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		row, err := e.scan(rows)
+		if err != nil {
+			return count, err
+		}
+		if err := ew.Write(row); err != nil {
+			return count, err
+		}
+		count++
+
+		if count%exportFlushRows == 0 {
+			if err := ew.Flush(); err != nil {
+				return count, err
+			}
+			if f, ok := w.(http.Flusher); ok {
+				f.Flush()
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return count, err
+	}
+
+	return count, ew.Close()
+}
@@ -0,0 +1,152 @@
+// Package services 业务逻辑服务层
+// 记录已接收的上传批次，同一批次重复上传时返回首次写入的结果，保证计数准确
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// maxBatchIDLength batch_id 的最大长度，与表结构一致
+const maxBatchIDLength = 64
+
+// ErrBatchKindMismatch batch_id 已被另一种日志类型的批次使用
+var ErrBatchKindMismatch = errors.New("batch_id 已被另一种日志类型的批次使用")
+
+// BatchMeta 上传批次的标识，由请求头传入
+type BatchMeta struct {
+	BatchID string
+	Kind    string // request / system
+	Source  string // 上传方的日志来源名称
+	File    string // 来源文件名
+	Offset  int64  // 批次结束时在来源文件中的字节位置
+}
+
+// Validate 校验批次标识
+func (m *BatchMeta) Validate() error {
+	if len(m.BatchID) > maxBatchIDLength {
+		return fmt.Errorf("batch_id 超过 %d 个字符", maxBatchIDLength)
+	}
+	if m.Offset < 0 {
+		return errors.New("batch offset 不能小于 0")
+	}
+	return nil
+}
+
+// IngestBatchService 上传批次记录服务
+type IngestBatchService struct{}
+
+// NewIngestBatchService 创建上传批次记录服务实例
+func NewIngestBatchService() *IngestBatchService {
+	return &IngestBatchService{}
+}
+
+// Find 查找已接收的批次，返回首次写入时的计数和被拒绝的行；批次不存在时返回 nil
+func (s *IngestBatchService) Find(meta *BatchMeta) (*BatchResult, error) {
+	var record models.IngestBatch
+	err := database.DB.Where("batch_id = ?", meta.BatchID).First(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if record.Kind != meta.Kind {
+		return nil, ErrBatchKindMismatch
+	}
+
+	result := &BatchResult{
+		BatchID:   record.BatchID,
+		Replayed:  true,
+		Received:  record.Received,
+		Inserted:  record.Inserted,
+		Duplicate: record.Duplicate,
+		Rejected:  record.Rejected,
+		Results:   make([]RowResult, 0, len(record.Rejects)),
+	}
+	for _, row := range record.Rejects {
+		result.Results = append(result.Results, RowResult{Index: row.Index, RequestID: row.RequestID, Status: RowRejected, Reason: row.Reason})
+	}
+	return result, nil
+}
+
+// IngestChunk 在 db 中写入一块数据，返回逐行结果
+type IngestChunk func(db *gorm.DB, rows []BatchRow) (*BatchResult, error)
+
+// Ingest 通过 read 逐块读取批次并调用 ingest 写入，返回合并后的逐行结果。
+// meta 为 nil 时每块单独提交，出错时返回的结果包含出错前已写入的分块；
+// 否则全部分块与批次记录在同一事务中写入，批次记录存在即表示数据已全部写入，出错时整批回滚
+func (s *IngestBatchService) Ingest(meta *BatchMeta, read func(handle func(rows []BatchRow) error) error, ingest IngestChunk) (*BatchResult, error) {
+	result := &BatchResult{Results: []RowResult{}}
+	handle := func(db *gorm.DB) func(rows []BatchRow) error {
+		return func(rows []BatchRow) error {
+			chunk, err := ingest(db, rows)
+			if err != nil {
+				return err
+			}
+			result.Merge(chunk)
+			return nil
+		}
+	}
+
+	if meta == nil {
+		return result, read(handle(database.DB))
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := read(handle(tx)); err != nil {
+			return err
+		}
+		return s.record(tx, meta, result)
+	})
+	if err != nil {
+		return &BatchResult{BatchID: meta.BatchID, Results: []RowResult{}}, err
+	}
+	result.BatchID = meta.BatchID
+
+	// 分块写入时的通知早于事务提交，提交后再通知一次实时日志流
+	switch meta.Kind {
+	case models.IngestKindRequest:
+		requestLogNotifier.Notify()
+	case models.IngestKindSystem:
+		systemLogNotifier.Notify()
+	}
+	return result, nil
+}
+
+// record 在 tx 中记录已写入的批次，只保存计数和被拒绝的行；并发写入同一批次时保留先写入的记录
+func (s *IngestBatchService) record(tx *gorm.DB, meta *BatchMeta, result *BatchResult) error {
+	record := models.IngestBatch{
+		BatchID:   meta.BatchID,
+		Kind:      meta.Kind,
+		Source:    meta.Source,
+		File:      meta.File,
+		EndOffset: meta.Offset,
+		Received:  result.Received,
+		Inserted:  result.Inserted,
+		Duplicate: result.Duplicate,
+		Rejected:  result.Rejected,
+		Rejects:   models.RejectedRows{},
+	}
+	for _, row := range result.Results {
+		if row.Status == RowRejected {
+			record.Rejects = append(record.Rejects, models.RejectedRow{Index: row.Index, RequestID: row.RequestID, Reason: row.Reason})
+		}
+	}
+
+	records := []models.IngestBatch{record}
+	_, err := database.CreateIgnoreConflicts(tx, &records, "batch_id")
+	return err
+}
+
+// DeleteExpired 删除 before 之前接收的批次记录，返回删除的记录数
+func (s *IngestBatchService) DeleteExpired(before time.Time) (int64, error) {
+	result := database.DB.Where("created_at < ?", before).Delete(&models.IngestBatch{})
+	return result.RowsAffected, result.Error
+}
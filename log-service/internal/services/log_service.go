@@ -13,7 +13,7 @@ import (
 	"zxm_ai_admin/log-service/internal/models"
 	"zxm_ai_admin/log-service/internal/utils"
 
-	"gorm.io/gorm/clause"
+	"gorm.io/gorm"
 )
 
 type LogService struct{}
@@ -25,24 +25,37 @@ func NewLogService() *LogService {
 
 // CreateLogRequest 创建日志请求
 type CreateLogRequest struct {
-	Time              string            `json:"time"` // 接受字符串格式，在服务层转换为 time.Time
-	Level             string            `json:"level"`
-	Msg               string            `json:"msg"`
-	RequestID         string            `json:"request_id"`
-	Method            string            `json:"method"`
-	Path              string            `json:"path"`
-	Query             string            `json:"query"`
-	RemoteAddr        string            `json:"remote_addr"`
-	UserAgent         string            `json:"user_agent"`
-	XForwardedFor     string            `json:"x_forwarded_for"`
-	RequestHeaders    map[string]string `json:"request_headers"`
-	Authorization     string            `json:"authorization"`
-	RequestBody       string            `json:"request_body"`
-	Status            int               `json:"status"`
-	ResponseHeaders   map[string]string `json:"response_headers"`
-	LatencyMs         int64             `json:"latency_ms"`
-	RequestSizeBytes  int               `json:"request_size_bytes"`
-	ResponseSizeBytes int               `json:"response_size_bytes"`
+	Time               string            `json:"time"` // 接受字符串格式，在服务层转换为 time.Time
+	Level              string            `json:"level"`
+	Msg                string            `json:"msg"`
+	RequestID          string            `json:"request_id"`
+	Method             string            `json:"method"`
+	Path               string            `json:"path"`
+	Query              string            `json:"query"`
+	RemoteAddr         string            `json:"remote_addr"`
+	UserAgent          string            `json:"user_agent"`
+	XForwardedFor      string            `json:"x_forwarded_for"`
+	RequestHeaders     map[string]string `json:"request_headers"`
+	Authorization      string            `json:"authorization"`
+	RequestBody        string            `json:"request_body"`
+	Status             int               `json:"status"`
+	ResponseHeaders    map[string]string `json:"response_headers"`
+	LatencyMs          int64             `json:"latency_ms"`
+	RequestSizeBytes   int               `json:"request_size_bytes"`
+	ResponseSizeBytes  int               `json:"response_size_bytes"`
+	AIModelID          int               `json:"ai_model_id"`
+	AIModelName        string            `json:"ai_model_name"`
+	OrderNo            string            `json:"order_no"`
+	IsStream           bool              `json:"is_stream"`
+	HeaderLatencyMs    int64             `json:"header_latency_ms"`
+	FirstByteLatencyMs int64             `json:"first_byte_latency_ms"`
+	StreamDurationMs   int64             `json:"stream_duration_ms"`
+	ChunkCount         int               `json:"chunk_count"`
+	PromptTokens       int64             `json:"prompt_tokens"`
+	CompletionTokens   int64             `json:"completion_tokens"`
+	TotalTokens        int64             `json:"total_tokens"`
+	ServiceID          string            `json:"service_id"` // 来源服务标识，由 log-syncer 按来源配置写入
+	Host               string            `json:"host"`       // 来源主机
 }
 
 // ListLogsRequest 日志列表查询请求
@@ -55,44 +68,68 @@ type ListLogsRequest struct {
 	Status        string `form:"status"`
 	Method        string `form:"method"`
 	Authorization string `form:"authorization"`
+	OrderNo       string `form:"order_no"`
+	ServiceID     string `form:"service_id"` // 来源服务标识，如 proxy 实例
+	Host          string `form:"host"`       // 来源主机
+	Q             string `form:"q"`          // 全文检索，语法见 ParseSearchQuery
+	TZ            string `form:"tz"`         // start_time / end_time 不带时区时使用的时区，为空时使用配置的默认时区
+	Archive       uint   `form:"archive"`    // 归档导入 ID，指定时查询导入的临时表
 }
 
 // ListLogsResponse 日志列表查询响应
 type ListLogsResponse struct {
-	Total int64                   `json:"total"`
+	Total int64                  `json:"total"`
 	List  []models.TokenUsageLog `json:"list"`
 }
 
 // CreateLog 创建日志记录
 func (s *LogService) CreateLog(req *CreateLogRequest) (*models.TokenUsageLog, error) {
-	parsedTime := utils.ParseTime(req.Time)
-
-	log := &models.TokenUsageLog{
-		Time:              parsedTime,
-		Level:             req.Level,
-		Msg:               req.Msg,
-		RequestID:         req.RequestID,
-		Method:            req.Method,
-		Path:              req.Path,
-		Query:             req.Query,
-		RemoteAddr:        req.RemoteAddr,
-		UserAgent:         req.UserAgent,
-		XForwardedFor:     req.XForwardedFor,
-		RequestHeaders:    req.RequestHeaders,
-		Authorization:     req.Authorization,
-		RequestBody:       req.RequestBody,
-		Status:            req.Status,
-		ResponseHeaders:   req.ResponseHeaders,
-		LatencyMs:         req.LatencyMs,
-		RequestSizeBytes:  req.RequestSizeBytes,
-		ResponseSizeBytes: req.ResponseSizeBytes,
-	}
-
-	if err := database.DB.Create(log).Error; err != nil {
+	log := newTokenUsageLog(req, utils.ParseTime(req.Time))
+
+	inserted, err := insertRequestLogs(database.DB, []models.TokenUsageLog{*log})
+	if err != nil || len(inserted) == 0 {
 		return nil, errors.New("创建日志记录失败")
 	}
+	requestLogNotifier.Notify()
+
+	return &inserted[0], nil
+}
 
-	return log, nil
+// newTokenUsageLog 由写入请求构造日志记录
+func newTokenUsageLog(req *CreateLogRequest, parsedTime time.Time) *models.TokenUsageLog {
+	return &models.TokenUsageLog{
+		Time:               parsedTime,
+		Level:              req.Level,
+		Msg:                req.Msg,
+		RequestID:          req.RequestID,
+		Method:             req.Method,
+		Path:               req.Path,
+		Query:              req.Query,
+		RemoteAddr:         req.RemoteAddr,
+		UserAgent:          req.UserAgent,
+		XForwardedFor:      req.XForwardedFor,
+		RequestHeaders:     req.RequestHeaders,
+		Authorization:      req.Authorization,
+		RequestBody:        req.RequestBody,
+		Status:             req.Status,
+		ResponseHeaders:    req.ResponseHeaders,
+		LatencyMs:          req.LatencyMs,
+		RequestSizeBytes:   req.RequestSizeBytes,
+		ResponseSizeBytes:  req.ResponseSizeBytes,
+		AIModelID:          req.AIModelID,
+		AIModelName:        req.AIModelName,
+		OrderNo:            req.OrderNo,
+		IsStream:           req.IsStream,
+		HeaderLatencyMs:    req.HeaderLatencyMs,
+		FirstByteLatencyMs: req.FirstByteLatencyMs,
+		StreamDurationMs:   req.StreamDurationMs,
+		ChunkCount:         req.ChunkCount,
+		PromptTokens:       req.PromptTokens,
+		CompletionTokens:   req.CompletionTokens,
+		TotalTokens:        req.TotalTokens,
+		ServiceID:          req.ServiceID,
+		Host:               req.Host,
+	}
 }
 
 // ListLogs 获取日志列表
@@ -112,26 +149,68 @@ func (s *LogService) ListLogs(req *ListLogsRequest) (*ListLogsResponse, error) {
 	var total int64
 	var list []models.TokenUsageLog
 
-	query := database.DB.Model(&models.TokenUsageLog{})
+	query, err := newRequestLogQuery(req)
+	if err != nil {
+		return nil, err
+	}
 
-	if req.RequestID != "" {
-		query = query.Where("request_id = ?", req.RequestID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.New("查询日志列表失败")
+	}
+
+	offset := (page - 1) * pageSize
+	if err := query.
+		Order("time DESC").
+		Offset(offset).
+		Limit(pageSize).
+		Find(&list).Error; err != nil {
+		return nil, errors.New("查询日志列表失败")
 	}
 
-	if req.StartTime != "" {
-		if startTime, err := time.Parse("2006-01-02 15:04:05", req.StartTime); err == nil {
-			query = query.Where("time >= ?", startTime)
+	return &ListLogsResponse{
+		Total: total,
+		List:  list,
+	}, nil
+}
+
+// newRequestLogQuery 按列表查询条件构建请求日志查询（列表查询与导出共用）
+func newRequestLogQuery(req *ListLogsRequest) (*gorm.DB, error) {
+	query := database.DB.Model(&models.TokenUsageLog{})
+	if req.Archive != 0 {
+		table, err := resolveArchiveImport(req.Archive, "token_usage_logs")
+		if err != nil {
+			return nil, err
 		}
+		query = query.Table(table)
 	}
-	if req.EndTime != "" {
-		if endTime, err := time.Parse("2006-01-02 15:04:05", req.EndTime); err == nil {
-			query = query.Where("time <= ?", endTime)
+	query = applyRequestLogFilters(query, req.RequestID, req.Status, req.Method, req.Authorization)
+	query = applyOriginFilters(query, req.ServiceID, req.Host)
+
+	if req.OrderNo != "" {
+		query = query.Where("order_no = ?", req.OrderNo)
+	}
+
+	// 全文检索
+	if req.Q != "" {
+		node, err := ParseSearchQuery(req.Q)
+		if err != nil {
+			return nil, err
 		}
+		query = applyFullTextSearch(query, node, req.Archive == 0)
+	}
+
+	return applyTimeFilter(query, req.StartTime, req.EndTime, req.TZ)
+}
+
+// applyRequestLogFilters 应用请求日志的通用过滤条件（列表查询与实时日志流共用）
+func applyRequestLogFilters(query *gorm.DB, requestID, status, method, authorization string) *gorm.DB {
+	if requestID != "" {
+		query = query.Where("request_id = ?", requestID)
 	}
 
 	// 解析状态码字符串（逗号分隔，支持单个或多个）
-	if req.Status != "" {
-		parts := strings.Split(req.Status, ",")
+	if status != "" {
+		parts := strings.Split(status, ",")
 		var statuses []int
 		for _, part := range parts {
 			if num, err := strconv.Atoi(strings.TrimSpace(part)); err == nil {
@@ -143,31 +222,37 @@ func (s *LogService) ListLogs(req *ListLogsRequest) (*ListLogsResponse, error) {
 		}
 	}
 
-	if req.Method != "" {
-		query = query.Where("method = ?", req.Method)
+	if method != "" {
+		query = query.Where("method = ?", method)
 	}
 
-	if req.Authorization != "" {
-		query = query.Where("authorization LIKE ?", "%"+req.Authorization+"%")
+	if authorization != "" {
+		query = query.Where(`"authorization" LIKE ?`, "%"+authorization+"%")
 	}
 
-	if err := query.Count(&total).Error; err != nil {
-		return nil, errors.New("查询日志列表失败")
-	}
+	return query
+}
 
-	offset := (page - 1) * pageSize
-	if err := query.
-		Order("time DESC").
-		Offset(offset).
-		Limit(pageSize).
-		Find(&list).Error; err != nil {
-		return nil, errors.New("查询日志列表失败")
+// applyOriginFilters 按日志来源过滤（请求日志与系统日志共用）
+func applyOriginFilters(query *gorm.DB, serviceID, host string) *gorm.DB {
+	if serviceID != "" {
+		query = query.Where("service_id = ?", serviceID)
+	}
+	if host != "" {
+		query = query.Where("host = ?", host)
 	}
+	return query
+}
 
-	return &ListLogsResponse{
-		Total: total,
-		List:  list,
-	}, nil
+// applyFullTextSearch 应用全文检索条件
+// SQLite 使用 FTS5 索引，其他存储后端与归档导入的临时表（useIndex 为 false，没有全文索引）退化为 LIKE 子串匹配
+func applyFullTextSearch(query *gorm.DB, node *searchNode, useIndex bool) *gorm.DB {
+	dialect := database.CurrentDialect()
+	if useIndex && dialect.SupportsFullText() {
+		return query.Where("id IN (SELECT rowid FROM token_usage_logs_fts WHERE token_usage_logs_fts MATCH ?)", node.FTS5())
+	}
+	expr, args := node.SQL(dialect)
+	return query.Where(expr, args...)
 }
 
 // GetLog 根据 ID 获取日志记录
@@ -179,67 +264,138 @@ func (s *LogService) GetLog(id uint) (*models.TokenUsageLog, error) {
 	return &log, nil
 }
 
-// BatchCreateLogs 批量创建日志记录（忽略重复 request_id）
-func (s *LogService) BatchCreateLogs(reqs []CreateLogRequest) int {
-	if len(reqs) == 0 {
-		logger.Warn("批量创建日志：请求为空")
-		return 0
-	}
-
-	logs := make([]models.TokenUsageLog, 0, len(reqs))
-	for _, req := range reqs {
-		parsedTime := utils.ParseTime(req.Time)
-
-		logs = append(logs, models.TokenUsageLog{
-			Time:              parsedTime,
-			Level:             req.Level,
-			Msg:               req.Msg,
-			RequestID:         req.RequestID,
-			Method:            req.Method,
-			Path:              req.Path,
-			Query:             req.Query,
-			RemoteAddr:        req.RemoteAddr,
-			UserAgent:         req.UserAgent,
-			XForwardedFor:     req.XForwardedFor,
-			RequestHeaders:    req.RequestHeaders,
-			Authorization:     req.Authorization,
-			RequestBody:       req.RequestBody,
-			Status:            req.Status,
-			ResponseHeaders:   req.ResponseHeaders,
-			LatencyMs:         req.LatencyMs,
-			RequestSizeBytes:  req.RequestSizeBytes,
-			ResponseSizeBytes: req.ResponseSizeBytes,
-		})
+// IngestRequestLogs 批量写入请求日志：逐行解析和校验，校验失败的行被拒绝，
+// 重复的 request_id 被忽略，其余在 db 的同一事务中写入（db 已在事务中时使用保存点）。
+// 返回每行的结果；数据库错误时整批失败
+func (s *LogService) IngestRequestLogs(db *gorm.DB, rows []BatchRow) (*BatchResult, error) {
+	result := &BatchResult{Results: make([]RowResult, 0, len(rows))}
+	if len(rows) == 0 {
+		return result, nil
+	}
+
+	rowResults := make([]RowResult, len(rows))
+	logs := make([]models.TokenUsageLog, 0, len(rows))
+	for i, row := range rows {
+		rowResults[i] = RowResult{Index: row.Index}
+
+		var req CreateLogRequest
+		err := decodeBatchRow(row, &req)
+		rowResults[i].RequestID = req.RequestID // 字段类型错误时其余字段仍会解析，便于调用方定位
+		if err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		if err := validateRequestID(req.RequestID); err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		parsedTime, err := utils.ParseLogTime(req.Time)
+		if err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		logs = append(logs, *newTokenUsageLog(&req, parsedTime))
 	}
 
 	logger.Debug("批量创建日志：准备插入数据库", "total_count", len(logs))
 
-	// 使用 OnConflict 忽略重复的 request_id
-	result := database.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "request_id"}},
-		DoNothing: true,
-	}).Create(&logs)
+	// 忽略重复的 request_id
+	inserted, err := insertRequestLogs(db, logs)
+	if err != nil {
+		logger.Error("批量创建日志：数据库插入失败", "error", err, "total_count", len(logs))
+		return nil, errors.New("批量创建请求日志记录失败")
+	}
 
-	if result.Error != nil {
-		logger.Error("批量创建日志：数据库插入失败", "error", result.Error, "total_count", len(logs))
-		return 0
+	inserting := make(map[string]bool, len(inserted))
+	for _, log := range inserted {
+		inserting[log.RequestID] = true
+	}
+	for _, row := range rowResults {
+		if row.Status == "" {
+			row.Status = RowDuplicate
+			if inserting[row.RequestID] {
+				row.Status = RowInserted
+				delete(inserting, row.RequestID) // 批次内重复的后续行按重复处理
+			}
+		}
+		result.add(row)
 	}
 
-	insertedCount := int(result.RowsAffected)
-	if insertedCount == 0 {
-		logger.Warn("批量创建日志：没有新记录插入（可能所有记录都已存在）", "total_count", len(logs))
+	if result.Inserted == 0 {
+		logger.Warn("批量创建日志：没有新记录插入", "received_count", result.Received, "duplicate_count", result.Duplicate, "rejected_count", result.Rejected)
 	} else {
-		logger.Info("批量创建日志：插入成功", "total_count", len(logs), "inserted_count", insertedCount)
+		logger.Info("批量创建日志：插入成功", "received_count", result.Received, "inserted_count", result.Inserted, "duplicate_count", result.Duplicate, "rejected_count", result.Rejected)
+		requestLogNotifier.Notify()
+	}
+
+	return result, nil
+}
+
+// insertRequestLogs 在同一事务中写入请求日志并累加预聚合统计，返回实际插入的记录
+// 已存在（或批次内重复）的 request_id 会被跳过，保证预聚合不会重复累加
+func insertRequestLogs(db *gorm.DB, logs []models.TokenUsageLog) ([]models.TokenUsageLog, error) {
+	var fresh []models.TokenUsageLog
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		fresh, err = filterExistingRequestLogs(tx, logs)
+		if err != nil {
+			return err
+		}
+		if len(fresh) == 0 {
+			return nil
+		}
+
+		rowsAffected, err := database.CreateIgnoreConflicts(tx, &fresh, "request_id")
+		if err != nil {
+			return err
+		}
+		if rowsAffected != int64(len(fresh)) {
+			// 并发写入了相同的 request_id，回滚以免预聚合重复累加，由调用方重试
+			return errors.New("存在并发写入的重复 request_id")
+		}
+
+		return applyRollups(tx, fresh)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return fresh, nil
+}
+
+// filterExistingRequestLogs 过滤批次内重复及数据库中已存在的 request_id
+func filterExistingRequestLogs(tx *gorm.DB, logs []models.TokenUsageLog) ([]models.TokenUsageLog, error) {
+	seen := make(map[string]bool, len(logs))
+	ids := make([]string, 0, len(logs))
+	for _, log := range logs {
+		if !seen[log.RequestID] {
+			seen[log.RequestID] = true
+			ids = append(ids, log.RequestID)
+		}
 	}
 
-	// 返回实际插入的记录数
-	return insertedCount
+	existing, err := existingRequestIDs(tx, &models.TokenUsageLog{}, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	fresh := make([]models.TokenUsageLog, 0, len(logs))
+	for _, log := range logs {
+		if existing[log.RequestID] {
+			continue
+		}
+		existing[log.RequestID] = true
+		fresh = append(fresh, log)
+	}
+	return fresh, nil
 }
 
 // DeleteLogsByTimeRangeRequest 按时间范围删除请求日志
 type DeleteLogsByTimeRangeRequest struct {
 	StartTime       string `json:"start_time" binding:"required"`
 	EndTime         string `json:"end_time" binding:"required"`
+	TZ              string `json:"tz"` // 时间不带时区时使用的时区，为空时使用配置的默认时区
 	SystemAuthToken string `json:"system_auth_token" binding:"required"`
 }
 
@@ -258,27 +414,99 @@ func (s *LogService) DeleteLogsByTimeRange(req *DeleteLogsByTimeRangeRequest) (*
 	}
 
 	// 解析时间范围
-	startTime, err := time.Parse("2006-01-02 15:04:05", req.StartTime)
+	startTime, endTime, err := parseDeleteTimeRange(req.StartTime, req.EndTime, req.TZ)
 	if err != nil {
-		return nil, errors.New("开始时间格式错误，正确格式为: 2006-01-02 15:04:05")
+		return nil, err
 	}
 
-	endTime, err := time.Parse("2006-01-02 15:04:05", req.EndTime)
+	// 执行硬删除，并在同一事务中记录为清理记录：预聚合数据保留不变，
+	// 重建预聚合时跳过这些自然日（见 rebuildableRange），不会用残缺的原始日志覆盖
+	began := time.Now().UTC()
+	var deleted int64
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		// 先计数再删除：ClickHouse 的删除不返回影响行数
+		if err := tx.Unscoped().Model(&models.TokenUsageLog{}).
+			Where("time >= ? AND time <= ?", startTime, endTime).
+			Count(&deleted).Error; err != nil {
+			return err
+		}
+		if deleted == 0 {
+			return nil
+		}
+		if err := tx.Unscoped().Where("time >= ? AND time <= ?", startTime, endTime).Delete(&models.TokenUsageLog{}).Error; err != nil {
+			return err
+		}
+
+		finished := time.Now().UTC()
+		return tx.Create(&models.PurgeRun{
+			TriggeredBy:        models.PurgeTriggerDelete,
+			Status:             models.PurgeStatusSuccess,
+			StartedAt:          began,
+			FinishedAt:         finished,
+			DurationMs:         finished.Sub(began).Milliseconds(),
+			DeletedRequestLogs: deleted,
+			Details: models.PurgeRuleResults{{
+				Table:   models.TokenUsageLog{}.TableName(),
+				Rule:    "time_range",
+				Cutoff:  endTime,
+				Deleted: deleted,
+				From:    &startTime,
+			}},
+		}).Error
+	})
 	if err != nil {
-		return nil, errors.New("结束时间格式错误，正确格式为: 2006-01-02 15:04:05")
+		return nil, errors.New("删除日志记录失败")
 	}
 
-	if startTime.After(endTime) {
-		return nil, errors.New("开始时间不能晚于结束时间")
+	return &DeleteLogsByTimeRangeResponse{
+		DeletedCount: deleted,
+	}, nil
+}
+
+// applyTimeFilter 按 start_time / end_time 过滤 time 列，空值不过滤
+// 带时区的 RFC3339 时间按自身偏移解析，不带时区的时间按 tz 解析
+func applyTimeFilter(query *gorm.DB, startTime, endTime, tz string) (*gorm.DB, error) {
+	loc, err := utils.LoadTimeZone(tz)
+	if err != nil {
+		return nil, err
 	}
 
-	// 执行硬删除
-	result := database.DB.Unscoped().Where("time >= ? AND time <= ?", startTime, endTime).Delete(&models.TokenUsageLog{})
-	if result.Error != nil {
-		return nil, errors.New("删除日志记录失败")
+	if startTime != "" {
+		t, err := utils.ParseTimeParam(startTime, loc)
+		if err != nil {
+			return nil, errors.New("开始时间" + err.Error())
+		}
+		query = query.Where("time >= ?", t)
 	}
+	if endTime != "" {
+		t, err := utils.ParseTimeParam(endTime, loc)
+		if err != nil {
+			return nil, errors.New("结束时间" + err.Error())
+		}
+		query = query.Where("time <= ?", t)
+	}
+	return query, nil
+}
 
-	return &DeleteLogsByTimeRangeResponse{
-		DeletedCount: result.RowsAffected,
-	}, nil
+// parseDeleteTimeRange 解析删除接口的时间范围，返回 UTC 时间
+func parseDeleteTimeRange(startTimeStr, endTimeStr, tz string) (time.Time, time.Time, error) {
+	loc, err := utils.LoadTimeZone(tz)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	startTime, err := utils.ParseTimeParam(startTimeStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("开始时间" + err.Error())
+	}
+
+	endTime, err := utils.ParseTimeParam(endTimeStr, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, errors.New("结束时间" + err.Error())
+	}
+
+	if startTime.After(endTime) {
+		return time.Time{}, time.Time{}, errors.New("开始时间不能晚于结束时间")
+	}
+	return startTime, endTime, nil
 }
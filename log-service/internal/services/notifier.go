@@ -0,0 +1,54 @@
+// Package services 业务逻辑服务层
+// 实现新日志写入通知，用于实时日志流唤醒订阅者
+package services
+
+import "sync"
+
+// Notifier 新数据写入通知器
+// 写入方调用 Notify，订阅方收到信号后自行按 ID 增量查询，信号可合并
+type Notifier struct {
+	mu          sync.Mutex
+	subscribers map[chan struct{}]struct{}
+}
+
+// NewNotifier 创建通知器
+func NewNotifier() *Notifier {
+	return &Notifier{
+		subscribers: make(map[chan struct{}]struct{}),
+	}
+}
+
+// Subscribe 订阅通知，返回信号通道和取消订阅函数
+func (n *Notifier) Subscribe() (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+
+	n.mu.Lock()
+	n.subscribers[ch] = struct{}{}
+	n.mu.Unlock()
+
+	return ch, func() {
+		n.mu.Lock()
+		delete(n.subscribers, ch)
+		n.mu.Unlock()
+	}
+}
+
+// Notify 通知所有订阅者有新数据（非阻塞，未消费的信号会合并）
+func (n *Notifier) Notify() {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	for ch := range n.subscribers {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+var (
+	// requestLogNotifier 请求日志写入通知
+	requestLogNotifier = NewNotifier()
+	// systemLogNotifier 系统日志写入通知
+	systemLogNotifier = NewNotifier()
+)
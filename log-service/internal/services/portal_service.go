@@ -0,0 +1,465 @@
+// Package services 业务逻辑服务层
+// 客户自助查询：客户使用自己的 sk- Token 查询剩余额度、过期时间、按天按模型用量与最近请求，只返回该 Token 的数据
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+
+	"gorm.io/gorm"
+)
+
+// portalLookupTimeout 调用管理服务校验 Token 的超时时间
+const portalLookupTimeout = 5 * time.Second
+
+// portalCacheMaxEntries Token 校验缓存条数超过该值时清理过期条目
+const portalCacheMaxEntries = 10000
+
+var (
+	// ErrPortalUnauthorized Token 无效
+	ErrPortalUnauthorized = errors.New("无效的 Token")
+	// ErrPortalParam 自助查询接口参数错误
+	ErrPortalParam = errors.New("参数错误")
+)
+
+// portalCacheEntry Token 校验结果缓存，token 为 nil 表示 Token 不存在
+type portalCacheEntry struct {
+	token     *PortalToken
+	expiresAt time.Time
+}
+
+// portalCache Token 校验结果缓存，避免每次请求都调用管理服务
+var portalCache = struct {
+	sync.Mutex
+	entries map[string]portalCacheEntry
+}{entries: make(map[string]portalCacheEntry)}
+
+// PortalService 客户自助查询服务
+type PortalService struct {
+	statisticsService *StatisticsService
+}
+
+// NewPortalService 创建客户自助查询服务实例
+func NewPortalService() *PortalService {
+	return &PortalService{
+		statisticsService: NewStatisticsService(),
+	}
+}
+
+// PortalToken 管理服务返回的 Token 信息（只保留客户可见的字段）
+type PortalToken struct {
+	Token      string     `json:"token"`
+	ModelName  string     `json:"model_name"`
+	Status     int        `json:"status"`
+	ExpireAt   *time.Time `json:"expire_at"`
+	UsageLimit int        `json:"usage_limit"`
+}
+
+// PortalOverview Token 概览
+type PortalOverview struct {
+	Token             string     `json:"token"`              // 脱敏后的 Token
+	ModelName         string     `json:"model_name"`         // 关联的模型名称
+	Status            int        `json:"status"`             // 1=启用，0=禁用
+	ExpireAt          *time.Time `json:"expire_at"`          // 过期时间，null 表示永不过期
+	Expired           bool       `json:"expired"`            // 是否已过期
+	UsageLimit        int        `json:"usage_limit"`        // 请求次数限额，0 表示无限制
+	UsedRequests      int64      `json:"used_requests"`      // 累计请求次数
+	RemainingRequests *int64     `json:"remaining_requests"` // 剩余请求次数，无限制时为 null
+	PromptTokens      int64      `json:"prompt_tokens"`      // 累计输入 token
+	CompletionTokens  int64      `json:"completion_tokens"`  // 累计输出 token
+	TotalTokens       int64      `json:"total_tokens"`       // 累计总 token
+	LastUsedAt        *time.Time `json:"last_used_at"`       // 最近一次请求时间
+}
+
+// PortalUsageRequest 用量查询请求
+type PortalUsageRequest struct {
+	StartTime string `form:"start_time"`
+	EndTime   string `form:"end_time"`
+	TZ        string `form:"tz"` // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
+}
+
+// PortalUsageResponse 按天、按模型的用量
+type PortalUsageResponse struct {
+	TimeRange TimeRange        `json:"time_range"`
+	Days      []PortalDayUsage `json:"days"`
+}
+
+// PortalDayUsage 单日用量
+type PortalDayUsage struct {
+	Date string `json:"date"`
+	PortalUsage
+	Models []PortalModelUsage `json:"models"`
+}
+
+// PortalModelUsage 单日单模型用量
+type PortalModelUsage struct {
+	AIModelName string `json:"ai_model_name"`
+	PortalUsage
+}
+
+// PortalUsage 用量计数
+type PortalUsage struct {
+	RequestCount     int64 `json:"request_count"`
+	ErrorCount       int64 `json:"error_count"` // 状态码 >= 400 的请求数
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+}
+
+// PortalRequestsRequest 最近请求查询请求
+type PortalRequestsRequest struct {
+	Page      int    `form:"page"`
+	PageSize  int    `form:"page_size"`
+	StartTime string `form:"start_time"`
+	EndTime   string `form:"end_time"`
+	TZ        string `form:"tz"`
+}
+
+// PortalRequestsResponse 最近请求列表
+type PortalRequestsResponse struct {
+	Total int64               `json:"total"`
+	List  []PortalRequestItem `json:"list"`
+}
+
+// PortalRequestItem 单条请求（不含请求/响应体、请求头与来源信息）
+type PortalRequestItem struct {
+	RequestID          string    `json:"request_id"`
+	Time               time.Time `json:"time"`
+	Method             string    `json:"method"`
+	Path               string    `json:"path"`
+	Status             int       `json:"status"`
+	AIModelName        string    `json:"ai_model_name"`
+	IsStream           bool      `json:"is_stream"`
+	LatencyMs          int64     `json:"latency_ms"`
+	FirstByteLatencyMs int64     `json:"first_byte_latency_ms"`
+	RequestSizeBytes   int       `json:"request_size_bytes"`
+	ResponseSizeBytes  int       `json:"response_size_bytes"`
+	PromptTokens       int64     `json:"prompt_tokens"`
+	CompletionTokens   int64     `json:"completion_tokens"`
+	TotalTokens        int64     `json:"total_tokens"`
+}
+
+// ValidatePortalConfig 校验客户自助查询配置
+func ValidatePortalConfig(cfg config.PortalConfig) error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.ServerURL == "" || cfg.SystemToken == "" {
+		return errors.New("server_url 与 system_token 必填")
+	}
+	if d, err := time.ParseDuration(cfg.CacheTTL); err != nil || d < 0 {
+		return fmt.Errorf("cache_ttl 格式错误: %s", cfg.CacheTTL)
+	}
+	if cfg.RateLimit < 0 || cfg.IPRateLimit < 0 {
+		return errors.New("rate_limit / ip_rate_limit 不能为负数")
+	}
+	if cfg.MaxDays < 1 {
+		return errors.New("max_days 必须大于 0")
+	}
+	return nil
+}
+
+// Authenticate 校验客户 Token，Token 不存在（或已删除）时返回 ErrPortalUnauthorized
+// 校验结果（包括不存在）按 cache_ttl 缓存
+func (s *PortalService) Authenticate(ctx context.Context, token string) (*PortalToken, error) {
+	if !strings.HasPrefix(token, "sk-") {
+		return nil, ErrPortalUnauthorized
+	}
+
+	now := time.Now()
+	portalCache.Lock()
+	entry, ok := portalCache.entries[token]
+	portalCache.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		if entry.token == nil {
+			return nil, ErrPortalUnauthorized
+		}
+		return entry.token, nil
+	}
+
+	cfg := config.GetConfig().Portal
+	info, err := s.lookupToken(ctx, cfg, token)
+	if err != nil && !errors.Is(err, ErrPortalUnauthorized) {
+		return nil, err
+	}
+
+	ttl, _ := time.ParseDuration(cfg.CacheTTL)
+	portalCache.Lock()
+	if len(portalCache.entries) >= portalCacheMaxEntries {
+		for key, e := range portalCache.entries {
+			if !now.Before(e.expiresAt) {
+				delete(portalCache.entries, key)
+			}
+		}
+	}
+	portalCache.entries[token] = portalCacheEntry{token: info, expiresAt: now.Add(ttl)}
+	portalCache.Unlock()
+
+	return info, err
+}
+
+// lookupToken 调用管理服务的系统认证接口按 Token 值查询
+func (s *PortalService) lookupToken(ctx context.Context, cfg config.PortalConfig, token string) (*PortalToken, error) {
+	ctx, cancel := context.WithTimeout(ctx, portalLookupTimeout)
+	defer cancel()
+
+	// Token 放在请求体中，避免出现在管理服务的访问日志里
+	body, err := json.Marshal(map[string]string{"token": token})
+	if err != nil {
+		return nil, err
+	}
+
+	lookupURL := strings.TrimRight(cfg.ServerURL, "/") + "/api/tokens/lookup"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, lookupURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+cfg.SystemToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("校验 Token 失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrPortalUnauthorized
+	}
+
+	var result struct {
+		Code    int          `json:"code"`
+		Message string       `json:"message"`
+		Data    *PortalToken `json:"data"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("校验 Token 失败: 管理服务返回 HTTP %d", resp.StatusCode)
+	}
+	if resp.StatusCode != http.StatusOK || result.Code != 0 || result.Data == nil {
+		return nil, fmt.Errorf("校验 Token 失败: 管理服务返回 HTTP %d: %s", resp.StatusCode, result.Message)
+	}
+	return result.Data, nil
+}
+
+// Overview Token 概览：状态、过期时间、限额与累计用量（来自按天预聚合，原始日志清理后仍然准确）
+func (s *PortalService) Overview(token *PortalToken) (*PortalOverview, error) {
+	type result struct {
+		RequestCount     int64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+	}
+	var usage result
+	if err := database.DB.Model(&models.UsageRollup{}).
+		Select("COALESCE(SUM(request_count), 0) AS request_count, "+
+			"COALESCE(SUM(prompt_tokens), 0) AS prompt_tokens, "+
+			"COALESCE(SUM(completion_tokens), 0) AS completion_tokens, "+
+			"COALESCE(SUM(total_tokens), 0) AS total_tokens").
+		Where("granularity = ?", models.RollupGranularityDay).
+		Where(`"authorization" IN ?`, portalAuthorizations(token.Token)).
+		Scan(&usage).Error; err != nil {
+		return nil, errors.New("查询用量失败")
+	}
+
+	var last []models.TokenUsageLog
+	if err := portalLogs(token).
+		Select("time").
+		Order("time DESC").
+		Limit(1).
+		Find(&last).Error; err != nil {
+		return nil, errors.New("查询用量失败")
+	}
+
+	overview := &PortalOverview{
+		Token:            maskToken(token.Token),
+		ModelName:        token.ModelName,
+		Status:           token.Status,
+		ExpireAt:         token.ExpireAt,
+		Expired:          token.ExpireAt != nil && token.ExpireAt.Before(time.Now()),
+		UsageLimit:       token.UsageLimit,
+		UsedRequests:     usage.RequestCount,
+		PromptTokens:     usage.PromptTokens,
+		CompletionTokens: usage.CompletionTokens,
+		TotalTokens:      usage.TotalTokens,
+	}
+	if token.UsageLimit > 0 {
+		remaining := int64(token.UsageLimit) - usage.RequestCount
+		if remaining < 0 {
+			remaining = 0
+		}
+		overview.RemainingRequests = &remaining
+	}
+	if len(last) > 0 {
+		overview.LastUsedAt = &last[0].Time
+	}
+	return overview, nil
+}
+
+// Usage 按天（请求时区）、按模型统计用量，默认最近 7 天，时间跨度不超过 max_days
+func (s *PortalService) Usage(token *PortalToken, req *PortalUsageRequest) (*PortalUsageResponse, error) {
+	startTime, endTime, loc, err := s.statisticsService.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPortalParam, err.Error())
+	}
+	if maxDays := config.GetConfig().Portal.MaxDays; endTime.Sub(startTime) > time.Duration(maxDays)*24*time.Hour {
+		return nil, fmt.Errorf("%w: 时间跨度不能超过 %d 天", ErrPortalParam, maxDays)
+	}
+
+	type result struct {
+		Bucket           string
+		AIModelName      string
+		StatusClass      string
+		RequestCount     int64
+		PromptTokens     int64
+		CompletionTokens int64
+		TotalTokens      int64
+	}
+	query, bucket := rollupBuckets(database.TimeUnitDay, startTime, endTime, utils.ZoneOffset(loc, endTime))
+	var results []result
+	if err := query.
+		Select(bucket+" AS bucket, ai_model_name, status_class, "+
+			"SUM(request_count) AS request_count, SUM(prompt_tokens) AS prompt_tokens, "+
+			"SUM(completion_tokens) AS completion_tokens, SUM(total_tokens) AS total_tokens").
+		Where(`"authorization" IN ?`, portalAuthorizations(token.Token)).
+		Group(bucket + ", ai_model_name, status_class").
+		Scan(&results).Error; err != nil {
+		return nil, errors.New("查询用量失败")
+	}
+
+	days := make(map[string]*PortalDayUsage)
+	dayModels := make(map[string]map[string]*PortalModelUsage)
+	for _, r := range results {
+		day, ok := days[r.Bucket]
+		if !ok {
+			day = &PortalDayUsage{Date: r.Bucket}
+			days[r.Bucket] = day
+			dayModels[r.Bucket] = make(map[string]*PortalModelUsage)
+		}
+		model, ok := dayModels[r.Bucket][r.AIModelName]
+		if !ok {
+			model = &PortalModelUsage{AIModelName: r.AIModelName}
+			dayModels[r.Bucket][r.AIModelName] = model
+		}
+
+		usage := PortalUsage{
+			RequestCount:     r.RequestCount,
+			PromptTokens:     r.PromptTokens,
+			CompletionTokens: r.CompletionTokens,
+			TotalTokens:      r.TotalTokens,
+		}
+		if r.StatusClass == "4xx" || r.StatusClass == "5xx" {
+			usage.ErrorCount = r.RequestCount
+		}
+		day.add(usage)
+		model.add(usage)
+	}
+
+	response := &PortalUsageResponse{
+		TimeRange: newTimeRange(startTime, endTime, loc),
+		Days:      make([]PortalDayUsage, 0, len(days)),
+	}
+	for date, day := range days {
+		for _, model := range dayModels[date] {
+			day.Models = append(day.Models, *model)
+		}
+		sort.Slice(day.Models, func(i, j int) bool {
+			return day.Models[i].AIModelName < day.Models[j].AIModelName
+		})
+		response.Days = append(response.Days, *day)
+	}
+	sort.Slice(response.Days, func(i, j int) bool {
+		return response.Days[i].Date < response.Days[j].Date
+	})
+	return response, nil
+}
+
+// add 累加用量
+func (u *PortalUsage) add(other PortalUsage) {
+	u.RequestCount += other.RequestCount
+	u.ErrorCount += other.ErrorCount
+	u.PromptTokens += other.PromptTokens
+	u.CompletionTokens += other.CompletionTokens
+	u.TotalTokens += other.TotalTokens
+}
+
+// Requests 最近请求列表（按时间倒序），不返回请求/响应体、请求头与来源 IP
+func (s *PortalService) Requests(token *PortalToken, req *PortalRequestsRequest) (*PortalRequestsResponse, error) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	loc, err := utils.LoadTimeZone(req.TZ)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrPortalParam, err.Error())
+	}
+
+	query := portalLogs(token)
+	if req.StartTime != "" {
+		startTime, err := utils.ParseTimeParam(req.StartTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 开始时间%s", ErrPortalParam, err.Error())
+		}
+		query = query.Where("time >= ?", startTime)
+	}
+	if req.EndTime != "" {
+		endTime, err := utils.ParseTimeParam(req.EndTime, loc)
+		if err != nil {
+			return nil, fmt.Errorf("%w: 结束时间%s", ErrPortalParam, err.Error())
+		}
+		query = query.Where("time <= ?", endTime)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.New("查询请求记录失败")
+	}
+
+	list := make([]PortalRequestItem, 0, pageSize)
+	if err := query.
+		Select("request_id", "time", "method", "path", "status", "ai_model_name", "is_stream",
+			"latency_ms", "first_byte_latency_ms", "request_size_bytes", "response_size_bytes",
+			"prompt_tokens", "completion_tokens", "total_tokens").
+		Order("time DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Scan(&list).Error; err != nil {
+		return nil, errors.New("查询请求记录失败")
+	}
+
+	return &PortalRequestsResponse{
+		Total: total,
+		List:  list,
+	}, nil
+}
+
+// portalLogs 只包含该 Token 请求日志的查询
+func portalLogs(token *PortalToken) *gorm.DB {
+	return database.DB.Model(&models.TokenUsageLog{}).
+		Where(`"authorization" IN ?`, portalAuthorizations(token.Token))
+}
+
+// portalAuthorizations 日志中该 Token 可能的 authorization 取值（proxy 记录原始请求头，支持带或不带 Bearer 前缀）
+func portalAuthorizations(token string) []string {
+	return []string{token, "Bearer " + token, "bearer " + token}
+}
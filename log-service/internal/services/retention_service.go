@@ -0,0 +1,483 @@
+// Package services 业务逻辑服务层
+// 按保留策略分批清理过期的请求日志与系统日志，清理后回收存储空间并记录执行结果
+package services
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/archive"
+	"zxm_ai_admin/log-service/internal/config"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// purgeMu 保证同一时间只有一个清理任务在执行（定时任务与手动触发共用）
+var purgeMu sync.Mutex
+
+// ErrPurgeRunning 已有清理任务在执行
+var ErrPurgeRunning = errors.New("清理任务正在执行，请稍后再试")
+
+// RetentionService 数据保留服务
+type RetentionService struct{}
+
+// NewRetentionService 创建数据保留服务实例
+func NewRetentionService() *RetentionService {
+	return &RetentionService{}
+}
+
+// ListPurgeRunsRequest 清理记录列表查询请求
+type ListPurgeRunsRequest struct {
+	Page     int    `form:"page"`
+	PageSize int    `form:"page_size"`
+	Status   string `form:"status"`
+}
+
+// ListPurgeRunsResponse 清理记录列表查询响应
+type ListPurgeRunsResponse struct {
+	Total int64             `json:"total"`
+	List  []models.PurgeRun `json:"list"`
+}
+
+// retentionSettings 解析后的保留策略
+type retentionSettings struct {
+	interval   time.Duration
+	batchSize  int
+	batchPause time.Duration
+	batchTTL   time.Duration // 上传批次记录保留时长
+	targets    []purgeTarget
+	archive    archive.Store // 启用归档时的归档存储，删除前先归档
+}
+
+// purgeTarget 一条保留规则编译后的删除条件
+type purgeTarget struct {
+	table string
+	model interface{}
+	rule  string
+	days  int
+	where string // 规则匹配条件（已排除前面规则匹配的记录），为空表示匹配全部
+	args  []interface{}
+}
+
+// query 返回早于 cutoff 且匹配规则的记录（包含软删除的记录），maxID 大于 0 时只包含 id 不超过 maxID 的记录
+func (t purgeTarget) query(cutoff time.Time, maxID uint) *gorm.DB {
+	query := database.DB.Unscoped().Model(t.model).Where("time < ?", cutoff)
+	if t.where != "" {
+		query = query.Where(t.where, t.args...)
+	}
+	if maxID > 0 {
+		query = query.Where("id <= ?", maxID)
+	}
+	return query
+}
+
+// ValidateRetentionConfig 校验保留策略配置
+func ValidateRetentionConfig(cfg config.RetentionConfig) error {
+	_, err := parseRetentionConfig(cfg)
+	return err
+}
+
+// parseRetentionConfig 解析保留策略，将规则编译为删除条件
+// 规则按顺序匹配，后面的规则与默认天数都排除前面规则匹配的记录
+func parseRetentionConfig(cfg config.RetentionConfig) (*retentionSettings, error) {
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil || interval <= 0 {
+		return nil, fmt.Errorf("interval 格式错误: %s", cfg.Interval)
+	}
+	batchPause, err := time.ParseDuration(cfg.BatchPause)
+	if err != nil || batchPause < 0 {
+		return nil, fmt.Errorf("batch_pause 格式错误: %s", cfg.BatchPause)
+	}
+	if cfg.BatchSize <= 0 {
+		return nil, fmt.Errorf("batch_size 必须大于 0")
+	}
+	batchTTL, err := time.ParseDuration(cfg.IngestBatchTTL)
+	if err != nil || batchTTL <= 0 {
+		return nil, fmt.Errorf("ingest_batch_ttl 格式错误: %s", cfg.IngestBatchTTL)
+	}
+
+	settings := &retentionSettings{
+		interval:   interval,
+		batchSize:  cfg.BatchSize,
+		batchPause: batchPause,
+		batchTTL:   batchTTL,
+	}
+
+	requestTargets, err := compileRetentionRules("token_usage_logs", &models.TokenUsageLog{}, cfg.RequestLogs,
+		func(rule config.RetentionRule) (string, string, []interface{}, error) {
+			if rule.Status == "" {
+				return "", "", nil, errors.New("request_logs 规则需要配置 status")
+			}
+			where, args, err := parseStatusSelector(rule.Status)
+			return "status=" + rule.Status, where, args, err
+		})
+	if err != nil {
+		return nil, err
+	}
+	systemTargets, err := compileRetentionRules("system_logs", &models.SystemLog{}, cfg.SystemLogs,
+		func(rule config.RetentionRule) (string, string, []interface{}, error) {
+			if rule.Level == "" {
+				return "", "", nil, errors.New("system_logs 规则需要配置 level")
+			}
+			levels := splitSelector(strings.ToUpper(rule.Level))
+			return "level=" + rule.Level, "UPPER(level) IN ?", []interface{}{levels}, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+
+	settings.targets = append(requestTargets, systemTargets...)
+	return settings, nil
+}
+
+// compileRetentionRules 编译单张表的保留规则，天数为 0 的规则不删除但仍参与排除
+func compileRetentionRules(table string, model interface{}, cfg config.RetentionTableConfig,
+	selector func(rule config.RetentionRule) (string, string, []interface{}, error)) ([]purgeTarget, error) {
+	if cfg.Days < 0 {
+		return nil, fmt.Errorf("%s.days 不能小于 0", table)
+	}
+
+	var targets []purgeTarget
+	var previous []string
+	var previousArgs []interface{}
+
+	for i, rule := range cfg.Rules {
+		if rule.Days < 0 {
+			return nil, fmt.Errorf("%s 第 %d 条规则 days 不能小于 0", table, i+1)
+		}
+		name, where, args, err := selector(rule)
+		if err != nil {
+			return nil, fmt.Errorf("%s 第 %d 条规则: %w", table, i+1, err)
+		}
+
+		if rule.Days > 0 {
+			target := purgeTarget{table: table, model: model, rule: name, days: rule.Days, where: "(" + where + ")", args: args}
+			if len(previous) > 0 {
+				target.where += " AND NOT (" + strings.Join(previous, " OR ") + ")"
+				target.args = append(append([]interface{}{}, args...), previousArgs...)
+			}
+			targets = append(targets, target)
+		}
+
+		previous = append(previous, "("+where+")")
+		previousArgs = append(previousArgs, args...)
+	}
+
+	if cfg.Days > 0 {
+		target := purgeTarget{table: table, model: model, rule: "default", days: cfg.Days}
+		if len(previous) > 0 {
+			target.where = "NOT (" + strings.Join(previous, " OR ") + ")"
+			target.args = previousArgs
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+// parseStatusSelector 解析状态码选择器，如 2xx、4xx,5xx、429
+func parseStatusSelector(selector string) (string, []interface{}, error) {
+	var conditions []string
+	var args []interface{}
+	for _, part := range splitSelector(strings.ToLower(selector)) {
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			class, err := strconv.Atoi(part[:1])
+			if err != nil || class < 1 || class > 5 {
+				return "", nil, fmt.Errorf("无效的状态码分类: %s", part)
+			}
+			conditions = append(conditions, "(status >= ? AND status < ?)")
+			args = append(args, class*100, class*100+100)
+			continue
+		}
+		code, err := strconv.Atoi(part)
+		if err != nil || code < 100 || code > 599 {
+			return "", nil, fmt.Errorf("无效的状态码: %s", part)
+		}
+		conditions = append(conditions, "status = ?")
+		args = append(args, code)
+	}
+	if len(conditions) == 0 {
+		return "", nil, fmt.Errorf("无效的状态码: %s", selector)
+	}
+	return strings.Join(conditions, " OR "), args, nil
+}
+
+// splitSelector 按逗号拆分选择器并去除空白
+func splitSelector(selector string) []string {
+	var parts []string
+	for _, part := range strings.Split(selector, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return parts
+}
+
+// Schedule 按配置的间隔定时执行清理，直到 ctx 结束
+func (s *RetentionService) Schedule(ctx context.Context) {
+	settings, err := parseRetentionConfig(config.GetConfig().Retention)
+	if err != nil {
+		logger.Error("数据保留策略配置错误，定时清理未启动", "error", err)
+		return
+	}
+	logger.Info("数据保留定时清理已启动", "interval", settings.interval.String(), "rules", len(settings.targets))
+
+	ticker := time.NewTicker(settings.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := s.Run(ctx, models.PurgeTriggerSchedule); err != nil && !errors.Is(err, ErrPurgeRunning) {
+			logger.Error("定时清理执行失败", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run 按保留策略执行一次清理并记录执行结果
+// 已有清理任务在执行时返回 ErrPurgeRunning；ctx 结束时在当前批次完成后停止
+func (s *RetentionService) Run(ctx context.Context, triggeredBy string) (*models.PurgeRun, error) {
+	if !purgeMu.TryLock() {
+		return nil, ErrPurgeRunning
+	}
+	defer purgeMu.Unlock()
+
+	settings, err := parseRetentionConfig(config.GetConfig().Retention)
+	if err != nil {
+		return nil, err
+	}
+	if archiveCfg := config.GetConfig().Archive; archiveCfg.Enabled {
+		if settings.archive, err = archive.NewStore(archiveCfg); err != nil {
+			return nil, err
+		}
+	}
+
+	// 顺带删除过期的归档导入临时表和上传批次记录
+	if _, err := NewArchiveService().DropExpiredImports(); err != nil {
+		logger.Error("数据清理：删除过期归档导入失败", "error", err)
+	}
+	if _, err := NewIngestBatchService().DeleteExpired(time.Now().UTC().Add(-settings.batchTTL)); err != nil {
+		logger.Error("数据清理：删除过期上传批次记录失败", "error", err)
+	}
+
+	run := &models.PurgeRun{
+		TriggeredBy: triggeredBy,
+		Status:      models.PurgeStatusSuccess,
+		StartedAt:   time.Now().UTC(),
+		Details:     models.PurgeRuleResults{},
+	}
+
+	runErr := s.purge(ctx, settings, run)
+
+	// 有数据删除时回收空间，被取消时也回收已删除部分
+	if run.DeletedRequestLogs+run.DeletedSystemLogs > 0 && (runErr == nil || errors.Is(runErr, context.Canceled)) {
+		if err := database.ReclaimSpace(); err != nil {
+			logger.Error("数据清理：回收空间失败", "error", err)
+			runErr = fmt.Errorf("回收空间失败: %w", err)
+		} else {
+			run.Vacuumed = true
+		}
+	}
+
+	switch {
+	case runErr == nil:
+	case errors.Is(runErr, context.Canceled), errors.Is(runErr, context.DeadlineExceeded):
+		run.Status = models.PurgeStatusCanceled
+		run.Error = runErr.Error()
+	default:
+		run.Status = models.PurgeStatusFailed
+		run.Error = runErr.Error()
+	}
+	run.FinishedAt = time.Now().UTC()
+	run.DurationMs = run.FinishedAt.Sub(run.StartedAt).Milliseconds()
+
+	if err := database.DB.Create(run).Error; err != nil {
+		logger.Error("数据清理：保存执行记录失败", "error", err)
+	}
+
+	logger.Info("数据清理完成",
+		"triggered_by", triggeredBy,
+		"status", run.Status,
+		"deleted_request_logs", run.DeletedRequestLogs,
+		"deleted_system_logs", run.DeletedSystemLogs,
+		"vacuumed", run.Vacuumed,
+		"duration_ms", run.DurationMs,
+	)
+
+	if run.Status == models.PurgeStatusFailed {
+		return run, runErr
+	}
+	return run, nil
+}
+
+// purge 依次执行每条规则的删除，结果累加到 run
+func (s *RetentionService) purge(ctx context.Context, settings *retentionSettings, run *models.PurgeRun) error {
+	for i, target := range settings.targets {
+		result := models.PurgeRuleResult{
+			Table:  target.table,
+			Rule:   target.rule,
+			Days:   target.days,
+			Cutoff: run.StartedAt.AddDate(0, 0, -target.days),
+		}
+		// 同一次清理中不同规则的归档文件用规则序号区分
+		batch := fmt.Sprintf("%s-r%d", run.StartedAt.Format("20060102T150405Z"), i+1)
+		err := s.purgeTarget(ctx, settings, target, batch, &result)
+
+		run.Details = append(run.Details, result)
+		if target.table == "token_usage_logs" {
+			run.DeletedRequestLogs += result.Deleted
+		} else {
+			run.DeletedSystemLogs += result.Deleted
+		}
+
+		if result.Deleted > 0 {
+			logger.Info("数据清理：规则删除完成", "table", target.table, "rule", target.rule, "days", target.days,
+				"archived", result.Archived, "deleted", result.Deleted)
+		}
+		if err != nil {
+			return fmt.Errorf("清理 %s（%s）失败: %w", target.table, target.rule, err)
+		}
+	}
+	return nil
+}
+
+// purgeTarget 删除单条规则匹配的过期记录；启用归档时先归档，归档上传成功后才删除
+// 以开始时匹配记录的最大 id 为上界，保证删除的记录都已归档
+func (s *RetentionService) purgeTarget(ctx context.Context, settings *retentionSettings, target purgeTarget, batch string, result *models.PurgeRuleResult) error {
+	var maxID sql.NullInt64
+	if err := target.query(result.Cutoff, 0).Select("MAX(id)").Row().Scan(&maxID); err != nil {
+		return err
+	}
+	if !maxID.Valid || maxID.Int64 <= 0 {
+		return nil
+	}
+
+	if settings.archive != nil {
+		archived, files, err := s.archiveTarget(ctx, settings, target, result.Cutoff, uint(maxID.Int64), batch)
+		result.Archived = archived
+		result.ArchiveFiles = files
+		if err != nil {
+			return fmt.Errorf("归档失败，未删除: %w", err)
+		}
+	}
+
+	deleted, err := s.deleteInBatches(ctx, settings, target, result.Cutoff, uint(maxID.Int64))
+	result.Deleted = deleted
+	return err
+}
+
+// archiveTarget 将待删除的记录按日期分区写入归档文件并上传，返回归档的记录数与文件键
+func (s *RetentionService) archiveTarget(ctx context.Context, settings *retentionSettings, target purgeTarget, cutoff time.Time, maxID uint, batch string) (int64, []string, error) {
+	w, err := archive.NewWriter(settings.archive, config.GetConfig().Archive.Prefix, target.table, batch)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer w.Close()
+
+	archived, err := archiveRows(ctx, func() *gorm.DB { return target.query(cutoff, maxID) }, target.model, settings.batchSize, w)
+	if err != nil {
+		return archived, nil, err
+	}
+
+	files, err := w.Commit(ctx)
+	if err != nil {
+		return archived, files, err
+	}
+	if len(files) > 0 {
+		logger.Info("数据清理：归档完成", "table", target.table, "rule", target.rule, "archived", archived, "files", len(files))
+	}
+	return archived, files, nil
+}
+
+// deleteInBatches 分批删除匹配规则、早于 cutoff 且 id 不超过 maxID 的记录，每批之间暂停以免长时间占用写锁。
+// 每批按 id 顺序取出待删除的 id，删除该 id 区间内匹配的记录，删除条数按取出的 id 计数：
+// ClickHouse 的删除是异步执行的 mutation，不返回影响行数，执行完成前记录仍然可见，因此下一批从本批最后一个 id 之后取
+func (s *RetentionService) deleteInBatches(ctx context.Context, settings *retentionSettings, target purgeTarget, cutoff time.Time, maxID uint) (int64, error) {
+	var total int64
+	var lastID uint
+	for {
+		if err := ctx.Err(); err != nil {
+			return total, err
+		}
+
+		var ids []uint
+		if err := target.query(cutoff, maxID).
+			Where("id > ?", lastID).
+			Order("id ASC").
+			Limit(settings.batchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return total, err
+		}
+		if len(ids) == 0 {
+			return total, nil
+		}
+
+		first, last := ids[0], ids[len(ids)-1]
+		if err := target.query(cutoff, last).Where("id >= ?", first).Delete(target.model).Error; err != nil {
+			return total, err
+		}
+		total += int64(len(ids))
+		lastID = last
+		if len(ids) < settings.batchSize {
+			return total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(settings.batchPause):
+		}
+	}
+}
+
+// ListPurgeRuns 获取清理执行记录列表（按开始时间倒序）
+func (s *RetentionService) ListPurgeRuns(req *ListPurgeRunsRequest) (*ListPurgeRunsResponse, error) {
+	page := req.Page
+	if page < 1 {
+		page = 1
+	}
+	pageSize := req.PageSize
+	if pageSize < 1 {
+		pageSize = 10
+	}
+	if pageSize > 100 {
+		pageSize = 100
+	}
+
+	query := database.DB.Model(&models.PurgeRun{})
+	if req.Status != "" {
+		query = query.Where("status = ?", req.Status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, errors.New("查询清理记录失败")
+	}
+
+	var list []models.PurgeRun
+	if err := query.
+		Order("started_at DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&list).Error; err != nil {
+		return nil, errors.New("查询清理记录失败")
+	}
+
+	return &ListPurgeRunsResponse{
+		Total: total,
+		List:  list,
+	}, nil
+}
@@ -0,0 +1,419 @@
+// Package services 业务逻辑服务层
+// 维护请求日志的按小时/按天预聚合统计，统计接口直接读取预聚合表
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/logger"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// rollupBatchSize 重建时每批读取的原始日志条数
+const rollupBatchSize = 1000
+
+// RollupService 预聚合统计服务
+type RollupService struct{}
+
+// NewRollupService 创建预聚合统计服务实例
+func NewRollupService() *RollupService {
+	return &RollupService{}
+}
+
+// RebuildRollupsResult 重建结果
+type RebuildRollupsResult struct {
+	FullRebuild bool          // 未指定时间范围，重建全部可重建的数据
+	StartTime   time.Time     // 实际重建的起始时间（按天对齐）
+	EndTime     time.Time     // 实际重建的结束时间（按天对齐，不含）
+	ScannedLogs int64         // 扫描的原始日志条数
+	RollupRows  int           // 写入的预聚合行数
+	DeletedRows int64         // 删除的旧预聚合行数
+	Skipped     []RollupSpan  // 范围内通过接口删除过请求日志、保留原有预聚合数据的自然日
+	ElapsedTime time.Duration // 耗时
+}
+
+// RollupSpan 左闭右开的时间段（按天对齐）
+type RollupSpan struct {
+	Start time.Time
+	End   time.Time
+}
+
+// rollupKey 预聚合维度
+type rollupKey struct {
+	granularity   string
+	bucketStart   int64 // Unix 秒
+	authorization string
+	path          string
+	statusClass   string
+	aiModelName   string
+}
+
+// hourBucket 时间所在小时的起点（UTC）
+func hourBucket(t time.Time) time.Time {
+	return t.UTC().Truncate(time.Hour)
+}
+
+// rollupLocation 按天预聚合使用的时区，即配置的默认时区（server.timezone）
+func rollupLocation() *time.Location {
+	loc, err := utils.LoadTimeZone("")
+	if err != nil {
+		// 启动时已校验配置的时区，这里只作兜底
+		return time.UTC
+	}
+	return loc
+}
+
+// dayBucket 时间所在自然日（配置的默认时区）的起点，以 UTC 表示
+func dayBucket(t time.Time) time.Time {
+	local := t.In(rollupLocation())
+	return time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, local.Location()).UTC()
+}
+
+// dayRollupsMatch 按 offset 偏移分组自然日时能否直接读取按天预聚合：
+// 要求 offset 在整个时间范围内与预聚合时区的偏移一致
+func dayRollupsMatch(startTime, endTime time.Time, offset int) bool {
+	loc := rollupLocation()
+	return utils.ZoneOffset(loc, startTime) == offset && utils.ZoneOffset(loc, endTime) == offset
+}
+
+// statusClass 状态码分类，如 200 -> 2xx
+func statusClass(status int) string {
+	if status < 100 || status > 599 {
+		return "other"
+	}
+	return fmt.Sprintf("%dxx", status/100)
+}
+
+// accumulateRollups 将日志累加到按维度分组的预聚合结果中
+func accumulateRollups(acc map[rollupKey]*models.UsageRollup, logs []models.TokenUsageLog) {
+	for i := range logs {
+		log := &logs[i]
+		class := statusClass(log.Status)
+
+		buckets := []struct {
+			granularity string
+			start       time.Time
+		}{
+			{models.RollupGranularityHour, hourBucket(log.Time)},
+			{models.RollupGranularityDay, dayBucket(log.Time)},
+		}
+		for _, b := range buckets {
+			key := rollupKey{
+				granularity:   b.granularity,
+				bucketStart:   b.start.Unix(),
+				authorization: log.Authorization,
+				path:          log.Path,
+				statusClass:   class,
+				aiModelName:   log.AIModelName,
+			}
+
+			row, ok := acc[key]
+			if !ok {
+				row = &models.UsageRollup{
+					Granularity:   b.granularity,
+					BucketStart:   b.start,
+					Authorization: log.Authorization,
+					Path:          log.Path,
+					StatusClass:   class,
+					AIModelName:   log.AIModelName,
+					LatencyMinMs:  log.LatencyMs,
+					LatencyMaxMs:  log.LatencyMs,
+				}
+				acc[key] = row
+			}
+
+			row.RequestCount++
+			row.RequestBytes += int64(log.RequestSizeBytes)
+			row.ResponseBytes += int64(log.ResponseSizeBytes)
+			row.LatencySumMs += log.LatencyMs
+			row.LatencyMinMs = min(row.LatencyMinMs, log.LatencyMs)
+			row.LatencyMaxMs = max(row.LatencyMaxMs, log.LatencyMs)
+			row.PromptTokens += log.PromptTokens
+			row.CompletionTokens += log.CompletionTokens
+			row.TotalTokens += log.TotalTokens
+		}
+	}
+}
+
+// sortedRollups 将预聚合结果按维度排序输出，保证批量 upsert 时加锁顺序一致
+func sortedRollups(acc map[rollupKey]*models.UsageRollup) []models.UsageRollup {
+	keys := make([]rollupKey, 0, len(acc))
+	for key := range acc {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := keys[i], keys[j]
+		if a.granularity != b.granularity {
+			return a.granularity < b.granularity
+		}
+		if a.bucketStart != b.bucketStart {
+			return a.bucketStart < b.bucketStart
+		}
+		if a.authorization != b.authorization {
+			return a.authorization < b.authorization
+		}
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.statusClass != b.statusClass {
+			return a.statusClass < b.statusClass
+		}
+		return a.aiModelName < b.aiModelName
+	})
+
+	rows := make([]models.UsageRollup, 0, len(keys))
+	for _, key := range keys {
+		rows = append(rows, *acc[key])
+	}
+	return rows
+}
+
+// applyRollups 在 tx 中将新写入的日志增量累加到预聚合表
+// 调用方需保证 logs 均为本次新插入的记录，否则会重复累加
+func applyRollups(tx *gorm.DB, logs []models.TokenUsageLog) error {
+	if len(logs) == 0 {
+		return nil
+	}
+
+	acc := make(map[rollupKey]*models.UsageRollup)
+	accumulateRollups(acc, logs)
+	rows := sortedRollups(acc)
+
+	dialect := database.CurrentDialect()
+	if !dialect.SupportsOnConflict() {
+		// 不支持 upsert 的后端以增量行写入，查询时按维度汇总
+		return tx.CreateInBatches(rows, 500).Error
+	}
+
+	table := models.UsageRollup{}.TableName()
+	existing := func(column string) string { return table + "." + column }
+	excluded := func(column string) string { return "excluded." + column }
+	sum := func(column string) clause.Expr {
+		return gorm.Expr(existing(column) + " + " + excluded(column))
+	}
+
+	return tx.Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "granularity"},
+			{Name: "bucket_start"},
+			{Name: "authorization"},
+			{Name: "path"},
+			{Name: "status_class"},
+			{Name: "ai_model_name"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"request_count":     sum("request_count"),
+			"request_bytes":     sum("request_bytes"),
+			"response_bytes":    sum("response_bytes"),
+			"latency_sum_ms":    sum("latency_sum_ms"),
+			"latency_min_ms":    gorm.Expr(dialect.Least(existing("latency_min_ms"), excluded("latency_min_ms"))),
+			"latency_max_ms":    gorm.Expr(dialect.Greatest(existing("latency_max_ms"), excluded("latency_max_ms"))),
+			"prompt_tokens":     sum("prompt_tokens"),
+			"completion_tokens": sum("completion_tokens"),
+			"total_tokens":      sum("total_tokens"),
+			"updated_at":        gorm.Expr(excluded("updated_at")),
+		}),
+	}).CreateInBatches(rows, 500).Error
+}
+
+// rebuildableRange 可安全重建的时间范围（按天对齐）：从原始日志最早的一天起，
+// 但不早于最近一次清理删除了请求日志的截止时间之后的第一天（截止时间之前的原始日志可能只剩一部分），
+// 到原始日志最晚的一天结束。通过接口按时间范围删除过请求日志的自然日作为 deleted 返回（按开始时间排序、不重叠），
+// 重建时跳过。没有可重建的原始日志时 ok 为 false
+func rebuildableRange() (safe RollupSpan, deleted []RollupSpan, ok bool, err error) {
+	// 按 time 排序取首尾记录（MIN/MAX 聚合在 SQLite 中返回文本，无法直接扫描为时间）
+	var first, last []models.TokenUsageLog
+	if err := database.DB.Select("time").Order("time ASC").Limit(1).Find(&first).Error; err != nil {
+		return RollupSpan{}, nil, false, fmt.Errorf("查询原始日志时间范围失败: %w", err)
+	}
+	if err := database.DB.Select("time").Order("time DESC").Limit(1).Find(&last).Error; err != nil {
+		return RollupSpan{}, nil, false, fmt.Errorf("查询原始日志时间范围失败: %w", err)
+	}
+	if len(first) == 0 || len(last) == 0 {
+		return RollupSpan{}, nil, false, nil
+	}
+	safe = RollupSpan{Start: dayBucket(first[0].Time), End: dayBucket(last[0].Time).Add(24 * time.Hour)}
+
+	var runs []models.PurgeRun
+	if err := database.DB.Select("details").
+		Where("deleted_request_logs > 0").
+		Find(&runs).Error; err != nil {
+		return RollupSpan{}, nil, false, fmt.Errorf("查询清理记录失败: %w", err)
+	}
+	for _, run := range runs {
+		for _, detail := range run.Details {
+			if detail.Table != (models.TokenUsageLog{}).TableName() || detail.Deleted == 0 {
+				continue
+			}
+			if detail.From != nil {
+				deleted = append(deleted, RollupSpan{Start: dayBucket(*detail.From), End: dayBucket(detail.Cutoff).Add(24 * time.Hour)})
+				continue
+			}
+			if start := dayBucket(detail.Cutoff).Add(24 * time.Hour); start.After(safe.Start) {
+				safe.Start = start
+			}
+		}
+	}
+	return safe, mergeSpans(deleted), safe.Start.Before(safe.End), nil
+}
+
+// mergeSpans 按开始时间排序并合并重叠或相邻的时间段
+func mergeSpans(spans []RollupSpan) []RollupSpan {
+	sort.Slice(spans, func(i, j int) bool { return spans[i].Start.Before(spans[j].Start) })
+	var merged []RollupSpan
+	for _, span := range spans {
+		if n := len(merged); n > 0 && !span.Start.After(merged[n-1].End) {
+			if span.End.After(merged[n-1].End) {
+				merged[n-1].End = span.End
+			}
+			continue
+		}
+		merged = append(merged, span)
+	}
+	return merged
+}
+
+// splitSpan 从 span 中去掉 excluded（已排序、不重叠）的部分，返回剩余的时间段与实际去掉的时间段
+func splitSpan(span RollupSpan, excluded []RollupSpan) (kept, removed []RollupSpan) {
+	cursor := span.Start
+	for _, ex := range excluded {
+		start, end := ex.Start, ex.End
+		if start.Before(cursor) {
+			start = cursor
+		}
+		if end.After(span.End) {
+			end = span.End
+		}
+		if !start.Before(end) {
+			continue
+		}
+		if cursor.Before(start) {
+			kept = append(kept, RollupSpan{Start: cursor, End: start})
+		}
+		removed = append(removed, RollupSpan{Start: start, End: end})
+		cursor = end
+	}
+	if cursor.Before(span.End) {
+		kept = append(kept, RollupSpan{Start: cursor, End: span.End})
+	}
+	return kept, removed
+}
+
+// Rebuild 根据原始日志重建指定时间范围的预聚合数据
+// 时间范围向外对齐到自然日（配置的默认时区），并限制在可安全重建的范围内（见 rebuildableRange），
+// 原始日志已被清理或通过接口删除的时间段保留原有的预聚合数据；start、end 均为零值时重建全部可重建的数据
+func (s *RollupService) Rebuild(start, end time.Time) (*RebuildRollupsResult, error) {
+	began := time.Now()
+	result := &RebuildRollupsResult{FullRebuild: start.IsZero() && end.IsZero()}
+
+	if !result.FullRebuild {
+		if start.IsZero() || end.IsZero() {
+			return nil, errors.New("开始时间和结束时间需要同时指定")
+		}
+		if start.After(end) {
+			return nil, errors.New("开始时间不能晚于结束时间")
+		}
+	}
+
+	safe, deletedSpans, ok, err := rebuildableRange()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, errors.New("没有可重建的原始日志")
+	}
+	result.StartTime, result.EndTime = safe.Start, safe.End
+	if !result.FullRebuild {
+		if rangeStart := dayBucket(start); rangeStart.After(result.StartTime) {
+			result.StartTime = rangeStart
+		}
+		if rangeEnd := dayBucket(end).Add(24 * time.Hour); rangeEnd.Before(result.EndTime) {
+			result.EndTime = rangeEnd
+		}
+		if !result.StartTime.Before(result.EndTime) {
+			return nil, fmt.Errorf("指定范围内没有可重建的原始日志，可重建范围为 %s ~ %s",
+				safe.Start.In(rollupLocation()).Format(time.RFC3339), safe.End.In(rollupLocation()).Format(time.RFC3339))
+		}
+	}
+
+	// 跳过通过接口删除过请求日志的自然日，保留其原有的预聚合数据
+	var spans []RollupSpan
+	spans, result.Skipped = splitSpan(RollupSpan{Start: result.StartTime, End: result.EndTime}, deletedSpans)
+	if len(spans) == 0 {
+		return nil, errors.New("指定范围内的自然日都曾通过接口删除请求日志，没有可重建的数据")
+	}
+
+	err = database.DB.Transaction(func(tx *gorm.DB) error {
+		acc := make(map[rollupKey]*models.UsageRollup)
+		for _, span := range spans {
+			scanned, deletedRows, err := rebuildSpan(tx, span, acc)
+			result.ScannedLogs += scanned
+			result.DeletedRows += deletedRows
+			if err != nil {
+				return err
+			}
+		}
+
+		rows := sortedRollups(acc)
+		if len(rows) > 0 {
+			if err := tx.CreateInBatches(rows, 500).Error; err != nil {
+				return fmt.Errorf("写入预聚合数据失败: %w", err)
+			}
+		}
+		result.RollupRows = len(rows)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result.ElapsedTime = time.Since(began)
+	logger.Info("预聚合数据重建完成",
+		"full_rebuild", result.FullRebuild,
+		"start_time", result.StartTime,
+		"end_time", result.EndTime,
+		"skipped_spans", len(result.Skipped),
+		"scanned_logs", result.ScannedLogs,
+		"rollup_rows", result.RollupRows,
+		"deleted_rows", result.DeletedRows,
+		"elapsed", result.ElapsedTime,
+	)
+	return result, nil
+}
+
+// rebuildSpan 删除时间段内的旧预聚合数据，并将时间段内的原始日志累加到 acc，
+// 返回扫描的原始日志条数与删除的旧预聚合行数
+func rebuildSpan(tx *gorm.DB, span RollupSpan, acc map[rollupKey]*models.UsageRollup) (int64, int64, error) {
+	deleted := tx.Where("bucket_start >= ? AND bucket_start < ?", span.Start, span.End).
+		Delete(&models.UsageRollup{})
+	if deleted.Error != nil {
+		return 0, 0, fmt.Errorf("删除旧预聚合数据失败: %w", deleted.Error)
+	}
+
+	// 分批扫描原始日志并累加
+	query := tx.Model(&models.TokenUsageLog{}).
+		Select("id", "time", "authorization", "path", "status", "ai_model_name",
+			"latency_ms", "request_size_bytes", "response_size_bytes",
+			"prompt_tokens", "completion_tokens", "total_tokens").
+		Where("time >= ? AND time < ?", span.Start, span.End)
+
+	var scanned int64
+	var batch []models.TokenUsageLog
+	scan := query.FindInBatches(&batch, rollupBatchSize, func(_ *gorm.DB, _ int) error {
+		accumulateRollups(acc, batch)
+		scanned += int64(len(batch))
+		return nil
+	})
+	if scan.Error != nil {
+		return scanned, deleted.RowsAffected, fmt.Errorf("读取原始日志失败: %w", scan.Error)
+	}
+	return scanned, deleted.RowsAffected, nil
+}
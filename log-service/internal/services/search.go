@@ -0,0 +1,341 @@
+// Package services 业务逻辑服务层
+// 实现请求日志全文检索的查询语法解析，将用户输入转换为 FTS5 MATCH 表达式
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"zxm_ai_admin/log-service/internal/database"
+)
+
+// searchFields 检索语法中的字段前缀与 FTS5 列名的映射
+var searchFields = map[string]string{
+	"body":         "request_body",
+	"request_body": "request_body",
+	"path":         "path",
+	"ua":           "user_agent",
+	"user_agent":   "user_agent",
+	"msg":          "msg",
+}
+
+// minSearchTermLength trigram 分词器要求检索词至少 3 个字符
+const minSearchTermLength = 3
+
+type searchTokenKind int
+
+const (
+	searchTokenTerm searchTokenKind = iota
+	searchTokenAnd
+	searchTokenOr
+	searchTokenNot
+	searchTokenLParen
+	searchTokenRParen
+)
+
+type searchToken struct {
+	kind   searchTokenKind
+	field  string // FTS5 列名，为空表示检索全部列
+	text   string
+	negate bool // 以 - 前缀表示排除
+}
+
+// searchColumns 全文检索覆盖的列
+var searchColumns = []string{"request_body", "path", "user_agent", "msg"}
+
+// searchNode 检索表达式语法树节点
+type searchNode struct {
+	op        string        // term / and / or
+	field     string        // term：FTS5 列名，为空表示全部列
+	text      string        // term：检索词
+	children  []*searchNode // and / or：子节点（and 中为肯定项）
+	negatives []*searchNode // and：排除项
+}
+
+// ParseSearchQuery 解析检索语法，校验并返回语法树
+//
+// 语法：
+//   - 词语：deepseek、"hello world"（引号内为整体短语，按子串匹配）
+//   - 字段前缀：body:、path:、ua:（user_agent:）、msg:，如 path:/v1/chat、body:"天气预报"
+//   - 布尔运算：AND（可省略）、OR、NOT 或 - 前缀、括号分组
+//
+// 示例：body:天气预报 ua:curl -path:/v1/models
+func ParseSearchQuery(input string) (*searchNode, error) {
+	tokens, err := tokenizeSearchQuery(input)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, errors.New("检索条件为空")
+	}
+
+	p := &searchParser{tokens: tokens}
+	node, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, errors.New("检索语法错误：括号不匹配")
+	}
+	return node, nil
+}
+
+// FTS5 渲染为 FTS5 MATCH 表达式
+// FTS5 的 NOT 为二元运算，排除项统一放在肯定项之后：(a AND b) NOT c
+func (n *searchNode) FTS5() string {
+	switch n.op {
+	case "term":
+		phrase := `"` + strings.ReplaceAll(n.text, `"`, `""`) + `"`
+		if n.field != "" {
+			return n.field + " : " + phrase
+		}
+		return phrase
+	case "or":
+		parts := make([]string, 0, len(n.children))
+		for _, child := range n.children {
+			parts = append(parts, child.FTS5())
+		}
+		return "(" + strings.Join(parts, " OR ") + ")"
+	default:
+		parts := make([]string, 0, len(n.children))
+		for _, child := range n.children {
+			parts = append(parts, child.FTS5())
+		}
+		expr := parts[0]
+		if len(parts) > 1 {
+			expr = "(" + strings.Join(parts, " AND ") + ")"
+		}
+		for _, neg := range n.negatives {
+			expr = "(" + expr + " NOT " + neg.FTS5() + ")"
+		}
+		return expr
+	}
+}
+
+// SQL 渲染为子串匹配条件（用于没有原生全文索引的存储后端），语义与 trigram 子串匹配一致
+func (n *searchNode) SQL(dialect database.Dialect) (string, []interface{}) {
+	switch n.op {
+	case "term":
+		columns := searchColumns
+		if n.field != "" {
+			columns = []string{n.field}
+		}
+		parts := make([]string, 0, len(columns))
+		args := make([]interface{}, 0, len(columns))
+		for _, column := range columns {
+			expr, arg := dialect.Contains(column, n.text)
+			parts = append(parts, expr)
+			args = append(args, arg)
+		}
+		return "(" + strings.Join(parts, " OR ") + ")", args
+	case "or":
+		return joinSearchSQL(n.children, " OR ", dialect)
+	default:
+		expr, args := joinSearchSQL(n.children, " AND ", dialect)
+		for _, neg := range n.negatives {
+			negExpr, negArgs := neg.SQL(dialect)
+			expr = "(" + expr + " AND NOT " + negExpr + ")"
+			args = append(args, negArgs...)
+		}
+		return expr, args
+	}
+}
+
+// joinSearchSQL 用指定运算符连接多个子节点的 SQL 条件
+func joinSearchSQL(nodes []*searchNode, sep string, dialect database.Dialect) (string, []interface{}) {
+	parts := make([]string, 0, len(nodes))
+	var args []interface{}
+	for _, node := range nodes {
+		expr, nodeArgs := node.SQL(dialect)
+		parts = append(parts, expr)
+		args = append(args, nodeArgs...)
+	}
+	return "(" + strings.Join(parts, sep) + ")", args
+}
+
+// tokenizeSearchQuery 将检索语法切分为词法单元
+func tokenizeSearchQuery(input string) ([]searchToken, error) {
+	var tokens []searchToken
+	rest := input
+
+	for {
+		rest = strings.TrimLeftFunc(rest, unicode.IsSpace)
+		if rest == "" {
+			return tokens, nil
+		}
+
+		switch rest[0] {
+		case '(':
+			tokens = append(tokens, searchToken{kind: searchTokenLParen})
+			rest = rest[1:]
+			continue
+		case ')':
+			tokens = append(tokens, searchToken{kind: searchTokenRParen})
+			rest = rest[1:]
+			continue
+		}
+
+		tok := searchToken{kind: searchTokenTerm}
+		if rest[0] == '-' && len(rest) > 1 && !unicode.IsSpace(rune(rest[1])) {
+			tok.negate = true
+			rest = rest[1:]
+		}
+
+		// 字段前缀
+		if idx := strings.IndexByte(rest, ':'); idx > 0 {
+			if column, ok := searchFields[strings.ToLower(rest[:idx])]; ok && !strings.ContainsAny(rest[:idx], " \t()\"") {
+				tok.field = column
+				rest = rest[idx+1:]
+			}
+		}
+
+		var text string
+		var quoted bool
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				return nil, errors.New("检索语法错误：引号不匹配")
+			}
+			text = rest[1 : end+1]
+			rest = rest[end+2:]
+			quoted = true
+		} else {
+			end := strings.IndexFunc(rest, func(r rune) bool {
+				return unicode.IsSpace(r) || r == '(' || r == ')'
+			})
+			if end < 0 {
+				end = len(rest)
+			}
+			text = rest[:end]
+			rest = rest[end:]
+		}
+
+		// 未加引号、无字段前缀的 AND/OR/NOT 为布尔运算符
+		if !quoted && tok.field == "" && !tok.negate {
+			switch text {
+			case "AND":
+				tokens = append(tokens, searchToken{kind: searchTokenAnd})
+				continue
+			case "OR":
+				tokens = append(tokens, searchToken{kind: searchTokenOr})
+				continue
+			case "NOT":
+				tokens = append(tokens, searchToken{kind: searchTokenNot})
+				continue
+			}
+		}
+
+		if utf8.RuneCountInString(text) < minSearchTermLength {
+			return nil, fmt.Errorf("检索词 %q 过短，至少需要 %d 个字符", text, minSearchTermLength)
+		}
+		tok.text = text
+		tokens = append(tokens, tok)
+	}
+}
+
+// searchParser 递归下降解析器，优先级：OR < AND < NOT
+type searchParser struct {
+	tokens []searchToken
+	pos    int
+}
+
+func (p *searchParser) peek() *searchToken {
+	if p.pos >= len(p.tokens) {
+		return nil
+	}
+	return &p.tokens[p.pos]
+}
+
+// parseOr or := and ("OR" and)*
+func (p *searchParser) parseOr() (*searchNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	children := []*searchNode{left}
+	for tok := p.peek(); tok != nil && tok.kind == searchTokenOr; tok = p.peek() {
+		p.pos++
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		children = append(children, right)
+	}
+	if len(children) == 1 {
+		return left, nil
+	}
+	return &searchNode{op: "or", children: children}, nil
+}
+
+// parseAnd and := item (("AND")? item)*，item 可带 NOT 或 - 前缀
+func (p *searchParser) parseAnd() (*searchNode, error) {
+	node := &searchNode{op: "and"}
+
+	for {
+		tok := p.peek()
+		if tok == nil || tok.kind == searchTokenOr || tok.kind == searchTokenRParen {
+			break
+		}
+		if tok.kind == searchTokenAnd {
+			p.pos++
+			continue
+		}
+
+		negate := false
+		if tok.kind == searchTokenNot {
+			negate = true
+			p.pos++
+		}
+
+		item, itemNegate, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		if negate || itemNegate {
+			node.negatives = append(node.negatives, item)
+		} else {
+			node.children = append(node.children, item)
+		}
+	}
+
+	if len(node.children) == 0 {
+		if len(node.negatives) > 0 {
+			return nil, errors.New("检索语法错误：NOT 需要与其他条件一起使用")
+		}
+		return nil, errors.New("检索语法错误：缺少检索词")
+	}
+	if len(node.children) == 1 && len(node.negatives) == 0 {
+		return node.children[0], nil
+	}
+	return node, nil
+}
+
+// parsePrimary primary := "(" or ")" | term
+func (p *searchParser) parsePrimary() (*searchNode, bool, error) {
+	tok := p.peek()
+	if tok == nil {
+		return nil, false, errors.New("检索语法错误：缺少检索词")
+	}
+
+	switch tok.kind {
+	case searchTokenLParen:
+		p.pos++
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, false, err
+		}
+		if next := p.peek(); next == nil || next.kind != searchTokenRParen {
+			return nil, false, errors.New("检索语法错误：括号不匹配")
+		}
+		p.pos++
+		return node, false, nil
+	case searchTokenTerm:
+		p.pos++
+		return &searchNode{op: "term", field: tok.field, text: tok.text}, tok.negate, nil
+	default:
+		return nil, false, errors.New("检索语法错误：运算符位置不正确")
+	}
+}
@@ -4,9 +4,14 @@ package services
 
 import (
 	"errors"
+	"fmt"
+	"math"
+	"strings"
 	"time"
+
 	"zxm_ai_admin/log-service/internal/database"
 	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
 
 	"gorm.io/gorm"
 )
@@ -24,33 +29,36 @@ type GetUserStatisticsRequest struct {
 	Authorization string `form:"authorization" binding:"required"`
 	StartTime     string `form:"start_time"`
 	EndTime       string `form:"end_time"`
+	TZ            string `form:"tz"` // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
 }
 
 // UserStatisticsResponse 用户统计数据响应
 type UserStatisticsResponse struct {
-	Authorization string             `json:"authorization"`
-	TimeRange     TimeRange          `json:"time_range"`
-	Summary       SummaryStatistics  `json:"summary"`
-	Latency       LatencyStatistics  `json:"latency"`
-	ByIP          []IPStatistics     `json:"by_ip"`
-	ByPath        []PathStatistics   `json:"by_path"`
-	ByDate        []DateStatistics   `json:"by_date"`
-	ByTime        []TimeStatistics   `json:"by_time"`
+	Authorization string            `json:"authorization"`
+	TimeRange     TimeRange         `json:"time_range"`
+	Summary       SummaryStatistics `json:"summary"`
+	Latency       LatencyStatistics `json:"latency"`
+	Tokens        TokenStatistics   `json:"tokens"`
+	ByIP          []IPStatistics    `json:"by_ip"`
+	ByPath        []PathStatistics  `json:"by_path"`
+	ByDate        []DateStatistics  `json:"by_date"`
+	ByTime        []TimeStatistics  `json:"by_time"`
 }
 
 // TimeRange 时间范围
 type TimeRange struct {
-	Start string `json:"start"`
-	End   string `json:"end"`
+	Start    string `json:"start"`
+	End      string `json:"end"`
+	TimeZone string `json:"time_zone"`
 }
 
 // SummaryStatistics 汇总统计
 type SummaryStatistics struct {
-	TotalRequests       int64 `json:"total_requests"`
-	TotalRequestBytes   int64 `json:"total_request_bytes"`
-	TotalResponseBytes  int64 `json:"total_response_bytes"`
-	AvgRequestBytes     int64 `json:"avg_request_bytes"`
-	AvgResponseBytes    int64 `json:"avg_response_bytes"`
+	TotalRequests      int64 `json:"total_requests"`
+	TotalRequestBytes  int64 `json:"total_request_bytes"`
+	TotalResponseBytes int64 `json:"total_response_bytes"`
+	AvgRequestBytes    int64 `json:"avg_request_bytes"`
+	AvgResponseBytes   int64 `json:"avg_response_bytes"`
 }
 
 // LatencyStatistics 延迟统计
@@ -59,6 +67,17 @@ type LatencyStatistics struct {
 	AvgMs   float64 `json:"avg_ms"`
 	MinMs   int64   `json:"min_ms"`
 	MaxMs   int64   `json:"max_ms"`
+	P50Ms   int64   `json:"p50_ms"`
+	P90Ms   int64   `json:"p90_ms"`
+	P95Ms   int64   `json:"p95_ms"`
+	P99Ms   int64   `json:"p99_ms"`
+}
+
+// TokenStatistics token 用量统计
+type TokenStatistics struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
 }
 
 // IPStatistics IP统计
@@ -89,14 +108,15 @@ type TimeStatistics struct {
 type GetAuthorizationRankingRequest struct {
 	StartTime string `form:"start_time"`
 	EndTime   string `form:"end_time"`
+	TZ        string `form:"tz"` // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
 	Page      int    `form:"page"`
 	PageSize  int    `form:"page_size"`
 }
 
 // AuthorizationRankingResponse 排行榜响应
 type AuthorizationRankingResponse struct {
-	Total     int64                       `json:"total"`
-	TimeRange TimeRange                   `json:"time_range"`
+	Total     int64                      `json:"total"`
+	TimeRange TimeRange                  `json:"time_range"`
 	List      []AuthorizationRankingItem `json:"list"`
 }
 
@@ -104,168 +124,231 @@ type AuthorizationRankingResponse struct {
 type AuthorizationRankingItem struct {
 	Authorization string `json:"authorization"`
 	Count         int64  `json:"count"`
+	TotalTokens   int64  `json:"total_tokens"`
 }
 
 // GetUserStatistics 获取用户统计数据
+// 汇总、延迟、路径、日期和小时分布读取预聚合表（按小时精度），IP 分布读取原始日志
 func (s *StatisticsService) GetUserStatistics(req *GetUserStatisticsRequest) (*UserStatisticsResponse, error) {
 	// 解析时间范围，默认最近7天
-	startTime, endTime, err := s.parseTimeRange(req.StartTime, req.EndTime)
+	startTime, endTime, loc, err := s.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
 	if err != nil {
 		return nil, err
 	}
 
-	// 构建基础查询
-	baseQuery := database.DB.Model(&models.TokenUsageLog{}).
-		Where("authorization = ?", req.Authorization).
-		Where("time >= ?", startTime).
-		Where("time <= ?", endTime)
+	// 每次调用返回新的查询，避免条件在多次查询间互相污染
+	newRollupQuery := func(granularity string) *gorm.DB {
+		return rollupQuery(granularity, startTime, endTime).
+			Where(`"authorization" = ?`, req.Authorization)
+	}
 
 	// 获取汇总统计
-	summary, err := s.getSummary(baseQuery)
+	summary, latency, tokens, err := s.getRollupSummary(newRollupQuery(models.RollupGranularityHour))
 	if err != nil {
 		return nil, errors.New("获取汇总统计失败: " + err.Error())
 	}
 
-	// 获取延迟统计
-	latency, err := s.getLatency(baseQuery)
-	if err != nil {
-		return nil, errors.New("获取延迟统计失败: " + err.Error())
+	// 延迟分位数与IP分布无法预聚合，读取原始日志
+	newRawQuery := func() *gorm.DB {
+		return database.DB.Model(&models.TokenUsageLog{}).
+			Where(`"authorization" = ?`, req.Authorization).
+			Where("time >= ?", startTime).
+			Where("time <= ?", endTime)
+	}
+
+	// 延迟分位数
+	if err := s.fillLatencyPercentiles(newRawQuery, latency); err != nil {
+		return nil, errors.New("获取延迟分位数失败: " + err.Error())
 	}
 
 	// 按IP分组统计
-	byIP, err := s.groupByIP(baseQuery)
+	byIP, err := s.groupByIP(newRawQuery())
 	if err != nil {
 		return nil, errors.New("获取IP统计失败: " + err.Error())
 	}
 
 	// 按路径分组统计
-	byPath, err := s.groupByPath(baseQuery)
+	byPath, err := s.groupByPath(newRollupQuery(models.RollupGranularityHour))
 	if err != nil {
 		return nil, errors.New("获取路径统计失败: " + err.Error())
 	}
 
+	// 按日期、小时分组使用请求时区在结束时刻的偏移
+	offset := utils.ZoneOffset(loc, endTime)
+	newBucketQuery := func(unit database.TimeUnit) (*gorm.DB, string) {
+		query, bucket := rollupBuckets(unit, startTime, endTime, offset)
+		return query.Where(`"authorization" = ?`, req.Authorization), bucket
+	}
+
 	// 按日期分组统计
-	byDate, err := s.groupByDate(req.Authorization, startTime, endTime)
+	byDate, err := s.groupByDate(newBucketQuery(database.TimeUnitDay))
 	if err != nil {
 		return nil, errors.New("获取日期统计失败: " + err.Error())
 	}
 
 	// 按小时分组统计
-	byTime, err := s.groupByTime(req.Authorization, startTime, endTime)
+	byTime, err := s.groupByTime(newBucketQuery(database.TimeUnitHour))
 	if err != nil {
 		return nil, errors.New("获取小时统计失败: " + err.Error())
 	}
 
 	return &UserStatisticsResponse{
 		Authorization: req.Authorization,
-		TimeRange: TimeRange{
-			Start: startTime.Format("2006-01-02 15:04:05"),
-			End:   endTime.Format("2006-01-02 15:04:05"),
-		},
-		Summary: *summary,
-		Latency: *latency,
-		ByIP:    byIP,
-		ByPath:  byPath,
-		ByDate:  byDate,
-		ByTime:  byTime,
+		TimeRange:     newTimeRange(startTime, endTime, loc),
+		Summary:       *summary,
+		Latency:       *latency,
+		Tokens:        *tokens,
+		ByIP:          byIP,
+		ByPath:        byPath,
+		ByDate:        byDate,
+		ByTime:        byTime,
 	}, nil
 }
 
-// parseTimeRange 解析时间范围，默认最近7天
-func (s *StatisticsService) parseTimeRange(startTimeStr, endTimeStr string) (time.Time, time.Time, error) {
+// rollupQuery 构建预聚合表查询，时间桶起点落在 [start 所在桶, end] 内
+func rollupQuery(granularity string, startTime, endTime time.Time) *gorm.DB {
+	bucketStart := hourBucket(startTime)
+	if granularity == models.RollupGranularityDay {
+		bucketStart = dayBucket(startTime)
+	}
+	return database.DB.Model(&models.UsageRollup{}).
+		Where("granularity = ?", granularity).
+		Where("bucket_start >= ?", bucketStart).
+		Where("bucket_start <= ?", endTime.UTC())
+}
+
+// parseTimeRange 解析时间范围与时区，默认最近7天
+// 返回的开始、结束时间为 UTC，loc 用于按时区分组与展示
+func (s *StatisticsService) parseTimeRange(startTimeStr, endTimeStr, tz string) (time.Time, time.Time, *time.Location, error) {
+	loc, err := utils.LoadTimeZone(tz)
+	if err != nil {
+		return time.Time{}, time.Time{}, nil, err
+	}
+
 	var startTime, endTime time.Time
-	var err error
 
 	// 解析开始时间
 	if startTimeStr != "" {
-		startTime, err = time.Parse("2006-01-02 15:04:05", startTimeStr)
+		startTime, err = utils.ParseTimeParam(startTimeStr, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, errors.New("开始时间格式错误，正确格式为: 2006-01-02 15:04:05")
+			return time.Time{}, time.Time{}, nil, errors.New("开始时间" + err.Error())
 		}
 	} else {
 		// 默认7天前
-		startTime = time.Now().AddDate(0, 0, -7)
+		startTime = time.Now().UTC().AddDate(0, 0, -7)
 	}
 
 	// 解析结束时间
 	if endTimeStr != "" {
-		endTime, err = time.Parse("2006-01-02 15:04:05", endTimeStr)
+		endTime, err = utils.ParseTimeParam(endTimeStr, loc)
 		if err != nil {
-			return time.Time{}, time.Time{}, errors.New("结束时间格式错误，正确格式为: 2006-01-02 15:04:05")
+			return time.Time{}, time.Time{}, nil, errors.New("结束时间" + err.Error())
 		}
 	} else {
-		endTime = time.Now()
+		endTime = time.Now().UTC()
 	}
 
 	if startTime.After(endTime) {
-		return time.Time{}, time.Time{}, errors.New("开始时间不能晚于结束时间")
+		return time.Time{}, time.Time{}, nil, errors.New("开始时间不能晚于结束时间")
+	}
+
+	return startTime, endTime, loc, nil
+}
+
+// newTimeRange 按时区格式化响应中的时间范围
+func newTimeRange(startTime, endTime time.Time, loc *time.Location) TimeRange {
+	return TimeRange{
+		Start:    startTime.In(loc).Format("2006-01-02 15:04:05"),
+		End:      endTime.In(loc).Format("2006-01-02 15:04:05"),
+		TimeZone: loc.String(),
 	}
+}
 
-	return startTime, endTime, nil
+// rollupBuckets 构建按时区分桶的预聚合查询，返回查询与分桶表达式
+// 按天分桶且时区偏移与按天预聚合（配置的默认时区）一致时读取按天预聚合，否则读取按小时预聚合并在数据库中按时区重新分桶
+func rollupBuckets(unit database.TimeUnit, startTime, endTime time.Time, offset int) (*gorm.DB, string) {
+	granularity := models.RollupGranularityHour
+	if unit == database.TimeUnitDay && dayRollupsMatch(startTime, endTime, offset) {
+		granularity = models.RollupGranularityDay
+	}
+	return rollupQuery(granularity, startTime, endTime),
+		database.CurrentDialect().TimeBucket("bucket_start", unit, offset)
 }
 
-// getSummary 获取汇总统计
-func (s *StatisticsService) getSummary(query *gorm.DB) (*SummaryStatistics, error) {
+// getRollupSummary 从预聚合表获取汇总、延迟与 token 用量统计
+func (s *StatisticsService) getRollupSummary(query *gorm.DB) (*SummaryStatistics, *LatencyStatistics, *TokenStatistics, error) {
 	type Result struct {
-		TotalRequests       int64
-		TotalRequestBytes   int64
-		TotalResponseBytes  int64
+		TotalRequests      int64
+		TotalRequestBytes  int64
+		TotalResponseBytes int64
+		LatencySumMs       int64
+		LatencyMinMs       int64
+		LatencyMaxMs       int64
+		PromptTokens       int64
+		CompletionTokens   int64
+		TotalTokens        int64
 	}
 
 	var result Result
 	err := query.Select(
-		"COUNT(*) as total_requests",
-		"COALESCE(SUM(request_size_bytes), 0) as total_request_bytes",
-		"COALESCE(SUM(response_size_bytes), 0) as total_response_bytes",
+		"COALESCE(SUM(request_count), 0) as total_requests",
+		"COALESCE(SUM(request_bytes), 0) as total_request_bytes",
+		"COALESCE(SUM(response_bytes), 0) as total_response_bytes",
+		"COALESCE(SUM(latency_sum_ms), 0) as latency_sum_ms",
+		"COALESCE(MIN(latency_min_ms), 0) as latency_min_ms",
+		"COALESCE(MAX(latency_max_ms), 0) as latency_max_ms",
+		"COALESCE(SUM(prompt_tokens), 0) as prompt_tokens",
+		"COALESCE(SUM(completion_tokens), 0) as completion_tokens",
+		"COALESCE(SUM(total_tokens), 0) as total_tokens",
 	).Scan(&result).Error
 
 	if err != nil {
-		return nil, err
-	}
-
-	avgRequestBytes := int64(0)
-	avgResponseBytes := int64(0)
-	if result.TotalRequests > 0 {
-		avgRequestBytes = result.TotalRequestBytes / result.TotalRequests
-		avgResponseBytes = result.TotalResponseBytes / result.TotalRequests
+		return nil, nil, nil, err
 	}
 
-	return &SummaryStatistics{
+	summary := &SummaryStatistics{
 		TotalRequests:      result.TotalRequests,
 		TotalRequestBytes:  result.TotalRequestBytes,
 		TotalResponseBytes: result.TotalResponseBytes,
-		AvgRequestBytes:    avgRequestBytes,
-		AvgResponseBytes:   avgResponseBytes,
-	}, nil
+	}
+	latency := &LatencyStatistics{
+		TotalMs: result.LatencySumMs,
+		MinMs:   result.LatencyMinMs,
+		MaxMs:   result.LatencyMaxMs,
+	}
+	if result.TotalRequests > 0 {
+		summary.AvgRequestBytes = result.TotalRequestBytes / result.TotalRequests
+		summary.AvgResponseBytes = result.TotalResponseBytes / result.TotalRequests
+		latency.AvgMs = float64(result.LatencySumMs) / float64(result.TotalRequests)
+	}
+	tokens := &TokenStatistics{
+		PromptTokens:     result.PromptTokens,
+		CompletionTokens: result.CompletionTokens,
+		TotalTokens:      result.TotalTokens,
+	}
+
+	return summary, latency, tokens, nil
 }
 
-// getLatency 获取延迟统计
-func (s *StatisticsService) getLatency(query *gorm.DB) (*LatencyStatistics, error) {
-	type Result struct {
-		TotalMs int64
-		AvgMs   float64
-		MinMs   int64
-		MaxMs   int64
+// fillLatencyPercentiles 计算延迟的 P50/P90/P95/P99（原始日志已清理的部分不计入）
+func (s *StatisticsService) fillLatencyPercentiles(newQuery func() *gorm.DB, latency *LatencyStatistics) error {
+	var count int64
+	if err := newQuery().Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return nil
 	}
 
-	var result Result
-	err := query.Select(
-		"COALESCE(SUM(latency_ms), 0) as total_ms",
-		"COALESCE(AVG(latency_ms), 0) as avg_ms",
-		"COALESCE(MIN(latency_ms), 0) as min_ms",
-		"COALESCE(MAX(latency_ms), 0) as max_ms",
-	).Scan(&result).Error
-
+	percentiles, err := s.getPercentiles(newQuery(), "latency_ms")
 	if err != nil {
-		return nil, err
+		return err
 	}
-
-	return &LatencyStatistics{
-		TotalMs: result.TotalMs,
-		AvgMs:   result.AvgMs,
-		MinMs:   result.MinMs,
-		MaxMs:   result.MaxMs,
-	}, nil
+	latency.P50Ms = percentiles.P50
+	latency.P90Ms = percentiles.P90
+	latency.P95Ms = percentiles.P95
+	latency.P99Ms = percentiles.P99
+	return nil
 }
 
 // groupByIP 按IP分组统计
@@ -305,7 +388,7 @@ func (s *StatisticsService) groupByPath(query *gorm.DB) ([]PathStatistics, error
 	}
 
 	var results []Result
-	err := query.Select("path, COUNT(*) as count").
+	err := query.Select("path, SUM(request_count) as count").
 		Group("path").
 		Order("count DESC").
 		Scan(&results).Error
@@ -325,74 +408,62 @@ func (s *StatisticsService) groupByPath(query *gorm.DB) ([]PathStatistics, error
 	return stats, nil
 }
 
-// groupByDate 按日期分组统计
-func (s *StatisticsService) groupByDate(authorization string, startTime, endTime time.Time) ([]DateStatistics, error) {
-	type Result struct {
-		Date  string
-		Count int64
-	}
-
-	var results []Result
-	err := database.DB.Raw(`
-		SELECT strftime('%Y-%m-%d', datetime(time, '+8 hours')) as date, COUNT(*) as count
-		FROM token_usage_logs
-		WHERE authorization = ?
-		  AND time >= ? AND time <= ?
-		GROUP BY strftime('%Y-%m-%d', datetime(time, '+8 hours'))
-		ORDER BY date ASC
-	`, authorization, startTime, endTime).Scan(&results).Error
-
+// groupByDate 按日期（请求时区）分组统计
+func (s *StatisticsService) groupByDate(query *gorm.DB, bucket string) ([]DateStatistics, error) {
+	buckets, err := s.groupByBucket(query, bucket)
 	if err != nil {
 		return nil, err
 	}
 
 	var stats []DateStatistics
-	for _, r := range results {
+	for _, b := range buckets {
 		stats = append(stats, DateStatistics{
-			Date:  r.Date,
-			Count: r.Count,
+			Date:  b.Bucket,
+			Count: b.Count,
 		})
 	}
 
 	return stats, nil
 }
 
-// groupByTime 按小时分组统计
-func (s *StatisticsService) groupByTime(authorization string, startTime, endTime time.Time) ([]TimeStatistics, error) {
-	type Result struct {
-		Time  string
-		Count int64
-	}
-
-	var results []Result
-	err := database.DB.Raw(`
-		SELECT strftime('%Y-%m-%d %H:00:00', datetime(time, '+8 hours')) as time, COUNT(*) as count
-		FROM token_usage_logs
-		WHERE authorization = ?
-		  AND time >= ? AND time <= ?
-		GROUP BY strftime('%Y-%m-%d %H:00:00', datetime(time, '+8 hours'))
-		ORDER BY time ASC
-	`, authorization, startTime, endTime).Scan(&results).Error
-
+// groupByTime 按小时（请求时区）分组统计
+func (s *StatisticsService) groupByTime(query *gorm.DB, bucket string) ([]TimeStatistics, error) {
+	buckets, err := s.groupByBucket(query, bucket)
 	if err != nil {
 		return nil, err
 	}
 
 	var stats []TimeStatistics
-	for _, r := range results {
+	for _, b := range buckets {
 		stats = append(stats, TimeStatistics{
-			Time:  r.Time,
-			Count: r.Count,
+			Time:  b.Bucket,
+			Count: b.Count,
 		})
 	}
 
 	return stats, nil
 }
 
+// bucketCount 时间桶请求数
+type bucketCount struct {
+	Bucket string
+	Count  int64
+}
+
+// groupByBucket 按分桶表达式汇总预聚合请求数
+func (s *StatisticsService) groupByBucket(query *gorm.DB, bucket string) ([]bucketCount, error) {
+	var results []bucketCount
+	err := query.Select(bucket + " as bucket, SUM(request_count) as count").
+		Group(bucket).
+		Order("bucket ASC").
+		Scan(&results).Error
+	return results, err
+}
+
 // GetAuthorizationRanking 获取 authorization 使用次数排行
 func (s *StatisticsService) GetAuthorizationRanking(req *GetAuthorizationRankingRequest) (*AuthorizationRankingResponse, error) {
 	// 解析时间范围，默认最近7天
-	startTime, endTime, err := s.parseTimeRange(req.StartTime, req.EndTime)
+	startTime, endTime, loc, err := s.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
 	if err != nil {
 		return nil, err
 	}
@@ -410,11 +481,9 @@ func (s *StatisticsService) GetAuthorizationRanking(req *GetAuthorizationRanking
 		pageSize = 100
 	}
 
-	// 构建查询
-	baseQuery := database.DB.Model(&models.TokenUsageLog{}).
-		Where("time >= ?", startTime).
-		Where("time <= ?", endTime).
-		Where("authorization != ''")
+	// 构建查询（读取小时预聚合）
+	baseQuery := rollupQuery(models.RollupGranularityHour, startTime, endTime).
+		Where(`"authorization" != ''`)
 
 	// 获取总数（不同 authorization 的数量）
 	var total int64
@@ -426,11 +495,14 @@ func (s *StatisticsService) GetAuthorizationRanking(req *GetAuthorizationRanking
 	type Result struct {
 		Authorization string
 		Count         int64
+		TotalTokens   int64
 	}
 
 	var results []Result
 	offset := (page - 1) * pageSize
-	err = baseQuery.Select("authorization, COUNT(*) as count").
+	err = rollupQuery(models.RollupGranularityHour, startTime, endTime).
+		Where(`"authorization" != ''`).
+		Select(`"authorization", SUM(request_count) as count, SUM(total_tokens) as total_tokens`).
 		Group("authorization").
 		Order("count DESC").
 		Offset(offset).
@@ -447,15 +519,601 @@ func (s *StatisticsService) GetAuthorizationRanking(req *GetAuthorizationRanking
 		list = append(list, AuthorizationRankingItem{
 			Authorization: r.Authorization,
 			Count:         r.Count,
+			TotalTokens:   r.TotalTokens,
 		})
 	}
 
 	return &AuthorizationRankingResponse{
-		Total: total,
-		TimeRange: TimeRange{
-			Start: startTime.Format("2006-01-02 15:04:05"),
-			End:   endTime.Format("2006-01-02 15:04:05"),
-		},
-		List: list,
+		Total:     total,
+		TimeRange: newTimeRange(startTime, endTime, loc),
+		List:      list,
+	}, nil
+}
+
+// GetStreamStatisticsRequest 获取流式响应统计请求
+type GetStreamStatisticsRequest struct {
+	Authorization string `form:"authorization"`
+	AIModelName   string `form:"ai_model_name"`
+	StartTime     string `form:"start_time"`
+	EndTime       string `form:"end_time"`
+	TZ            string `form:"tz"` // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
+}
+
+// StreamStatisticsResponse 流式响应统计响应
+type StreamStatisticsResponse struct {
+	TimeRange TimeRange               `json:"time_range"`
+	Overall   StreamMetricStatistics  `json:"overall"`
+	ByModel   []ModelStreamStatistics `json:"by_model"`
+}
+
+// ModelStreamStatistics 按模型分组的流式响应统计
+type ModelStreamStatistics struct {
+	AIModelID   int                    `json:"ai_model_id"`
+	AIModelName string                 `json:"ai_model_name"`
+	Metrics     StreamMetricStatistics `json:"metrics"`
+}
+
+// StreamMetricStatistics 流式响应各项指标的分位数统计
+type StreamMetricStatistics struct {
+	Count              int64                `json:"count"`
+	HeaderLatencyMs    PercentileStatistics `json:"header_latency_ms"`
+	FirstByteLatencyMs PercentileStatistics `json:"first_byte_latency_ms"`
+	StreamDurationMs   PercentileStatistics `json:"stream_duration_ms"`
+	ChunkCount         PercentileStatistics `json:"chunk_count"`
+}
+
+// PercentileStatistics 分位数统计
+type PercentileStatistics struct {
+	Avg float64 `json:"avg"`
+	Min int64   `json:"min"`
+	Max int64   `json:"max"`
+	P50 int64   `json:"p50"`
+	P90 int64   `json:"p90"`
+	P95 int64   `json:"p95"`
+	P99 int64   `json:"p99"`
+}
+
+// GetStreamStatistics 获取流式响应的时间指标统计（整体及按模型分组）
+func (s *StatisticsService) GetStreamStatistics(req *GetStreamStatisticsRequest) (*StreamStatisticsResponse, error) {
+	startTime, endTime, loc, err := s.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	// 每次调用返回新的查询，避免条件在多次查询间互相污染
+	newQuery := func() *gorm.DB {
+		query := database.DB.Model(&models.TokenUsageLog{}).
+			Where("is_stream = ?", true).
+			Where("time >= ?", startTime).
+			Where("time <= ?", endTime)
+		if req.Authorization != "" {
+			query = query.Where(`"authorization" = ?`, req.Authorization)
+		}
+		if req.AIModelName != "" {
+			query = query.Where("ai_model_name = ?", req.AIModelName)
+		}
+		return query
+	}
+
+	overall, err := s.getStreamMetrics(newQuery)
+	if err != nil {
+		return nil, errors.New("获取流式响应统计失败: " + err.Error())
+	}
+
+	// 按模型分组
+	type ModelResult struct {
+		AIModelID   int
+		AIModelName string
+	}
+	var modelResults []ModelResult
+	if err := newQuery().
+		Select("ai_model_id, ai_model_name").
+		Group("ai_model_id, ai_model_name").
+		Order("ai_model_name ASC").
+		Scan(&modelResults).Error; err != nil {
+		return nil, errors.New("获取模型列表失败: " + err.Error())
+	}
+
+	byModel := make([]ModelStreamStatistics, 0, len(modelResults))
+	for _, m := range modelResults {
+		m := m
+		metrics, err := s.getStreamMetrics(func() *gorm.DB {
+			return newQuery().
+				Where("ai_model_id = ?", m.AIModelID).
+				Where("ai_model_name = ?", m.AIModelName)
+		})
+		if err != nil {
+			return nil, errors.New("获取模型流式响应统计失败: " + err.Error())
+		}
+		byModel = append(byModel, ModelStreamStatistics{
+			AIModelID:   m.AIModelID,
+			AIModelName: m.AIModelName,
+			Metrics:     *metrics,
+		})
+	}
+
+	return &StreamStatisticsResponse{
+		TimeRange: newTimeRange(startTime, endTime, loc),
+		Overall:   *overall,
+		ByModel:   byModel,
+	}, nil
+}
+
+// getStreamMetrics 计算流式响应各项指标的分位数
+func (s *StatisticsService) getStreamMetrics(newQuery func() *gorm.DB) (*StreamMetricStatistics, error) {
+	var count int64
+	if err := newQuery().Count(&count).Error; err != nil {
+		return nil, err
+	}
+
+	result := &StreamMetricStatistics{Count: count}
+	if count == 0 {
+		return result, nil
+	}
+
+	var err error
+	if result.HeaderLatencyMs, err = s.getPercentiles(newQuery(), "header_latency_ms"); err != nil {
+		return nil, err
+	}
+	if result.FirstByteLatencyMs, err = s.getPercentiles(newQuery(), "first_byte_latency_ms"); err != nil {
+		return nil, err
+	}
+	if result.StreamDurationMs, err = s.getPercentiles(newQuery(), "stream_duration_ms"); err != nil {
+		return nil, err
+	}
+	if result.ChunkCount, err = s.getPercentiles(newQuery(), "chunk_count"); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// percentileBasis 分位数以万分比表示，便于在 SQL 中用整数比较秩
+const percentileBasis = 10000
+
+// percentilePoint 将百分数（如 99.9）转换为万分比
+func percentilePoint(percent float64) int64 {
+	return int64(math.Round(percent * percentileBasis / 100))
+}
+
+// rankedValue 有序扫描中某个分组内取出的一行
+type rankedValue struct {
+	GroupKey string
+	RankNo   int64 // 分组内按列升序的行号（从 1 开始）
+	Total    int64 // 分组行数
+	Value    int64
+	AvgValue float64
+	MinValue int64
+	MaxValue int64
+}
+
+// rankedValues 在一次有序扫描中按分组取出各分位数（万分比，最近秩法）对应的行，
+// 同时返回分组的行数、平均值与极值。group 为分组表达式，为空时不分组。
+// 行号与行数由窗口函数计算，只有命中某个分位数秩的行会返回
+func rankedValues(query *gorm.DB, column, group string, points []int64) ([]rankedValue, error) {
+	groupKey, over := "''", ""
+	if group != "" {
+		groupKey, over = group, "PARTITION BY "+group
+	}
+
+	ranked := query.Select(
+		groupKey+" AS group_key",
+		column+" AS value",
+		"ROW_NUMBER() OVER ("+over+" ORDER BY "+column+" ASC) AS rank_no",
+		"COUNT(*) OVER ("+over+") AS total",
+		"AVG("+column+") OVER ("+over+") AS avg_value",
+		"MIN("+column+") OVER ("+over+") AS min_value",
+		"MAX("+column+") OVER ("+over+") AS max_value",
+	)
+
+	// 最近秩法的秩 k = ceil(p * total)，即满足 (k-1) < p * total <= k 的行号
+	conds := make([]string, 0, len(points))
+	args := make([]interface{}, 0, len(points)*2)
+	for _, point := range points {
+		conds = append(conds, fmt.Sprintf("(rank_no * %d >= ? * total AND (rank_no - 1) * %d < ? * total)", percentileBasis, percentileBasis))
+		args = append(args, point, point)
+	}
+	where := strings.Join(conds, " OR ")
+	// 分位数为 0 时秩按 1 计
+	where += " OR rank_no = 1"
+
+	var rows []rankedValue
+	err := database.DB.Table("(?) AS ranked", ranked).
+		Select("group_key, rank_no, total, value, avg_value, min_value, max_value").
+		Where(where, args...).
+		Order("group_key, rank_no").
+		Scan(&rows).Error
+	return rows, err
+}
+
+// valueAtPoint 从 rankedValues 的结果中取分组内分位数 point 对应行的值
+func valueAtPoint(rows []rankedValue, point int64) int64 {
+	if len(rows) == 0 {
+		return 0
+	}
+	rank := nearestRank(point, rows[0].Total)
+	for _, row := range rows {
+		if row.RankNo == rank {
+			return row.Value
+		}
+	}
+	return 0
+}
+
+// groupPercentiles 在一次有序扫描中按分组计算指定列的平均值、极值与 P50/P90/P95/P99。
+// group 为分组表达式，为空时不分组，结果的键为 ""
+func (s *StatisticsService) groupPercentiles(query *gorm.DB, column, group string) (map[string]PercentileStatistics, error) {
+	p50, p90, p95, p99 := percentilePoint(50), percentilePoint(90), percentilePoint(95), percentilePoint(99)
+	rows, err := rankedValues(query, column, group, []int64{p50, p90, p95, p99})
+	if err != nil {
+		return nil, err
+	}
+
+	byGroup := make(map[string][]rankedValue)
+	for _, row := range rows {
+		byGroup[row.GroupKey] = append(byGroup[row.GroupKey], row)
+	}
+
+	stats := make(map[string]PercentileStatistics, len(byGroup))
+	for key, rows := range byGroup {
+		stats[key] = PercentileStatistics{
+			Avg: rows[0].AvgValue,
+			Min: rows[0].MinValue,
+			Max: rows[0].MaxValue,
+			P50: valueAtPoint(rows, p50),
+			P90: valueAtPoint(rows, p90),
+			P95: valueAtPoint(rows, p95),
+			P99: valueAtPoint(rows, p99),
+		}
+	}
+	return stats, nil
+}
+
+// getPercentiles 计算指定列的平均值、极值与 P50/P90/P95/P99
+// SQLite 没有分位数函数，按最近秩法（nearest-rank）在一次有序扫描中取出各分位数对应的行
+func (s *StatisticsService) getPercentiles(query *gorm.DB, column string) (PercentileStatistics, error) {
+	stats, err := s.groupPercentiles(query, column, "")
+	if err != nil {
+		return PercentileStatistics{}, err
+	}
+	return stats[""], nil
+}
+
+// nearestRank 计算最近秩法下分位数 point（万分比）对应的行号（从 1 开始）
+func nearestRank(point int64, count int64) int64 {
+	rank := (point*count + percentileBasis - 1) / percentileBasis
+	if rank < 1 {
+		rank = 1
+	}
+	if rank > count {
+		rank = count
+	}
+	return rank
+}
+
+// 延迟与错误率统计中单独列出的上游状态码
+const (
+	statusTooManyRequests = 429 // 上游限流
+	statusOverloaded      = 529 // 上游过载（Anthropic）
+)
+
+// maxLatencyPaths 延迟统计按路径分组时最多返回的路径数（按请求数降序）
+const maxLatencyPaths = 20
+
+// GetLatencyStatisticsRequest 获取延迟分位数统计请求
+// authorization、path 均为空时统计全局
+type GetLatencyStatisticsRequest struct {
+	Authorization string `form:"authorization"`
+	Path          string `form:"path"`
+	StartTime     string `form:"start_time"`
+	EndTime       string `form:"end_time"`
+	TZ            string `form:"tz"` // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
+}
+
+// LatencyStatisticsResponse 延迟分位数统计响应
+type LatencyStatisticsResponse struct {
+	TimeRange TimeRange               `json:"time_range"`
+	Count     int64                   `json:"count"`
+	LatencyMs PercentileStatistics    `json:"latency_ms"`
+	ByPath    []PathLatencyStatistics `json:"by_path"`
+}
+
+// PathLatencyStatistics 按路径分组的延迟分位数
+type PathLatencyStatistics struct {
+	Path      string               `json:"path"`
+	Count     int64                `json:"count"`
+	LatencyMs PercentileStatistics `json:"latency_ms"`
+}
+
+// GetLatencyStatistics 获取延迟分位数统计（整体及请求数最多的路径）
+func (s *StatisticsService) GetLatencyStatistics(req *GetLatencyStatisticsRequest) (*LatencyStatisticsResponse, error) {
+	startTime, endTime, loc, err := s.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	newQuery := func() *gorm.DB {
+		return s.newStatusQuery(req.Authorization, req.Path, startTime, endTime)
+	}
+
+	var count int64
+	if err := newQuery().Count(&count).Error; err != nil {
+		return nil, errors.New("获取延迟统计失败: " + err.Error())
+	}
+
+	response := &LatencyStatisticsResponse{
+		TimeRange: newTimeRange(startTime, endTime, loc),
+		Count:     count,
+		ByPath:    []PathLatencyStatistics{},
+	}
+	if count == 0 {
+		return response, nil
+	}
+
+	if response.LatencyMs, err = s.getPercentiles(newQuery(), "latency_ms"); err != nil {
+		return nil, errors.New("获取延迟统计失败: " + err.Error())
+	}
+
+	// 按路径分组
+	type PathResult struct {
+		Path  string
+		Count int64
+	}
+	var pathResults []PathResult
+	if err := newQuery().
+		Select("path, COUNT(*) as count").
+		Group("path").
+		Order("count DESC").
+		Limit(maxLatencyPaths).
+		Scan(&pathResults).Error; err != nil {
+		return nil, errors.New("获取路径延迟统计失败: " + err.Error())
+	}
+
+	if len(pathResults) == 0 {
+		return response, nil
+	}
+
+	paths := make([]string, 0, len(pathResults))
+	for _, p := range pathResults {
+		paths = append(paths, p.Path)
+	}
+	byPath, err := s.groupPercentiles(newQuery().Where("path IN ?", paths), "latency_ms", "path")
+	if err != nil {
+		return nil, errors.New("获取路径延迟统计失败: " + err.Error())
+	}
+	for _, p := range pathResults {
+		response.ByPath = append(response.ByPath, PathLatencyStatistics{
+			Path:      p.Path,
+			Count:     p.Count,
+			LatencyMs: byPath[p.Path],
+		})
+	}
+
+	return response, nil
+}
+
+// GetErrorStatisticsRequest 获取错误率统计请求
+// authorization、path 均为空时统计全局
+type GetErrorStatisticsRequest struct {
+	Authorization string `form:"authorization"`
+	Path          string `form:"path"`
+	StartTime     string `form:"start_time"`
+	EndTime       string `form:"end_time"`
+	TZ            string `form:"tz"`       // 时区，如 Asia/Shanghai、+08:00，为空时使用配置的默认时区
+	Interval      string `form:"interval"` // 时间序列粒度：hour / day，默认范围不超过 2 天按小时，否则按天
+}
+
+// ErrorStatisticsResponse 错误率统计响应
+type ErrorStatisticsResponse struct {
+	TimeRange     TimeRange               `json:"time_range"`
+	Interval      string                  `json:"interval"`
+	Summary       ErrorSummaryStatistics  `json:"summary"`
+	ByStatusClass []StatusClassStatistics `json:"by_status_class"`
+	ByStatus      []StatusCodeStatistics  `json:"by_status"`
+	Timeline      []ErrorTimelinePoint    `json:"timeline"`
+}
+
+// ErrorSummaryStatistics 错误率汇总
+type ErrorSummaryStatistics struct {
+	TotalRequests   int64   `json:"total_requests"`
+	ErrorRequests   int64   `json:"error_requests"` // 状态码 >= 400
+	ClientErrors    int64   `json:"client_errors"`  // 4xx
+	ServerErrors    int64   `json:"server_errors"`  // 5xx
+	RateLimited     int64   `json:"rate_limited"`   // 429
+	Overloaded      int64   `json:"overloaded"`     // 529
+	ErrorRate       float64 `json:"error_rate"`
+	ClientErrorRate float64 `json:"client_error_rate"`
+	ServerErrorRate float64 `json:"server_error_rate"`
+}
+
+// StatusClassStatistics 按状态码分类统计
+type StatusClassStatistics struct {
+	StatusClass string  `json:"status_class"`
+	Count       int64   `json:"count"`
+	Ratio       float64 `json:"ratio"`
+}
+
+// StatusCodeStatistics 按具体状态码统计
+type StatusCodeStatistics struct {
+	Status int     `json:"status"`
+	Count  int64   `json:"count"`
+	Ratio  float64 `json:"ratio"`
+}
+
+// ErrorTimelinePoint 错误率时间序列中的一个点
+type ErrorTimelinePoint struct {
+	Time          string  `json:"time"`
+	TotalRequests int64   `json:"total_requests"`
+	ErrorRequests int64   `json:"error_requests"`
+	ClientErrors  int64   `json:"client_errors"`
+	ServerErrors  int64   `json:"server_errors"`
+	RateLimited   int64   `json:"rate_limited"`
+	Overloaded    int64   `json:"overloaded"`
+	ErrorRate     float64 `json:"error_rate"`
+}
+
+// GetErrorStatistics 获取状态码分布与错误率时间序列
+func (s *StatisticsService) GetErrorStatistics(req *GetErrorStatisticsRequest) (*ErrorStatisticsResponse, error) {
+	startTime, endTime, loc, err := s.parseTimeRange(req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, err
+	}
+
+	interval := req.Interval
+	switch interval {
+	case "":
+		interval = string(database.TimeUnitHour)
+		if endTime.Sub(startTime) > 48*time.Hour {
+			interval = string(database.TimeUnitDay)
+		}
+	case string(database.TimeUnitHour), string(database.TimeUnitDay):
+	default:
+		return nil, errors.New("interval 参数错误，可选值: hour、day")
+	}
+
+	newQuery := func() *gorm.DB {
+		return s.newStatusQuery(req.Authorization, req.Path, startTime, endTime)
+	}
+
+	// 按具体状态码分组
+	type StatusResult struct {
+		Status int
+		Count  int64
+	}
+	var statusResults []StatusResult
+	if err := newQuery().
+		Select("status, COUNT(*) as count").
+		Group("status").
+		Order("status ASC").
+		Scan(&statusResults).Error; err != nil {
+		return nil, errors.New("获取状态码统计失败: " + err.Error())
+	}
+
+	summary := ErrorSummaryStatistics{}
+	for _, r := range statusResults {
+		summary.TotalRequests += r.Count
+		summary.ClientErrors += countIf(r.Status >= 400 && r.Status < 500, r.Count)
+		summary.ServerErrors += countIf(r.Status >= 500, r.Count)
+		summary.RateLimited += countIf(r.Status == statusTooManyRequests, r.Count)
+		summary.Overloaded += countIf(r.Status == statusOverloaded, r.Count)
+	}
+	summary.ErrorRequests = summary.ClientErrors + summary.ServerErrors
+	summary.ErrorRate = ratio(summary.ErrorRequests, summary.TotalRequests)
+	summary.ClientErrorRate = ratio(summary.ClientErrors, summary.TotalRequests)
+	summary.ServerErrorRate = ratio(summary.ServerErrors, summary.TotalRequests)
+
+	byStatus := make([]StatusCodeStatistics, 0, len(statusResults))
+	classCounts := make(map[string]int64)
+	var classes []string
+	for _, r := range statusResults {
+		byStatus = append(byStatus, StatusCodeStatistics{
+			Status: r.Status,
+			Count:  r.Count,
+			Ratio:  ratio(r.Count, summary.TotalRequests),
+		})
+		class := statusClass(r.Status)
+		if _, ok := classCounts[class]; !ok {
+			classes = append(classes, class)
+		}
+		classCounts[class] += r.Count
+	}
+
+	byStatusClass := make([]StatusClassStatistics, 0, len(classes))
+	for _, class := range classes {
+		byStatusClass = append(byStatusClass, StatusClassStatistics{
+			StatusClass: class,
+			Count:       classCounts[class],
+			Ratio:       ratio(classCounts[class], summary.TotalRequests),
+		})
+	}
+
+	timeline, err := s.getErrorTimeline(newQuery, database.TimeUnit(interval), utils.ZoneOffset(loc, endTime))
+	if err != nil {
+		return nil, errors.New("获取错误率时间序列失败: " + err.Error())
+	}
+
+	return &ErrorStatisticsResponse{
+		TimeRange:     newTimeRange(startTime, endTime, loc),
+		Interval:      interval,
+		Summary:       summary,
+		ByStatusClass: byStatusClass,
+		ByStatus:      byStatus,
+		Timeline:      timeline,
 	}, nil
 }
+
+// getErrorTimeline 按时间桶（offset 为时区偏移秒数）统计请求数与各类错误数
+func (s *StatisticsService) getErrorTimeline(newQuery func() *gorm.DB, unit database.TimeUnit, offset int) ([]ErrorTimelinePoint, error) {
+	type Result struct {
+		Bucket        string
+		TotalRequests int64
+		ClientErrors  int64
+		ServerErrors  int64
+		RateLimited   int64
+		Overloaded    int64
+	}
+
+	bucket := database.CurrentDialect().TimeBucket("time", unit, offset)
+
+	var results []Result
+	if err := newQuery().Select(
+		bucket+" as bucket",
+		"COUNT(*) as total_requests",
+		"SUM(CASE WHEN status >= 400 AND status < 500 THEN 1 ELSE 0 END) as client_errors",
+		"SUM(CASE WHEN status >= 500 THEN 1 ELSE 0 END) as server_errors",
+		fmt.Sprintf("SUM(CASE WHEN status = %d THEN 1 ELSE 0 END) as rate_limited", statusTooManyRequests),
+		fmt.Sprintf("SUM(CASE WHEN status = %d THEN 1 ELSE 0 END) as overloaded", statusOverloaded),
+	).
+		Group(bucket).
+		Order("bucket ASC").
+		Scan(&results).Error; err != nil {
+		return nil, err
+	}
+
+	timeline := make([]ErrorTimelinePoint, 0, len(results))
+	for _, r := range results {
+		errorRequests := r.ClientErrors + r.ServerErrors
+		timeline = append(timeline, ErrorTimelinePoint{
+			Time:          r.Bucket,
+			TotalRequests: r.TotalRequests,
+			ErrorRequests: errorRequests,
+			ClientErrors:  r.ClientErrors,
+			ServerErrors:  r.ServerErrors,
+			RateLimited:   r.RateLimited,
+			Overloaded:    r.Overloaded,
+			ErrorRate:     ratio(errorRequests, r.TotalRequests),
+		})
+	}
+
+	return timeline, nil
+}
+
+// newStatusQuery 构建按 authorization / 路径 / 时间范围过滤的原始日志查询，空条件不过滤
+func (s *StatisticsService) newStatusQuery(authorization, path string, startTime, endTime time.Time) *gorm.DB {
+	query := database.DB.Model(&models.TokenUsageLog{}).
+		Where("time >= ?", startTime).
+		Where("time <= ?", endTime)
+	if authorization != "" {
+		query = query.Where(`"authorization" = ?`, authorization)
+	}
+	if path != "" {
+		query = query.Where("path = ?", path)
+	}
+	return query
+}
+
+// countIf 条件成立时返回 count，否则返回 0
+func countIf(cond bool, count int64) int64 {
+	if cond {
+		return count
+	}
+	return 0
+}
+
+// ratio 计算占比，保留 4 位小数
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 10000
+}
@@ -0,0 +1,111 @@
+// Package services 业务逻辑服务层
+// 系统日志的结构化属性：写入时展开为扁平的键值，查询时按属性过滤
+package services
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrSystemLogParam 系统日志查询参数错误
+var ErrSystemLogParam = errors.New("参数错误")
+
+// maxAttrFilters 单次查询最多的属性过滤条件数
+const maxAttrFilters = 10
+
+// attrKeyPattern 可用于过滤的属性键
+var attrKeyPattern = regexp.MustCompile(`^[A-Za-z0-9_.\-]{1,100}$`)
+
+// flattenAttrs 将 slog 属性展开为扁平的字符串键值：分组以 . 连接（如 req.path），
+// 数字、布尔值和数组保存为 JSON 文本，null 保存为空字符串。没有属性时返回 nil
+func flattenAttrs(attrs map[string]interface{}) models.JSONMap {
+	if len(attrs) == 0 {
+		return nil
+	}
+	flat := make(models.JSONMap, len(attrs))
+	for key, value := range attrs {
+		flattenAttr(key, value, flat)
+	}
+	return flat
+}
+
+// flattenAttr 展开单个属性，嵌套的对象递归展开
+func flattenAttr(key string, value interface{}, flat models.JSONMap) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for child, item := range v {
+			flattenAttr(key+"."+child, item, flat)
+		}
+	case string:
+		flat[key] = v
+	case nil:
+		flat[key] = ""
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			data = []byte(fmt.Sprint(v))
+		}
+		flat[key] = string(data)
+	}
+}
+
+// AttrFilter 属性过滤条件
+type AttrFilter struct {
+	Key      string
+	Value    string
+	Contains bool // true 时子串匹配（key~value），否则完全匹配（key=value）
+}
+
+// ParseAttrFilters 解析属性过滤条件，格式为 key=value（完全匹配）或 key~value（子串匹配），
+// 多个条件需同时满足
+func ParseAttrFilters(filters []string) ([]AttrFilter, error) {
+	if len(filters) > maxAttrFilters {
+		return nil, fmt.Errorf("%w: attr 条件不能超过 %d 个", ErrSystemLogParam, maxAttrFilters)
+	}
+
+	parsed := make([]AttrFilter, 0, len(filters))
+	for _, filter := range filters {
+		i := strings.IndexAny(filter, "=~")
+		if i <= 0 {
+			return nil, fmt.Errorf("%w: attr 格式应为 key=value 或 key~value: %s", ErrSystemLogParam, filter)
+		}
+		key := filter[:i]
+		if !attrKeyPattern.MatchString(key) {
+			return nil, fmt.Errorf("%w: attr 键只能包含字母、数字、_ . -: %s", ErrSystemLogParam, key)
+		}
+		parsed = append(parsed, AttrFilter{Key: key, Value: filter[i+1:], Contains: filter[i] == '~'})
+	}
+	return parsed, nil
+}
+
+// applyAttrFilters 按属性过滤系统日志
+func applyAttrFilters(query *gorm.DB, filters []AttrFilter) *gorm.DB {
+	dialect := database.CurrentDialect()
+	for _, filter := range filters {
+		field, keyArg := dialect.JSONField("attrs", filter.Key)
+		if filter.Contains {
+			expr, arg := dialect.Contains(field, filter.Value)
+			query = query.Where(expr, keyArg, arg)
+		} else {
+			query = query.Where(field+" = ?", keyArg, filter.Value)
+		}
+	}
+	return query
+}
+
+// applyMsgFilter 按消息内容子串过滤系统日志
+func applyMsgFilter(query *gorm.DB, msg string) *gorm.DB {
+	if msg == "" {
+		return query
+	}
+	expr, arg := database.CurrentDialect().Contains("msg", msg)
+	return query.Where(expr, arg)
+}
@@ -4,6 +4,7 @@ package services
 
 import (
 	"errors"
+	"fmt"
 	"time"
 	"zxm_ai_admin/log-service/internal/config"
 	"zxm_ai_admin/log-service/internal/database"
@@ -11,7 +12,7 @@ import (
 	"zxm_ai_admin/log-service/internal/models"
 	"zxm_ai_admin/log-service/internal/utils"
 
-	"gorm.io/gorm/clause"
+	"gorm.io/gorm"
 )
 
 type SystemLogService struct{}
@@ -23,10 +24,16 @@ func NewSystemLogService() *SystemLogService {
 
 // CreateSystemLogRequest 创建系统日志请求
 type CreateSystemLogRequest struct {
-	RequestID string `json:"request_id"`
-	Time      string `json:"time"` // 接受字符串格式，在服务层转换为 time.Time
-	Level     string `json:"level"`
-	Msg       string `json:"msg"`
+	RequestID    string                 `json:"request_id"` // 关联的请求 ID，可为空
+	Time         string                 `json:"time"`       // 接受字符串格式，在服务层转换为 time.Time
+	Level        string                 `json:"level"`
+	Msg          string                 `json:"msg"`
+	Attrs        map[string]interface{} `json:"attrs"`         // 其余 slog 属性，如 error、target_url、path
+	ServiceID    string                 `json:"service_id"`    // 来源服务标识，由 log-syncer 按来源配置写入
+	Host         string                 `json:"host"`          // 来源主机
+	Source       string                 `json:"source"`        // log-syncer 的日志来源名称
+	SourceFile   string                 `json:"source_file"`   // 来源文件名
+	SourceOffset *int64                 `json:"source_offset"` // 行首在来源文件中的字节位置，与 source、source_file 一起用于去重
 }
 
 // BatchCreateSystemLogsRequest 批量创建系统日志请求
@@ -34,74 +41,221 @@ type BatchCreateSystemLogsRequest struct {
 	Logs []CreateSystemLogRequest `json:"logs" binding:"required"`
 }
 
-// CreateSystemLog 创建系统日志记录
-func (s *SystemLogService) CreateSystemLog(req *CreateSystemLogRequest) (*models.SystemLog, error) {
-	parsedTime := utils.ParseTime(req.Time)
+// newSystemLog 由请求构建系统日志记录
+func newSystemLog(req *CreateSystemLogRequest, parsedTime time.Time) *models.SystemLog {
+	return &models.SystemLog{
+		RequestID:    req.RequestID,
+		Time:         parsedTime,
+		Level:        req.Level,
+		Msg:          req.Msg,
+		Attrs:        flattenAttrs(req.Attrs),
+		ServiceID:    req.ServiceID,
+		Host:         req.Host,
+		Source:       req.Source,
+		SourceFile:   req.SourceFile,
+		SourceOffset: req.SourceOffset,
+	}
+}
 
-	log := &models.SystemLog{
-		RequestID: req.RequestID,
-		Time:      parsedTime,
-		Level:     req.Level,
-		Msg:       req.Msg,
+// validateSystemLogRequest 校验 request_id 长度和来源位置，source_offset 与 source_file 必须同时提供
+func validateSystemLogRequest(req *CreateSystemLogRequest) error {
+	if len(req.RequestID) > maxRequestIDLength {
+		return fmt.Errorf("request_id 超过 %d 个字符", maxRequestIDLength)
+	}
+	if req.SourceOffset == nil {
+		if req.SourceFile != "" {
+			return errors.New("提供 source_file 时必须提供 source_offset")
+		}
+		return nil
+	}
+	if req.SourceFile == "" {
+		return errors.New("提供 source_offset 时必须提供 source_file")
+	}
+	if *req.SourceOffset < 0 {
+		return errors.New("source_offset 不能小于 0")
 	}
+	return nil
+}
+
+// CreateSystemLog 创建系统日志记录
+func (s *SystemLogService) CreateSystemLog(req *CreateSystemLogRequest) (*models.SystemLog, error) {
+	log := newSystemLog(req, utils.ParseTime(req.Time))
 
 	if err := database.DB.Create(log).Error; err != nil {
 		return nil, errors.New("创建系统日志记录失败")
 	}
+	systemLogNotifier.Notify()
 
 	return log, nil
 }
 
-// BatchCreateSystemLogs 批量创建系统日志记录（忽略重复 request_id）
-func (s *SystemLogService) BatchCreateSystemLogs(reqs []CreateSystemLogRequest) (int, error) {
-	if len(reqs) == 0 {
-		logger.Warn("批量创建系统日志：请求为空")
-		return 0, nil
+// IngestSystemLogs 批量写入系统日志：逐行解析和校验，校验失败的行被拒绝，
+// 来源/文件/行首位置已存在的行被忽略，其余在 db 的同一事务中写入（db 已在事务中时使用保存点）。
+// 返回每行的结果；数据库错误时整批失败
+func (s *SystemLogService) IngestSystemLogs(db *gorm.DB, rows []BatchRow) (*BatchResult, error) {
+	result := &BatchResult{Results: make([]RowResult, 0, len(rows))}
+	if len(rows) == 0 {
+		return result, nil
 	}
 
-	logs := make([]models.SystemLog, 0, len(reqs))
-	for _, req := range reqs {
-		parsedTime := utils.ParseTime(req.Time)
-
-		logs = append(logs, models.SystemLog{
-			RequestID: req.RequestID,
-			Time:      parsedTime,
-			Level:     req.Level,
-			Msg:       req.Msg,
-		})
+	rowResults := make([]RowResult, len(rows))
+	logIndexes := make([]int, len(rows)) // 每行对应 logs 中的下标，被拒绝的行为 -1
+	logs := make([]models.SystemLog, 0, len(rows))
+	for i, row := range rows {
+		rowResults[i] = RowResult{Index: row.Index}
+		logIndexes[i] = -1
+
+		var req CreateSystemLogRequest
+		err := decodeBatchRow(row, &req)
+		rowResults[i].RequestID = req.RequestID // 字段类型错误时其余字段仍会解析，便于调用方定位
+		if err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		if err := validateSystemLogRequest(&req); err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		parsedTime, err := utils.ParseLogTime(req.Time)
+		if err != nil {
+			rowResults[i].Status, rowResults[i].Reason = RowRejected, err.Error()
+			continue
+		}
+		logIndexes[i] = len(logs)
+		logs = append(logs, *newSystemLog(&req, parsedTime))
 	}
 
 	logger.Debug("批量创建系统日志：准备插入数据库", "total_count", len(logs))
 
-	// 使用 OnConflict 忽略重复的 request_id
-	result := database.DB.Clauses(clause.OnConflict{
-		Columns:   []clause.Column{{Name: "request_id"}},
-		DoNothing: true,
-	}).Create(&logs)
+	inserted, err := insertSystemLogs(db, logs)
+	if err != nil {
+		logger.Error("批量创建系统日志：数据库插入失败", "error", err, "total_count", len(logs))
+		return nil, errors.New("批量创建系统日志记录失败")
+	}
 
-	if result.Error != nil {
-		logger.Error("批量创建系统日志：数据库插入失败", "error", result.Error, "total_count", len(logs))
-		return 0, errors.New("批量创建系统日志记录失败")
+	for i, row := range rowResults {
+		if row.Status == "" {
+			row.Status = RowDuplicate
+			if inserted[logIndexes[i]] {
+				row.Status = RowInserted
+			}
+		}
+		result.add(row)
 	}
 
-	insertedCount := int(result.RowsAffected)
-	if insertedCount == 0 {
-		logger.Warn("批量创建系统日志：没有新记录插入（可能所有记录都已存在）", "total_count", len(logs))
+	if result.Inserted == 0 {
+		logger.Warn("批量创建系统日志：没有新记录插入", "received_count", result.Received, "duplicate_count", result.Duplicate, "rejected_count", result.Rejected)
 	} else {
-		logger.Info("批量创建系统日志：插入成功", "total_count", len(logs), "inserted_count", insertedCount)
+		logger.Info("批量创建系统日志：插入成功", "received_count", result.Received, "inserted_count", result.Inserted, "duplicate_count", result.Duplicate, "rejected_count", result.Rejected)
+		systemLogNotifier.Notify()
 	}
 
-	// 返回实际插入的记录数
-	return insertedCount, nil
+	return result, nil
+}
+
+// insertSystemLogs 在同一事务中写入系统日志，来源/文件/行首位置已存在（或批次内重复）的行会被跳过，
+// 返回每条日志是否已插入
+func insertSystemLogs(db *gorm.DB, logs []models.SystemLog) ([]bool, error) {
+	inserted := make([]bool, len(logs))
+	if len(logs) == 0 {
+		return inserted, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		indexes, err := filterExistingSystemLogs(tx, logs)
+		if err != nil {
+			return err
+		}
+		if len(indexes) == 0 {
+			return nil
+		}
+
+		fresh := make([]models.SystemLog, len(indexes))
+		for i, index := range indexes {
+			fresh[i] = logs[index]
+		}
+		rowsAffected, err := database.CreateIgnoreConflicts(tx, &fresh, "source", "source_file", "source_offset")
+		if err != nil {
+			return err
+		}
+		if rowsAffected != int64(len(fresh)) {
+			// 并发写入了相同的行，无法区分哪些行已写入，回滚后由调用方重试
+			return errors.New("存在并发写入的重复系统日志")
+		}
+		for _, index := range indexes {
+			inserted[index] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return inserted, nil
+}
+
+// systemLogLine 系统日志的去重键：来源/文件/行首位置
+type systemLogLine struct {
+	source string
+	file   string
+	offset int64
+}
+
+// filterExistingSystemLogs 过滤批次内重复及数据库中已存在的行（按来源/文件/行首位置），
+// 返回需要插入的日志下标；没有行首位置的日志不去重
+func filterExistingSystemLogs(tx *gorm.DB, logs []models.SystemLog) ([]int, error) {
+	// 按来源文件分组查询已存在的行首位置
+	type sourceFile struct{ source, file string }
+	offsets := make(map[sourceFile][]int64)
+	for _, log := range logs {
+		if log.SourceOffset != nil {
+			key := sourceFile{log.Source, log.SourceFile}
+			offsets[key] = append(offsets[key], *log.SourceOffset)
+		}
+	}
+
+	existing := make(map[systemLogLine]bool)
+	const chunkSize = 500
+	for key, list := range offsets {
+		for i := 0; i < len(list); i += chunkSize {
+			var found []int64
+			if err := tx.Unscoped().Model(&models.SystemLog{}).
+				Where("source = ? AND source_file = ? AND source_offset IN ?", key.source, key.file, list[i:min(i+chunkSize, len(list))]).
+				Pluck("source_offset", &found).Error; err != nil {
+				return nil, err
+			}
+			for _, offset := range found {
+				existing[systemLogLine{key.source, key.file, offset}] = true
+			}
+		}
+	}
+
+	indexes := make([]int, 0, len(logs))
+	for i, log := range logs {
+		if log.SourceOffset != nil {
+			line := systemLogLine{log.Source, log.SourceFile, *log.SourceOffset}
+			if existing[line] {
+				continue
+			}
+			existing[line] = true
+		}
+		indexes = append(indexes, i)
+	}
+	return indexes, nil
 }
 
 // ListSystemLogsRequest 系统日志列表查询请求
 type ListSystemLogsRequest struct {
-	Page      int    `form:"page"`
-	PageSize  int    `form:"page_size"`
-	Level     string `form:"level"`
-	StartTime string `form:"start_time"`
-	EndTime   string `form:"end_time"`
+	Page      int      `form:"page"`
+	PageSize  int      `form:"page_size"`
+	Level     string   `form:"level"`
+	Msg       string   `form:"msg"`        // 消息内容子串
+	Attrs     []string `form:"attr"`       // 属性过滤，key=value 或 key~value，可重复，需同时满足
+	ServiceID string   `form:"service_id"` // 来源服务标识
+	Host      string   `form:"host"`       // 来源主机
+	StartTime string   `form:"start_time"`
+	EndTime   string   `form:"end_time"`
+	TZ        string   `form:"tz"`      // start_time / end_time 不带时区时使用的时区，为空时使用配置的默认时区
+	Archive   uint     `form:"archive"` // 归档导入 ID，指定时查询导入的临时表
 }
 
 // ListSystemLogsResponse 系统日志列表查询响应
@@ -128,20 +282,29 @@ func (s *SystemLogService) ListSystemLogs(req *ListSystemLogsRequest) (*ListSyst
 	var list []models.SystemLog
 
 	query := database.DB.Model(&models.SystemLog{})
+	if req.Archive != 0 {
+		table, err := resolveArchiveImport(req.Archive, "system_logs")
+		if err != nil {
+			return nil, err
+		}
+		query = query.Table(table)
+	}
+
+	attrFilters, err := ParseAttrFilters(req.Attrs)
+	if err != nil {
+		return nil, err
+	}
 
 	if req.Level != "" {
 		query = query.Where("level = ?", req.Level)
 	}
+	query = applyMsgFilter(query, req.Msg)
+	query = applyAttrFilters(query, attrFilters)
+	query = applyOriginFilters(query, req.ServiceID, req.Host)
 
-	if req.StartTime != "" {
-		if startTime, err := time.Parse("2006-01-02 15:04:05", req.StartTime); err == nil {
-			query = query.Where("time >= ?", startTime)
-		}
-	}
-	if req.EndTime != "" {
-		if endTime, err := time.Parse("2006-01-02 15:04:05", req.EndTime); err == nil {
-			query = query.Where("time <= ?", endTime)
-		}
+	query, err = applyTimeFilter(query, req.StartTime, req.EndTime, req.TZ)
+	if err != nil {
+		return nil, err
 	}
 
 	if err := query.Count(&total).Error; err != nil {
@@ -176,6 +339,7 @@ func (s *SystemLogService) GetSystemLog(id uint) (*models.SystemLog, error) {
 type DeleteSystemLogsByTimeRangeRequest struct {
 	StartTime       string `json:"start_time" binding:"required"`
 	EndTime         string `json:"end_time" binding:"required"`
+	TZ              string `json:"tz"` // 时间不带时区时使用的时区，为空时使用配置的默认时区
 	SystemAuthToken string `json:"system_auth_token" binding:"required"`
 }
 
@@ -194,18 +358,9 @@ func (s *SystemLogService) DeleteSystemLogsByTimeRange(req *DeleteSystemLogsByTi
 	}
 
 	// 解析时间范围
-	startTime, err := time.Parse("2006-01-02 15:04:05", req.StartTime)
+	startTime, endTime, err := parseDeleteTimeRange(req.StartTime, req.EndTime, req.TZ)
 	if err != nil {
-		return nil, errors.New("开始时间格式错误，正确格式为: 2006-01-02 15:04:05")
-	}
-
-	endTime, err := time.Parse("2006-01-02 15:04:05", req.EndTime)
-	if err != nil {
-		return nil, errors.New("结束时间格式错误，正确格式为: 2006-01-02 15:04:05")
-	}
-
-	if startTime.After(endTime) {
-		return nil, errors.New("开始时间不能晚于结束时间")
+		return nil, err
 	}
 
 	// 执行硬删除
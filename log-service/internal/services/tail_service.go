@@ -0,0 +1,168 @@
+// Package services 业务逻辑服务层
+// 实现请求日志和系统日志的实时日志流（按 ID 增量推送新写入的记录）
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+
+	"gorm.io/gorm"
+)
+
+const (
+	// tailBatchSize 单次增量查询的最大条数
+	tailBatchSize = 100
+	// tailPollInterval 兜底轮询间隔（其他实例写入或通知丢失时仍能推送）
+	tailPollInterval = 2 * time.Second
+	// tailHeartbeatInterval 心跳间隔，避免连接被中间代理断开
+	tailHeartbeatInterval = 15 * time.Second
+)
+
+// TailLogsRequest 请求日志实时流请求，过滤条件与 ListLogsRequest 相同
+type TailLogsRequest struct {
+	SinceID       uint   `form:"since_id"` // 从该 ID 之后开始推送，默认从当前最新记录之后开始
+	RequestID     string `form:"request_id"`
+	Status        string `form:"status"`
+	Method        string `form:"method"`
+	Authorization string `form:"authorization"`
+	ServiceID     string `form:"service_id"`
+	Host          string `form:"host"`
+}
+
+// TailSystemLogsRequest 系统日志实时流请求
+type TailSystemLogsRequest struct {
+	SinceID   uint     `form:"since_id"`
+	Level     string   `form:"level"`
+	RequestID string   `form:"request_id"`
+	ServiceID string   `form:"service_id"`
+	Host      string   `form:"host"`
+	Msg       string   `form:"msg"`
+	Attrs     []string `form:"attr"` // 属性过滤，格式同 ListSystemLogsRequest
+}
+
+// TailSink 实时日志流的输出端
+type TailSink[T any] struct {
+	Send      func(list []T) error // 推送新记录
+	Heartbeat func() error         // 推送心跳
+}
+
+// TailLogs 持续推送新写入的请求日志，直到 ctx 结束或推送失败
+func (s *LogService) TailLogs(ctx context.Context, req *TailLogsRequest, sink TailSink[models.TokenUsageLog]) error {
+	newQuery := func() *gorm.DB {
+		query := database.DB.Model(&models.TokenUsageLog{})
+		query = applyRequestLogFilters(query, req.RequestID, req.Status, req.Method, req.Authorization)
+		return applyOriginFilters(query, req.ServiceID, req.Host)
+	}
+
+	return tail(ctx, requestLogNotifier, &models.TokenUsageLog{}, req.SinceID, newQuery,
+		func(list []models.TokenUsageLog) uint { return list[len(list)-1].ID }, sink)
+}
+
+// TailSystemLogs 持续推送新写入的系统日志，直到 ctx 结束或推送失败
+func (s *SystemLogService) TailSystemLogs(ctx context.Context, req *TailSystemLogsRequest, sink TailSink[models.SystemLog]) error {
+	attrFilters, err := ParseAttrFilters(req.Attrs)
+	if err != nil {
+		return err
+	}
+
+	newQuery := func() *gorm.DB {
+		query := database.DB.Model(&models.SystemLog{})
+		if req.Level != "" {
+			query = query.Where("level = ?", req.Level)
+		}
+		if req.RequestID != "" {
+			query = query.Where("request_id = ?", req.RequestID)
+		}
+		query = applyMsgFilter(query, req.Msg)
+		query = applyAttrFilters(query, attrFilters)
+		return applyOriginFilters(query, req.ServiceID, req.Host)
+	}
+
+	return tail(ctx, systemLogNotifier, &models.SystemLog{}, req.SinceID, newQuery,
+		func(list []models.SystemLog) uint { return list[len(list)-1].ID }, sink)
+}
+
+// tail 实时日志流主循环
+// 收到写入通知或兜底轮询时，按 id > lastID 增量查询并推送
+func tail[T any](
+	ctx context.Context,
+	notifier *Notifier,
+	model interface{},
+	sinceID uint,
+	newQuery func() *gorm.DB,
+	lastIDOf func(list []T) uint,
+	sink TailSink[T],
+) error {
+	// 先订阅再确定起点，避免遗漏两者之间写入的记录
+	signal, unsubscribe := notifier.Subscribe()
+	defer unsubscribe()
+
+	lastID := sinceID
+	if lastID == 0 {
+		maxID, err := maxLogID(model)
+		if err != nil {
+			return err
+		}
+		lastID = maxID
+	}
+
+	pollTicker := time.NewTicker(tailPollInterval)
+	defer pollTicker.Stop()
+	heartbeatTicker := time.NewTicker(tailHeartbeatInterval)
+	defer heartbeatTicker.Stop()
+
+	for {
+		// 推送积压的全部新记录。以查询前的最大 id 为本轮上界，查完后 lastID 推进到上界，
+		// 过滤条件很少命中时，下一轮不会再扫描本轮已检查过但不匹配的记录
+		upperID, err := maxLogID(model)
+		if err != nil {
+			return err
+		}
+		for lastID < upperID {
+			var list []T
+			if err := newQuery().
+				Where("id > ? AND id <= ?", lastID, upperID).
+				Order("id ASC").
+				Limit(tailBatchSize).
+				Find(&list).Error; err != nil {
+				return errors.New("查询新日志失败")
+			}
+			if len(list) > 0 {
+				if err := sink.Send(list); err != nil {
+					return err
+				}
+			}
+			if len(list) < tailBatchSize {
+				lastID = upperID
+				break
+			}
+			lastID = lastIDOf(list)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-signal:
+		case <-pollTicker.C:
+		case <-heartbeatTicker.C:
+			if err := sink.Heartbeat(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// maxLogID 日志表当前的最大 id，表为空时返回 0
+func maxLogID(model interface{}) (uint, error) {
+	var maxID *uint
+	if err := database.DB.Model(model).Select("MAX(id)").Scan(&maxID).Error; err != nil {
+		return 0, errors.New("查询最新日志 ID 失败")
+	}
+	if maxID == nil {
+		return 0, nil
+	}
+	return *maxID, nil
+}
@@ -0,0 +1,272 @@
+// Package services 业务逻辑服务层
+// 请求时间线：将请求日志与同一 request_id 的系统日志合并为按时间排序的事件
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"zxm_ai_admin/log-service/internal/database"
+	"zxm_ai_admin/log-service/internal/models"
+	"zxm_ai_admin/log-service/internal/utils"
+)
+
+var (
+	// ErrTimelineParam 时间线查询参数错误
+	ErrTimelineParam = errors.New("参数错误")
+	// ErrTimelineNotFound 没有找到请求日志或系统日志
+	ErrTimelineNotFound = errors.New("没有找到相关日志")
+)
+
+const (
+	// maxTimelineWindow 按 Token 查询时的最大时间窗口
+	maxTimelineWindow = 24 * time.Hour
+	// maxTimelineRequests 按 Token 查询时最多返回的请求数
+	maxTimelineRequests = 100
+)
+
+// 时间线事件类型
+const (
+	TimelineRequestStart    = "request_start"    // 请求开始（请求日志时间减去耗时）
+	TimelineUpstreamAttempt = "upstream_attempt" // 上游调用：失败为带 target_url 属性的系统日志，成功时由请求日志的响应头耗时得出
+	TimelineSystemLog       = "system_log"       // 其他系统日志，如 token 不在缓存中
+	TimelineRequestEnd      = "request_end"      // 请求完成，即请求日志本身
+)
+
+// TimelineService 请求时间线业务逻辑
+type TimelineService struct{}
+
+// NewTimelineService 创建请求时间线业务逻辑实例
+func NewTimelineService() *TimelineService {
+	return &TimelineService{}
+}
+
+// RequestTimelineRequest 请求时间线查询参数，request_id 与 authorization 二选一
+type RequestTimelineRequest struct {
+	RequestID     string `form:"request_id"`
+	Authorization string `form:"authorization"` // Token（模糊匹配），需同时指定 start_time 和 end_time
+	StartTime     string `form:"start_time"`
+	EndTime       string `form:"end_time"`
+	TZ            string `form:"tz"` // start_time / end_time 不带时区时使用的时区，为空时使用配置的默认时区
+}
+
+// TimelineEvent 时间线中的一个事件
+type TimelineEvent struct {
+	Time      time.Time      `json:"time"`
+	ElapsedMs int64          `json:"elapsed_ms"` // 距请求开始的毫秒数，没有请求日志时距第一条系统日志
+	Kind      string         `json:"kind"`       // request_start / upstream_attempt / system_log / request_end
+	Level     string         `json:"level,omitempty"`
+	Msg       string         `json:"msg,omitempty"`
+	Status    int            `json:"status,omitempty"`  // request_end 的响应状态码，成功的 upstream_attempt 的上游响应状态码
+	Attempt   int            `json:"attempt,omitempty"` // upstream_attempt 按出现顺序的序号，从 1 开始
+	LogID     uint           `json:"log_id,omitempty"`  // 对应的请求日志或系统日志 ID
+	Attrs     models.JSONMap `json:"attrs,omitempty"`   // 系统日志的属性
+}
+
+// RequestTimeline 单个请求的时间线
+type RequestTimeline struct {
+	RequestID        string                `json:"request_id"`
+	Request          *models.TokenUsageLog `json:"request"` // 请求日志，尚未同步时为 null
+	SystemLogs       int                   `json:"system_logs"`
+	UpstreamAttempts int                   `json:"upstream_attempts"`
+	Events           []TimelineEvent       `json:"events"`
+}
+
+// RequestTimelineResponse 请求时间线查询响应
+type RequestTimelineResponse struct {
+	Requests  []RequestTimeline `json:"requests"`  // 按请求开始时间排序
+	Truncated bool              `json:"truncated"` // 按 Token 查询时窗口内的请求超过上限，只返回最早的部分
+}
+
+// GetRequestTimeline 查询请求时间线：按 request_id 返回单个请求，按 Token 返回时间窗口内的全部请求
+func (s *TimelineService) GetRequestTimeline(req *RequestTimelineRequest) (*RequestTimelineResponse, error) {
+	var requests []models.TokenUsageLog
+	var requestIDs []string
+	truncated := false
+
+	switch {
+	case req.RequestID != "" && req.Authorization != "":
+		return nil, fmt.Errorf("%w: request_id 与 authorization 只能指定一个", ErrTimelineParam)
+	case req.RequestID != "":
+		if err := database.DB.Where("request_id = ?", req.RequestID).Find(&requests).Error; err != nil {
+			return nil, errors.New("查询请求日志失败")
+		}
+		requestIDs = []string{req.RequestID}
+	case req.Authorization != "":
+		start, end, err := parseTimelineWindow(req)
+		if err != nil {
+			return nil, err
+		}
+		query := applyRequestLogFilters(database.DB.Model(&models.TokenUsageLog{}), "", "", "", req.Authorization)
+		if err := query.
+			Where("time >= ? AND time <= ?", start, end).
+			Order("time ASC").
+			Limit(maxTimelineRequests + 1).
+			Find(&requests).Error; err != nil {
+			return nil, errors.New("查询请求日志失败")
+		}
+		if len(requests) > maxTimelineRequests {
+			requests, truncated = requests[:maxTimelineRequests], true
+		}
+		for _, r := range requests {
+			if r.RequestID != "" {
+				requestIDs = append(requestIDs, r.RequestID)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("%w: 需要指定 request_id 或 authorization", ErrTimelineParam)
+	}
+
+	var systemLogs []models.SystemLog
+	if len(requestIDs) > 0 {
+		if err := database.DB.
+			Where("request_id IN ?", requestIDs).
+			Order("time ASC, id ASC").
+			Find(&systemLogs).Error; err != nil {
+			return nil, errors.New("查询系统日志失败")
+		}
+	}
+	if len(requests) == 0 && len(systemLogs) == 0 {
+		return nil, ErrTimelineNotFound
+	}
+
+	return &RequestTimelineResponse{
+		Requests:  buildTimelines(requests, systemLogs),
+		Truncated: truncated,
+	}, nil
+}
+
+// parseTimelineWindow 解析按 Token 查询的时间窗口，起止时间必填且不超过 maxTimelineWindow
+func parseTimelineWindow(req *RequestTimelineRequest) (time.Time, time.Time, error) {
+	if req.StartTime == "" || req.EndTime == "" {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 按 authorization 查询时需要指定 start_time 和 end_time", ErrTimelineParam)
+	}
+	loc, err := utils.LoadTimeZone(req.TZ)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: %v", ErrTimelineParam, err)
+	}
+	start, err := utils.ParseTimeParam(req.StartTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 开始时间%v", ErrTimelineParam, err)
+	}
+	end, err := utils.ParseTimeParam(req.EndTime, loc)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 结束时间%v", ErrTimelineParam, err)
+	}
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 结束时间早于开始时间", ErrTimelineParam)
+	}
+	if end.Sub(start) > maxTimelineWindow {
+		return time.Time{}, time.Time{}, fmt.Errorf("%w: 时间窗口不能超过 %d 小时", ErrTimelineParam, int(maxTimelineWindow.Hours()))
+	}
+	return start, end, nil
+}
+
+// buildTimelines 按 request_id 合并请求日志与系统日志。只有系统日志的请求（请求日志尚未同步）也会返回
+func buildTimelines(requests []models.TokenUsageLog, systemLogs []models.SystemLog) []RequestTimeline {
+	timelines := make(map[string]*RequestTimeline)
+	var order []string
+	get := func(requestID string) *RequestTimeline {
+		timeline, ok := timelines[requestID]
+		if !ok {
+			timeline = &RequestTimeline{RequestID: requestID, Events: []TimelineEvent{}}
+			timelines[requestID] = timeline
+			order = append(order, requestID)
+		}
+		return timeline
+	}
+
+	for i := range requests {
+		r := &requests[i]
+		timeline := get(r.RequestID)
+		timeline.Request = r
+		timeline.Events = append(timeline.Events,
+			TimelineEvent{
+				Time:  r.Time.Add(-time.Duration(r.LatencyMs) * time.Millisecond),
+				Kind:  TimelineRequestStart,
+				Msg:   r.Method + " " + r.Path,
+				LogID: r.ID,
+			},
+			TimelineEvent{
+				Time:   r.Time,
+				Kind:   TimelineRequestEnd,
+				Level:  r.Level,
+				Msg:    r.Msg,
+				Status: r.Status,
+				LogID:  r.ID,
+			},
+		)
+	}
+
+	for _, l := range systemLogs {
+		timeline := get(l.RequestID)
+		event := TimelineEvent{
+			Time:  l.Time,
+			Kind:  TimelineSystemLog,
+			Level: l.Level,
+			Msg:   l.Msg,
+			LogID: l.ID,
+			Attrs: l.Attrs,
+		}
+		timeline.SystemLogs++
+		if l.Attrs["target_url"] != "" {
+			event.Kind = TimelineUpstreamAttempt
+			timeline.UpstreamAttempts++
+			event.Attempt = timeline.UpstreamAttempts
+		}
+		timeline.Events = append(timeline.Events, event)
+	}
+
+	// proxy 只在上游调用失败时记录系统日志；已转发到上游（命中模型配置）且没有失败记录的请求，
+	// 按请求日志的响应头耗时补充一次成功的上游调用
+	for _, timeline := range timelines {
+		r := timeline.Request
+		if r == nil || r.AIModelName == "" || timeline.UpstreamAttempts > 0 {
+			continue
+		}
+		timeline.UpstreamAttempts++
+		timeline.Events = append(timeline.Events, TimelineEvent{
+			Time:    r.Time.Add(time.Duration(r.HeaderLatencyMs-r.LatencyMs) * time.Millisecond),
+			Kind:    TimelineUpstreamAttempt,
+			Msg:     "上游响应",
+			Status:  r.Status,
+			Attempt: timeline.UpstreamAttempts,
+			LogID:   r.ID,
+		})
+	}
+
+	result := make([]RequestTimeline, 0, len(order))
+	for _, requestID := range order {
+		timeline := timelines[requestID]
+		sortTimelineEvents(timeline.Events)
+		result = append(result, *timeline)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Events[0].Time.Before(result[j].Events[0].Time)
+	})
+	return result
+}
+
+// timelineKindOrder 同一时刻的事件顺序：请求开始最先，请求完成最后
+var timelineKindOrder = map[string]int{
+	TimelineRequestStart:    0,
+	TimelineUpstreamAttempt: 1,
+	TimelineSystemLog:       1,
+	TimelineRequestEnd:      2,
+}
+
+// sortTimelineEvents 按时间排序并计算距第一个事件的耗时。
+// 系统日志与请求日志的时间精度为毫秒，同一时刻按事件类型排序
+func sortTimelineEvents(events []TimelineEvent) {
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].Time.Equal(events[j].Time) {
+			return events[i].Time.Before(events[j].Time)
+		}
+		return timelineKindOrder[events[i].Kind] < timelineKindOrder[events[j].Kind]
+	})
+	for i := range events {
+		events[i].ElapsedMs = events[i].Time.Sub(events[0].Time).Milliseconds()
+	}
+}
@@ -3,36 +3,97 @@
 package utils
 
 import (
+	"errors"
+	"fmt"
+	"strings"
 	"time"
+	_ "time/tzdata" // 内置时区数据，容器镜像中没有 zoneinfo 时也能加载 IANA 时区
+
+	"zxm_ai_admin/log-service/internal/config"
 )
 
-// ParseTime 解析时间字符串
+// logTimeLayouts 写入日志时支持的时间格式
+var logTimeLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05.000-07:00", // 带毫秒的 ISO 8601 格式
+}
+
+// ParseTime 解析写入日志时的时间字符串，统一转换为 UTC 存储
 // 支持 RFC3339、RFC3339Nano 和带毫秒的 ISO 8601 格式
-// 如果解析失败或为空，返回当前时间
+// 如果解析失败或为空，返回 1970-01-01 00:00:00 UTC
 func ParseTime(timeStr string) time.Time {
+	t, err := ParseLogTime(timeStr)
+	if err != nil {
+		return time.Unix(0, 0).UTC()
+	}
+	return t
+}
+
+// ParseLogTime 解析写入日志时的时间字符串，格式同 ParseTime，为空或格式不支持时返回错误
+func ParseLogTime(timeStr string) (time.Time, error) {
 	if timeStr == "" {
-		return time.Unix(0, 0)
+		return time.Time{}, errors.New("缺少 time 字段")
 	}
+	for _, layout := range logTimeLayouts {
+		if t, err := time.Parse(layout, timeStr); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("time 格式错误: %s", timeStr)
+}
 
-	// 尝试 RFC3339 格式
-	t, err := time.Parse(time.RFC3339, timeStr)
-	if err == nil {
-		return t
+// localTimeLayouts 查询参数中不带时区的时间格式，按请求时区解析
+var localTimeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// ParseTimeParam 解析查询参数中的时间，返回 UTC 时间
+// 带时区的 RFC3339 时间按自身偏移解析，2006-01-02 15:04:05 等不带时区的格式按 loc 解析
+func ParseTimeParam(value string, loc *time.Location) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t.UTC(), nil
 	}
+	for _, layout := range localTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t.UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("格式错误: %s，支持 RFC3339（如 2006-01-02T15:04:05+08:00）或 2006-01-02 15:04:05", value)
+}
 
-	// 尝试 RFC3339Nano 格式
-	t, err = time.Parse(time.RFC3339Nano, timeStr)
-	if err == nil {
-		return t
+// LoadTimeZone 解析 tz 参数，为空时使用配置的默认时区
+// 支持 IANA 时区名（如 Asia/Shanghai、UTC）与固定偏移（如 +08:00、-05:30）
+func LoadTimeZone(name string) (*time.Location, error) {
+	if name == "" {
+		if cfg := config.GetConfig(); cfg != nil {
+			name = cfg.Server.Timezone
+		}
+	}
+	if name == "" {
+		return time.UTC, nil
 	}
 
-	// 尝试带毫秒的 ISO 8601 格式：2006-01-02T15:04:05.000-07:00
-	t, err = time.Parse("2006-01-02T15:04:05.000-07:00", timeStr)
-	if err == nil {
-		return t
+	if strings.HasPrefix(name, "+") || strings.HasPrefix(name, "-") {
+		t, err := time.Parse("-07:00", name)
+		if err != nil {
+			return nil, errors.New("时区格式错误: " + name + "，固定偏移格式为 +08:00")
+		}
+		_, offset := t.Zone()
+		return time.FixedZone(name, offset), nil
 	}
 
-	// 解析失败时返回 1970-01-01 00:00:00 +08:00
-	return time.Date(1970, 1, 1, 0, 0, 0, 0, time.FixedZone("UTC+8", 8*3600))
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, errors.New("未知时区: " + name)
+	}
+	return loc, nil
 }
 
+// ZoneOffset 时区在 t 时刻相对 UTC 的偏移秒数，用于在数据库中按时区分桶
+func ZoneOffset(loc *time.Location, t time.Time) int {
+	_, offset := t.In(loc).Zone()
+	return offset
+}
@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+
+	"zxm_ai_admin/log-syncer/internal/archiver"
+	"zxm_ai_admin/log-syncer/internal/config"
+	"zxm_ai_admin/log-syncer/internal/parser"
+	"zxm_ai_admin/log-syncer/internal/uploader"
+)
+
+// commands 子命令，参数为子命令之后的命令行参数，返回进程退出码
+var commands = map[string]func(args []string) int{
+	"replay": runReplay,
+}
+
+// replayOptions 重放参数
+type replayOptions struct {
+	dryRun    bool
+	force     bool
+	logType   string
+	source    string
+	serviceID string
+	host      string
+}
+
+// replayResult 单个归档文件的重放结果
+type replayResult struct {
+	lines   int
+	entries int
+	invalid int
+}
+
+// runReplay 将归档文件重新上传到 log-service
+//
+// 用法：log-syncer replay [-config configs/config.yaml] [-dry-run] [-force] <归档文件或目录>...
+// 上传前按清单校验解压后内容的 sha256，不一致时跳过（-force 仍然上传）。log-service 按 request_id（系统日志按来源位置）去重，重复重放不会产生重复数据
+func runReplay(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	configPath := fs.String("config", "configs/config.yaml", "配置文件路径")
+	var opts replayOptions
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "只校验和解析，不上传")
+	fs.BoolVar(&opts.force, "force", false, "清单校验不通过时仍然上传")
+	fs.StringVar(&opts.logType, "type", "", "没有清单时的日志类型：request 或 system，默认按文件名前缀判断")
+	fs.StringVar(&opts.source, "source", "", "没有清单时的来源名称，写入系统日志的 source，用于去重")
+	fs.StringVar(&opts.serviceID, "service-id", "", "没有清单时写入的来源服务标识")
+	fs.StringVar(&opts.host, "host", "", "没有清单时写入的来源主机")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fmt.Fprintln(os.Stderr, "用法: log-syncer replay [-config configs/config.yaml] [-dry-run] [-force] <归档文件或目录>...")
+		return 2
+	}
+	if opts.logType != "" && opts.logType != "request" && opts.logType != "system" {
+		fmt.Fprintln(os.Stderr, "-type 只能是 request 或 system")
+		return 2
+	}
+
+	files, err := collectArchives(fs.Args())
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 2
+	}
+	if len(files) == 0 {
+		fmt.Fprintln(os.Stderr, "没有找到归档文件")
+		return 1
+	}
+
+	if err := config.Load(*configPath); err != nil {
+		fmt.Fprintf(os.Stderr, "加载配置失败: %v\n", err)
+		return 1
+	}
+	cfg := config.GetConfig()
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	r := &replayer{
+		parser: parser.NewParser(),
+		uploader: uploader.NewUploader(cfg.Server.LogServiceURL, cfg.Server.SystemAuthToken, cfg.Server.Timeout, uploader.RetryPolicy{
+			MaxRetries:     cfg.Uploader.MaxRetries,
+			InitialBackoff: cfg.Uploader.InitialBackoff,
+			MaxBackoff:     cfg.Uploader.MaxBackoff,
+		}, cfg.Uploader.Compression == "gzip"),
+		batchSize: cfg.Uploader.BatchSize,
+		opts:      opts,
+	}
+
+	failed := 0
+	var total replayResult
+	for _, path := range files {
+		if ctx.Err() != nil {
+			break
+		}
+		result, err := r.replay(ctx, path)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %v\n", path, err)
+			failed++
+			continue
+		}
+		fmt.Printf("%s: %d 行, 上传 %d 条, 无效 %d 行\n", path, result.lines, result.entries, result.invalid)
+		total.lines += result.lines
+		total.entries += result.entries
+		total.invalid += result.invalid
+	}
+
+	fmt.Printf("合计: %d 个文件, 失败 %d 个, 上传 %d 条, 无效 %d 行\n", len(files), failed, total.entries, total.invalid)
+	if ctx.Err() != nil {
+		fmt.Fprintln(os.Stderr, "已中断")
+		return 1
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// collectArchives 展开命令行中的文件和目录（递归），跳过清单和未写完的临时文件
+func collectArchives(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(p string, d os.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			name := d.Name()
+			if d.IsDir() || strings.HasPrefix(name, ".") || strings.HasSuffix(name, archiver.ManifestSuffix) || strings.HasSuffix(name, ".tmp") {
+				return nil
+			}
+			files = append(files, p)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// replayer 重放归档文件
+type replayer struct {
+	parser    *parser.Parser
+	uploader  *uploader.Uploader
+	batchSize int
+	opts      replayOptions
+}
+
+// replay 校验并上传单个归档文件
+func (r *replayer) replay(ctx context.Context, path string) (replayResult, error) {
+	manifest, err := archiver.ReadManifest(path)
+	if err != nil {
+		return replayResult{}, err
+	}
+	name := archiver.OriginalName(filepath.Base(path))
+
+	origin := parser.Origin{Source: r.opts.source, ServiceID: r.opts.serviceID, Host: r.opts.host}
+	logType := r.opts.logType
+	if manifest != nil {
+		origin = parser.Origin{Source: manifest.Source, ServiceID: manifest.ServiceID, Host: manifest.Host}
+		logType = manifest.Type
+		if err := verifyArchive(path, manifest); err != nil {
+			if !r.opts.force {
+				return replayResult{}, err
+			}
+			fmt.Fprintf(os.Stderr, "%s: %v（-force，继续上传）\n", path, err)
+		}
+	} else {
+		fmt.Fprintf(os.Stderr, "%s: 没有清单，跳过校验\n", path)
+	}
+	if logType == "" {
+		switch {
+		case strings.HasPrefix(name, "request-"):
+			logType = "request"
+		case strings.HasPrefix(name, "system-"):
+			logType = "system"
+		default:
+			return replayResult{}, fmt.Errorf("无法判断日志类型，请指定 -type")
+		}
+	}
+
+	content, err := archiver.OpenArchive(path)
+	if err != nil {
+		return replayResult{}, err
+	}
+	defer content.Close()
+
+	parserType := parser.LogTypeSystem
+	if logType == "request" {
+		parserType = parser.LogTypeRequest
+	}
+	reader := r.parser.NewReader(content, name, parserType)
+	reader.SetOrigin(origin)
+
+	var result replayResult
+	for {
+		if err := ctx.Err(); err != nil {
+			return result, err
+		}
+		entries, invalid, err := reader.ReadBatch(r.batchSize)
+		if err != nil {
+			return result, err
+		}
+		if len(entries) == 0 && len(invalid) == 0 {
+			break
+		}
+		result.invalid += len(invalid)
+
+		if len(entries) == 0 || r.opts.dryRun {
+			result.entries += len(entries)
+			continue
+		}
+		// 不带批次 ID：重放用于恢复被删除的数据，只按行去重
+		upload := r.uploader.UploadWithRetry(ctx, batchData(entries), parserType == parser.LogTypeRequest, uploader.Batch{})
+		if !upload.Success {
+			return result, fmt.Errorf("第 %d 行附近的批次上传失败: %w", reader.Line(), upload.Error)
+		}
+		rejected := rejectedLines(entries, upload.Rejected)
+		for _, line := range rejected {
+			fmt.Fprintf(os.Stderr, "%s: 第 %d 行: %s\n", path, line.Number, line.Reason)
+		}
+		result.invalid += len(rejected)
+		result.entries += len(entries) - len(rejected)
+	}
+	result.lines = reader.Line()
+	return result, nil
+}
+
+// verifyArchive 解压归档文件，校验内容的 sha256 与清单一致
+func verifyArchive(path string, manifest *archiver.Manifest) error {
+	content, err := archiver.OpenArchive(path)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return fmt.Errorf("解压归档文件失败: %w", err)
+	}
+	if sum := hex.EncodeToString(hash.Sum(nil)); sum != manifest.SHA256 {
+		return fmt.Errorf("sha256 与清单不一致: %s != %s", sum, manifest.SHA256)
+	}
+	return nil
+}
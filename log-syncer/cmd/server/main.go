@@ -2,22 +2,29 @@
 package main
 
 import (
+	"context"
 	"log/slog"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
-	"zxm_ai_admin/log-syncer/internal/archiver"
 	"zxm_ai_admin/log-syncer/internal/config"
 	applogger "zxm_ai_admin/log-syncer/internal/logger"
 	"zxm_ai_admin/log-syncer/internal/parser"
 	"zxm_ai_admin/log-syncer/internal/scheduler"
-	"zxm_ai_admin/log-syncer/internal/scanner"
+	"zxm_ai_admin/log-syncer/internal/trigger"
 	"zxm_ai_admin/log-syncer/internal/uploader"
 )
 
 func main() {
+	// 子命令
+	if len(os.Args) > 1 {
+		if command, ok := commands[os.Args[1]]; ok {
+			os.Exit(command(os.Args[2:]))
+		}
+	}
+
 	// 加载配置
 	configPath := "configs/config.yaml"
 	if len(os.Args) > 1 {
@@ -40,185 +47,128 @@ func main() {
 	}
 
 	applogger.Info("日志同步服务启动",
-		"log_dir", cfg.Proxy.LogDir,
+		"sources", len(cfg.Sources),
 		"archive_dir", cfg.Archive.Dir,
 		"retention_days", cfg.Archive.RetentionDays,
 		"log_service_url", cfg.Server.LogServiceURL,
+		"dead_letter_dir", cfg.DeadLetter.Dir,
+		"checkpoint_dir", cfg.Checkpoint.Dir,
 	)
 
 	// 创建组件
-	logScanner := scanner.NewScanner(cfg.Proxy.LogDir)
 	logParser := parser.NewParser()
-	upldr := uploader.NewUploader(cfg.Server.LogServiceURL, cfg.Server.SystemAuthToken, cfg.Server.Timeout)
-	arch := archiver.NewArchiver(cfg.Archive.Dir, cfg.Archive.RetentionDays)
-
-	// 创建调度器
-	sched := scheduler.NewScheduler(func() {
-		runSyncTask(logScanner, logParser, upldr, arch)
-	})
-
-	// 启动调度器
-	sched.Start()
-
-	// 启动时立即执行一次同步任务
-	applogger.Info("启动时执行同步任务")
-	runSyncTask(logScanner, logParser, upldr, arch)
-
-	// 等待退出信号
-	quit := make(chan os.Signal, 1)
-	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
-
-	applogger.Info("正在关闭服务...")
-	sched.Stop()
-	applogger.Info("服务已关闭")
-}
-
-// runSyncTask 执行同步任务
-func runSyncTask(
-	logScanner *scanner.Scanner,
-	logParser *parser.Parser,
-	upldr *uploader.Uploader,
-	arch *archiver.Archiver,
-) {
-	applogger.Info("===== 开始扫描日志文件 =====")
-
-	cfg := config.GetConfig()
-	cutoffTime := getCutoffTime()
-
-	// 扫描日志文件
-	files, err := logScanner.Scan(cutoffTime)
-	if err != nil {
-		applogger.Error("扫描日志文件失败", "error", err)
-		return
+	upldr := uploader.NewUploader(cfg.Server.LogServiceURL, cfg.Server.SystemAuthToken, cfg.Server.Timeout, uploader.RetryPolicy{
+		MaxRetries:     cfg.Uploader.MaxRetries,
+		InitialBackoff: cfg.Uploader.InitialBackoff,
+		MaxBackoff:     cfg.Uploader.MaxBackoff,
+	}, cfg.Uploader.Compression == "gzip")
+	task := &syncer{
+		parser:    logParser,
+		uploader:  upldr,
+		batchSize: cfg.Uploader.BatchSize,
 	}
-
-	if len(files) == 0 {
-		applogger.Info("没有需要上传的日志文件")
-	} else {
-		applogger.Info("发现日志文件", "count", len(files))
-	}
-
-	// 按类型分类
-	var requestFiles []*scanner.LogFile
-	var systemFiles []*scanner.LogFile
-
-	for _, file := range files {
-		if file.Type == scanner.LogTypeRequest {
-			requestFiles = append(requestFiles, file)
-		} else {
-			systemFiles = append(systemFiles, file)
+	for _, srcCfg := range cfg.Sources {
+		src, err := newSource(cfg, srcCfg)
+		if err != nil {
+			applogger.Error("日志来源初始化失败", "error", err)
+			os.Exit(1)
 		}
+		task.sources = append(task.sources, src)
+		applogger.Info("日志来源",
+			"name", srcCfg.Name,
+			"dir", srcCfg.Dir,
+			"pattern", srcCfg.Pattern,
+			"type", srcCfg.Type,
+			"service_id", srcCfg.ServiceID,
+			"host", srcCfg.Host,
+			"timezone", srcCfg.Timezone,
+		)
 	}
 
-	applogger.Info("日志分类完成",
-		"request_count", len(requestFiles),
-		"system_count", len(systemFiles),
-	)
-
-	// 处理请求日志
-	for _, file := range requestFiles {
-		if err := processLogFile(file, logParser, upldr, arch, cfg.Uploader.BatchSize, true); err != nil {
-			applogger.Error("处理请求日志失败", "file", file.Name, "error", err)
+	// 调度规则
+	var schedule scheduler.Schedule = scheduler.IntervalSchedule{Interval: cfg.Schedule.Interval}
+	if cfg.Schedule.Cron != "" {
+		cronSchedule, err := scheduler.ParseCron(cfg.Schedule.Cron, cfg.Proxy.Location())
+		if err != nil {
+			applogger.Error("schedule.cron 配置错误", "error", err)
+			os.Exit(1)
 		}
+		schedule = cronSchedule
 	}
 
-	// 处理系统日志
-	for _, file := range systemFiles {
-		if err := processLogFile(file, logParser, upldr, arch, cfg.Uploader.BatchSize, false); err != nil {
-			applogger.Error("处理系统日志失败", "file", file.Name, "error", err)
+	// 收到退出信号时取消 ctx，中断正在等待的重试
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// 创建并启动调度器
+	sched := scheduler.NewScheduler(schedule, task.run)
+	sched.Start(ctx)
+	applogger.Info("调度器已启动", "schedule", schedule.String())
+
+	// 本地 HTTP 触发接口
+	var triggerServer *trigger.Server
+	if cfg.Trigger.Listen != "" {
+		triggerServer = trigger.NewServer(cfg.Trigger.Listen, sched)
+		if err := triggerServer.Start(); err != nil {
+			applogger.Error("触发接口启动失败", "error", err)
+			os.Exit(1)
 		}
+		applogger.Info("触发接口已启动", "listen", cfg.Trigger.Listen)
 	}
 
-	applogger.Info("===== 扫描完成 =====")
-
-	// 清理过期归档
-	if err := arch.CleanExpired(); err != nil {
-		applogger.Error("清理过期归档失败", "error", err)
-	}
-}
-
-// processLogFile 处理单个日志文件
-func processLogFile(
-	file *scanner.LogFile,
-	p *parser.Parser,
-	upldr *uploader.Uploader,
-	arch *archiver.Archiver,
-	batchSize int,
-	isRequest bool,
-) error {
-	applogger.Info("处理文件", "file", file.Name)
-
-	// 解析日志文件
-	logType := parser.LogTypeSystem
-	if isRequest {
-		logType = parser.LogTypeRequest
-	}
-
-	entries, err := p.ParseFile(file.Path, logType)
-	if err != nil {
-		return err
-	}
-
-	if len(entries) == 0 {
-		applogger.Info("文件为空或无有效日志", "file", file.Name)
-		// 空文件也归档
-		return arch.Archive(file.Path)
-	}
-
-	applogger.Info("解析日志", "file", file.Name, "entries", len(entries))
-
-	// 分批上传
-	batches := p.Batch(entries, batchSize)
-	successCount := 0
-
-	for i, batch := range batches {
-		var result uploader.UploadResult
-		if isRequest {
-			// 转换为 []interface{}
-			batchEntries := make([]interface{}, len(batch))
-			for j, entry := range batch {
-				batchEntries[j] = entry
-			}
-			result = upldr.UploadWithRetry(batchEntries, true)
-		} else {
-			// 转换系统日志为 []interface{}
-			batchEntries := make([]interface{}, len(batch))
-			for j, entry := range batch {
-				batchEntries[j] = entry
+	// 监听信号：SIGINT/SIGTERM 退出，SIGHUP 立即执行一次同步
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for {
+			select {
+			case <-hup:
+				if err := sched.Trigger(scheduler.TriggerSignal); err != nil {
+					applogger.Warn("SIGHUP 触发同步失败", "error", err)
+				} else {
+					applogger.Info("收到 SIGHUP，已触发同步")
+				}
+			case <-quit:
+				cancel()
+				return
 			}
-			result = upldr.UploadWithRetry(batchEntries, false)
-		}
-
-		if result.Success {
-			successCount++
-			applogger.Info("批次上传成功",
-				"batch", i+1,
-				"total", len(batches),
-				"batch_size", len(batch),
-			)
-		} else {
-			applogger.Error("批次上传失败",
-				"batch", i+1,
-				"total", len(batches),
-				"batch_size", len(batch),
-				"error", result.Error,
-			)
-			return result.Error // 失败不重试，直接返回
 		}
+	}()
+
+	// 跟随模式：持续上传正在写入的文件
+	tailDone := make(chan struct{})
+	if cfg.Tail.Enabled {
+		applogger.Info("跟随模式已开启", "interval", cfg.Tail.Interval.String(), "grace", cfg.Tail.Grace.String())
+		go func() {
+			defer close(tailDone)
+			task.tail(ctx, cfg.Tail.Interval, cfg.Tail.Grace)
+		}()
+	} else {
+		close(tailDone)
 	}
 
-	// 上传成功后归档
-	if successCount == len(batches) {
-		return arch.Archive(file.Path)
+	// 启动时立即执行一次同步任务
+	applogger.Info("启动时执行同步任务")
+	if err := sched.Trigger(scheduler.TriggerStartup); err != nil {
+		applogger.Warn("启动时触发同步失败", "error", err)
 	}
 
-	return nil
-}
+	// 等待退出信号
+	<-ctx.Done()
 
-// getCutoffTime 获取截止时间（当前时间）
-func getCutoffTime() time.Time {
-	return time.Now()
+	applogger.Info("正在关闭服务...")
+	if triggerServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := triggerServer.Shutdown(shutdownCtx); err != nil {
+			applogger.Error("关闭触发接口失败", "error", err)
+		}
+		shutdownCancel()
+	}
+	sched.Wait()
+	<-tailDone
+	applogger.Info("服务已关闭")
 }
 
 // parseLogLevel 解析日志级别
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-syncer/internal/archiver"
+	"zxm_ai_admin/log-syncer/internal/checkpoint"
+	"zxm_ai_admin/log-syncer/internal/config"
+	"zxm_ai_admin/log-syncer/internal/deadletter"
+	applogger "zxm_ai_admin/log-syncer/internal/logger"
+	"zxm_ai_admin/log-syncer/internal/parser"
+	"zxm_ai_admin/log-syncer/internal/scanner"
+	"zxm_ai_admin/log-syncer/internal/uploader"
+)
+
+// source 一个日志来源及其专属的组件，断点、归档和死信按来源分目录，互不干扰
+type source struct {
+	name        string
+	origin      parser.Origin
+	scanner     *scanner.Scanner
+	archiver    *archiver.Archiver
+	deadLetter  *deadletter.DeadLetter
+	checkpoints *checkpoint.Store
+}
+
+// newSource 按配置创建日志来源
+func newSource(cfg *config.Config, src config.SourceConfig) (*source, error) {
+	logType := scanner.LogTypeRequest
+	if src.Type == "system" {
+		logType = scanner.LogTypeSystem
+	}
+	sc, err := scanner.NewScanner(src.Dir, src.Pattern, logType, src.Location())
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", src.Name, err)
+	}
+	dl, err := deadletter.NewDeadLetter(filepath.Join(cfg.DeadLetter.Dir, src.Subdir()), cfg.DeadLetter.MaxFileFailures)
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", src.Name, err)
+	}
+	checkpoints, err := checkpoint.NewStore(filepath.Join(cfg.Checkpoint.Dir, src.Subdir()))
+	if err != nil {
+		return nil, fmt.Errorf("source %s: %w", src.Name, err)
+	}
+
+	return &source{
+		name:    src.Name,
+		origin:  parser.Origin{Source: src.Name, ServiceID: src.ServiceID, Host: src.Host},
+		scanner: sc,
+		archiver: archiver.NewArchiver(filepath.Join(cfg.Archive.Dir, src.Subdir()), cfg.Archive.RetentionDays, sc, archiver.Options{
+			Compression: cfg.Archive.Compression,
+			DateDirs:    cfg.Archive.DateDirs,
+		}),
+		deadLetter:  dl,
+		checkpoints: checkpoints,
+	}, nil
+}
+
+// syncer 同步任务依赖的组件
+type syncer struct {
+	sources   []*source
+	parser    *parser.Parser
+	uploader  *uploader.Uploader
+	batchSize int
+
+	// mu 保证定时同步和跟随模式不会同时处理文件
+	mu sync.Mutex
+}
+
+// run 执行同步任务，有文件处理失败时返回错误
+func (s *syncer) run(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	applogger.Info("===== 开始扫描日志文件 =====")
+
+	failed, total := 0, 0
+	var scanErr error
+	for _, src := range s.sources {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		n, f, err := s.runSource(ctx, src)
+		total += n
+		failed += f
+		if err != nil && scanErr == nil {
+			scanErr = err
+		}
+	}
+
+	applogger.Info("===== 扫描完成 =====")
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if scanErr != nil {
+		return scanErr
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d/%d 个文件处理失败", failed, total)
+	}
+	return nil
+}
+
+// runSource 同步单个来源中已写完的文件，返回文件数和失败数
+func (s *syncer) runSource(ctx context.Context, src *source) (total, failed int, err error) {
+	cutoffTime := getCutoffTime()
+
+	// 扫描日志文件
+	files, err := src.scanner.Scan(cutoffTime)
+	if err != nil {
+		applogger.Error("扫描日志文件失败", "source", src.name, "error", err)
+		return 0, 0, fmt.Errorf("source %s: 扫描日志文件失败: %w", src.name, err)
+	}
+
+	if len(files) == 0 {
+		applogger.Info("没有需要上传的日志文件", "source", src.name)
+	} else {
+		applogger.Info("发现日志文件", "source", src.name, "count", len(files))
+	}
+
+	// 按类型分类
+	var requestFiles []*scanner.LogFile
+	var systemFiles []*scanner.LogFile
+
+	for _, file := range files {
+		if file.Type == scanner.LogTypeRequest {
+			requestFiles = append(requestFiles, file)
+		} else {
+			systemFiles = append(systemFiles, file)
+		}
+	}
+
+	applogger.Info("日志分类完成",
+		"source", src.name,
+		"request_count", len(requestFiles),
+		"system_count", len(systemFiles),
+	)
+
+	// 先处理请求日志，再处理系统日志
+	for _, file := range append(requestFiles, systemFiles...) {
+		if ctx.Err() != nil {
+			break
+		}
+		if err := s.processLogFile(ctx, src, file, false); err != nil {
+			applogger.Error("处理日志文件失败", "source", src.name, "file", file.Name, "request", file.Type == scanner.LogTypeRequest, "error", err)
+			s.recordFailure(ctx, src, file, err)
+			failed++
+		}
+	}
+
+	// 清理过期归档
+	if err := src.archiver.CleanExpired(); err != nil {
+		applogger.Error("清理过期归档失败", "source", src.name, "error", err)
+	}
+
+	return len(files), failed, nil
+}
+
+// processLogFile 处理单个日志文件：从断点处流式读取，每上传成功一批就保存一次断点，
+// 中断后下次从断点继续，内存中只保留一个批次。
+// follow 表示文件仍在写入：只上传已写完的行，不归档
+func (s *syncer) processLogFile(ctx context.Context, src *source, file *scanner.LogFile, follow bool) error {
+	isRequest := file.Type == scanner.LogTypeRequest
+
+	cp, err := src.checkpoints.Load(file.Name)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(file.Path)
+	if err != nil {
+		return fmt.Errorf("读取文件信息失败: %w", err)
+	}
+	if cp.Offset > info.Size() {
+		// 文件被截断或替换，断点已失效
+		applogger.Warn("断点超出文件大小，从头处理", "source", src.name, "file", file.Name, "offset", cp.Offset, "size", info.Size())
+		cp = &checkpoint.Checkpoint{File: file.Name}
+	}
+
+	if follow && cp.Offset == info.Size() {
+		return nil // 没有新内容
+	}
+
+	if follow {
+		applogger.Debug("跟随文件", "source", src.name, "file", file.Name, "offset", cp.Offset, "size", info.Size())
+	} else if cp.Offset > 0 {
+		applogger.Info("从断点继续处理文件", "source", src.name, "file", file.Name, "offset", cp.Offset, "line", cp.Line, "batches", cp.Batches)
+	} else {
+		applogger.Info("处理文件", "source", src.name, "file", file.Name, "size", info.Size())
+	}
+
+	logType := parser.LogTypeSystem
+	if isRequest {
+		logType = parser.LogTypeRequest
+	}
+	reader, err := s.parser.OpenFile(file.Path, logType, cp.Offset, cp.Line)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	reader.SetFollow(follow)
+	reader.SetOrigin(src.origin)
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		entries, invalid, err := reader.ReadBatch(s.batchSize)
+		if err != nil {
+			return err
+		}
+		if len(entries) == 0 && len(invalid) == 0 {
+			break
+		}
+
+		if len(entries) > 0 {
+			batch := uploader.Batch{
+				ID:     batchID(src.name, file.Name, cp.Offset, reader.Offset()),
+				Source: src.name,
+				File:   file.Name,
+				Offset: reader.Offset(),
+			}
+			result := s.uploader.UploadWithRetry(ctx, batchData(entries), isRequest, batch)
+			if !result.Success {
+				applogger.Error("批次上传失败",
+					"source", src.name,
+					"file", file.Name,
+					"batch", cp.Batches+1,
+					"batch_size", len(entries),
+					"offset", cp.Offset,
+					"attempts", result.Attempts,
+					"error", result.Error,
+				)
+				return result.Error // 重试用尽，本轮放弃该文件，下一轮从断点继续
+			}
+			// log-service 拒绝的行与无法解析的行一样写入死信目录，其余行已写入或重复
+			rejected := rejectedLines(entries, result.Rejected)
+			invalid = append(invalid, rejected...)
+			cp.Batches++
+			cp.Entries += len(entries) - len(rejected)
+			cp.Inserted += result.Inserted
+			cp.Duplicate += result.Duplicate
+			applogger.Info("批次上传成功",
+				"source", src.name,
+				"file", file.Name,
+				"batch", cp.Batches,
+				"batch_id", batch.ID,
+				"batch_size", len(entries),
+				"inserted", result.Inserted,
+				"duplicate", result.Duplicate,
+				"rejected", len(rejected),
+				"offset", reader.Offset(),
+			)
+		}
+
+		if len(invalid) > 0 {
+			if err := src.deadLetter.AppendInvalidLines(file.Path, invalid); err != nil {
+				return err
+			}
+			cp.Invalid += len(invalid)
+			applogger.Warn("无效的日志行已写入死信目录", "source", src.name, "file", file.Name, "count", len(invalid))
+		}
+
+		cp.Offset = reader.Offset()
+		cp.Line = reader.Line()
+		if err := src.checkpoints.Save(cp); err != nil {
+			return err
+		}
+	}
+
+	if follow {
+		return nil
+	}
+
+	applogger.Info("文件处理完成",
+		"source", src.name,
+		"file", file.Name,
+		"lines", cp.Line,
+		"entries", cp.Entries,
+		"inserted", cp.Inserted,
+		"duplicate", cp.Duplicate,
+		"invalid_lines", cp.Invalid,
+		"batches", cp.Batches,
+	)
+	return s.finishLogFile(src, file, cp)
+}
+
+// batchID 批次 ID，由来源、文件名和批次的起止位置生成。保存断点前中断时，
+// 下次从同一位置重新上传的批次 ID 相同，log-service 返回首次写入的结果，计数不会重复
+func batchID(source, file string, start, end int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s/%s:%d-%d", source, file, start, end)))
+	return hex.EncodeToString(sum[:16])
+}
+
+// batchData 上传的批次数据
+func batchData(entries []parser.Entry) []interface{} {
+	data := make([]interface{}, len(entries))
+	for i, entry := range entries {
+		data[i] = entry.Data
+	}
+	return data
+}
+
+// rejectedLines 按批次序号找到 log-service 拒绝的行
+func rejectedLines(entries []parser.Entry, rejected []uploader.RejectedRow) []parser.InvalidLine {
+	var lines []parser.InvalidLine
+	for _, row := range rejected {
+		if row.Index < 0 || row.Index >= len(entries) {
+			continue
+		}
+		lines = append(lines, parser.InvalidLine{
+			Line:   entries[row.Index].Line,
+			Reason: "log-service 拒绝: " + row.Reason,
+		})
+	}
+	return lines
+}
+
+// finishLogFile 文件上传完成：清除失败记录，压缩归档并写入清单后删除断点。
+// 归档失败时保留断点，下一轮读到文件末尾后直接重试归档
+func (s *syncer) finishLogFile(src *source, file *scanner.LogFile, cp *checkpoint.Checkpoint) error {
+	if err := src.deadLetter.Clear(file.Path); err != nil {
+		applogger.Error("清除失败记录失败", "source", src.name, "file", file.Name, "error", err)
+	}
+
+	uploadedAt := cp.UpdatedAt
+	if uploadedAt.IsZero() {
+		uploadedAt = time.Now() // 空文件没有上传过批次
+	}
+	logType := "system"
+	if file.Type == scanner.LogTypeRequest {
+		logType = "request"
+	}
+	manifest := archiver.Manifest{
+		Source:           src.name,
+		Type:             logType,
+		ServiceID:        src.origin.ServiceID,
+		Host:             src.origin.Host,
+		UploadedEntries:  cp.Entries,
+		InsertedEntries:  cp.Inserted,
+		DuplicateEntries: cp.Duplicate,
+		InvalidLines:     cp.Invalid,
+		UploadedAt:       uploadedAt,
+	}
+	if err := src.archiver.Archive(file.Path, manifest); err != nil {
+		return err
+	}
+	return src.checkpoints.Delete(file.Name)
+}
+
+// recordFailure 记录文件处理失败，连续失败次数达到上限时文件被移入死信目录，
+// 断点保留，移回后从断点继续。因退出信号中断的处理不计入失败次数
+func (s *syncer) recordFailure(ctx context.Context, src *source, file *scanner.LogFile, cause error) {
+	if ctx.Err() != nil {
+		return
+	}
+	moved, err := src.deadLetter.RecordFailure(file.Path, cause)
+	if err != nil {
+		applogger.Error("记录文件失败次数失败", "source", src.name, "file", file.Name, "error", err)
+	}
+	if moved {
+		applogger.Error("文件多次处理失败，已移入死信目录", "source", src.name, "file", file.Name, "reason", cause)
+	}
+}
+
+// getCutoffTime 获取截止时间（当前时间）
+func getCutoffTime() time.Time {
+	return time.Now()
+}
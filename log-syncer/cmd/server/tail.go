@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	applogger "zxm_ai_admin/log-syncer/internal/logger"
+)
+
+// tail 跟随模式：每隔 interval 检查一次日志目录，上传正在写入的半小时文件中新写完的行。
+// proxy 切换到下一个半小时文件后，旧文件在 grace 之后按已写完处理：上传剩余内容并归档
+func (s *syncer) tail(ctx context.Context, interval, grace time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.tailOnce(ctx, grace)
+		}
+	}
+}
+
+// tailOnce 执行一次跟随检查。定时同步正在进行时跳过本次
+func (s *syncer) tailOnce(ctx context.Context, grace time.Duration) {
+	if !s.mu.TryLock() {
+		return
+	}
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, src := range s.sources {
+		files, err := src.scanner.ScanAll()
+		if err != nil {
+			applogger.Error("扫描日志文件失败", "source", src.name, "error", err)
+			continue
+		}
+
+		for _, file := range files {
+			if ctx.Err() != nil {
+				return
+			}
+
+			// 文件名中的时间是该半小时的结束时间，结束后再等待 grace，避免漏掉切换前的最后几行
+			follow := now.Before(file.FileTime.Add(grace))
+			if err := s.processLogFile(ctx, src, file, follow); err != nil {
+				// 跟随模式下不累计失败次数，下次检查时从断点继续；
+				// 反复失败的文件由定时同步计数并移入死信目录
+				applogger.Error("跟随上传失败", "source", src.name, "file", file.Name, "follow", follow, "error", err)
+			}
+		}
+	}
+}
@@ -5,3 +5,5 @@ go 1.21
 require gopkg.in/yaml.v3 v3.0.1
 
 require github.com/google/uuid v1.6.0 // indirect
+
+require github.com/klauspost/compress v1.17.8
@@ -2,78 +2,281 @@
 package archiver
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"regexp"
+	"time"
+
 	"zxm_ai_admin/log-syncer/internal/scanner"
 )
 
+// dateDirPattern 按日期分目录时的子目录名
+var dateDirPattern = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+// Options 归档选项
+type Options struct {
+	Compression string // none / gzip / zstd
+	DateDirs    bool   // 按日期（YYYY-MM-DD）分子目录
+}
+
+// Manifest 归档清单，与归档文件放在同一目录，用于校验和重放
+type Manifest struct {
+	File             string    `json:"file"`                 // 原始文件名
+	ArchiveFile      string    `json:"archive_file"`         // 归档文件名
+	Source           string    `json:"source"`               // 日志来源名称
+	Type             string    `json:"type"`                 // request 或 system
+	ServiceID        string    `json:"service_id,omitempty"` // 来源服务标识
+	Host             string    `json:"host,omitempty"`       // 来源主机
+	Compression      string    `json:"compression"`
+	Lines            int       `json:"lines"`             // 原始文件行数
+	Size             int64     `json:"size"`              // 原始文件大小
+	ArchiveSize      int64     `json:"archive_size"`      // 归档文件大小
+	SHA256           string    `json:"sha256"`            // 原始内容的 sha256，重放时校验
+	UploadedEntries  int       `json:"uploaded_entries"`  // 已上传的条目数
+	InsertedEntries  int       `json:"inserted_entries"`  // 其中 log-service 新写入的条目数
+	DuplicateEntries int       `json:"duplicate_entries"` // 其中 log-service 中已存在的条目数
+	InvalidLines     int       `json:"invalid_lines"`     // 写入死信目录的无效行数
+	UploadedAt       time.Time `json:"uploaded_at"`       // 最后一批上传成功的时间
+	ArchivedAt       time.Time `json:"archived_at"`
+}
+
 // Archiver 归档器
 type Archiver struct {
-	archiveDir     string
-	retentionDays  int
+	archiveDir    string
+	retentionDays int
+	options       Options
+	scanner       *scanner.Scanner // 按日志来源的文件名格式解析归档文件的时间
 }
 
-// NewArchiver 创建归档器
-func NewArchiver(archiveDir string, retentionDays int) *Archiver {
+// NewArchiver 创建归档器，sc 为对应日志来源的扫描器，用于解析文件名中的时间
+func NewArchiver(archiveDir string, retentionDays int, sc *scanner.Scanner, options Options) *Archiver {
+	if options.Compression == "" {
+		options.Compression = CompressionGzip
+	}
 	return &Archiver{
 		archiveDir:    archiveDir,
 		retentionDays: retentionDays,
+		options:       options,
+		scanner:       sc,
 	}
 }
 
-// Archive 归档文件
-func (a *Archiver) Archive(srcPath string) error {
-	// 确保归档目录存在
-	if err := os.MkdirAll(a.archiveDir, 0755); err != nil {
+// Archive 压缩归档文件并写入清单，完成后删除原文件。
+// manifest 由调用方填写来源和上传信息，行数、大小、sha256 等在归档时计算。
+// 中途失败时原文件保留，下次重新归档会覆盖不完整的结果
+func (a *Archiver) Archive(srcPath string, manifest Manifest) error {
+	filename := filepath.Base(srcPath)
+	dir := a.dirFor(filename)
+	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	// 获取文件名
-	filename := filepath.Base(srcPath)
-	dstPath := filepath.Join(a.archiveDir, filename)
+	manifest.File = filename
+	manifest.ArchiveFile = filename + compressionExt[a.options.Compression]
+	manifest.Compression = a.options.Compression
+	dstPath := filepath.Join(dir, manifest.ArchiveFile)
+
+	if err := a.compress(srcPath, dstPath, &manifest); err != nil {
+		os.Remove(dstPath + tmpSuffix)
+		return fmt.Errorf("归档文件失败: %w", err)
+	}
+	manifest.ArchivedAt = time.Now()
+	if err := writeManifest(filepath.Join(dir, filename+ManifestSuffix), manifest); err != nil {
+		return err
+	}
+	if err := os.Remove(srcPath); err != nil {
+		return fmt.Errorf("删除已归档的文件失败: %w", err)
+	}
+
+	log.Printf("归档文件: %s → %s (%d 行, %d → %d 字节)", srcPath, dstPath, manifest.Lines, manifest.Size, manifest.ArchiveSize)
+	return nil
+}
+
+// compress 将 srcPath 压缩写入 dstPath，同时统计原始内容的行数、大小和 sha256
+func (a *Archiver) compress(srcPath, dstPath string, manifest *Manifest) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
 
-	// 移动文件
-	if err := os.Rename(srcPath, dstPath); err != nil {
+	tmpPath := dstPath + tmpSuffix
+	dst, err := os.Create(tmpPath)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	zw, err := newWriter(dst, a.options.Compression)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	counter := &lineCounter{}
+	size, err := io.Copy(io.MultiWriter(zw, hash, counter), src)
+	if err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := dst.Sync(); err != nil {
+		return err
+	}
+	info, err := dst.Stat()
+	if err != nil {
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dstPath); err != nil {
 		return err
 	}
 
-	log.Printf("归档文件: %s → %s", srcPath, dstPath)
+	manifest.Lines = counter.Lines()
+	manifest.Size = size
+	manifest.ArchiveSize = info.Size()
+	manifest.SHA256 = hex.EncodeToString(hash.Sum(nil))
 	return nil
 }
 
-// CleanExpired 清理过期的归档文件
+// dirFor 归档文件所在目录。按日期分目录时使用文件覆盖时段所在的日期：
+// 文件名中的时间是时段的结束时间，00:00 结束的文件归入前一天
+func (a *Archiver) dirFor(filename string) string {
+	if !a.options.DateDirs {
+		return a.archiveDir
+	}
+	fileTime, ok := a.scanner.FileTime(filename)
+	if !ok {
+		return a.archiveDir
+	}
+	return filepath.Join(a.archiveDir, fileTime.Add(-time.Minute).Format("2006-01-02"))
+}
+
+// CleanExpired 清理过期的归档文件及其清单，包括日期子目录中的文件，清理后删除空的日期子目录
 func (a *Archiver) CleanExpired() error {
 	if err := os.MkdirAll(a.archiveDir, 0755); err != nil {
 		return err
 	}
 
+	cutoffTime := scanner.GetArchiveRetentionTime(a.retentionDays)
+	cleanedCount, err := a.cleanDir(a.archiveDir, cutoffTime)
+	if err != nil {
+		return err
+	}
+
 	entries, err := os.ReadDir(a.archiveDir)
 	if err != nil {
 		return err
 	}
+	for _, entry := range entries {
+		if !entry.IsDir() || !dateDirPattern.MatchString(entry.Name()) {
+			continue
+		}
+		dir := filepath.Join(a.archiveDir, entry.Name())
+		n, err := a.cleanDir(dir, cutoffTime)
+		if err != nil {
+			return err
+		}
+		cleanedCount += n
+		if rest, err := os.ReadDir(dir); err == nil && len(rest) == 0 {
+			os.Remove(dir)
+		}
+	}
 
-	cleanedCount := 0
-	cutoffTime := scanner.GetArchiveRetentionTime(a.retentionDays)
+	if cleanedCount > 0 {
+		log.Printf("清理过期归档完成: 删除 %d 个文件", cleanedCount)
+	}
+
+	return nil
+}
+
+// cleanDir 删除目录中文件时间早于 cutoffTime 的归档文件和清单，返回删除的文件数
+func (a *Archiver) cleanDir(dir string, cutoffTime time.Time) (int, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0, err
+	}
 
+	cleanedCount := 0
 	for _, entry := range entries {
 		if entry.IsDir() {
 			continue
 		}
 
 		filename := entry.Name()
-		if scanner.ShouldCleanArchive(filename, a.retentionDays) {
-			path := filepath.Join(a.archiveDir, filename)
+		if fileTime, ok := a.scanner.FileTime(OriginalName(filename)); ok && fileTime.Before(cutoffTime) {
+			path := filepath.Join(dir, filename)
 			if err := os.Remove(path); err == nil {
 				log.Printf("删除过期归档: %s (时间: %s)", filename, cutoffTime.Format("2006-01-02 15:04:05"))
 				cleanedCount++
 			}
 		}
 	}
+	return cleanedCount, nil
+}
 
-	if cleanedCount > 0 {
-		log.Printf("清理过期归档完成: 删除 %d 个文件", cleanedCount)
+// ReadManifest 读取归档文件对应的清单，清单不存在时返回 nil
+func ReadManifest(archivePath string) (*Manifest, error) {
+	path := filepath.Join(filepath.Dir(archivePath), OriginalName(filepath.Base(archivePath))+ManifestSuffix)
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取清单失败: %w", err)
 	}
 
+	var manifest Manifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, fmt.Errorf("解析清单失败: %w", err)
+	}
+	return &manifest, nil
+}
+
+// writeManifest 先写临时文件再重命名，避免留下不完整的清单
+func writeManifest(path string, manifest Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化清单失败: %w", err)
+	}
+	if err := os.WriteFile(path+tmpSuffix, data, 0644); err != nil {
+		return fmt.Errorf("写入清单失败: %w", err)
+	}
+	if err := os.Rename(path+tmpSuffix, path); err != nil {
+		return fmt.Errorf("写入清单失败: %w", err)
+	}
 	return nil
 }
+
+// lineCounter 统计行数，末尾没有换行符的最后一行也计为一行，与日志读取的行号一致
+type lineCounter struct {
+	newlines int
+	last     byte
+	written  bool
+}
+
+func (c *lineCounter) Write(p []byte) (int, error) {
+	if len(p) > 0 {
+		c.newlines += bytes.Count(p, []byte("\n"))
+		c.last = p[len(p)-1]
+		c.written = true
+	}
+	return len(p), nil
+}
+
+// Lines 已写入内容的行数
+func (c *lineCounter) Lines() int {
+	if c.written && c.last != '\n' {
+		return c.newlines + 1
+	}
+	return c.newlines
+}
@@ -0,0 +1,113 @@
+package archiver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// 归档压缩格式
+const (
+	CompressionNone = "none"
+	CompressionGzip = "gzip"
+	CompressionZstd = "zstd"
+)
+
+// ManifestSuffix 清单文件的后缀，清单文件名为 <原始文件名>.manifest.json
+const ManifestSuffix = ".manifest.json"
+
+// tmpSuffix 正在写入的归档文件的后缀，写完后重命名
+const tmpSuffix = ".tmp"
+
+// compressionExt 各压缩格式的归档文件扩展名
+var compressionExt = map[string]string{
+	CompressionNone: "",
+	CompressionGzip: ".gz",
+	CompressionZstd: ".zst",
+}
+
+// ValidCompression 检查压缩格式是否支持
+func ValidCompression(compression string) bool {
+	_, ok := compressionExt[compression]
+	return ok
+}
+
+// OriginalName 由归档文件名或清单文件名得到原始日志文件名
+func OriginalName(name string) string {
+	name = strings.TrimSuffix(name, tmpSuffix)
+	name = strings.TrimSuffix(name, ManifestSuffix)
+	for _, ext := range compressionExt {
+		if ext != "" && strings.HasSuffix(name, ext) {
+			return strings.TrimSuffix(name, ext)
+		}
+	}
+	return name
+}
+
+// newWriter 按压缩格式包装输出
+func newWriter(w io.Writer, compression string) (io.WriteCloser, error) {
+	switch compression {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w)
+	case CompressionNone:
+		return nopWriteCloser{w}, nil
+	default:
+		return nil, fmt.Errorf("不支持的压缩格式: %s", compression)
+	}
+}
+
+// nopWriteCloser 不压缩时的输出
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// OpenArchive 打开归档文件，按扩展名解压，返回原始日志内容
+func OpenArchive(path string) (io.ReadCloser, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("打开归档文件失败: %w", err)
+	}
+
+	switch {
+	case strings.HasSuffix(path, compressionExt[CompressionGzip]):
+		gz, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("解压归档文件失败: %w", err)
+		}
+		return &archiveReader{Reader: gz, close: func() error {
+			gz.Close()
+			return file.Close()
+		}}, nil
+	case strings.HasSuffix(path, compressionExt[CompressionZstd]):
+		zr, err := zstd.NewReader(file)
+		if err != nil {
+			file.Close()
+			return nil, fmt.Errorf("解压归档文件失败: %w", err)
+		}
+		return &archiveReader{Reader: zr, close: func() error {
+			zr.Close()
+			return file.Close()
+		}}, nil
+	default:
+		return file, nil
+	}
+}
+
+// archiveReader 解压后的归档内容，关闭时同时关闭解压器和文件
+type archiveReader struct {
+	io.Reader
+	close func() error
+}
+
+func (r *archiveReader) Close() error {
+	return r.close()
+}
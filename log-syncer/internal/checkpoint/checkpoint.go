@@ -0,0 +1,86 @@
+// Package checkpoint 文件上传进度（断点）的持久化
+package checkpoint
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Checkpoint 单个日志文件的上传进度
+type Checkpoint struct {
+	File      string    `json:"file"`
+	Offset    int64     `json:"offset"`    // 已上传数据之后的字节位置，续传从这里开始
+	Line      int       `json:"line"`      // Offset 之前的行数，用于续传后的行号
+	Batches   int       `json:"batches"`   // 已上传成功的批次数
+	Entries   int       `json:"entries"`   // 已上传成功的条目数（新写入和已存在的）
+	Inserted  int       `json:"inserted"`  // 其中 log-service 新写入的条目数
+	Duplicate int       `json:"duplicate"` // 其中 log-service 中已存在的条目数
+	Invalid   int       `json:"invalid"`   // 已写入死信目录的无效行数
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Store 断点存储，每个日志文件一个 JSON 文件
+type Store struct {
+	dir string
+}
+
+// NewStore 创建断点存储
+func NewStore(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建断点目录失败: %w", err)
+	}
+	return &Store{dir: dir}, nil
+}
+
+// Load 读取文件的断点，不存在时返回从头开始的断点
+func (s *Store) Load(name string) (*Checkpoint, error) {
+	data, err := os.ReadFile(s.path(name))
+	if os.IsNotExist(err) {
+		return &Checkpoint{File: name}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("读取断点失败: %w", err)
+	}
+
+	var cp Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("解析断点失败: %w", err)
+	}
+	cp.File = name
+	return &cp, nil
+}
+
+// Save 保存断点，先写临时文件再重命名，进程中断时不会留下不完整的断点
+func (s *Store) Save(cp *Checkpoint) error {
+	cp.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(cp, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化断点失败: %w", err)
+	}
+
+	path := s.path(cp.File)
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入断点失败: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("写入断点失败: %w", err)
+	}
+	return nil
+}
+
+// Delete 删除断点，文件处理完成后调用
+func (s *Store) Delete(name string) error {
+	if err := os.Remove(s.path(name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("删除断点失败: %w", err)
+	}
+	return nil
+}
+
+// path 断点文件路径
+func (s *Store) path(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
@@ -4,8 +4,13 @@ package config
 import (
 	"fmt"
 	"os"
+	"regexp"
 	"sync"
 	"time"
+	_ "time/tzdata" // 内置时区数据，容器镜像中没有 zoneinfo 时也能加载 IANA 时区
+
+	"zxm_ai_admin/log-syncer/internal/archiver"
+	"zxm_ai_admin/log-syncer/internal/scanner"
 
 	"gopkg.in/yaml.v3"
 )
@@ -17,11 +22,17 @@ var (
 
 // Config 配置结构
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Proxy    ProxyConfig    `yaml:"proxy"`
-	Archive  ArchiveConfig  `yaml:"archive"`
-	Uploader UploaderConfig `yaml:"uploader"`
-	Log      LogConfig      `yaml:"log"`
+	Server     ServerConfig     `yaml:"server"`
+	Proxy      ProxyConfig      `yaml:"proxy"`
+	Sources    []SourceConfig   `yaml:"sources"`
+	Archive    ArchiveConfig    `yaml:"archive"`
+	Uploader   UploaderConfig   `yaml:"uploader"`
+	DeadLetter DeadLetterConfig `yaml:"dead_letter"`
+	Checkpoint CheckpointConfig `yaml:"checkpoint"`
+	Tail       TailConfig       `yaml:"tail"`
+	Schedule   ScheduleConfig   `yaml:"schedule"`
+	Trigger    TriggerConfig    `yaml:"trigger"`
+	Log        LogConfig        `yaml:"log"`
 }
 
 // ServerConfig 服务器配置
@@ -33,18 +44,94 @@ type ServerConfig struct {
 
 // ProxyConfig Proxy 日志配置
 type ProxyConfig struct {
-	LogDir string `yaml:"log_dir"`
+	LogDir   string `yaml:"log_dir"`
+	Timezone string `yaml:"timezone"` // 日志文件名中时间使用的时区（proxy 所在机器的时区），默认 Local
+
+	location *time.Location
+}
+
+// Location 日志文件名中时间使用的时区
+func (c ProxyConfig) Location() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// SourceConfig 日志来源：一个目录及其中符合文件名格式的日志文件
+type SourceConfig struct {
+	Name      string `yaml:"name"`       // 来源名称，唯一，断点、归档和死信按名称分子目录
+	Dir       string `yaml:"dir"`        // 日志目录
+	Pattern   string `yaml:"pattern"`    // 文件名正则，需包含 time 分组，默认 proxy 的 request-/system- 格式
+	Type      string `yaml:"type"`       // request 或 system；为空时由 pattern 的 type 分组判断
+	ServiceID string `yaml:"service_id"` // 来源服务标识（如 proxy-1），写入每条日志
+	Host      string `yaml:"host"`       // 来源主机，写入每条日志，默认本机 hostname
+	Timezone  string `yaml:"timezone"`   // 文件名中时间使用的时区，默认 proxy.timezone
+
+	location *time.Location
+	legacy   bool // 由 proxy.log_dir 生成的来源，沿用不分子目录的布局
+}
+
+// Location 文件名中时间使用的时区
+func (c SourceConfig) Location() *time.Location {
+	if c.location == nil {
+		return time.Local
+	}
+	return c.location
+}
+
+// Subdir 来源在断点、归档和死信目录下的子目录，由 proxy.log_dir 生成的来源为空
+func (c SourceConfig) Subdir() string {
+	if c.legacy {
+		return ""
+	}
+	return c.Name
 }
 
 // ArchiveConfig 归档配置
 type ArchiveConfig struct {
-	Dir            string `yaml:"dir"`
-	RetentionDays  int    `yaml:"retention_days"`
+	Dir           string `yaml:"dir"`
+	RetentionDays int    `yaml:"retention_days"`
+	Compression   string `yaml:"compression"` // none / gzip / zstd，默认 gzip
+	DateDirs      bool   `yaml:"date_dirs"`   // 按日期（YYYY-MM-DD）分子目录
 }
 
 // UploaderConfig 上传配置
 type UploaderConfig struct {
-	BatchSize int `yaml:"batch_size"`
+	BatchSize      int           `yaml:"batch_size"`
+	MaxRetries     int           `yaml:"max_retries"`     // 单批次失败后的最大重试次数，默认 5，负数表示不重试
+	InitialBackoff time.Duration `yaml:"initial_backoff"` // 首次重试前的等待时间，之后按 2 倍递增，默认 1s
+	MaxBackoff     time.Duration `yaml:"max_backoff"`     // 单次等待时间上限，默认 1m
+	Compression    string        `yaml:"compression"`     // 请求体压缩：none / gzip，默认 gzip
+}
+
+// DeadLetterConfig 死信配置
+type DeadLetterConfig struct {
+	Dir             string `yaml:"dir"`               // 死信目录，默认 ./dead-letter
+	MaxFileFailures int    `yaml:"max_file_failures"` // 文件连续处理失败达到该次数后移入死信目录，默认 5
+}
+
+// CheckpointConfig 断点配置
+type CheckpointConfig struct {
+	Dir string `yaml:"dir"` // 断点目录，默认 ./checkpoints
+}
+
+// TailConfig 跟随模式配置
+type TailConfig struct {
+	Enabled  bool          `yaml:"enabled"`  // 是否持续上传正在写入的半小时文件
+	Interval time.Duration `yaml:"interval"` // 检查新内容的间隔，默认 5s
+	Grace    time.Duration `yaml:"grace"`    // 半小时结束后等待多久视为文件已写完，默认 10s
+}
+
+// ScheduleConfig 定时同步配置，cron 与 interval 二选一
+type ScheduleConfig struct {
+	Cron     string        `yaml:"cron"`     // 5 段 cron 表达式（分 时 日 月 周），按 proxy.timezone 解释，默认 "1,31 * * * *"
+	Interval time.Duration `yaml:"interval"` // 固定间隔，如 10m
+}
+
+// TriggerConfig 手动触发配置
+type TriggerConfig struct {
+	Listen string `yaml:"listen"` // 本地 HTTP 触发接口监听地址，如 127.0.0.1:6810，为空不开启
 }
 
 // LogConfig 日志配置
@@ -78,7 +165,131 @@ func loadFile(path string, cfg *Config) error {
 	if cfg.Uploader.BatchSize == 0 {
 		cfg.Uploader.BatchSize = 100
 	}
+	if cfg.Uploader.MaxRetries == 0 {
+		cfg.Uploader.MaxRetries = 5
+	} else if cfg.Uploader.MaxRetries < 0 {
+		cfg.Uploader.MaxRetries = 0
+	}
+	if cfg.Uploader.InitialBackoff <= 0 {
+		cfg.Uploader.InitialBackoff = time.Second
+	}
+	if cfg.Uploader.MaxBackoff <= 0 {
+		cfg.Uploader.MaxBackoff = time.Minute
+	}
+	if cfg.Uploader.MaxBackoff < cfg.Uploader.InitialBackoff {
+		cfg.Uploader.MaxBackoff = cfg.Uploader.InitialBackoff
+	}
+	if cfg.Uploader.Compression == "" {
+		cfg.Uploader.Compression = "gzip"
+	}
+	if cfg.Uploader.Compression != "gzip" && cfg.Uploader.Compression != "none" {
+		return fmt.Errorf("uploader.compression 只能是 none 或 gzip")
+	}
+	if cfg.Archive.Compression == "" {
+		cfg.Archive.Compression = archiver.CompressionGzip
+	}
+	if !archiver.ValidCompression(cfg.Archive.Compression) {
+		return fmt.Errorf("archive.compression 只能是 none、gzip 或 zstd")
+	}
+	if cfg.DeadLetter.Dir == "" {
+		cfg.DeadLetter.Dir = "./dead-letter"
+	}
+	if cfg.DeadLetter.MaxFileFailures <= 0 {
+		cfg.DeadLetter.MaxFileFailures = 5
+	}
+	if cfg.Checkpoint.Dir == "" {
+		cfg.Checkpoint.Dir = "./checkpoints"
+	}
+	if cfg.Tail.Interval <= 0 {
+		cfg.Tail.Interval = 5 * time.Second
+	}
+	if cfg.Tail.Grace <= 0 {
+		cfg.Tail.Grace = 10 * time.Second
+	}
+	if cfg.Schedule.Cron != "" && cfg.Schedule.Interval != 0 {
+		return fmt.Errorf("schedule.cron 和 schedule.interval 只能配置一个")
+	}
+	if cfg.Schedule.Interval < 0 {
+		return fmt.Errorf("schedule.interval 不能为负数")
+	}
+	if cfg.Schedule.Cron == "" && cfg.Schedule.Interval == 0 {
+		cfg.Schedule.Cron = "1,31 * * * *"
+	}
+	if cfg.Proxy.Timezone == "" {
+		cfg.Proxy.Timezone = "Local"
+	}
+	loc, err := time.LoadLocation(cfg.Proxy.Timezone)
+	if err != nil {
+		return fmt.Errorf("proxy.timezone 配置错误: %w", err)
+	}
+	cfg.Proxy.location = loc
+
+	return loadSources(cfg)
+}
+
+// sourceNamePattern 来源名称同时用作子目录名，只允许安全字符
+var sourceNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// loadSources 校验日志来源并设置默认值。未配置 sources 时，由 proxy.log_dir 生成名为 proxy 的来源
+func loadSources(cfg *Config) error {
+	if len(cfg.Sources) == 0 {
+		if cfg.Proxy.LogDir == "" {
+			return fmt.Errorf("未配置日志来源：请配置 sources 或 proxy.log_dir")
+		}
+		cfg.Sources = []SourceConfig{{
+			Name:     "proxy",
+			Dir:      cfg.Proxy.LogDir,
+			Timezone: cfg.Proxy.Timezone,
+			legacy:   true,
+		}}
+	}
+
+	hostname, _ := os.Hostname()
+	names := make(map[string]bool, len(cfg.Sources))
+	for i := range cfg.Sources {
+		src := &cfg.Sources[i]
+		if !sourceNamePattern.MatchString(src.Name) {
+			return fmt.Errorf("sources[%d].name 无效: %q（只允许字母、数字、.、_、-）", i, src.Name)
+		}
+		if names[src.Name] {
+			return fmt.Errorf("sources[%d].name 重复: %s", i, src.Name)
+		}
+		names[src.Name] = true
 
+		if src.Dir == "" {
+			return fmt.Errorf("source %s: dir 不能为空", src.Name)
+		}
+		if src.Pattern == "" {
+			src.Pattern = scanner.DefaultPattern
+		}
+		re, err := regexp.Compile(src.Pattern)
+		if err != nil {
+			return fmt.Errorf("source %s: pattern 错误: %w", src.Name, err)
+		}
+		if re.SubexpIndex("time") < 0 {
+			return fmt.Errorf("source %s: pattern 需要包含 (?P<time>\\d{12}) 分组", src.Name)
+		}
+		switch src.Type {
+		case "":
+			if re.SubexpIndex("type") < 0 {
+				return fmt.Errorf("source %s: pattern 没有 type 分组时必须配置 type", src.Name)
+			}
+		case "request", "system":
+		default:
+			return fmt.Errorf("source %s: type 只能是 request 或 system", src.Name)
+		}
+		if src.Host == "" {
+			src.Host = hostname
+		}
+		if src.Timezone == "" {
+			src.Timezone = cfg.Proxy.Timezone
+		}
+		loc, err := time.LoadLocation(src.Timezone)
+		if err != nil {
+			return fmt.Errorf("source %s: timezone 配置错误: %w", src.Name, err)
+		}
+		src.location = loc
+	}
 	return nil
 }
 
@@ -0,0 +1,214 @@
+// Package deadletter 死信目录：存放反复处理失败的日志文件和无法解析的日志行
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"zxm_ai_admin/log-syncer/internal/parser"
+)
+
+// stateFile 记录文件连续失败次数的状态文件，保存在死信目录下，重启后继续累计
+const stateFile = ".failures.json"
+
+// reasonSuffix 原因说明文件（sidecar）的后缀
+const reasonSuffix = ".reason.json"
+
+// lineReasonSuffix 无效行原因文件的后缀，每行一条 JSON
+const lineReasonSuffix = ".reason.jsonl"
+
+// invalidSuffix 无法解析的日志行文件的后缀
+const invalidSuffix = ".invalid"
+
+// failureRecord 单个文件的失败记录
+type failureRecord struct {
+	Count         int       `json:"count"`
+	LastError     string    `json:"last_error"`
+	FirstFailedAt time.Time `json:"first_failed_at"`
+	LastFailedAt  time.Time `json:"last_failed_at"`
+}
+
+// FileReason 整个文件移入死信目录时的原因说明
+type FileReason struct {
+	File           string    `json:"file"`
+	SourcePath     string    `json:"source_path"`
+	Reason         string    `json:"reason"`
+	Failures       int       `json:"failures"`
+	FirstFailedAt  time.Time `json:"first_failed_at"`
+	LastFailedAt   time.Time `json:"last_failed_at"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+}
+
+// InvalidLineReason 单行解析失败的原因，按行追加到 <文件名>.invalid.reason.jsonl
+type InvalidLineReason struct {
+	Line           int       `json:"line"`
+	Offset         int64     `json:"offset"`
+	Reason         string    `json:"reason"`
+	DeadLetteredAt time.Time `json:"dead_lettered_at"`
+}
+
+// DeadLetter 死信管理器
+type DeadLetter struct {
+	dir             string
+	maxFileFailures int
+
+	mu       sync.Mutex
+	failures map[string]*failureRecord // 文件名 → 失败记录
+}
+
+// NewDeadLetter 创建死信管理器，并加载已有的失败记录
+func NewDeadLetter(dir string, maxFileFailures int) (*DeadLetter, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("创建死信目录失败: %w", err)
+	}
+
+	d := &DeadLetter{
+		dir:             dir,
+		maxFileFailures: maxFileFailures,
+		failures:        make(map[string]*failureRecord),
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, stateFile))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("读取失败记录失败: %w", err)
+	}
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &d.failures); err != nil {
+			return nil, fmt.Errorf("解析失败记录失败: %w", err)
+		}
+	}
+	return d, nil
+}
+
+// RecordFailure 记录文件处理失败。连续失败次数达到上限时将文件移入死信目录，
+// 返回 moved=true；此后该文件不会再被扫描到
+func (d *DeadLetter) RecordFailure(srcPath string, cause error) (moved bool, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := filepath.Base(srcPath)
+	now := time.Now()
+	record, ok := d.failures[name]
+	if !ok {
+		record = &failureRecord{FirstFailedAt: now}
+		d.failures[name] = record
+	}
+	record.Count++
+	record.LastError = cause.Error()
+	record.LastFailedAt = now
+
+	if record.Count < d.maxFileFailures {
+		return false, d.saveLocked()
+	}
+
+	reason := FileReason{
+		File:           name,
+		SourcePath:     srcPath,
+		Reason:         record.LastError,
+		Failures:       record.Count,
+		FirstFailedAt:  record.FirstFailedAt,
+		LastFailedAt:   record.LastFailedAt,
+		DeadLetteredAt: now,
+	}
+	dstPath := d.availablePath(name)
+	if err := os.Rename(srcPath, dstPath); err != nil {
+		// 移动失败时保留失败记录，下次失败会再次尝试移动
+		_ = d.saveLocked()
+		return false, fmt.Errorf("移入死信目录失败: %w", err)
+	}
+	if err := writeJSON(dstPath+reasonSuffix, reason); err != nil {
+		return true, err
+	}
+
+	delete(d.failures, name)
+	return true, d.saveLocked()
+}
+
+// Clear 清除文件的失败记录，文件处理成功后调用
+func (d *DeadLetter) Clear(srcPath string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	name := filepath.Base(srcPath)
+	if _, ok := d.failures[name]; !ok {
+		return nil
+	}
+	delete(d.failures, name)
+	return d.saveLocked()
+}
+
+// AppendInvalidLines 将无法解析的日志行追加到死信目录：
+// <文件名>.invalid 保存原始行，<文件名>.invalid.reason.jsonl 逐行记录行号、字节位置和原因
+func (d *DeadLetter) AppendInvalidLines(srcPath string, lines []parser.InvalidLine) error {
+	if len(lines) == 0 {
+		return nil
+	}
+
+	var content, reasons bytes.Buffer
+	now := time.Now()
+	encoder := json.NewEncoder(&reasons)
+	for _, line := range lines {
+		content.WriteString(line.Text)
+		content.WriteByte('\n')
+		if err := encoder.Encode(InvalidLineReason{Line: line.Number, Offset: line.Offset, Reason: line.Reason, DeadLetteredAt: now}); err != nil {
+			return fmt.Errorf("序列化无效行原因失败: %w", err)
+		}
+	}
+
+	dstPath := filepath.Join(d.dir, filepath.Base(srcPath)+invalidSuffix)
+	if err := appendFile(dstPath, content.Bytes()); err != nil {
+		return fmt.Errorf("写入无效日志行失败: %w", err)
+	}
+	if err := appendFile(dstPath+lineReasonSuffix, reasons.Bytes()); err != nil {
+		return fmt.Errorf("写入无效行原因失败: %w", err)
+	}
+	return nil
+}
+
+// availablePath 返回死信目录中不与已有文件冲突的路径
+func (d *DeadLetter) availablePath(name string) string {
+	dstPath := filepath.Join(d.dir, name)
+	if _, err := os.Stat(dstPath); os.IsNotExist(err) {
+		return dstPath
+	}
+	return filepath.Join(d.dir, fmt.Sprintf("%s.%d", name, time.Now().UnixNano()))
+}
+
+// saveLocked 持久化失败记录，调用方需持有锁
+func (d *DeadLetter) saveLocked() error {
+	return writeJSON(filepath.Join(d.dir, stateFile), d.failures)
+}
+
+// writeJSON 先写临时文件再重命名，避免进程中断留下不完整的文件
+func writeJSON(path string, v interface{}) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return fmt.Errorf("序列化失败: %w", err)
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return fmt.Errorf("写入 %s 失败: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// appendFile 追加写入文件，不存在时创建
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -6,7 +6,9 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"time"
 )
 
@@ -28,10 +30,42 @@ type RequestLogEntry map[string]interface{}
 
 // SystemLogEntry 系统日志条目
 type SystemLogEntry struct {
-	RequestID string `json:"request_id"`
-	Time      string `json:"time"`
-	Level     string `json:"level"`
-	Msg       string `json:"msg"`
+	RequestID    string                 `json:"request_id,omitempty"` // 关联的请求 ID，一个请求可以有多条系统日志
+	Time         string                 `json:"time"`
+	Level        string                 `json:"level"`
+	Msg          string                 `json:"msg"`
+	Attrs        map[string]interface{} `json:"attrs,omitempty"` // 其余 slog 属性（error、target_url 等），原样上传
+	ServiceID    string                 `json:"service_id,omitempty"`
+	Host         string                 `json:"host,omitempty"`
+	Source       string                 `json:"source"`        // 来源名称
+	SourceFile   string                 `json:"source_file"`   // 文件名
+	SourceOffset int64                  `json:"source_offset"` // 行首在文件中的字节位置，log-service 按 来源/文件/行首位置 去重
+}
+
+// Origin 日志来源标识，写入每条上传的日志
+type Origin struct {
+	Source    string // 来源名称，系统日志按 来源/文件/行首位置 去重
+	ServiceID string
+	Host      string
+}
+
+// Line 日志文件中的一行
+type Line struct {
+	Number int    // 行号，从 1 开始
+	Offset int64  // 行首在文件中的字节位置
+	Text   string // 原始内容，过长的行只保留前 maxLineSize 字节
+}
+
+// Entry 解析成功的日志条目及其所在行，log-service 拒绝该条目时按行写入死信
+type Entry struct {
+	Line
+	Data LogEntry
+}
+
+// InvalidLine 无法解析或被 log-service 拒绝的日志行
+type InvalidLine struct {
+	Line
+	Reason string // 失败原因
 }
 
 // Parser 解析器
@@ -42,48 +76,35 @@ func NewParser() *Parser {
 	return &Parser{}
 }
 
-// ParseFile 解析日志文件
-func (p *Parser) ParseFile(filePath string, logType LogType) ([]LogEntry, error) {
+// OpenFile 打开日志文件用于流式读取，从 offset 字节处开始，line 为 offset 之前已读取的行数
+func (p *Parser) OpenFile(filePath string, logType LogType, offset int64, line int) (*FileReader, error) {
 	file, err := os.Open(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("打开文件失败: %w", err)
 	}
-	defer file.Close()
-
-	var entries []LogEntry
-	logScanner := bufio.NewScanner(file)
-
-	// 增加缓冲区大小以处理长日志行
-	const maxScanTokenSize = 1024 * 1024 // 1MB
-	buf := make([]byte, 0, maxScanTokenSize)
-	logScanner.Buffer(buf, maxScanTokenSize)
-
-	for logScanner.Scan() {
-		line := logScanner.Text()
-		if line == "" {
-			continue
-		}
-
-		if logType == LogTypeRequest {
-			entry, err := p.parseRequestLog(line)
-			if err != nil {
-				continue // 跳过解析失败的行
-			}
-			entries = append(entries, entry)
-		} else {
-			entry, err := p.parseSystemLog(line)
-			if err != nil {
-				continue // 跳过解析失败的行
-			}
-			entries = append(entries, entry)
+	if offset > 0 {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("定位文件失败: %w", err)
 		}
 	}
 
-	if err := logScanner.Err(); err != nil {
-		return nil, fmt.Errorf("读取文件失败: %w", err)
-	}
+	reader := p.NewReader(file, filepath.Base(filePath), logType)
+	reader.closer = file
+	reader.offset = offset
+	reader.line = line
+	return reader, nil
+}
 
-	return entries, nil
+// NewReader 从 r 流式读取日志内容（如解压后的归档文件），name 为原始文件名。
+// 返回的 FileReader 的 Close 不会关闭 r
+func (p *Parser) NewReader(r io.Reader, name string, logType LogType) *FileReader {
+	return &FileReader{
+		parser:  p,
+		logType: logType,
+		name:    name,
+		reader:  bufio.NewReaderSize(r, readBufferSize),
+	}
 }
 
 // parseRequestLog 解析请求日志
@@ -108,11 +129,8 @@ func (p *Parser) parseSystemLog(line string) (*SystemLogEntry, error) {
 		return nil, err
 	}
 
-	// 验证必填字段
-	requestID, ok := entry["request_id"].(string)
-	if !ok || requestID == "" {
-		return nil, errors.New("缺少 request_id 字段")
-	}
+	// 不在请求链路中的系统日志（如启动日志、其他服务的日志）没有 request_id，按来源位置去重
+	requestID, _ := entry["request_id"].(string)
 
 	// 提取需要的字段
 	timeStr, _ := entry["time"].(string)
@@ -127,25 +145,21 @@ func (p *Parser) parseSystemLog(line string) (*SystemLogEntry, error) {
 		level = "INFO"
 	}
 
+	// 其余字段作为结构化属性保留
+	attrs := make(map[string]interface{}, len(entry))
+	for key, value := range entry {
+		switch key {
+		case "time", "level", "msg", "request_id":
+			continue
+		}
+		attrs[key] = value
+	}
+
 	return &SystemLogEntry{
 		RequestID: requestID,
 		Time:      timeStr,
 		Level:     level,
 		Msg:       msg,
+		Attrs:     attrs,
 	}, nil
 }
-
-// Batch 分批处理日志条目
-func (p *Parser) Batch(entries []LogEntry, batchSize int) [][]LogEntry {
-	var batches [][]LogEntry
-
-	for i := 0; i < len(entries); i += batchSize {
-		end := i + batchSize
-		if end > len(entries) {
-			end = len(entries)
-		}
-		batches = append(batches, entries[i:end])
-	}
-
-	return batches
-}
@@ -0,0 +1,165 @@
+package parser
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	// maxLineSize 单行最大长度，超过的行作为无效行跳过
+	maxLineSize = 1024 * 1024 // 1MB
+	// readBufferSize 读取缓冲区大小
+	readBufferSize = 64 * 1024
+)
+
+// FileReader 流式读取日志文件，每次只在内存中保留一个批次，
+// 并记录已读取的字节位置和行数，用于断点续传
+type FileReader struct {
+	parser  *Parser
+	logType LogType
+	name    string    // 文件名，写入系统日志的 source_file
+	closer  io.Closer // 由 OpenFile 打开的文件
+	reader  *bufio.Reader
+	offset  int64 // 已读取到的字节位置（总在行边界上）
+	line    int   // 已读取的行数
+	lineBuf []byte
+	follow  bool // 跟随模式：文件仍在写入，末尾没有换行符的行视为未写完
+	stopped bool // 跟随模式下遇到未写完的行，本次不再继续读取
+	origin  Origin
+}
+
+// SetOrigin 设置日志来源，读取的每条日志都会带上 service_id 和 host
+func (r *FileReader) SetOrigin(origin Origin) {
+	r.origin = origin
+}
+
+// SetFollow 设置跟随模式。文件仍在写入时开启，末尾未写完的行留到下次读取，
+// 不计入 Offset；关闭时（默认）末尾没有换行符的行也作为完整的一行
+func (r *FileReader) SetFollow(follow bool) {
+	r.follow = follow
+}
+
+// ReadBatch 读取下一批数据，有效条目与无效行合计不超过 batchSize。
+// 两者都为空表示文件已读完
+func (r *FileReader) ReadBatch(batchSize int) (entries []Entry, invalid []InvalidLine, err error) {
+	for len(entries)+len(invalid) < batchSize {
+		lineOffset := r.offset
+		line, tooLong, err := r.readLine()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取文件失败（第 %d 行）: %w", r.line+1, err)
+		}
+		r.line++
+		current := Line{Number: r.line, Offset: lineOffset, Text: string(line)}
+
+		if tooLong {
+			invalid = append(invalid, InvalidLine{Line: current, Reason: fmt.Sprintf("行长度超过 %d 字节", maxLineSize)})
+			continue
+		}
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry LogEntry
+		if r.logType == LogTypeRequest {
+			var requestEntry RequestLogEntry
+			if requestEntry, err = r.parser.parseRequestLog(string(line)); err == nil {
+				r.stampRequest(requestEntry)
+				entry = requestEntry
+			}
+		} else {
+			var systemEntry *SystemLogEntry
+			if systemEntry, err = r.parser.parseSystemLog(string(line)); err == nil {
+				r.stampSystem(systemEntry, lineOffset)
+				entry = systemEntry
+			}
+		}
+		if err != nil {
+			invalid = append(invalid, InvalidLine{Line: current, Reason: err.Error()})
+			continue
+		}
+		entries = append(entries, Entry{Line: current, Data: entry})
+	}
+	return entries, invalid, nil
+}
+
+// stampRequest 写入来源标识，日志中已有的值不覆盖
+func (r *FileReader) stampRequest(entry RequestLogEntry) {
+	if _, ok := entry["service_id"]; !ok && r.origin.ServiceID != "" {
+		entry["service_id"] = r.origin.ServiceID
+	}
+	if _, ok := entry["host"]; !ok && r.origin.Host != "" {
+		entry["host"] = r.origin.Host
+	}
+}
+
+// stampSystem 写入来源标识和行的位置，同一行重复上传时由 log-service 按 来源/文件名/行首位置 去重
+func (r *FileReader) stampSystem(entry *SystemLogEntry, lineOffset int64) {
+	entry.ServiceID = r.origin.ServiceID
+	entry.Host = r.origin.Host
+	entry.Source = r.origin.Source
+	entry.SourceFile = r.name
+	entry.SourceOffset = lineOffset
+}
+
+// Offset 已读取到的字节位置
+func (r *FileReader) Offset() int64 {
+	return r.offset
+}
+
+// Line 已读取的行数
+func (r *FileReader) Line() int {
+	return r.line
+}
+
+// Close 关闭文件
+func (r *FileReader) Close() error {
+	if r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// readLine 读取一行（不含换行符），超过 maxLineSize 的部分被丢弃并返回 tooLong=true。
+// 文件末尾没有换行符的最后一行：跟随模式下退回行首并返回 io.EOF，否则作为完整的一行返回
+func (r *FileReader) readLine() (line []byte, tooLong bool, err error) {
+	if r.stopped {
+		return nil, false, io.EOF
+	}
+	lineStart := r.offset
+	r.lineBuf = r.lineBuf[:0]
+	for {
+		chunk, err := r.reader.ReadSlice('\n')
+		r.offset += int64(len(chunk))
+		if !tooLong {
+			content := bytes.TrimSuffix(chunk, []byte("\n"))
+			if len(r.lineBuf)+len(content) > maxLineSize {
+				tooLong = true
+				r.lineBuf = append(r.lineBuf, content[:maxLineSize-len(r.lineBuf)]...)
+			} else {
+				r.lineBuf = append(r.lineBuf, content...)
+			}
+		}
+
+		if errors.Is(err, bufio.ErrBufferFull) {
+			continue
+		}
+		if err == io.EOF && r.offset > lineStart {
+			if r.follow {
+				r.offset = lineStart
+				r.stopped = true
+				return nil, false, io.EOF
+			}
+			err = nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+		return bytes.TrimSuffix(r.lineBuf, []byte("\r")), tooLong, nil
+	}
+}
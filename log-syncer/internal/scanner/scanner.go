@@ -2,6 +2,7 @@
 package scanner
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -9,6 +10,9 @@ import (
 	"time"
 )
 
+// DefaultPattern proxy 日志文件名格式: request-YYYYMMDDHHMM.log 或 system-YYYYMMDDHHMM.log
+const DefaultPattern = `^(?P<type>request|system)-(?P<time>\d{12})\.log$`
+
 // LogType 日志类型
 type LogType int
 
@@ -24,24 +28,69 @@ type LogFile struct {
 	Path     string    // 文件完整路径
 	Name     string    // 文件名
 	Type     LogType   // 日志类型
-	FileTime time.Time // 文件名中的时间
+	FileTime time.Time // 文件名中的时间，即该半小时的结束时间
 }
 
 // Scanner 扫描器
 type Scanner struct {
-	logDir string
+	logDir    string
+	pattern   *regexp.Regexp
+	timeIndex int            // pattern 中 time 分组的位置
+	typeIndex int            // pattern 中 type 分组的位置，-1 表示没有
+	logType   LogType        // pattern 没有 type 分组时所有文件的日志类型
+	location  *time.Location // 文件名中时间使用的时区
 }
 
 // NewScanner 创建扫描器
-func NewScanner(logDir string) *Scanner {
-	return &Scanner{
-		logDir: logDir,
+// pattern 为文件名正则，必须包含 time 命名分组（12 位 YYYYMMDDHHMM，表示文件覆盖时段的结束时间），
+// 可以包含 type 命名分组（request 或 system）；没有 type 分组时所有文件都按 logType 处理。
+// loc 为文件名中时间使用的时区
+func NewScanner(logDir, pattern string, logType LogType, loc *time.Location) (*Scanner, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("文件名正则错误: %w", err)
 	}
+	timeIndex := re.SubexpIndex("time")
+	if timeIndex < 0 {
+		return nil, fmt.Errorf("文件名正则缺少 time 分组: %s", pattern)
+	}
+
+	return &Scanner{
+		logDir:    logDir,
+		pattern:   re,
+		timeIndex: timeIndex,
+		typeIndex: re.SubexpIndex("type"),
+		logType:   logType,
+		location:  loc,
+	}, nil
+}
+
+// Dir 扫描的日志目录
+func (s *Scanner) Dir() string {
+	return s.logDir
 }
 
 // Scan 扫描日志目录
 // 只返回文件时间早于 cutoffTime 的文件
 func (s *Scanner) Scan(cutoffTime time.Time) ([]*LogFile, error) {
+	all, err := s.ScanAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var files []*LogFile
+	for _, logFile := range all {
+		// 只处理文件时间早于截止时间的文件
+		if logFile.FileTime.Before(cutoffTime) || logFile.FileTime.Equal(cutoffTime) {
+			files = append(files, logFile)
+		}
+	}
+
+	return files, nil
+}
+
+// ScanAll 扫描日志目录中的全部日志文件，包括正在写入的文件
+func (s *Scanner) ScanAll() ([]*LogFile, error) {
 	var files []*LogFile
 
 	entries, err := os.ReadDir(s.logDir)
@@ -58,35 +107,27 @@ func (s *Scanner) Scan(cutoffTime time.Time) ([]*LogFile, error) {
 		if !ok {
 			continue
 		}
-
-		// 只处理文件时间早于截止时间的文件
-		if logFile.FileTime.Before(cutoffTime) || logFile.FileTime.Equal(cutoffTime) {
-			files = append(files, logFile)
-		}
+		files = append(files, logFile)
 	}
 
 	return files, nil
 }
 
-// parseLogFile 解析日志文件名
-// 格式: request-YYYYMMDDHHMM.log 或 system-YYYYMMDDHHMM.log
+// parseLogFile 按文件名正则解析日志文件名
 func (s *Scanner) parseLogFile(filename string) (*LogFile, bool) {
-	// 匹配 request-YYYYMMDDHHMM.log 或 system-YYYYMMDDHHMM.log
-	re := regexp.MustCompile(`^(request|system)-(\d{12})\.log$`)
-	matches := re.FindStringSubmatch(filename)
-	if matches == nil {
-		return nil, false
-	}
-
-	// 解析时间（使用本地时区）
-	fileTime, err := time.ParseInLocation("200601021504", matches[2], time.Local)
-	if err != nil {
+	fileTime, ok := s.FileTime(filename)
+	if !ok {
 		return nil, false
 	}
 
-	logType := LogTypeRequest
-	if matches[1] == "system" {
-		logType = LogTypeSystem
+	logType := s.logType
+	if s.typeIndex >= 0 {
+		switch s.pattern.FindStringSubmatch(filename)[s.typeIndex] {
+		case "request":
+			logType = LogTypeRequest
+		case "system":
+			logType = LogTypeSystem
+		}
 	}
 
 	return &LogFile{
@@ -97,20 +138,18 @@ func (s *Scanner) parseLogFile(filename string) (*LogFile, bool) {
 	}, true
 }
 
-// ParseFileTime 从文件名解析时间 (YYYYMMDDHHMM)
-func ParseFileTime(filename string) (time.Time, bool) {
-	re := regexp.MustCompile(`^(\d{12})`)
-	matches := re.FindStringSubmatch(filename)
+// FileTime 解析文件名中的时间（使用配置的时区），文件名不匹配时返回 false
+func (s *Scanner) FileTime(filename string) (time.Time, bool) {
+	matches := s.pattern.FindStringSubmatch(filename)
 	if matches == nil {
 		return time.Time{}, false
 	}
 
-	t, err := time.ParseInLocation("200601021504", matches[1], time.Local)
+	fileTime, err := time.ParseInLocation("200601021504", matches[s.timeIndex], s.location)
 	if err != nil {
 		return time.Time{}, false
 	}
-
-	return t, true
+	return fileTime, true
 }
 
 // FormatFileTime 格式化时间为文件名时间格式 (YYYYMMDDHHMM)
@@ -123,17 +162,6 @@ func GetArchiveRetentionTime(retentionDays int) time.Time {
 	return time.Now().AddDate(0, 0, -retentionDays)
 }
 
-// ShouldCleanArchive 判断归档文件是否应该清理
-func ShouldCleanArchive(filename string, retentionDays int) bool {
-	t, ok := ParseFileTime(filename)
-	if !ok {
-		return false
-	}
-
-	cutoffTime := GetArchiveRetentionTime(retentionDays)
-	return t.Before(cutoffTime)
-}
-
 // StrToInt 字符串转整数
 func StrToInt(s string) int {
 	i, _ := strconv.Atoi(s)
@@ -0,0 +1,190 @@
+package scheduler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule 计算下一次执行时间
+type Schedule interface {
+	// Next 返回 after 之后的下一次执行时间
+	Next(after time.Time) time.Time
+	// String 调度规则的描述
+	String() string
+}
+
+// IntervalSchedule 固定间隔调度
+type IntervalSchedule struct {
+	Interval time.Duration
+}
+
+// Next 返回 after + Interval
+func (s IntervalSchedule) Next(after time.Time) time.Time {
+	return after.Add(s.Interval)
+}
+
+func (s IntervalSchedule) String() string {
+	return "every " + s.Interval.String()
+}
+
+// CronSchedule 标准 5 段 cron 表达式：分 时 日 月 周
+// 每段支持 *、数字、a-b 范围、a,b 列表和 /n 步长；周的取值 0-7，0 和 7 都表示周日
+type CronSchedule struct {
+	expr     string
+	minute   uint64
+	hour     uint64
+	dom      uint64
+	month    uint64
+	dow      uint64
+	domStar  bool // 日为 *
+	dowStar  bool // 周为 *
+	location *time.Location
+}
+
+// cronField cron 表达式单段的取值范围
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"分", 0, 59},
+	{"时", 0, 23},
+	{"日", 1, 31},
+	{"月", 1, 12},
+	{"周", 0, 7},
+}
+
+// cronMaxYears 查找下一次执行时间的最大范围，超出视为表达式永远不会触发（如 2 月 30 日）
+const cronMaxYears = 5
+
+// ParseCron 解析 cron 表达式，loc 为表达式使用的时区
+func ParseCron(expr string, loc *time.Location) (*CronSchedule, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron 表达式需要 5 段（分 时 日 月 周），实际 %d 段: %q", len(parts), expr)
+	}
+
+	bits := make([]uint64, len(parts))
+	for i, part := range parts {
+		b, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, fmt.Errorf("cron 表达式 %q 的%s字段错误: %w", expr, cronFields[i].name, err)
+		}
+		bits[i] = b
+	}
+
+	// 周日可写作 0 或 7
+	if bits[4]&(1<<7) != 0 {
+		bits[4] |= 1
+	}
+
+	if loc == nil {
+		loc = time.Local
+	}
+	s := &CronSchedule{
+		expr:     expr,
+		minute:   bits[0],
+		hour:     bits[1],
+		dom:      bits[2],
+		month:    bits[3],
+		dow:      bits[4],
+		domStar:  parts[2] == "*",
+		dowStar:  parts[4] == "*",
+		location: loc,
+	}
+	if s.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron 表达式 %q 在 %d 年内不会触发", expr, cronMaxYears)
+	}
+	return s, nil
+}
+
+// parseCronField 解析单段，返回按位表示的取值集合
+func parseCronField(field string, f cronField) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			rangePart = item[:i]
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("步长无效: %q", item)
+			}
+			step = n
+		}
+
+		start, end := f.min, f.max
+		switch {
+		case rangePart == "*":
+		case strings.Contains(rangePart, "-"):
+			bounds := strings.SplitN(rangePart, "-", 2)
+			a, err1 := strconv.Atoi(bounds[0])
+			b, err2 := strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || a > b {
+				return 0, fmt.Errorf("范围无效: %q", item)
+			}
+			start, end = a, b
+		default:
+			n, err := strconv.Atoi(rangePart)
+			if err != nil {
+				return 0, fmt.Errorf("取值无效: %q", item)
+			}
+			start = n
+			// 单个数字带步长时（如 5/15）表示从该值到最大值
+			if step == 1 {
+				end = n
+			}
+		}
+		if start < f.min || end > f.max {
+			return 0, fmt.Errorf("取值超出范围 %d-%d: %q", f.min, f.max, item)
+		}
+
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Next 返回 after 之后（不含）的下一次执行时间，找不到时返回零值
+func (s *CronSchedule) Next(after time.Time) time.Time {
+	t := after.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(cronMaxYears, 0, 0)
+
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches 日和周的匹配规则与标准 cron 一致：两者都有限制时满足其一即可
+func (s *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func (s *CronSchedule) String() string {
+	return "cron " + s.expr
+}
@@ -1,84 +1,183 @@
 // Package scheduler 定时调度器
-// 每小时的 01 分和 31 分触发任务
+// 按 cron 表达式或固定间隔触发任务，同一时间只运行一个任务，也可以手动触发
 package scheduler
 
 import (
+	"context"
+	"errors"
 	"log"
+	"sync"
 	"time"
 )
 
-// Task 定时任务函数
-type Task func()
+// 触发来源
+const (
+	TriggerSchedule = "schedule" // 定时触发
+	TriggerStartup  = "startup"  // 启动时触发
+	TriggerSignal   = "sighup"   // SIGHUP 信号触发
+	TriggerHTTP     = "http"     // 本地 HTTP 接口触发
+)
+
+// 运行结果
+const (
+	ResultRunning = "running" // 正在运行
+	ResultSuccess = "success" // 成功
+	ResultFailed  = "failed"  // 失败（含被退出信号中断）
+)
+
+// ErrRunning 已有任务在运行
+var ErrRunning = errors.New("同步任务正在运行")
+
+// Task 定时任务函数，ctx 在调度器停止时取消
+type Task func(ctx context.Context) error
+
+// RunStatus 单次运行状态
+type RunStatus struct {
+	Trigger    string     `json:"trigger"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+	Duration   string     `json:"duration,omitempty"`
+	Result     string     `json:"result"`
+	Error      string     `json:"error,omitempty"`
+}
+
+// Status 调度器状态
+type Status struct {
+	Schedule string     `json:"schedule"`
+	Running  bool       `json:"running"`
+	Current  *RunStatus `json:"current,omitempty"`  // 正在进行的运行
+	LastRun  *RunStatus `json:"last_run,omitempty"` // 最近一次完成的运行
+	NextRun  time.Time  `json:"next_run"`
+	Runs     int        `json:"runs"`     // 已完成的运行次数
+	Failures int        `json:"failures"` // 其中失败的次数
+	Skipped  int        `json:"skipped"`  // 因上一次仍在运行而跳过的定时触发次数
+}
 
 // Scheduler 调度器
 type Scheduler struct {
-	task Task
-	quit chan struct{}
+	schedule Schedule
+	task     Task
+
+	ctx  context.Context
+	wg   sync.WaitGroup
+	mu   sync.Mutex
+	stat Status
 }
 
 // NewScheduler 创建调度器
-func NewScheduler(task Task) *Scheduler {
+func NewScheduler(schedule Schedule, task Task) *Scheduler {
 	return &Scheduler{
-		task: task,
-		quit: make(chan struct{}),
+		schedule: schedule,
+		task:     task,
+		stat:     Status{Schedule: schedule.String()},
 	}
 }
 
-// Start 启动调度器
-func (s *Scheduler) Start() {
-	// 启动定时调度
+// Start 启动调度器，ctx 取消后停止调度并取消正在运行的任务
+func (s *Scheduler) Start(ctx context.Context) {
+	s.ctx = ctx
+	s.wg.Add(1)
 	go s.run()
 }
 
-// Stop 停止调度器
-func (s *Scheduler) Stop() {
-	close(s.quit)
+// Wait 等待调度循环和正在运行的任务退出
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
 }
 
-// run 运行调度循环
+// Trigger 立即运行一次任务，已有任务在运行时返回 ErrRunning
+func (s *Scheduler) Trigger(trigger string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.ctx == nil || s.ctx.Err() != nil {
+		return errors.New("调度器未运行")
+	}
+	if s.stat.Running {
+		return ErrRunning
+	}
+
+	current := &RunStatus{Trigger: trigger, StartedAt: time.Now(), Result: ResultRunning}
+	s.stat.Running = true
+	s.stat.Current = current
+	s.wg.Add(1)
+	go s.execute(current)
+	return nil
+}
+
+// Status 返回调度器状态
+func (s *Scheduler) Status() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stat := s.stat
+	if stat.Current != nil {
+		current := *stat.Current
+		stat.Current = &current
+	}
+	if stat.LastRun != nil {
+		last := *stat.LastRun
+		stat.LastRun = &last
+	}
+	return stat
+}
+
+// run 运行调度循环，任务在独立的 goroutine 中执行，耗时较长也不会推迟后续的调度时间
 func (s *Scheduler) run() {
+	defer s.wg.Done()
+
 	for {
-		nextTick := s.nextTick()
+		nextTick := s.schedule.Next(time.Now())
+		if nextTick.IsZero() {
+			log.Printf("调度器: %s 不会再触发", s.schedule)
+			return
+		}
+		s.mu.Lock()
+		s.stat.NextRun = nextTick
+		s.mu.Unlock()
 		log.Printf("调度器: 等待下一次执行，时间: %s", nextTick.Format("2006-01-02 15:04:05"))
 
+		timer := time.NewTimer(time.Until(nextTick))
 		select {
-		case <-time.After(time.Until(nextTick)):
+		case <-timer.C:
 			log.Printf("调度器: 触发任务")
-			s.task()
-		case <-s.quit:
+			if err := s.Trigger(TriggerSchedule); errors.Is(err, ErrRunning) {
+				s.mu.Lock()
+				s.stat.Skipped++
+				s.mu.Unlock()
+				log.Printf("调度器: 上一次任务仍在运行，跳过本次")
+			}
+		case <-s.ctx.Done():
+			timer.Stop()
 			log.Printf("调度器: 已停止")
 			return
 		}
 	}
 }
 
-// nextTick 计算下一次执行时间
-// 规则: 每小时的 01 分和 31 分
-func (s *Scheduler) nextTick() time.Time {
-	now := time.Now()
-
-	// 计算当前小时的 01 分和 31 分
-	hour := now.Hour()
-	minute := now.Minute()
-
-	var nextTime time.Time
-
-	// 本小时的 01 分
-	t01 := time.Date(now.Year(), now.Month(), now.Day(), hour, 1, 0, 0, now.Location())
-	// 本小时的 31 分
-	t31 := time.Date(now.Year(), now.Month(), now.Day(), hour, 31, 0, 0, now.Location())
-	// 下一小时的 01 分
-	tNext01 := time.Date(now.Year(), now.Month(), now.Day(), hour+1, 1, 0, 0, now.Location())
-
-	// 根据当前时间选择下一个执行点
-	switch {
-	case minute < 1:
-		nextTime = t01
-	case minute < 31:
-		nextTime = t31
-	default:
-		nextTime = tNext01
+// execute 执行任务并记录结果
+func (s *Scheduler) execute(current *RunStatus) {
+	defer s.wg.Done()
+
+	err := s.task(s.ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	finished := *current
+	finishedAt := time.Now()
+	finished.FinishedAt = &finishedAt
+	finished.Duration = finishedAt.Sub(finished.StartedAt).Round(time.Millisecond).String()
+	finished.Result = ResultSuccess
+	if err != nil {
+		finished.Result = ResultFailed
+		finished.Error = err.Error()
+		s.stat.Failures++
 	}
+	s.stat.Running = false
+	s.stat.Current = nil
+	s.stat.LastRun = &finished
+	s.stat.Runs++
 
-	return nextTime
+	log.Printf("调度器: 任务完成，触发来源: %s，耗时: %s，结果: %s", finished.Trigger, finished.Duration, finished.Result)
 }
@@ -0,0 +1,94 @@
+// Package trigger 本地 HTTP 接口：手动触发同步、查询调度状态
+package trigger
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+
+	applogger "zxm_ai_admin/log-syncer/internal/logger"
+	"zxm_ai_admin/log-syncer/internal/scheduler"
+)
+
+// Server 触发接口服务
+type Server struct {
+	srv   *http.Server
+	sched *scheduler.Scheduler
+}
+
+// response 统一响应格式，与 log-service 一致
+type response struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewServer 创建触发接口服务
+func NewServer(addr string, sched *scheduler.Scheduler) *Server {
+	s := &Server{sched: sched}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/sync", s.handleSync)
+	mux.HandleFunc("/status", s.handleStatus)
+	s.srv = &http.Server{Addr: addr, Handler: mux}
+	return s
+}
+
+// Start 监听端口并在后台提供服务，端口被占用等错误直接返回
+func (s *Server) Start() error {
+	ln, err := net.Listen("tcp", s.srv.Addr)
+	if err != nil {
+		return fmt.Errorf("监听 %s 失败: %w", s.srv.Addr, err)
+	}
+
+	go func() {
+		if err := s.srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			applogger.Error("触发接口服务异常退出", "error", err)
+		}
+	}()
+	return nil
+}
+
+// Shutdown 关闭服务
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.srv.Shutdown(ctx)
+}
+
+// handleSync POST /sync 立即执行一次同步，已有同步在运行时返回 409
+func (s *Server) handleSync(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeJSON(w, http.StatusMethodNotAllowed, response{Code: http.StatusMethodNotAllowed, Message: "仅支持 POST"})
+		return
+	}
+
+	if err := s.sched.Trigger(scheduler.TriggerHTTP); err != nil {
+		status := http.StatusServiceUnavailable
+		if errors.Is(err, scheduler.ErrRunning) {
+			status = http.StatusConflict
+		}
+		writeJSON(w, status, response{Code: status, Message: err.Error(), Data: s.sched.Status()})
+		return
+	}
+
+	applogger.Info("通过 HTTP 接口触发同步", "remote_addr", r.RemoteAddr)
+	writeJSON(w, http.StatusAccepted, response{Code: 0, Message: "已触发同步", Data: s.sched.Status()})
+}
+
+// handleStatus GET /status 查询调度状态
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeJSON(w, http.StatusMethodNotAllowed, response{Code: http.StatusMethodNotAllowed, Message: "仅支持 GET"})
+		return
+	}
+	writeJSON(w, http.StatusOK, response{Code: 0, Message: "success", Data: s.sched.Status()})
+}
+
+// writeJSON 写入 JSON 响应
+func writeJSON(w http.ResponseWriter, status int, body response) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(body)
+}
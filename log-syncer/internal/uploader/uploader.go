@@ -3,68 +3,164 @@ package uploader
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"math/rand"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// maxRetryAfter 服务端 Retry-After 的最大等待时间，避免异常响应导致长时间阻塞
+const maxRetryAfter = 5 * time.Minute
+
+// RetryPolicy 重试策略
+type RetryPolicy struct {
+	MaxRetries     int           // 最大重试次数（不含首次请求）
+	InitialBackoff time.Duration // 首次重试前的等待时间
+	MaxBackoff     time.Duration // 单次等待时间上限
+}
+
+// HTTPError 上传接口返回非 200 状态码
+type HTTPError struct {
+	StatusCode int
+	RetryAfter time.Duration // 429/503 响应中的 Retry-After，未设置时为 0
+	Body       string
+}
+
+func (e *HTTPError) Error() string {
+	return fmt.Sprintf("上传失败 (status=%d): %s", e.StatusCode, e.Body)
+}
+
+// RejectedRow 被 log-service 拒绝的行
+type RejectedRow struct {
+	Index     int    `json:"index"` // 在本批次中的序号，从 0 开始
+	RequestID string `json:"request_id"`
+	Reason    string `json:"reason"`
+}
+
+// Batch 批次标识，通过请求头发送，log-service 对同一批次 ID 只写入一次
+type Batch struct {
+	ID     string // 为空时不发送批次标识
+	Source string // 日志来源名称
+	File   string // 来源文件名
+	Offset int64  // 批次结束时在文件中的字节位置
+}
+
+// BatchResponse 批量上传接口的响应数据
+type BatchResponse struct {
+	Count     int  `json:"count"` // 插入条数
+	Duplicate int  `json:"duplicate"`
+	Replayed  bool `json:"replayed"` // 批次已被接收过，计数为首次写入时的结果
+	Results   []struct {
+		RejectedRow
+		Status string `json:"status"` // inserted / duplicate / rejected
+	} `json:"results"` // 逐行结果，旧版 log-service 不返回
+}
+
+// Rejected 被拒绝的行
+func (r *BatchResponse) Rejected() []RejectedRow {
+	var rows []RejectedRow
+	for _, row := range r.Results {
+		if row.Status == "rejected" {
+			rows = append(rows, row.RejectedRow)
+		}
+	}
+	return rows
+}
+
 // Uploader 上传器
 type Uploader struct {
-	baseURL        string
+	baseURL         string
 	systemAuthToken string
-	timeout        time.Duration
+	timeout         time.Duration
+	retry           RetryPolicy
+	gzip            bool // 请求体使用 gzip 压缩
 }
 
-// NewUploader 创建上传器
-func NewUploader(baseURL, systemAuthToken string, timeout time.Duration) *Uploader {
+// NewUploader 创建上传器，gzipBody 为 true 时请求体使用 gzip 压缩
+func NewUploader(baseURL, systemAuthToken string, timeout time.Duration, retry RetryPolicy, gzipBody bool) *Uploader {
 	return &Uploader{
-		baseURL:        baseURL,
+		baseURL:         baseURL,
 		systemAuthToken: systemAuthToken,
-		timeout:        timeout,
+		timeout:         timeout,
+		retry:           retry,
+		gzip:            gzipBody,
 	}
 }
 
 // UploadRequestLogs 上传请求日志
-func (u *Uploader) UploadRequestLogs(entries []interface{}) error {
+func (u *Uploader) UploadRequestLogs(entries []interface{}, batch Batch) (*BatchResponse, error) {
 	if len(entries) == 0 {
-		return nil
+		return &BatchResponse{}, nil
 	}
 
 	url := fmt.Sprintf("%s/api/request-logs/batch", u.baseURL)
-	return u.upload(url, entries)
+	return u.upload(url, entries, batch)
 }
 
 // UploadSystemLogs 上传系统日志
-func (u *Uploader) UploadSystemLogs(entries []interface{}) error {
+func (u *Uploader) UploadSystemLogs(entries []interface{}, batch Batch) (*BatchResponse, error) {
 	if len(entries) == 0 {
-		return nil
+		return &BatchResponse{}, nil
 	}
 
 	url := fmt.Sprintf("%s/api/system-logs/batch", u.baseURL)
-	return u.upload(url, entries)
+	return u.upload(url, entries, batch)
+}
+
+// encodeBody 序列化批次，开启压缩时使用 gzip
+func (u *Uploader) encodeBody(entries []interface{}) ([]byte, error) {
+	body, err := json.Marshal(entries)
+	if err != nil || !u.gzip {
+		return body, err
+	}
+
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	if _, err := gz.Write(body); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
 }
 
 // upload 执行上传
-func (u *Uploader) upload(url string, entries []interface{}) error {
+func (u *Uploader) upload(url string, entries []interface{}, batch Batch) (*BatchResponse, error) {
 	slog.Debug("开始上传", "url", url, "entries_count", len(entries))
 
-	body, err := json.Marshal(entries)
+	body, err := u.encodeBody(entries)
 	if err != nil {
 		slog.Error("序列化数据失败", "error", err, "url", url, "entries_count", len(entries))
-		return fmt.Errorf("序列化数据失败: %w", err)
+		return nil, fmt.Errorf("序列化数据失败: %w", err)
 	}
 
 	req, err := http.NewRequest("POST", url, bytes.NewReader(body))
 	if err != nil {
 		slog.Error("创建请求失败", "error", err, "url", url)
-		return fmt.Errorf("创建请求失败: %w", err)
+		return nil, fmt.Errorf("创建请求失败: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
+	if u.gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
 	req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", u.systemAuthToken))
+	if batch.ID != "" {
+		req.Header.Set("X-Batch-ID", batch.ID)
+		req.Header.Set("X-Batch-Source", batch.Source)
+		req.Header.Set("X-Batch-File", batch.File)
+		req.Header.Set("X-Batch-Offset", strconv.FormatInt(batch.Offset, 10))
+	}
 
 	client := &http.Client{
 		Timeout: u.timeout,
@@ -73,60 +169,152 @@ func (u *Uploader) upload(url string, entries []interface{}) error {
 	resp, err := client.Do(req)
 	if err != nil {
 		slog.Error("请求失败", "error", err, "url", url, "entries_count", len(entries))
-		return fmt.Errorf("请求失败: %w", err)
+		return nil, fmt.Errorf("请求失败: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		respBody, _ := io.ReadAll(resp.Body)
 		slog.Error("上传失败：HTTP状态码错误", "status", resp.StatusCode, "response", string(respBody), "url", url, "entries_count", len(entries))
-		return fmt.Errorf("上传失败 (status=%d): %s", resp.StatusCode, string(respBody))
+		httpErr := &HTTPError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable {
+			httpErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+		}
+		return nil, httpErr
 	}
 
 	// 解析响应
 	var result struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-		Data    struct {
-			Count int `json:"count"`
-		} `json:"data"`
+		Code    int           `json:"code"`
+		Message string        `json:"message"`
+		Data    BatchResponse `json:"data"`
 	}
 
 	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		slog.Error("解析响应失败", "error", err, "url", url, "entries_count", len(entries))
-		return fmt.Errorf("解析响应失败: %w", err)
+		return nil, fmt.Errorf("解析响应失败: %w", err)
 	}
 
 	if result.Code != 0 {
 		slog.Error("上传失败", "code", result.Code, "message", result.Message, "url", url, "entries_count", len(entries))
-		return fmt.Errorf("上传失败: %s", result.Message)
+		return nil, fmt.Errorf("上传失败: %s", result.Message)
 	}
 
-	if result.Data.Count == 0 {
-		slog.Warn("上传成功但插入0条记录", "url", url, "entries_count", len(entries), "inserted_count", result.Data.Count)
+	rejected := len(result.Data.Rejected())
+	if result.Data.Replayed {
+		slog.Info("批次已被接收，使用首次上传的结果", "url", url, "batch_id", batch.ID, "entries_count", len(entries), "inserted_count", result.Data.Count, "duplicate_count", result.Data.Duplicate, "rejected_count", rejected)
+	} else if result.Data.Count == 0 {
+		slog.Warn("上传成功但插入0条记录", "url", url, "entries_count", len(entries), "inserted_count", result.Data.Count, "duplicate_count", result.Data.Duplicate, "rejected_count", rejected)
 	} else {
-		slog.Info("上传成功", "url", url, "entries_count", len(entries), "inserted_count", result.Data.Count)
+		slog.Info("上传成功", "url", url, "entries_count", len(entries), "inserted_count", result.Data.Count, "duplicate_count", result.Data.Duplicate, "rejected_count", rejected)
 	}
-	return nil
+	return &result.Data, nil
 }
 
 // UploadResult 上传结果
 type UploadResult struct {
-	Success bool
-	Error   error
+	Success   bool
+	Error     error
+	Attempts  int           // 实际请求次数
+	Inserted  int           // 新写入的条目数
+	Duplicate int           // 已存在的条目数
+	Rejected  []RejectedRow // 上传成功时被 log-service 拒绝的行，其余行已写入或重复
 }
 
-// UploadWithRetry 上传（带重试，当前不重试）
-func (u *Uploader) UploadWithRetry(entries []interface{}, isRequest bool) UploadResult {
+// UploadWithRetry 上传，失败时按指数退避加随机抖动重试。
+// 网络错误、408、429 和 5xx 会重试，429/503 优先使用服务端返回的 Retry-After；
+// 其他 4xx 和业务错误重试也不会成功，直接返回。ctx 取消时停止等待。
+func (u *Uploader) UploadWithRetry(ctx context.Context, entries []interface{}, isRequest bool, batch Batch) UploadResult {
+	var resp *BatchResponse
 	var err error
-	if isRequest {
-		err = u.UploadRequestLogs(entries)
-	} else {
-		err = u.UploadSystemLogs(entries)
+	attempts := 0
+	for {
+		attempts++
+		if isRequest {
+			resp, err = u.UploadRequestLogs(entries, batch)
+		} else {
+			resp, err = u.UploadSystemLogs(entries, batch)
+		}
+		if err == nil || !isRetryable(err) || attempts > u.retry.MaxRetries {
+			break
+		}
+
+		wait := u.backoff(attempts)
+		var httpErr *HTTPError
+		if errors.As(err, &httpErr) && httpErr.RetryAfter > wait {
+			wait = httpErr.RetryAfter
+		}
+		slog.Warn("上传失败，等待后重试", "attempt", attempts, "max_retries", u.retry.MaxRetries, "wait", wait.String(), "error", err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return UploadResult{Error: fmt.Errorf("%w（已取消重试）", err), Attempts: attempts}
+		case <-timer.C:
+		}
+	}
+
+	result := UploadResult{
+		Success:  err == nil,
+		Error:    err,
+		Attempts: attempts,
+	}
+	if resp != nil {
+		result.Inserted = resp.Count
+		result.Duplicate = resp.Duplicate
+		result.Rejected = resp.Rejected()
+	}
+	return result
+}
+
+// backoff 第 attempt 次失败后的等待时间：InitialBackoff·2^(attempt-1)，不超过 MaxBackoff，
+// 取其 50%~100% 之间的随机值，避免多个实例同时重试
+func (u *Uploader) backoff(attempt int) time.Duration {
+	wait := u.retry.InitialBackoff
+	for i := 1; i < attempt && wait < u.retry.MaxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > u.retry.MaxBackoff {
+		wait = u.retry.MaxBackoff
 	}
+	if wait <= 0 {
+		return 0
+	}
+	half := wait / 2
+	return half + time.Duration(rand.Int63n(int64(wait-half)+1))
+}
 
-	return UploadResult{
-		Success: err == nil,
-		Error:   err,
+// isRetryable 判断错误是否值得重试
+func isRetryable(err error) bool {
+	var httpErr *HTTPError
+	if errors.As(err, &httpErr) {
+		return httpErr.StatusCode == http.StatusRequestTimeout ||
+			httpErr.StatusCode == http.StatusTooManyRequests ||
+			httpErr.StatusCode >= 500
+	}
+	// 连接失败、超时等网络错误
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// parseRetryAfter 解析 Retry-After 头，支持秒数和 HTTP 日期两种格式
+func parseRetryAfter(value string) time.Duration {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0
+	}
+	var wait time.Duration
+	if seconds, err := strconv.Atoi(value); err == nil {
+		wait = time.Duration(seconds) * time.Second
+	} else if t, err := http.ParseTime(value); err == nil {
+		wait = time.Until(t)
+	}
+	if wait < 0 {
+		return 0
+	}
+	if wait > maxRetryAfter {
+		return maxRetryAfter
 	}
+	return wait
 }
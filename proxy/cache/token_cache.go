@@ -15,6 +15,7 @@ import (
 type TokenModel struct {
 	TokenID          int    `json:"token_id"`
 	Token            string `json:"token"`
+	TokenOrderNo     string `json:"token_order_no"`
 	TokenStatus      int    `json:"token_status"`
 	AIModelID        int    `json:"ai_model_id"`
 	AIModelName      string `json:"ai_model_name"`
@@ -10,11 +10,28 @@ import (
 
 // Config 代理配置
 type Config struct {
-	LogLevel         string `mapstructure:"log_level"`
-	ListenAddr       string `mapstructure:"listen_addr"`
-	ServerBaseURL    string `mapstructure:"server_base_url"`
-	SystemAuthToken  string `mapstructure:"system_auth_token"`
-	SyncInterval     int    `mapstructure:"sync_interval"`
+	LogLevel        string           `mapstructure:"log_level"`
+	ListenAddr      string           `mapstructure:"listen_addr"`
+	ServerBaseURL   string           `mapstructure:"server_base_url"`
+	SystemAuthToken string           `mapstructure:"system_auth_token"`
+	SyncInterval    int              `mapstructure:"sync_interval"`
+	LogShipper      LogShipperConfig `mapstructure:"log_shipper"`
+}
+
+// LogShipperConfig 日志直投配置
+// 启用后请求日志和系统日志在写入文件的同时直接批量投递到 log-service，
+// 单机部署时可替代 log-syncer
+type LogShipperConfig struct {
+	Enabled         bool   `mapstructure:"enabled"`
+	LogServiceURL   string `mapstructure:"log_service_url"`   // log-service 地址，如 http://localhost:6809
+	SystemAuthToken string `mapstructure:"system_auth_token"` // log-service 系统认证令牌
+	BatchSize       int    `mapstructure:"batch_size"`        // 单批最大条数，默认 100
+	FlushInterval   int    `mapstructure:"flush_interval"`    // 最长攒批时间（秒），默认 5
+	QueueSize       int    `mapstructure:"queue_size"`        // 内存队列容量，默认 10000
+	SpillDir        string `mapstructure:"spill_dir"`         // log-service 不可用时的落盘目录，默认 ./logs/spill
+	Source          string `mapstructure:"source"`            // 来源名称，需与 log-syncer 中本日志目录的来源名称一致，默认 proxy
+	ServiceID       string `mapstructure:"service_id"`        // 来源服务标识，写入每条日志
+	Host            string `mapstructure:"host"`              // 来源主机，写入每条日志，默认本机 hostname
 }
 
 var appConfig *Config
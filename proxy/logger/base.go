@@ -11,13 +11,43 @@ import (
 
 // baseLogger 日志记录器基类，包含公共字段和方法
 type baseLogger struct {
-	logDir          string
-	level           slog.Level
-	mu              sync.Mutex
-	logger          *slog.Logger
+	logDir           string
+	level            slog.Level
+	mu               sync.Mutex
+	logger           *slog.Logger
 	currentTimestamp string
-	file            *os.File
-	filePrefix      string // 文件名前缀，如 "request-" 或 "system-"
+	file             *os.File
+	filePrefix       string // 文件名前缀，如 "request-" 或 "system-"
+	sink             Sink   // 可选的日志行旁路（如直接投递到 log-service）
+	skipSink         bool   // 为 true 时写入的日志行只写文件，不交给 sink
+}
+
+// Sink 日志行旁路，file 为日志文件名（不含目录），offset 为行首在文件中的字节位置
+type Sink func(line []byte, file string, offset int64)
+
+// teeWriter 写入日志文件的同时，将日志行交给 sink
+// slog 的 JSONHandler 每条记录调用一次 Write，即一行完整 JSON
+type teeWriter struct {
+	file *os.File
+	name string // 文件名，与 log-syncer 写入的 source_file 一致
+	size int64  // 文件当前大小，即下一行的行首位置
+	base *baseLogger
+}
+
+func (w *teeWriter) Write(p []byte) (int, error) {
+	offset := w.size
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	// Write 在持有 base.mu 时被调用，可直接读取 sink
+	if w.base.sink != nil && !w.base.skipSink {
+		w.base.sink(p, w.name, offset)
+	}
+	return n, err
+}
+
+// setSink 设置日志行旁路（调用前必须已加锁）
+func (b *baseLogger) setSink(sink Sink) {
+	b.sink = sink
 }
 
 // getCurrentHalfHour 获取当前半小时标识（用于文件名）
@@ -80,12 +110,18 @@ func (b *baseLogger) rotate() error {
 	if err != nil {
 		return err
 	}
+	// 追加写入，行首位置从文件已有大小开始计算（进程重启后继续写同一个半小时文件）
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
 
 	b.file = file
 	b.currentTimestamp = currentTimestamp
 
 	// 创建新的 logger
-	b.logger = slog.New(slog.NewJSONHandler(file, &slog.HandlerOptions{
+	b.logger = slog.New(slog.NewJSONHandler(&teeWriter{file: file, name: filepath.Base(filename), size: info.Size(), base: b}, &slog.HandlerOptions{
 		Level: b.level,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			if a.Key == slog.TimeKey {
@@ -102,4 +138,3 @@ func (b *baseLogger) rotate() error {
 func (b *baseLogger) getLogger() *slog.Logger {
 	return b.logger
 }
-
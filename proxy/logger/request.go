@@ -34,6 +34,14 @@ func (r *RequestLogger) Log(ctx context.Context, level slog.Level, msg string, a
 	logger.Log(ctx, level, msg, args...)
 }
 
+// SetSink 设置日志行旁路，每写入一行日志都会调用 sink
+func (r *RequestLogger) SetSink(sink Sink) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.setSink(sink)
+}
+
 // Info 记录 info 级别日志
 func (r *RequestLogger) Info(msg string, args ...any) {
 	r.mu.Lock()
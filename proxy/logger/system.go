@@ -1,6 +1,7 @@
 package logger
 
 import (
+	"context"
 	"log/slog"
 
 	"github.com/google/uuid"
@@ -21,6 +22,14 @@ func (s *SystemLogger) Init(logDir string, level slog.Level) error {
 	return s.init(logDir, level, "system-")
 }
 
+// SetSink 设置日志行旁路，每写入一行日志都会调用 sink
+func (s *SystemLogger) SetSink(sink Sink) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setSink(sink)
+}
+
 // Info 记录 info 级别日志
 func (s *SystemLogger) Info(msg string, args ...any) {
 	s.mu.Lock()
@@ -69,5 +78,23 @@ func (s *SystemLogger) Warn(msg string, args ...any) {
 	logger.Warn(msg, newArgs...)
 }
 
+// Local 记录只写入日志文件、不交给 sink 的日志
+// 用于日志投递器自身的诊断日志，避免投递失败时产生更多待投递的日志
+func (s *SystemLogger) Local(level slog.Level, msg string, args ...any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.getLogger() == nil {
+		return
+	}
+
+	s.rotate()
+	s.skipSink = true
+	defer func() { s.skipSink = false }()
+	requestID := uuid.New().String()
+	newArgs := append([]any{"request_id", requestID}, args...)
+	s.getLogger().Log(context.Background(), level, msg, newArgs...)
+}
+
 // System 导出的系统日志实例
 var System = system
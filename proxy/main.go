@@ -5,6 +5,8 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
 	"syscall"
 	"time"
@@ -14,6 +16,7 @@ import (
 	"proxy/logger"
 	"proxy/middleware"
 	"proxy/proxy"
+	"proxy/shipper"
 )
 
 func main() {
@@ -35,6 +38,13 @@ func main() {
 		panic("请求日志初始化失败: " + err.Error())
 	}
 
+	// 启动日志直投（可选，替代 log-syncer）
+	shipperDone := make(chan struct{})
+	var shipperWg sync.WaitGroup
+	if cfg.LogShipper.Enabled {
+		startLogShippers(cfg.LogShipper, shipperDone, &shipperWg)
+	}
+
 	// 创建 token 缓存
 	tokenCache := cache.New(cfg.ServerBaseURL, cfg.SystemAuthToken)
 	cacheDone := make(chan struct{})
@@ -106,5 +116,64 @@ func main() {
 	serverWg.Wait()
 
 	logger.Info("服务器已关闭")
+
+	// 最后停止日志直投，确保关闭过程中的日志也被投递或落盘
+	close(shipperDone)
+	shipperWg.Wait()
+}
+
+// startLogShippers 启动请求日志和系统日志的直投
+func startLogShippers(cfg config.LogShipperConfig, done chan struct{}, wg *sync.WaitGroup) {
+	spillDir := cfg.SpillDir
+	if spillDir == "" {
+		spillDir = filepath.Join("./logs", "spill")
+	}
+	// 默认值与 log-syncer 由 proxy.log_dir 生成的来源一致
+	source := cfg.Source
+	if source == "" {
+		source = "proxy"
+	}
+	host := cfg.Host
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+
+	targets := []struct {
+		name         string
+		path         string
+		linePosition bool
+		set          func(logger.Sink)
+	}{
+		{"request", "/api/request-logs/batch", false, logger.Request.SetSink},
+		{"system", "/api/system-logs/batch", true, logger.System.SetSink},
+	}
+
+	for _, target := range targets {
+		s := shipper.New(shipper.Options{
+			Endpoint:        strings.TrimRight(cfg.LogServiceURL, "/") + target.path,
+			SystemAuthToken: cfg.SystemAuthToken,
+			BatchSize:       cfg.BatchSize,
+			FlushInterval:   time.Duration(cfg.FlushInterval) * time.Second,
+			QueueSize:       cfg.QueueSize,
+			SpillDir:        filepath.Join(spillDir, target.name),
+			Source:          source,
+			ServiceID:       cfg.ServiceID,
+			Host:            host,
+			LinePosition:    target.linePosition,
+		})
+		target.set(s.Enqueue)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.Run(done)
+		}()
+	}
+
+	logger.Info("日志直投已启用",
+		"log_service_url", cfg.LogServiceURL,
+		"spill_dir", spillDir,
+		"source", source,
+	)
 }
 
@@ -64,19 +64,21 @@ func (p *Proxy) Handler() http.Handler {
 		wrapped := &ResponseWrapper{
 			ResponseWriter: w,
 			StatusCode:     http.StatusOK,
+			Start:          start,
 		}
 
 		// 执行代理
-		p.serveHTTP(wrapped, r)
+		model := p.serveHTTP(wrapped, r)
 
 		// 精简请求体后再记录日志
 		sanitizedBody := sanitizeRequestBody(string(requestBody))
-		p.logRequest(ctx, r, wrapped, originalAuth, sanitizedBody, start)
+		p.logRequest(ctx, r, wrapped, model, originalAuth, sanitizedBody, start)
 	})
 }
 
 // serveHTTP 执行代理逻辑
-func (p *Proxy) serveHTTP(wrapped *ResponseWrapper, r *http.Request) {
+// 返回命中的 token 模型配置，请求被拒绝时返回 nil
+func (p *Proxy) serveHTTP(wrapped *ResponseWrapper, r *http.Request) *cache.TokenModel {
 	ctx := r.Context()
 	requestID := logger.RequestIDFromContext(ctx)
 
@@ -89,7 +91,7 @@ func (p *Proxy) serveHTTP(wrapped *ResponseWrapper, r *http.Request) {
 			"remote_addr", r.RemoteAddr,
 		)
 		http.Error(wrapped, "Service Unavailable: cache not ready", http.StatusServiceUnavailable)
-		return
+		return nil
 	}
 
 	authHeader := r.Header.Get("Authorization")
@@ -105,7 +107,7 @@ func (p *Proxy) serveHTTP(wrapped *ResponseWrapper, r *http.Request) {
 			"authorization", authHeader,
 		)
 		http.Error(wrapped, "Unauthorized: Invalid token", http.StatusUnauthorized)
-		return
+		return nil
 	}
 
 	// 获取或创建目标代理
@@ -118,11 +120,12 @@ func (p *Proxy) serveHTTP(wrapped *ResponseWrapper, r *http.Request) {
 			"target_url", model.AIModelAPIURL,
 		)
 		http.Error(wrapped, "Internal Server Error", http.StatusInternalServerError)
-		return
+		return nil
 	}
 
 	// 使用模型的 API Key 替换 Authorization
 	p.proxyWithAPIKey(targetProxy, wrapped, r, "Bearer "+model.AIModelAPIKey)
+	return model
 }
 
 // getProxy 获取或创建指定目标的代理
@@ -204,6 +207,7 @@ func createReverseProxy(targetURL string) (*httputil.ReverseProxy, error) {
 			"method", r.Method,
 			"path", r.URL.Path,
 			"remote_addr", r.RemoteAddr,
+			"target_url", targetURL, // log-service 请求时间线据此识别失败的上游调用
 			"error", err.Error(),
 		)
 		http.Error(w, "代理请求失败", http.StatusBadGateway)
@@ -212,10 +216,20 @@ func createReverseProxy(targetURL string) (*httputil.ReverseProxy, error) {
 	return p, nil
 }
 
-func (p *Proxy) logRequest(ctx context.Context, r *http.Request, wrapped *ResponseWrapper, originalAuth, requestBody string, start time.Time) {
+func (p *Proxy) logRequest(ctx context.Context, r *http.Request, wrapped *ResponseWrapper, model *cache.TokenModel, originalAuth, requestBody string, start time.Time) {
 	latency := time.Since(start)
 	statusCode := wrapped.StatusCode
 
+	var aiModelID int
+	var aiModelName, orderNo string
+	if model != nil {
+		aiModelID = model.AIModelID
+		aiModelName = model.AIModelName
+		orderNo = model.TokenOrderNo
+	}
+
+	usage := wrapped.Usage()
+
 	// 根据状态码决定日志级别
 	level := slog.LevelInfo
 	msg := "proxy_request"
@@ -243,6 +257,17 @@ func (p *Proxy) logRequest(ctx context.Context, r *http.Request, wrapped *Respon
 		"latency_ms", latency.Milliseconds(),
 		"request_size_bytes", len(requestBody),
 		"response_size_bytes", wrapped.ResponseSize,
+		"ai_model_id", aiModelID,
+		"ai_model_name", aiModelName,
+		"order_no", orderNo,
+		"is_stream", wrapped.IsStream(),
+		"header_latency_ms", wrapped.HeaderLatency().Milliseconds(),
+		"first_byte_latency_ms", wrapped.FirstByteLatency().Milliseconds(),
+		"stream_duration_ms", wrapped.StreamDuration().Milliseconds(),
+		"chunk_count", wrapped.ChunkCount,
+		"prompt_tokens", usage.PromptTokens,
+		"completion_tokens", usage.CompletionTokens,
+		"total_tokens", usage.TotalTokens,
 	)
 }
 
@@ -2,17 +2,31 @@ package proxy
 
 import (
 	"net/http"
+	"strings"
+	"time"
 )
 
 // ResponseWrapper 包装 ResponseWriter 以捕获状态码、响应头和响应大小
+// 同时记录流式响应的时间指标（响应头耗时、首字节耗时、分块数）
 type ResponseWrapper struct {
 	http.ResponseWriter
-	StatusCode    int
-	Headers       http.Header
-	ResponseSize  int
+	StatusCode   int
+	Headers      http.Header
+	ResponseSize int
+
+	Start       time.Time // 请求开始时间
+	HeaderAt    time.Time // 响应头写出时间
+	FirstByteAt time.Time // 首个响应体字节写出时间
+	LastByteAt  time.Time // 最后一个响应体字节写出时间
+	ChunkCount  int       // 响应体写入次数（SSE 下约等于事件分块数）
+
+	usage *usageCollector // token 用量解析器，首次写入响应体时创建
 }
 
 func (w *ResponseWrapper) WriteHeader(statusCode int) {
+	if w.HeaderAt.IsZero() {
+		w.HeaderAt = time.Now()
+	}
 	w.StatusCode = statusCode
 	// 复制响应头
 	if w.Headers == nil {
@@ -25,11 +39,87 @@ func (w *ResponseWrapper) WriteHeader(statusCode int) {
 }
 
 func (w *ResponseWrapper) Write(b []byte) (int, error) {
+	now := time.Now()
+	// 未显式调用 WriteHeader 时，首次 Write 隐式写出响应头
+	if w.HeaderAt.IsZero() {
+		w.HeaderAt = now
+	}
+	if len(b) > 0 {
+		if w.FirstByteAt.IsZero() {
+			w.FirstByteAt = now
+		}
+		w.LastByteAt = now
+		w.ChunkCount++
+		w.collectUsage(b)
+	}
 	n, err := w.ResponseWriter.Write(b)
 	w.ResponseSize += n
 	return n, err
 }
 
+// Flush 支持流式响应（SSE）逐块刷新
+func (w *ResponseWrapper) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap 返回底层 ResponseWriter，供 http.ResponseController 使用
+func (w *ResponseWrapper) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// IsStream 是否为流式响应（text/event-stream）
+func (w *ResponseWrapper) IsStream() bool {
+	return strings.HasPrefix(w.Headers.Get("Content-Type"), "text/event-stream")
+}
+
+// HeaderLatency 请求开始到响应头写出的耗时
+func (w *ResponseWrapper) HeaderLatency() time.Duration {
+	if w.HeaderAt.IsZero() {
+		return 0
+	}
+	return w.HeaderAt.Sub(w.Start)
+}
+
+// FirstByteLatency 请求开始到首个响应体字节（首 token）的耗时
+func (w *ResponseWrapper) FirstByteLatency() time.Duration {
+	if w.FirstByteAt.IsZero() {
+		return 0
+	}
+	return w.FirstByteAt.Sub(w.Start)
+}
+
+// StreamDuration 首个响应体字节到最后一个响应体字节的耗时
+func (w *ResponseWrapper) StreamDuration() time.Duration {
+	if w.FirstByteAt.IsZero() {
+		return 0
+	}
+	return w.LastByteAt.Sub(w.FirstByteAt)
+}
+
+// collectUsage 将响应体交给用量解析器
+// 压缩响应无法直接解析，跳过
+func (w *ResponseWrapper) collectUsage(b []byte) {
+	if w.usage == nil {
+		header := w.ResponseWriter.Header()
+		encoding := header.Get("Content-Encoding")
+		w.usage = &usageCollector{
+			stream:   strings.HasPrefix(header.Get("Content-Type"), "text/event-stream"),
+			disabled: encoding != "" && encoding != "identity",
+		}
+	}
+	w.usage.write(b)
+}
+
+// Usage 从响应体中解析出的 token 用量，未解析到时各项为 0
+func (w *ResponseWrapper) Usage() Usage {
+	if w.usage == nil {
+		return Usage{}
+	}
+	return w.usage.result()
+}
+
 // Hijack 支持 WebSocket
 func (w *ResponseWrapper) Hijack() (interface{}, interface{}, error) {
 	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
@@ -0,0 +1,122 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// maxUsageBufferSize 非流式响应最多缓存的响应体大小，超过则放弃解析用量
+const maxUsageBufferSize = 1 << 20
+
+// Usage 上游返回的 token 用量
+type Usage struct {
+	PromptTokens     int64
+	CompletionTokens int64
+	TotalTokens      int64
+}
+
+// usagePayload 兼容 OpenAI（prompt/completion）与 Anthropic（input/output）两种用量字段
+type usagePayload struct {
+	PromptTokens     int64 `json:"prompt_tokens"`
+	CompletionTokens int64 `json:"completion_tokens"`
+	TotalTokens      int64 `json:"total_tokens"`
+	InputTokens      int64 `json:"input_tokens"`
+	OutputTokens     int64 `json:"output_tokens"`
+}
+
+// usageEnvelope 响应体（或 SSE 事件）中 usage 可能出现的位置
+// Anthropic 流式响应的 input_tokens 位于 message_start 事件的 message.usage 中
+type usageEnvelope struct {
+	Usage   *usagePayload `json:"usage"`
+	Message *struct {
+		Usage *usagePayload `json:"usage"`
+	} `json:"message"`
+}
+
+// usageCollector 从响应体中提取 token 用量
+// 流式响应按行解析 SSE data 事件，非流式响应缓存完整响应体后解析
+type usageCollector struct {
+	stream   bool
+	disabled bool
+	buf      bytes.Buffer
+	usage    Usage
+}
+
+// write 写入一段响应体
+func (c *usageCollector) write(b []byte) {
+	if c.disabled {
+		return
+	}
+	if c.buf.Len()+len(b) > maxUsageBufferSize {
+		// 非流式响应过大或 SSE 单行过长，放弃解析
+		c.disabled = true
+		c.buf.Reset()
+		return
+	}
+	c.buf.Write(b)
+
+	if !c.stream {
+		return
+	}
+	for {
+		data := c.buf.Bytes()
+		idx := bytes.IndexByte(data, '\n')
+		if idx < 0 {
+			return
+		}
+		c.parseSSELine(data[:idx])
+		c.buf.Next(idx + 1)
+	}
+}
+
+// parseSSELine 解析一行 SSE，仅处理包含 usage 的 data 事件
+func (c *usageCollector) parseSSELine(line []byte) {
+	line = bytes.TrimSpace(line)
+	if !bytes.HasPrefix(line, []byte("data:")) {
+		return
+	}
+	data := bytes.TrimSpace(line[len("data:"):])
+	if !bytes.Contains(data, []byte(`"usage"`)) {
+		return
+	}
+	c.merge(data)
+}
+
+// merge 解析一段 JSON 并合并其中的用量（各字段取最大值）
+func (c *usageCollector) merge(data []byte) {
+	var env usageEnvelope
+	if err := json.Unmarshal(data, &env); err != nil {
+		return
+	}
+	if env.Usage != nil {
+		c.mergePayload(env.Usage)
+	}
+	if env.Message != nil && env.Message.Usage != nil {
+		c.mergePayload(env.Message.Usage)
+	}
+}
+
+func (c *usageCollector) mergePayload(p *usagePayload) {
+	c.usage.PromptTokens = max(c.usage.PromptTokens, p.PromptTokens, p.InputTokens)
+	c.usage.CompletionTokens = max(c.usage.CompletionTokens, p.CompletionTokens, p.OutputTokens)
+	c.usage.TotalTokens = max(c.usage.TotalTokens, p.TotalTokens)
+}
+
+// result 返回解析到的用量，响应结束后调用
+func (c *usageCollector) result() Usage {
+	if !c.disabled {
+		if c.stream {
+			// 最后一行可能没有换行符
+			c.parseSSELine(c.buf.Bytes())
+		} else if bytes.Contains(c.buf.Bytes(), []byte(`"usage"`)) {
+			c.merge(c.buf.Bytes())
+		}
+		c.buf.Reset()
+	}
+
+	usage := c.usage
+	if usage.TotalTokens == 0 {
+		usage.TotalTokens = usage.PromptTokens + usage.CompletionTokens
+	}
+	return usage
+}
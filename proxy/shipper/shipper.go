@@ -0,0 +1,457 @@
+package shipper
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"proxy/logger"
+)
+
+// 默认配置
+const (
+	defaultBatchSize     = 100
+	defaultFlushInterval = 5 * time.Second
+	defaultQueueSize     = 10000
+	defaultTimeout       = 30 * time.Second
+)
+
+// maxSpillFileBytes 单个落盘文件的最大字节数，超过后切换到新文件
+const maxSpillFileBytes = 16 << 20
+
+// Options 日志投递器配置
+type Options struct {
+	Endpoint        string        // 批量写入接口完整地址，如 http://localhost:6809/api/request-logs/batch
+	SystemAuthToken string        // log-service 系统认证令牌
+	BatchSize       int           // 单批最大条数
+	FlushInterval   time.Duration // 未攒满一批时的最长等待时间
+	QueueSize       int           // 内存队列容量
+	SpillDir        string        // log-service 不可用时的落盘目录
+	Timeout         time.Duration // 单次 HTTP 请求超时
+
+	// 来源标识，与 log-syncer 中同一日志目录的来源配置一致时，两者同时运行不会重复入库
+	Source       string // 来源名称
+	ServiceID    string // 来源服务标识
+	Host         string // 来源主机
+	LinePosition bool   // 为每行写入 source/source_file/source_offset（系统日志按此去重）
+}
+
+// Shipper 日志投递器
+// 将日志行放入有界队列，按批次 POST 到 log-service 的批量写入接口；
+// 投递失败或队列满时由投递循环追加写入落盘文件（NDJSON），服务恢复后按文件顺序补投。
+// Enqueue 在写日志时被调用（持有 logger 的锁），只做内存操作，不做任何文件或网络 I/O
+type Shipper struct {
+	opts   Options
+	queue  chan json.RawMessage
+	client *http.Client
+	origin []byte // 追加到每行末尾的 service_id/host 字段（已编码，以 , 开头）
+
+	overflowMu sync.Mutex
+	overflow   []json.RawMessage // 队列已满时暂存的日志，由投递循环批量落盘，最多 QueueSize 条
+
+	dropped atomic.Int64 // 队列与暂存区都已满而丢弃的条数
+
+	// 以下字段只在投递循环中访问
+	degraded  bool     // 最近一次投递失败：新批次直接落盘，补投全部成功后恢复
+	spillFile *os.File // 当前追加写入的落盘文件
+	spillPath string
+	spillSize int64
+}
+
+// New 创建日志投递器
+func New(opts Options) *Shipper {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = defaultBatchSize
+	}
+	if opts.FlushInterval <= 0 {
+		opts.FlushInterval = defaultFlushInterval
+	}
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = defaultQueueSize
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = defaultTimeout
+	}
+
+	var origin []byte
+	if opts.ServiceID != "" {
+		origin = appendField(origin, "service_id", opts.ServiceID)
+	}
+	if opts.Host != "" {
+		origin = appendField(origin, "host", opts.Host)
+	}
+
+	return &Shipper{
+		opts:   opts,
+		queue:  make(chan json.RawMessage, opts.QueueSize),
+		client: &http.Client{Timeout: opts.Timeout},
+		origin: origin,
+	}
+}
+
+// Enqueue 投递一行 JSON 日志（非阻塞），file 和 offset 为该行所在的日志文件名和行首位置
+// 队列已满时放入暂存区，暂存区也满时丢弃并计数，不阻塞请求处理
+func (s *Shipper) Enqueue(line []byte, file string, offset int64) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 {
+		return
+	}
+
+	// slog 写入的缓冲区会被复用，必须复制
+	entry := s.stamp(line, file, offset)
+
+	select {
+	case s.queue <- entry:
+	default:
+		s.overflowMu.Lock()
+		if len(s.overflow) < s.opts.QueueSize {
+			s.overflow = append(s.overflow, entry)
+		} else {
+			s.dropped.Add(1)
+		}
+		s.overflowMu.Unlock()
+	}
+}
+
+// stamp 复制日志行，并在末尾追加来源字段（与 log-syncer 写入的字段一致）
+func (s *Shipper) stamp(line []byte, file string, offset int64) json.RawMessage {
+	if line[len(line)-1] != '}' || (len(s.origin) == 0 && !s.opts.LinePosition) {
+		entry := make(json.RawMessage, len(line))
+		copy(entry, line)
+		return entry
+	}
+
+	entry := make(json.RawMessage, 0, len(line)+len(s.origin)+len(file)+64)
+	entry = append(entry, line[:len(line)-1]...)
+	entry = append(entry, s.origin...)
+	if s.opts.LinePosition {
+		entry = appendField(entry, "source", s.opts.Source)
+		entry = appendField(entry, "source_file", file)
+		entry = append(entry, `,"source_offset":`...)
+		entry = strconv.AppendInt(entry, offset, 10)
+	}
+	return append(entry, '}')
+}
+
+// appendField 追加一个 ,"key":"value" 字段
+func appendField(dst []byte, key, value string) []byte {
+	encoded, _ := json.Marshal(value)
+	dst = append(dst, ',', '"')
+	dst = append(dst, key...)
+	dst = append(dst, '"', ':')
+	return append(dst, encoded...)
+}
+
+// Run 运行投递循环，直到 done 关闭
+// 退出前会清空队列，未能投递的批次落盘等待下次启动补投
+func (s *Shipper) Run(done chan struct{}) {
+	ticker := time.NewTicker(s.opts.FlushInterval)
+	defer ticker.Stop()
+	defer s.closeSpillFile()
+
+	batch := make([]json.RawMessage, 0, s.opts.BatchSize)
+
+	flush := func() {
+		if len(batch) == 0 {
+			return
+		}
+		s.ship(batch)
+		batch = make([]json.RawMessage, 0, s.opts.BatchSize)
+	}
+
+	for {
+		select {
+		case entry := <-s.queue:
+			batch = append(batch, entry)
+			if len(batch) >= s.opts.BatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+			s.spillOverflow()
+			s.replaySpilled()
+			if dropped := s.dropped.Swap(0); dropped > 0 {
+				s.logLocal(slog.LevelWarn, "日志投递队列已满，部分日志被丢弃",
+					"endpoint", s.opts.Endpoint,
+					"dropped_count", dropped,
+				)
+			}
+		case <-done:
+			// 清空队列
+			for {
+				select {
+				case entry := <-s.queue:
+					batch = append(batch, entry)
+					if len(batch) >= s.opts.BatchSize {
+						flush()
+					}
+				default:
+					flush()
+					s.spillOverflow()
+					return
+				}
+			}
+		}
+	}
+}
+
+// ship 投递一批日志，失败时落盘。投递失败后直接落盘，直到补投成功，
+// 避免 log-service 不可用期间每个批次都等待请求超时而让队列积压
+func (s *Shipper) ship(batch []json.RawMessage) {
+	if !s.degraded {
+		err := s.post(batch)
+		if err == nil {
+			return
+		}
+		s.degraded = true
+		s.logLocal(slog.LevelWarn, "日志投递失败，后续批次写入落盘目录直到补投成功",
+			"endpoint", s.opts.Endpoint,
+			"batch_size", len(batch),
+			"error", err,
+		)
+	}
+	s.spillBatch(batch)
+}
+
+// spillOverflow 将暂存区的日志写入落盘文件
+func (s *Shipper) spillOverflow() {
+	s.overflowMu.Lock()
+	overflow := s.overflow
+	s.overflow = nil
+	s.overflowMu.Unlock()
+
+	if len(overflow) > 0 {
+		s.spillBatch(overflow)
+	}
+}
+
+// spillBatch 落盘一批日志，失败时丢弃并记录
+func (s *Shipper) spillBatch(batch []json.RawMessage) {
+	if err := s.spill(batch); err != nil {
+		s.logLocal(slog.LevelError, "日志落盘失败，批次丢弃",
+			"spill_dir", s.opts.SpillDir,
+			"batch_size", len(batch),
+			"error", err,
+		)
+	}
+}
+
+// batchID 按批次内容生成批次 ID，同一批次补投时 ID 不变，
+// 上次投递超时但已写入时 log-service 直接返回首次写入的结果
+func batchID(batch []json.RawMessage) string {
+	h := sha256.New()
+	for _, entry := range batch {
+		h.Write(entry)
+		h.Write([]byte{'\n'})
+	}
+	return "shipper-" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// post 以 JSON 数组形式 POST 到批量写入接口（与 log-syncer 上传格式一致）
+func (s *Shipper) post(batch []json.RawMessage) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("序列化数据失败: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.opts.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+s.opts.SystemAuthToken)
+	req.Header.Set("X-Batch-ID", batchID(batch))
+	if s.opts.Source != "" {
+		req.Header.Set("X-Batch-Source", s.opts.Source)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("上传失败 (status=%d): %s", resp.StatusCode, string(respBody))
+	}
+
+	var result struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return fmt.Errorf("解析响应失败: %w", err)
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("上传失败: %s", result.Message)
+	}
+
+	return nil
+}
+
+// spill 将一批日志按行追加到当前落盘文件（NDJSON），文件超过 maxSpillFileBytes 后切换到新文件
+func (s *Shipper) spill(batch []json.RawMessage) error {
+	if s.opts.SpillDir == "" {
+		return fmt.Errorf("未配置落盘目录")
+	}
+
+	if s.spillFile == nil {
+		if err := os.MkdirAll(s.opts.SpillDir, 0755); err != nil {
+			return err
+		}
+		path := filepath.Join(s.opts.SpillDir, fmt.Sprintf("spill-%d.ndjson", time.Now().UnixNano()))
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+		if err != nil {
+			return err
+		}
+		s.spillFile, s.spillPath, s.spillSize = file, path, 0
+	}
+
+	var buf bytes.Buffer
+	for _, entry := range batch {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	n, err := s.spillFile.Write(buf.Bytes())
+	s.spillSize += int64(n)
+	if err != nil {
+		s.closeSpillFile()
+		return err
+	}
+
+	if s.spillSize >= maxSpillFileBytes {
+		s.closeSpillFile()
+	}
+	return nil
+}
+
+// closeSpillFile 关闭当前落盘文件，之后的批次写入新文件
+func (s *Shipper) closeSpillFile() {
+	if s.spillFile == nil {
+		return
+	}
+	s.spillFile.Sync()
+	s.spillFile.Close()
+	s.spillFile, s.spillPath, s.spillSize = nil, "", 0
+}
+
+// replaySpilled 按时间顺序补投落盘文件，遇到失败即停止，等待下一个周期。
+// 文件按 BatchSize 分批投递，中途失败时文件只保留未投递的行，下次从失败的批次开始按同样的边界分批，
+// 批次 ID 不变。全部补投成功后恢复直接投递
+func (s *Shipper) replaySpilled() {
+	if s.opts.SpillDir == "" {
+		s.degraded = false
+		return
+	}
+
+	names, err := spillFiles(s.opts.SpillDir)
+	if err != nil || len(names) == 0 {
+		s.degraded = false
+		return
+	}
+
+	// 当前文件也参与补投，之后的批次写入新文件
+	s.closeSpillFile()
+
+	for _, name := range names {
+		path := filepath.Join(s.opts.SpillDir, name)
+		entries, err := readSpillFile(path)
+		if err != nil {
+			s.logLocal(slog.LevelError, "落盘文件损坏，已跳过", "file", path, "error", err)
+			os.Rename(path, path+".bad")
+			continue
+		}
+
+		for start := 0; start < len(entries); start += s.opts.BatchSize {
+			end := min(start+s.opts.BatchSize, len(entries))
+			if err := s.post(entries[start:end]); err != nil {
+				if start > 0 {
+					if err := rewriteSpillFile(path, entries[start:]); err != nil {
+						s.logLocal(slog.LevelError, "更新落盘文件失败，已投递的行可能被重复投递", "file", path, "error", err)
+					}
+				}
+				s.degraded = true
+				return
+			}
+		}
+
+		os.Remove(path)
+		s.logLocal(slog.LevelInfo, "落盘日志补投成功", "file", name, "count", len(entries))
+	}
+	s.degraded = false
+}
+
+// spillFiles 落盘目录中待补投的文件，按文件名（即创建时间）排序
+func spillFiles(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, "spill-") || !strings.HasSuffix(name, ".ndjson") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// readSpillFile 读取落盘文件中的日志行
+func readSpillFile(path string) ([]json.RawMessage, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []json.RawMessage
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		// 进程崩溃时最后一行可能不完整
+		if !json.Valid(line) {
+			continue
+		}
+		entries = append(entries, json.RawMessage(line))
+	}
+	return entries, nil
+}
+
+// rewriteSpillFile 用剩余的行替换落盘文件（先写临时文件再重命名）
+func rewriteSpillFile(path string, entries []json.RawMessage) error {
+	var buf bytes.Buffer
+	for _, entry := range entries {
+		buf.Write(entry)
+		buf.WriteByte('\n')
+	}
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, buf.Bytes(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// logLocal 记录投递器自身的诊断日志，只写入系统日志文件，不进入投递队列，
+// 避免 log-service 不可用时投递失败的日志又产生新的待投递日志
+func (s *Shipper) logLocal(level slog.Level, msg string, args ...any) {
+	logger.System.Local(level, msg, args...)
+}
@@ -176,6 +176,10 @@ func setupRoutes(r *gin.Engine) {
 
 		// Token 与模型关联接口（proxy 使用系统认证令牌调用）
 		api.GET("/tokens/with-model", middleware.SystemAuthMiddleware(), tokenHandler.ListAllTokensWithModel)
+		// 按 Token 值查询（日志服务自助查询接口使用系统认证令牌调用）
+		api.POST("/tokens/lookup", middleware.SystemAuthMiddleware(), tokenHandler.LookupToken)
+		// 按 Token 值禁用（日志服务异常检测使用系统认证令牌调用）
+		api.POST("/tokens/disable", middleware.SystemAuthMiddleware(), tokenHandler.DisableToken)
 
 		// 模型来源相关（需要认证）
 		modelSourceHandler := handlers.NewModelSourceHandler()
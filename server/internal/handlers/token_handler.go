@@ -3,6 +3,7 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
 	"zxm_ai_admin/server/internal/services"
@@ -98,6 +99,44 @@ func (h *TokenHandler) UpdateToken(c *gin.Context) {
 	utils.Success(c, token)
 }
 
+// LookupToken 按 Token 值查询 Token（系统认证，供日志服务自助查询接口校验 Token）
+func (h *TokenHandler) LookupToken(c *gin.Context) {
+	var req services.LookupTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	token, err := h.tokenService.LookupToken(req.Token)
+	if err != nil {
+		if errors.Is(err, services.ErrTokenNotFound) {
+			utils.NotFound(c, err.Error())
+			return
+		}
+		utils.InternalServerError(c, err.Error())
+		return
+	}
+
+	utils.Success(c, token)
+}
+
+// DisableToken 按 Token 值禁用 Token（系统认证，供日志服务异常检测自动处置调用）
+func (h *TokenHandler) DisableToken(c *gin.Context) {
+	var req services.DisableTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		utils.BadRequest(c, "参数错误: "+err.Error())
+		return
+	}
+
+	token, err := h.tokenService.DisableToken(&req)
+	if err != nil {
+		utils.Error(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	utils.Success(c, token)
+}
+
 // DeleteToken 删除 Token
 func (h *TokenHandler) DeleteToken(c *gin.Context) {
 	idStr := c.Param("id")
@@ -6,11 +6,15 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"errors"
+	"fmt"
 	"time"
 	"zxm_ai_admin/server/internal/database"
 	"zxm_ai_admin/server/internal/models"
 )
 
+// ErrTokenNotFound Token 不存在
+var ErrTokenNotFound = errors.New("Token 不存在")
+
 type TokenService struct{}
 
 // NewTokenService 创建 Token 业务逻辑实例
@@ -38,6 +42,18 @@ type UpdateTokenRequest struct {
 	Remark     *string     `json:"remark"`       // 备注
 }
 
+// DisableTokenRequest 按 Token 值禁用请求（日志服务异常检测自动处置使用）
+type DisableTokenRequest struct {
+	Token  string `json:"token" binding:"required"` // Token 值
+	Reason string `json:"reason"`                   // 禁用原因，追加到备注
+}
+
+// LookupTokenRequest 按 Token 值查询请求（日志服务自助查询接口使用）
+// Token 放在请求体中，避免客户 Token 出现在 URL 和访问日志里
+type LookupTokenRequest struct {
+	Token string `json:"token" binding:"required"` // Token 值
+}
+
 // ListTokensRequest 列表查询请求
 type ListTokensRequest struct {
 	Page     int    `form:"page"`      // 页码，从1开始
@@ -206,6 +222,26 @@ func (s *TokenService) ListAllTokensWithModel() ([]TokenWithFullModel, error) {
 	return list, nil
 }
 
+// LookupToken 按 Token 值查询 Token 及关联模型名称（不含已删除的 Token）
+func (s *TokenService) LookupToken(tokenStr string) (*TokenWithModelName, error) {
+	var list []TokenWithModelName
+	if err := database.DB.Table("tokens t").
+		Select(`t.id, t.token, t.ai_model_id, m.model_name,
+			t.order_no, t.status, t.expire_at, t.usage_limit,
+			t.remark, t.created_at, t.updated_at`).
+		Joins("LEFT JOIN ai_models m ON t.ai_model_id = m.id").
+		Where("t.deleted_at IS NULL").
+		Where("t.token = ?", tokenStr).
+		Limit(1).
+		Scan(&list).Error; err != nil {
+		return nil, errors.New("查询 Token 失败")
+	}
+	if len(list) == 0 {
+		return nil, ErrTokenNotFound
+	}
+	return &list[0], nil
+}
+
 // UpdateToken 更新 Token
 func (s *TokenService) UpdateToken(id uint, req *UpdateTokenRequest) (*models.Token, error) {
 	// 查询 Token 是否存在
@@ -263,6 +299,38 @@ func (s *TokenService) UpdateToken(id uint, req *UpdateTokenRequest) (*models.To
 	return &token, nil
 }
 
+// DisableToken 按 Token 值禁用 Token，禁用原因追加到备注
+// 已禁用的 Token 不重复追加备注
+func (s *TokenService) DisableToken(req *DisableTokenRequest) (*models.Token, error) {
+	var token models.Token
+	if err := database.DB.Where("token = ?", req.Token).First(&token).Error; err != nil {
+		return nil, errors.New("Token 不存在")
+	}
+
+	if token.Status == 0 {
+		return &token, nil
+	}
+
+	token.Status = 0
+	if req.Reason != "" {
+		note := fmt.Sprintf("[%s 自动禁用] %s", time.Now().Format("2006-01-02 15:04:05"), req.Reason)
+		if token.Remark != "" {
+			note = token.Remark + "\n" + note
+		}
+		// 备注字段长度 500，超出时保留最新的内容
+		if runes := []rune(note); len(runes) > 500 {
+			note = string(runes[len(runes)-500:])
+		}
+		token.Remark = note
+	}
+
+	if err := database.DB.Save(&token).Error; err != nil {
+		return nil, errors.New("禁用 Token 失败")
+	}
+
+	return &token, nil
+}
+
 // DeleteToken 删除 Token（软删除）
 func (s *TokenService) DeleteToken(id uint) error {
 	// 检查是否存在